@@ -181,6 +181,10 @@ func main() {
 		proxy.ServeHTTP(w, r)
 	}).Methods("GET")
 
+	// Attachment links are signed by the chat service and expire, so they
+	// open without a token, e.g. from a CRM ticket
+	handlers.RegisterChatAttachmentLinks(router, cfg.ChatServiceURL)
+
 	// Set up auth routes (no auth required)
 	authRouter := router.PathPrefix("/auth").Subrouter()
 	handlers.RegisterAuthRoutes(authRouter, cfg.UserServiceURL)
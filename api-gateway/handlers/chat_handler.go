@@ -3,11 +3,6 @@ package handlers
 import (
 	"api-gateway/proxy"
 	"log"
-	"net/http"
-	"net/http/httputil"
-	"net/url"
-
-	// Keep for createHTTPProxy if needed
 
 	"github.com/gorilla/mux"
 )
@@ -15,15 +10,17 @@ import (
 // RegisterChatRoutes registers routes for the Chat service
 // chatServiceURL should be the base URL of the chat service, e.g., "http://chat-service:8081"
 func RegisterChatRoutes(router *mux.Router, chatServiceURL string) {
-	// General HTTP proxy for regular endpoints
-	httpProxy, err := createHTTPProxy(chatServiceURL)
+	// The chat service serves its REST API at the root, so /api/chat is
+	// stripped and the authenticated user forwarded like for the CRM
+	httpProxy, err := proxy.NewReverseProxy(chatServiceURL, "/api/chat")
 	if err != nil {
 		log.Fatalf("Failed to create Chat service HTTP proxy: %v", err)
 	}
 
-	// Regular HTTP endpoints (assuming they are relative to chatServiceURL)
+	// Conversations and their messages
 	router.PathPrefix("/sessions").Handler(httpProxy)
 	router.PathPrefix("/messages").Handler(httpProxy)
+	router.PathPrefix("/attachments").Handler(httpProxy)
 
 	// WebSocket endpoint
 	// The target path on the backend chat service is "/ws"
@@ -36,36 +33,12 @@ func RegisterChatRoutes(router *mux.Router, chatServiceURL string) {
 	log.Printf("Registered WebSocket handler for path ending in /ws to proxy to %s/ws", chatServiceURL)
 }
 
-// createHTTPProxy is for non-WebSocket endpoints
-func createHTTPProxy(targetBaseURL string) (http.Handler, error) {
-	targetURL, err := url.Parse(targetBaseURL)
+// RegisterChatAttachmentLinks serves the chat service's signed attachment
+// links under /chat/attachments/ on the public router
+func RegisterChatAttachmentLinks(router *mux.Router, chatServiceURL string) {
+	attachmentProxy, err := proxy.NewReverseProxy(chatServiceURL, "/chat")
 	if err != nil {
-		return nil, err
-	}
-
-	p := httputil.NewSingleHostReverseProxy(targetURL)
-	originalDirector := p.Director
-	p.Director = func(req *http.Request) {
-		originalDirector(req)
-		req.Host = targetURL.Host // Important for the backend
-
-		// Path adjustment logic:
-		// If this handler is mounted under "/api/chat" by a parent router,
-		// and a request comes for "/api/chat/sessions/123",
-		// req.URL.Path might be "/api/chat/sessions/123".
-		// If the backend expects "/sessions/123", you need to strip "/api/chat".
-		// This depends on your main router setup.
-		// For now, assuming the path passed to this director is what the backend expects
-		// relative to targetBaseURL, or that the main router handles stripping.
-		// Example stripping (if needed):
-		// if strings.HasPrefix(req.URL.Path, "/api/chat/") {
-		// req.URL.Path = strings.TrimPrefix(req.URL.Path, "/api/chat")
-		// }
-		log.Printf("HTTPProxy Director: Forwarding to %s%s", req.URL.Host, req.URL.Path)
-	}
-	p.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
-		log.Printf("HTTPProxy ErrorHandler: %v for request %s", err, r.URL.Path)
-		http.Error(w, "Proxy error", http.StatusBadGateway)
+		log.Fatalf("Failed to create Chat service attachment proxy: %v", err)
 	}
-	return p, nil
+	router.PathPrefix("/chat/attachments/").Handler(attachmentProxy).Methods("GET")
 }
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"time"
+
+	"chat-service/internal/adapters/secondary/directory"
+	"chat-service/internal/adapters/secondary/repository"
+	"chat-service/internal/config"
+	"chat-service/internal/core/domain"
+	"chat-service/internal/core/services"
+)
+
+// runExport writes conversation transcripts to a file or stdout, reading the
+// database and CRM settings from the environment like the server does:
+//
+//	chat-service export -conversation ID [-format json|csv|html|markdown] [-o FILE]
+//	chat-service export [-customer ID] [-from DATE] [-to DATE] ...
+func runExport(args []string) error {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	conversationID := flags.String("conversation", "", "export the conversation with this ID")
+	customerID := flags.String("customer", "", "export the conversations of this customer")
+	from := flags.String("from", "", "export conversations started at or after this RFC 3339 time or date")
+	to := flags.String("to", "", "export conversations started before this RFC 3339 time or date")
+	format := flags.String("format", domain.TranscriptJSON, "json, csv, html or markdown")
+	output := flags.String("o", "", "file to write, stdout by default")
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return err
+	}
+
+	query := domain.TranscriptQuery{ConversationID: *conversationID, CustomerID: *customerID}
+	var err error
+	if query.From, err = parseExportTime(*from); err != nil {
+		return fmt.Errorf("invalid -from: %w", err)
+	}
+	if query.To, err = parseExportTime(*to); err != nil {
+		return fmt.Errorf("invalid -to: %w", err)
+	}
+
+	cfg := config.LoadConfig()
+	repo, err := repository.NewPostgresRepository(cfg.DBHost, cfg.DBUser, cfg.DBPassword, cfg.DBName)
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer repo.GetDB().Close()
+
+	// Nothing is published; the chat service only reads
+	chatService := services.NewChatService(repo, repo, nil)
+	configureTranscripts(chatService, cfg)
+
+	blobStore, err := newBlobStore(cfg)
+	if err != nil {
+		log.Printf("Warning: %v, attachments will be listed without links", err)
+	}
+	if blobStore != nil {
+		attachmentRepo := repository.NewPostgresAttachmentRepository(repo.GetDB())
+		attachments := services.NewAttachmentService(blobStore, attachmentRepo, repo, attachmentSigningKey(cfg))
+		attachments.SetURLPrefix(cfg.AttachmentURLPrefix)
+		chatService.SetAttachments(attachments, time.Duration(cfg.AttachmentLinkTTLMinutes)*time.Minute)
+		chatService.SetTranscriptLinkTTL(time.Duration(cfg.AttachmentTicketLinkTTLHours) * time.Hour)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if *output == "" {
+		return chatService.ExportTranscripts(ctx, query, *format, os.Stdout)
+	}
+
+	file, err := os.Create(*output)
+	if err != nil {
+		return err
+	}
+	err = chatService.ExportTranscripts(ctx, query, *format, file)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(*output)
+		return err
+	}
+	log.Printf("Transcripts written to %s", *output)
+	return nil
+}
+
+// configureTranscripts names the senders of exported transcripts: customers
+// and agents as the CRM knows them, when CRM_SERVICE_URL is set
+func configureTranscripts(chatService *services.ChatServiceImpl, cfg config.Config) {
+	if cfg.CRMServiceURL != "" {
+		chatService.SetParticipantDirectory(directory.NewCRMDirectory(cfg.CRMServiceURL))
+	}
+	chatService.SetBotName(botID, botName)
+}
+
+// parseExportTime reads an RFC 3339 time or a date, which is midnight UTC.
+// An empty value is the zero time.
+func parseExportTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if parsed, err := time.Parse(time.RFC3339, value); err == nil {
+		return parsed, nil
+	}
+	return time.Parse("2006-01-02", value)
+}
@@ -2,20 +2,44 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"strings"
 	"time"
 
 	httphandlers "chat-service/internal/adapters/primary/http"
 	"chat-service/internal/adapters/primary/websocket"
+	"chat-service/internal/adapters/secondary/auth"
+	"chat-service/internal/adapters/secondary/blobstore"
+	"chat-service/internal/adapters/secondary/embedding"
+	"chat-service/internal/adapters/secondary/llm"
 	"chat-service/internal/adapters/secondary/messaging"
 	"chat-service/internal/adapters/secondary/repository"
 	"chat-service/internal/config"
+	"chat-service/internal/core/domain"
+	"chat-service/internal/core/ports"
 	"chat-service/internal/core/services"
 )
 
+// The bot answering customers, as transcripts name it
+const (
+	botID   = "bot-1"
+	botName = "Support Bot"
+)
+
 func main() {
+	// "chat-service export ..." writes transcripts instead of serving
+	if len(os.Args) > 1 && os.Args[1] == "export" {
+		if err := runExport(os.Args[2:]); err != nil {
+			log.Fatalf("Export failed: %v", err)
+		}
+		return
+	}
+
 	cfg := config.LoadConfig()
 
 	repo, err := repository.NewPostgresRepository(
@@ -33,6 +57,12 @@ func main() {
 		log.Fatalf("Failed to connect to RabbitMQ: %v", err)
 	}
 	defer rabbitMQClient.Close()
+	// Every replica consumes the chat messages of all of them from a queue
+	// named after it
+	if cfg.InstanceID != "" {
+		rabbitMQClient.SetInstanceID(cfg.InstanceID)
+	}
+	log.Printf("Chat service instance %s", rabbitMQClient.InstanceID())
 	messagePublisher := messaging.NewRabbitMQAdapter(rabbitMQClient)
 
 	messageRepository := repo
@@ -46,41 +76,185 @@ func main() {
 		log.Printf("Warning: Failed to initialize knowledge base schema: %v", err)
 		log.Printf("Knowledge base functionality may be limited")
 	}
+
+	flowRepo := repository.NewPostgresFlowRepository(repo.GetDB())
+	if err := flowRepo.InitSchema(initCtx); err != nil {
+		log.Printf("Warning: Failed to initialize dialog flow schema: %v", err)
+	}
+
+	summaryRepo := repository.NewPostgresSummaryRepository(repo.GetDB())
+	if err := summaryRepo.InitSchema(initCtx); err != nil {
+		log.Printf("Warning: Failed to initialize conversation summary schema: %v", err)
+	}
+
+	satisfactionRepo := repository.NewPostgresSatisfactionRepository(repo.GetDB())
+	if err := satisfactionRepo.InitSchema(initCtx); err != nil {
+		log.Printf("Warning: Failed to initialize satisfaction survey schema: %v", err)
+	}
+
+	attachmentRepo := repository.NewPostgresAttachmentRepository(repo.GetDB())
+	if err := attachmentRepo.InitSchema(initCtx); err != nil {
+		log.Printf("Warning: Failed to initialize attachment schema: %v", err)
+	}
 	initCancel()
 
 	// Create knowledge base service with repository
 	knowledgeBase := services.NewKnowledgeBase(knowledgeRepo)
+	knowledgeBase.SetMatchThreshold(cfg.KBMatchThreshold)
+
+	embedder, err := newEmbedder(cfg)
+	if err != nil {
+		log.Printf("Warning: %v, knowledge retrieval will be disabled", err)
+	}
+	if embedder != nil {
+		knowledgeBase.ConfigureRetrieval(embedder, cfg.RAGTopK, cfg.RAGMinScore)
+	}
 
 	// Create other services
 	chatService := services.NewChatService(messageRepository, messageRepository, messagePublisher)
+	chatService.SetEventPublisher(messagePublisher)
+	chatService.SetReopenWindow(time.Duration(cfg.ReopenWindowMinutes) * time.Minute)
+	chatService.SetEditWindow(time.Duration(cfg.MessageEditWindowMinutes) * time.Minute)
+	configureTranscripts(chatService, cfg)
+
+	// Personal data is kept out of the logs and the language model
+	redactor, err := newPIIRedactor(cfg)
+	if err != nil {
+		log.Fatalf("Invalid PII_PATTERNS: %v", err)
+	}
 
 	// Pass knowledge base to bot agent
-	botAgent := services.NewBotAgent("bot-1", "Support Bot", cfg.UseAI,
+	botAgent := services.NewBotAgent(botID, botName, cfg.UseAI,
 		messageRepository, messagePublisher, knowledgeBase)
+	botAgent.SetPIIRedactor(redactor)
+
+	responseCache := services.NewResponseCache(cfg.ResponseCacheSize,
+		time.Duration(cfg.ResponseCacheTTLSeconds)*time.Second)
+	botAgent.SetResponseCache(responseCache)
+
+	memory := services.NewMemoryService(messageRepository, messageRepository, summaryRepo)
+	memory.SetTokenBudget(cfg.MemoryTokenBudget)
+	memory.SetRedactor(redactor)
+	botAgent.SetConversationMemory(memory)
 
 	if cfg.UseAI {
 		fmt.Println("Setting up AI client")
-		botAgent.SetupAIClient(cfg.OpenAIKey)
+		provider, err := newLLMProvider(cfg)
+		if err != nil {
+			log.Printf("Warning: %v, AI features will be disabled", err)
+		}
+		botAgent.SetLLMProvider(provider)
+		if provider != nil {
+			memory.SetSummarizer(provider)
+		}
 	}
 
-	hub := websocket.NewHub(chatService, botAgent)
+	flowEngine := services.NewFlowEngine(flowRepo, messageRepository)
+	botAgent.SetFlowEngine(flowEngine)
+
+	escalationService := services.NewEscalationService(messagePublisher, messageRepository, messageRepository)
+	escalationService.SetFallbackLimit(cfg.EscalationFallbackLimit)
+	escalationService.SetMessagePublisher(messagePublisher)
+	botAgent.SetEscalationService(escalationService)
+
+	// Customers attach files uploaded over HTTP to their messages; the CRM
+	// gets longer-lived links to them with escalated conversations
+	blobStore, err := newBlobStore(cfg)
+	if err != nil {
+		log.Printf("Warning: %v, attachments will be disabled", err)
+	}
+	var attachments *services.AttachmentService
+	if blobStore != nil {
+		attachments = services.NewAttachmentService(blobStore, attachmentRepo, messageRepository, attachmentSigningKey(cfg))
+		attachments.SetURLPrefix(cfg.AttachmentURLPrefix)
+		linkTTL := time.Duration(cfg.AttachmentLinkTTLMinutes) * time.Minute
+		attachments.SetLinkTTL(linkTTL)
+		chatService.SetAttachments(attachments, linkTTL)
+		escalationService.SetAttachments(attachments, time.Duration(cfg.AttachmentTicketLinkTTLHours)*time.Hour)
+		chatService.SetTranscriptLinkTTL(time.Duration(cfg.AttachmentTicketLinkTTLHours) * time.Hour)
+	}
+
+	takeoverService := services.NewTakeoverService(messageRepository, messageRepository, messagePublisher)
+	botAgent.SetTakeoverService(takeoverService)
+
+	// The hub only queues messages; a fixed set of workers answers them
+	botPool := services.NewBotWorkerPool(botAgent, cfg.BotWorkers, cfg.BotQueueSize)
+	botPool.SetEnqueueTimeout(time.Duration(cfg.BotEnqueueTimeoutMillis) * time.Millisecond)
+	defer botPool.Stop()
+	chatService.SetBotQueue(botPool)
+
+	hub := websocket.NewHub(chatService, botPool)
 	botAgent.SetHub(hub) // Connect hub to bot agent
+	escalationService.SetHub(hub)
+	takeoverService.SetHub(hub)
+	hub.SetTakeover(takeoverService)
+	hub.SetHistoryLimit(cfg.HistoryLimit)
+	hub.SetRedactor(redactor)
+
+	// Customers are asked to rate conversations as they close
+	satisfaction := services.NewSatisfactionService(satisfactionRepo, messageRepository)
+	hub.SetSurvey(satisfaction)
 
 	if err := hub.SubscribeToBotMessages(); err != nil {
 		log.Fatalf("Failed to subscribe to messages: %v", err)
 	}
 
+	if err := hub.SubscribeToConversationEvents(messagePublisher); err != nil {
+		log.Fatalf("Failed to subscribe to conversation events: %v", err)
+	}
+
+	if err := escalationService.Subscribe(); err != nil {
+		log.Fatalf("Failed to subscribe to escalation tickets: %v", err)
+	}
 
 	// Create admin handlers with repository
 	adminHandlers := httphandlers.NewAdminHandlers(knowledgeRepo, knowledgeBase)
+	adminHandlers.SetResponseCache(responseCache)
+	adminHandlers.SetBotPool(botPool)
+	adminHandlers.SetSatisfaction(satisfaction)
+	adminHandlers.SetMessageSearch(chatService, cfg.ConversationURLPrefix)
+	adminHandlers.SetTranscriptExport(chatService)
 	adminHandlers.RegisterRoutes(http.DefaultServeMux)
 
+	flowHandlers := httphandlers.NewFlowHandlers(flowEngine)
+	flowHandlers.RegisterRoutes(http.DefaultServeMux)
+
+	sessionHandlers := httphandlers.NewSessionHandlers(chatService, hub, hub)
+	sessionHandlers.RegisterRoutes(http.DefaultServeMux)
+
+	messageHandlers := httphandlers.NewMessageHandlers(chatService)
+	messageHandlers.RegisterRoutes(http.DefaultServeMux)
+
+	if attachments != nil {
+		attachmentHandlers := httphandlers.NewAttachmentHandlers(attachments)
+		attachmentHandlers.RegisterRoutes(http.DefaultServeMux)
+	}
+
 	go hub.Run()
 
+	// Conversations nobody writes in are closed; IDLE_TIMEOUT_MINUTES=0 keeps
+	// them open until closed over the API
+	if cfg.IdleTimeoutMinutes > 0 {
+		lifecycle := services.NewConversationLifecycle(chatService,
+			time.Duration(cfg.IdleTimeoutMinutes)*time.Minute)
+		lifecycle.SetCheckInterval(time.Duration(cfg.IdleCheckIntervalSeconds) * time.Second)
+		lifecycle.SetHub(hub)
+		go lifecycle.Run(context.Background())
+	}
+
 	http.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
 		websocket.ServeWS(hub, w, r)
 	})
 
+	if cfg.JWTSecret != "" {
+		authenticator := auth.NewJWTAuthenticator([]byte(cfg.JWTSecret), cfg.AgentRoles)
+		http.HandleFunc("/ws/agent", func(w http.ResponseWriter, r *http.Request) {
+			websocket.ServeAgentWS(hub, authenticator, w, r)
+		})
+	} else {
+		log.Println("JWT_SECRET not set, agent takeover endpoint disabled")
+	}
+
 	http.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte("OK"))
@@ -89,3 +263,110 @@ func main() {
 	log.Printf("Chat service running on port %s", cfg.Port)
 	log.Fatal(http.ListenAndServe(":"+cfg.Port, nil))
 }
+
+// newLLMProvider builds the language model provider selected by LLM_PROVIDER
+func newLLMProvider(cfg config.Config) (ports.LLMProvider, error) {
+	options := llm.Options{
+		Model:            cfg.OpenAIChatModel,
+		MaxTokens:        cfg.OpenAIChatMaxTokens,
+		Temperature:      cfg.OpenAIChatTemperature,
+		TopP:             cfg.OpenAIChatTopP,
+		FrequencyPenalty: cfg.OpenAIChatFrequencyPenalty,
+		PresencePenalty:  cfg.OpenAIChatPresencePenalty,
+		Stop:             cfg.OpenAIChatStop,
+	}
+
+	switch cfg.LLMProvider {
+	case "openai":
+		if cfg.OpenAIKey == "" && cfg.OpenAIURL == "" {
+			return nil, errors.New("empty OpenAI API key provided")
+		}
+		return llm.NewOpenAIProvider(cfg.OpenAIKey, cfg.OpenAIURL, options), nil
+	case "ollama":
+		options.Model = cfg.OllamaModel
+		return llm.NewOllamaProvider(cfg.OllamaURL, options), nil
+	case "fake":
+		return llm.NewFakeProvider(cfg.FakeLLMResponses...), nil
+	default:
+		return nil, fmt.Errorf("unknown LLM provider %q", cfg.LLMProvider)
+	}
+}
+
+// newPIIRedactor builds the redactor for the built-in kinds of personal data
+// and the kind=regex patterns of PII_PATTERNS
+func newPIIRedactor(cfg config.Config) (*services.PIIRedactor, error) {
+	redactor := services.NewPIIRedactor()
+	redactor.SetRestoredKinds(cfg.PIIRestoreKinds)
+	for _, pattern := range cfg.PIIPatterns {
+		kind, expr, ok := strings.Cut(pattern, "=")
+		if !ok {
+			return nil, fmt.Errorf("pattern %q is not kind=regex", pattern)
+		}
+		if err := redactor.AddPattern(domain.PIIPattern{Kind: kind, Pattern: expr}); err != nil {
+			return nil, err
+		}
+	}
+	return redactor, nil
+}
+
+// newEmbedder builds the embedder selected by EMBEDDING_PROVIDER; "none"
+// disables knowledge retrieval
+func newEmbedder(cfg config.Config) (ports.Embedder, error) {
+	switch cfg.EmbeddingProvider {
+	case "none", "":
+		return nil, nil
+	case "hashing":
+		return embedding.NewHashingEmbedder(cfg.EmbeddingDimensions), nil
+	case "openai":
+		if cfg.OpenAIKey == "" && cfg.OpenAIURL == "" {
+			return nil, errors.New("empty OpenAI API key provided")
+		}
+		return embedding.NewOpenAIEmbedder(cfg.OpenAIKey, cfg.OpenAIURL, cfg.EmbeddingModel, cfg.EmbeddingDimensions), nil
+	default:
+		return nil, fmt.Errorf("unknown embedding provider %q", cfg.EmbeddingProvider)
+	}
+}
+
+// newBlobStore builds the store for attachments selected by BLOB_STORE;
+// "none" disables attachments
+func newBlobStore(cfg config.Config) (ports.BlobStore, error) {
+	switch cfg.BlobStore {
+	case "none", "":
+		return nil, nil
+	case "filesystem":
+		store, err := blobstore.NewFilesystemStore(cfg.BlobDir)
+		if err != nil {
+			return nil, err
+		}
+		return store, nil
+	case "s3":
+		store, err := blobstore.NewS3Store(blobstore.S3Options{
+			Endpoint:  cfg.S3Endpoint,
+			Region:    cfg.S3Region,
+			Bucket:    cfg.S3Bucket,
+			AccessKey: cfg.S3AccessKey,
+			SecretKey: cfg.S3SecretKey,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return store, nil
+	default:
+		return nil, fmt.Errorf("unknown blob store %q", cfg.BlobStore)
+	}
+}
+
+// attachmentSigningKey is the key download links are signed with. Every
+// replica needs the same one; without ATTACHMENT_SIGNING_KEY a random key
+// is used and links only work on this replica until it restarts.
+func attachmentSigningKey(cfg config.Config) []byte {
+	if cfg.AttachmentSigningKey != "" {
+		return []byte(cfg.AttachmentSigningKey)
+	}
+	log.Println("Warning: ATTACHMENT_SIGNING_KEY not set, attachment links will not survive a restart")
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		log.Fatalf("Failed to generate attachment signing key: %v", err)
+	}
+	return key
+}
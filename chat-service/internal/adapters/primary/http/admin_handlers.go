@@ -6,8 +6,13 @@ import (
 	"chat-service/internal/core/services"
 	"context"
 	"encoding/json"
+	"errors"
 	"log"
+	"mime"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -17,6 +22,13 @@ import (
 type AdminHandlers struct {
 	knowledgeRepo ports.KnowledgeRepository
 	knowledgeBase *services.KnowledgeBase
+	responseCache *services.ResponseCache
+	botPool       *services.BotWorkerPool
+	satisfaction  *services.SatisfactionService
+	search        ports.MessageSearch
+	transcripts   ports.TranscriptExport
+	// Where search hits link to their conversation
+	conversationURLPrefix string
 }
 
 // NewAdminHandlers creates a new AdminHandlers
@@ -27,11 +39,275 @@ func NewAdminHandlers(repo ports.KnowledgeRepository, kb *services.KnowledgeBase
 	}
 }
 
+// SetResponseCache sets the bot response cache invalidated when knowledge changes
+func (h *AdminHandlers) SetResponseCache(cache *services.ResponseCache) {
+	h.responseCache = cache
+}
+
+// SetBotPool sets the bot worker pool whose queue is reported
+func (h *AdminHandlers) SetBotPool(pool *services.BotWorkerPool) {
+	h.botPool = pool
+}
+
+// SetSatisfaction sets the survey service whose ratings are reported
+func (h *AdminHandlers) SetSatisfaction(satisfaction *services.SatisfactionService) {
+	h.satisfaction = satisfaction
+}
+
+// SetMessageSearch sets the transcript search, linking hits to their
+// conversation under the prefix, such as the gateway's /api/chat
+func (h *AdminHandlers) SetMessageSearch(search ports.MessageSearch, conversationURLPrefix string) {
+	h.search = search
+	h.conversationURLPrefix = strings.TrimSuffix(conversationURLPrefix, "/")
+}
+
+// SetTranscriptExport sets the export of conversation transcripts
+func (h *AdminHandlers) SetTranscriptExport(transcripts ports.TranscriptExport) {
+	h.transcripts = transcripts
+}
+
 // RegisterRoutes registers HTTP routes
 func (h *AdminHandlers) RegisterRoutes(mux *http.ServeMux) {
 	mux.HandleFunc("/admin/knowledge", h.handleKnowledge)
 	mux.HandleFunc("/admin/knowledge/", h.handleKnowledgeEntry)
 	mux.HandleFunc("/admin/knowledge/search", h.handleKnowledgeSearch)
+	mux.HandleFunc("/admin/knowledge/reindex", h.handleKnowledgeReindex)
+	mux.HandleFunc("/admin/knowledge/synonyms", h.handleSynonyms)
+	mux.HandleFunc("/admin/knowledge/synonyms/", h.handleSynonym)
+	mux.HandleFunc("/admin/cache", h.handleCache)
+	mux.HandleFunc("/admin/bot/queue", h.handleBotQueue)
+	mux.HandleFunc("/admin/reports/csat", h.handleSatisfactionReport)
+	mux.HandleFunc("/admin/search", h.handleMessageSearch)
+	mux.HandleFunc("/admin/transcripts", h.handleTranscriptExport)
+}
+
+// handleCache reports response cache metrics on GET and empties it on DELETE
+func (h *AdminHandlers) handleCache(w http.ResponseWriter, r *http.Request) {
+	if h.responseCache == nil {
+		http.Error(w, "Response cache not configured", http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(h.responseCache.Stats())
+	case http.MethodDelete:
+		h.responseCache.Purge()
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// How far back the satisfaction report looks without a since parameter
+const defaultReportPeriod = 30 * 24 * time.Hour
+
+// handleSatisfactionReport aggregates survey ratings given since the RFC 3339
+// time in ?since, by default over the last 30 days
+func (h *AdminHandlers) handleSatisfactionReport(w http.ResponseWriter, r *http.Request) {
+	if h.satisfaction == nil {
+		http.Error(w, "Satisfaction survey not configured", http.StatusNotFound)
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	since := time.Now().Add(-defaultReportPeriod)
+	if value := r.URL.Query().Get("since"); value != "" {
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			http.Error(w, "Invalid since parameter, expected an RFC 3339 time", http.StatusBadRequest)
+			return
+		}
+		since = parsed
+	}
+
+	report, err := h.satisfaction.Report(r.Context(), since)
+	if err != nil {
+		log.Printf("Error building satisfaction report: %v", err)
+		http.Error(w, "Error building satisfaction report", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// handleMessageSearch searches the messages of every conversation for ?q.
+// It is narrowed by customer_id, type, status (of the conversation) and the
+// from and to times, each an RFC 3339 time or a date; to is exclusive. The
+// page is chosen with limit and offset.
+func (h *AdminHandlers) handleMessageSearch(w http.ResponseWriter, r *http.Request) {
+	if h.search == nil {
+		http.Error(w, "Message search not configured", http.StatusNotFound)
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	params := r.URL.Query()
+	search := domain.MessageSearch{
+		Query:              params.Get("q"),
+		CustomerID:         params.Get("customer_id"),
+		Type:               domain.MessageType(params.Get("type")),
+		ConversationStatus: params.Get("status"),
+	}
+	var err error
+	if search.From, err = parseSearchTime(params.Get("from")); err != nil {
+		http.Error(w, "Invalid from parameter, expected an RFC 3339 time or a date", http.StatusBadRequest)
+		return
+	}
+	if search.To, err = parseSearchTime(params.Get("to")); err != nil {
+		http.Error(w, "Invalid to parameter, expected an RFC 3339 time or a date", http.StatusBadRequest)
+		return
+	}
+	for name, value := range map[string]*int{"limit": &search.Limit, "offset": &search.Offset} {
+		if param := params.Get(name); param != "" {
+			if *value, err = strconv.Atoi(param); err != nil {
+				http.Error(w, "Invalid "+name, http.StatusBadRequest)
+				return
+			}
+		}
+	}
+
+	results, err := h.search.SearchMessages(r.Context(), search)
+	if err != nil {
+		if errors.Is(err, domain.ErrInvalidSearch) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		log.Printf("Error searching messages: %v", err)
+		http.Error(w, "Error searching messages", http.StatusInternalServerError)
+		return
+	}
+	for i := range results.Hits {
+		results.Hits[i].ConversationURL = h.conversationURLPrefix + "/sessions/" + url.PathEscape(results.Hits[i].ConversationID)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(results)
+}
+
+// handleTranscriptExport downloads the transcript of ?conversation_id, or of
+// every conversation of ?customer_id and/or started between from and to, in
+// ?format (json by default). The transcript is streamed as it is read.
+func (h *AdminHandlers) handleTranscriptExport(w http.ResponseWriter, r *http.Request) {
+	if h.transcripts == nil {
+		http.Error(w, "Transcript export not configured", http.StatusNotFound)
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	params := r.URL.Query()
+	query := domain.TranscriptQuery{
+		ConversationID: params.Get("conversation_id"),
+		CustomerID:     params.Get("customer_id"),
+	}
+	var err error
+	if query.From, err = parseSearchTime(params.Get("from")); err != nil {
+		http.Error(w, "Invalid from parameter, expected an RFC 3339 time or a date", http.StatusBadRequest)
+		return
+	}
+	if query.To, err = parseSearchTime(params.Get("to")); err != nil {
+		http.Error(w, "Invalid to parameter, expected an RFC 3339 time or a date", http.StatusBadRequest)
+		return
+	}
+	format := params.Get("format")
+	if format == "" {
+		format = domain.TranscriptJSON
+	}
+
+	name := "transcripts"
+	if query.ConversationID != "" {
+		name = "transcript-" + query.ConversationID
+	} else if query.CustomerID != "" {
+		name = "transcripts-" + query.CustomerID
+	}
+	out := &exportResponse{ResponseWriter: w, format: format, fileName: name}
+
+	err = h.transcripts.ExportTranscripts(r.Context(), query, format, out)
+	switch {
+	case err == nil:
+	case out.started:
+		// Too late to report it; the client sees the transcript cut short
+		log.Printf("Error exporting transcripts %+v: %v", query, err)
+	case errors.Is(err, domain.ErrInvalidExport):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, domain.ErrNoConversation):
+		http.Error(w, "Conversation not found", http.StatusNotFound)
+	default:
+		log.Printf("Error exporting transcripts %+v: %v", query, err)
+		http.Error(w, "Error exporting transcripts", http.StatusInternalServerError)
+	}
+}
+
+// exportResponse sends the download headers with the first bytes of an
+// export, so that an export failing before it starts gets an error status
+type exportResponse struct {
+	http.ResponseWriter
+	format   string
+	fileName string
+	started  bool
+}
+
+func (e *exportResponse) Write(p []byte) (int, error) {
+	if !e.started {
+		e.started = true
+		format := domain.TranscriptFormats[e.format]
+		e.Header().Set("Content-Type", format.ContentType)
+		e.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+			"filename": e.fileName + "." + format.Extension,
+		}))
+	}
+	return e.ResponseWriter.Write(p)
+}
+
+// parseSearchTime reads an RFC 3339 time or a date, which is midnight UTC.
+// An empty value is the zero time.
+func parseSearchTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if parsed, err := time.Parse(time.RFC3339, value); err == nil {
+		return parsed, nil
+	}
+	return time.Parse("2006-01-02", value)
+}
+
+// handleBotQueue reports the bot worker pool's queue depth and counters
+func (h *AdminHandlers) handleBotQueue(w http.ResponseWriter, r *http.Request) {
+	if h.botPool == nil {
+		http.Error(w, "Bot worker pool not configured", http.StatusNotFound)
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.botPool.Stats())
+}
+
+// knowledgeChanged drops cached answers that may no longer be right. An
+// empty entry ID means any answer could have changed.
+func (h *AdminHandlers) knowledgeChanged(entryID string) {
+	if h.responseCache == nil {
+		return
+	}
+	if entryID == "" {
+		h.responseCache.InvalidateKnowledge()
+		return
+	}
+	h.responseCache.InvalidateEntry(entryID)
 }
 
 // handleKnowledge handles GET (list all) and POST (create) operations
@@ -93,6 +369,136 @@ func (h *AdminHandlers) handleKnowledgeSearch(w http.ResponseWriter, r *http.Req
 	json.NewEncoder(w).Encode(entries)
 }
 
+// handleKnowledgeReindex rebuilds the embeddings for every entry
+func (h *AdminHandlers) handleKnowledgeReindex(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 60*time.Second)
+	defer cancel()
+
+	indexed, err := h.knowledgeBase.IndexEntries(ctx)
+	if err != nil {
+		log.Printf("Error reindexing knowledge entries: %v", err)
+		http.Error(w, "Error reindexing entries", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int{"indexed": indexed})
+}
+
+// handleSynonyms handles GET (list all) and POST (create or replace) operations
+func (h *AdminHandlers) handleSynonyms(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		h.listSynonyms(w, r)
+	case http.MethodPost:
+		var synonym domain.Synonym
+		if err := json.NewDecoder(r.Body).Decode(&synonym); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		h.saveSynonym(w, r, &synonym)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleSynonym handles PUT/DELETE operations on a specific synonym group
+func (h *AdminHandlers) handleSynonym(w http.ResponseWriter, r *http.Request) {
+	term := r.URL.Path[len("/admin/knowledge/synonyms/"):]
+	if term == "" {
+		http.Error(w, "Missing synonym term", http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		var synonym domain.Synonym
+		if err := json.NewDecoder(r.Body).Decode(&synonym); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		// Ensure term in URL matches the group
+		synonym.Term = term
+		h.saveSynonym(w, r, &synonym)
+	case http.MethodDelete:
+		h.deleteSynonym(w, r, term)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// listSynonyms lists all synonym groups
+func (h *AdminHandlers) listSynonyms(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	synonyms, err := h.knowledgeRepo.GetSynonyms(ctx)
+	if err != nil {
+		log.Printf("Error fetching synonyms: %v", err)
+		http.Error(w, "Error fetching synonyms", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(synonyms)
+}
+
+// saveSynonym creates or replaces a synonym group
+func (h *AdminHandlers) saveSynonym(w http.ResponseWriter, r *http.Request, synonym *domain.Synonym) {
+	synonym.Term = strings.ToLower(strings.TrimSpace(synonym.Term))
+	var cleaned []string
+	for _, word := range synonym.Synonyms {
+		if word = strings.ToLower(strings.TrimSpace(word)); word != "" && word != synonym.Term {
+			cleaned = append(cleaned, word)
+		}
+	}
+	synonym.Synonyms = cleaned
+
+	if synonym.Term == "" || len(synonym.Synonyms) == 0 {
+		http.Error(w, "A term and at least one synonym are required", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	if err := h.knowledgeRepo.SaveSynonym(ctx, synonym); err != nil {
+		log.Printf("Error saving synonym: %v", err)
+		http.Error(w, "Error saving synonym", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(synonym)
+
+	// Refresh knowledge base cache so matching picks up the change
+	h.knowledgeBase.RefreshCache()
+	h.knowledgeChanged("")
+}
+
+// deleteSynonym deletes a synonym group
+func (h *AdminHandlers) deleteSynonym(w http.ResponseWriter, r *http.Request, term string) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	if err := h.knowledgeRepo.DeleteSynonym(ctx, term); err != nil {
+		log.Printf("Error deleting synonym: %v", err)
+		http.Error(w, "Error deleting synonym", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+
+	// Refresh knowledge base cache so matching picks up the change
+	h.knowledgeBase.RefreshCache()
+	h.knowledgeChanged("")
+}
+
 // listKnowledgeEntries lists all knowledge entries
 func (h *AdminHandlers) listKnowledgeEntries(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
@@ -152,12 +558,17 @@ func (h *AdminHandlers) createKnowledgeEntry(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
+	if err := h.knowledgeBase.IndexEntry(ctx, &entry); err != nil {
+		log.Printf("Error indexing knowledge entry %s: %v", entry.ID, err)
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(entry)
 
 	// Refresh knowledge base cache
 	h.knowledgeBase.RefreshCache()
+	h.knowledgeChanged("")
 }
 
 // updateKnowledgeEntry updates an existing entry
@@ -180,11 +591,17 @@ func (h *AdminHandlers) updateKnowledgeEntry(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
+	if err := h.knowledgeBase.IndexEntry(ctx, &entry); err != nil {
+		log.Printf("Error indexing knowledge entry %s: %v", entry.ID, err)
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(entry)
 
-	// Refresh knowledge base cache
+	// Refresh knowledge base cache. The new keywords may capture questions
+	// other entries used to answer.
 	h.knowledgeBase.RefreshCache()
+	h.knowledgeChanged("")
 }
 
 // deleteKnowledgeEntry deletes an entry
@@ -202,4 +619,5 @@ func (h *AdminHandlers) deleteKnowledgeEntry(w http.ResponseWriter, r *http.Requ
 
 	// Refresh knowledge base cache
 	h.knowledgeBase.RefreshCache()
+	h.knowledgeChanged(id)
 }
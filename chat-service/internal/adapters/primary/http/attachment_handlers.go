@@ -0,0 +1,144 @@
+package http
+
+import (
+	"chat-service/internal/core/domain"
+	"chat-service/internal/core/ports"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// maxUploadOverhead leaves room in an upload request for the multipart
+// framing and form fields around the file
+const maxUploadOverhead = 64 << 10
+
+// AttachmentHandlers handles uploading files to conversations and
+// downloading them through signed links
+type AttachmentHandlers struct {
+	attachments ports.AttachmentUploads
+}
+
+// NewAttachmentHandlers creates a new AttachmentHandlers
+func NewAttachmentHandlers(attachments ports.AttachmentUploads) *AttachmentHandlers {
+	return &AttachmentHandlers{attachments: attachments}
+}
+
+// RegisterRoutes registers HTTP routes
+func (h *AttachmentHandlers) RegisterRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("/attachments", h.handleUpload)
+	mux.HandleFunc("/attachments/", h.handleDownload)
+}
+
+// handleUpload stores a file posted as the "file" field of a multipart form
+// along with the conversation_id it is for, from the user the gateway
+// authenticated or the user_id field. Send the returned ID with a message to
+// attach the file to it.
+func (h *AttachmentHandlers) handleUpload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, domain.MaxAttachmentSize+maxUploadOverhead)
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, "File is too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "Missing file", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	attachment := &domain.Attachment{
+		ConversationID: r.FormValue("conversation_id"),
+		UploadedBy:     r.Header.Get("X-User-ID"),
+		FileName:       header.Filename,
+	}
+	if attachment.UploadedBy == "" {
+		attachment.UploadedBy = r.FormValue("user_id")
+	}
+	switch {
+	case attachment.ConversationID == "":
+		http.Error(w, "Missing conversation_id", http.StatusBadRequest)
+		return
+	case attachment.UploadedBy == "":
+		http.Error(w, "Missing user_id", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.attachments.Upload(r.Context(), attachment, file); err != nil {
+		switch {
+		case errors.Is(err, domain.ErrInvalidAttachment):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, domain.ErrUploadNotAllowed):
+			http.Error(w, err.Error(), http.StatusForbidden)
+		case errors.Is(err, domain.ErrConversationClosed):
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			log.Printf("Error storing attachment: %v", err)
+			http.Error(w, "Error storing attachment", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(attachment)
+}
+
+// handleDownload serves the file a signed link points to
+func (h *AttachmentHandlers) handleDownload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id := strings.TrimPrefix(r.URL.Path, "/attachments/")
+	if id == "" || strings.Contains(id, "/") {
+		http.NotFound(w, r)
+		return
+	}
+	expires, err := strconv.ParseInt(r.URL.Query().Get("expires"), 10, 64)
+	if err != nil {
+		http.Error(w, domain.ErrInvalidSignature.Error(), http.StatusForbidden)
+		return
+	}
+
+	attachment, body, err := h.attachments.Open(r.Context(), id, expires, r.URL.Query().Get("signature"))
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrInvalidSignature):
+			http.Error(w, err.Error(), http.StatusForbidden)
+		case errors.Is(err, domain.ErrAttachmentNotFound), errors.Is(err, domain.ErrBlobNotFound):
+			http.Error(w, "Attachment not found", http.StatusNotFound)
+		default:
+			log.Printf("Error opening attachment %s: %v", id, err)
+			http.Error(w, "Error reading attachment", http.StatusInternalServerError)
+		}
+		return
+	}
+	defer body.Close()
+
+	// Only images are shown inline; anything else is saved, and never
+	// sniffed into something the browser would run
+	disposition := "attachment"
+	if domain.IsInlineType(attachment.ContentType) {
+		disposition = "inline"
+	}
+	w.Header().Set("Content-Type", attachment.ContentType)
+	w.Header().Set("Content-Length", strconv.FormatInt(attachment.Size, 10))
+	w.Header().Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": attachment.FileName}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "private, max-age=300")
+	if _, err := io.Copy(w, body); err != nil {
+		log.Printf("Error sending attachment %s: %v", id, err)
+	}
+}
@@ -0,0 +1,169 @@
+package http
+
+import (
+	"chat-service/internal/core/domain"
+	"chat-service/internal/core/services"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// maxFlowSize limits the size of an uploaded flow definition
+const maxFlowSize = 1 << 20
+
+// FlowHandlers handles the admin API for dialog flows
+type FlowHandlers struct {
+	engine *services.FlowEngine
+}
+
+// NewFlowHandlers creates a new FlowHandlers
+func NewFlowHandlers(engine *services.FlowEngine) *FlowHandlers {
+	return &FlowHandlers{engine: engine}
+}
+
+// RegisterRoutes registers HTTP routes
+func (h *FlowHandlers) RegisterRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("/admin/flows", h.handleFlows)
+	mux.HandleFunc("/admin/flows/", h.handleFlow)
+}
+
+// handleFlows handles GET (list all) and POST (create or replace) operations.
+// Definitions may be sent as JSON or, with a YAML content type, as YAML.
+func (h *FlowHandlers) handleFlows(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		h.listFlows(w, r)
+	case http.MethodPost:
+		flow, err := decodeFlow(r)
+		if err != nil {
+			http.Error(w, "Invalid flow definition: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		h.saveFlow(w, r, flow)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleFlow handles GET/PUT/DELETE operations on a specific flow
+func (h *FlowHandlers) handleFlow(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Path[len("/admin/flows/"):]
+	if id == "" {
+		http.Error(w, "Missing flow ID", http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		h.getFlow(w, r, id)
+	case http.MethodPut:
+		flow, err := decodeFlow(r)
+		if err != nil {
+			http.Error(w, "Invalid flow definition: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		// Ensure ID in URL matches flow
+		flow.ID = id
+		h.saveFlow(w, r, flow)
+	case http.MethodDelete:
+		h.deleteFlow(w, r, id)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// decodeFlow reads a flow definition in the format given by the content type
+func decodeFlow(r *http.Request) (*domain.Flow, error) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxFlowSize))
+	if err != nil {
+		return nil, err
+	}
+
+	var flow domain.Flow
+	if strings.Contains(r.Header.Get("Content-Type"), "yaml") {
+		err = yaml.Unmarshal(body, &flow)
+	} else {
+		err = json.Unmarshal(body, &flow)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &flow, nil
+}
+
+// listFlows lists all flows
+func (h *FlowHandlers) listFlows(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	flows, err := h.engine.GetFlows(ctx)
+	if err != nil {
+		log.Printf("Error fetching flows: %v", err)
+		http.Error(w, "Error fetching flows", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(flows)
+}
+
+// getFlow fetches a specific flow by ID
+func (h *FlowHandlers) getFlow(w http.ResponseWriter, r *http.Request, id string) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	flow, err := h.engine.GetFlow(ctx, id)
+	if err != nil {
+		log.Printf("Error fetching flow: %v", err)
+		http.Error(w, "Error fetching flow", http.StatusInternalServerError)
+		return
+	}
+
+	if flow == nil {
+		http.Error(w, "Flow not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(flow)
+}
+
+// saveFlow validates and stores a flow
+func (h *FlowHandlers) saveFlow(w http.ResponseWriter, r *http.Request, flow *domain.Flow) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	if err := h.engine.SaveFlow(ctx, flow); err != nil {
+		if errors.Is(err, domain.ErrInvalidFlow) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		log.Printf("Error saving flow: %v", err)
+		http.Error(w, "Error saving flow", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(flow)
+}
+
+// deleteFlow deletes a flow
+func (h *FlowHandlers) deleteFlow(w http.ResponseWriter, r *http.Request, id string) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	if err := h.engine.DeleteFlow(ctx, id); err != nil {
+		log.Printf("Error deleting flow: %v", err)
+		http.Error(w, "Error deleting flow", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,181 @@
+package http
+
+import (
+	"chat-service/internal/core/domain"
+	"chat-service/internal/core/ports"
+	"chat-service/pkg/chatws"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// MessageHandlers handles changing messages after they were sent: customers
+// edit and delete their own, admins any of them, and admins redact them and
+// read their audit trail
+type MessageHandlers struct {
+	editor ports.MessageEditor
+}
+
+// NewMessageHandlers creates a new MessageHandlers
+func NewMessageHandlers(editor ports.MessageEditor) *MessageHandlers {
+	return &MessageHandlers{editor: editor}
+}
+
+// RegisterRoutes registers HTTP routes
+func (h *MessageHandlers) RegisterRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("/messages/", h.handleMessage)
+	mux.HandleFunc("/admin/messages/", h.handleAdminMessage)
+}
+
+// editMessageRequest is the new content of a message
+type editMessageRequest struct {
+	Content string `json:"content"`
+}
+
+// redactMessageRequest lists the parts of a message to redact
+type redactMessageRequest struct {
+	Spans []domain.RedactionSpan `json:"spans"`
+}
+
+// handleMessage edits (PATCH) or deletes (DELETE) a message of the customer
+// the gateway authenticated, or of the user_id parameter
+func (h *MessageHandlers) handleMessage(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/messages/")
+	if id == "" || strings.Contains(id, "/") {
+		http.NotFound(w, r)
+		return
+	}
+
+	editor := domain.Editor{UserID: r.Header.Get("X-User-ID")}
+	if editor.UserID == "" {
+		editor.UserID = r.URL.Query().Get("user_id")
+	}
+	if editor.UserID == "" {
+		http.Error(w, "Missing user_id", http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPatch:
+		h.editMessage(w, r, editor, id)
+	case http.MethodDelete:
+		h.deleteMessage(w, r, editor, id)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleAdminMessage lets admins edit, delete and redact any message and
+// read its revisions
+func (h *MessageHandlers) handleAdminMessage(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/admin/messages/"), "/")
+	if parts[0] == "" {
+		http.Error(w, "Missing message ID", http.StatusBadRequest)
+		return
+	}
+
+	editor := domain.Editor{UserID: r.Header.Get("X-User-ID"), Admin: true}
+	if editor.UserID == "" {
+		editor.UserID = "admin"
+	}
+
+	switch {
+	case len(parts) == 1 && r.Method == http.MethodPatch:
+		h.editMessage(w, r, editor, parts[0])
+	case len(parts) == 1 && r.Method == http.MethodDelete:
+		h.deleteMessage(w, r, editor, parts[0])
+	case len(parts) == 2 && parts[1] == "redact" && r.Method == http.MethodPost:
+		h.redactMessage(w, r, editor, parts[0])
+	case len(parts) == 2 && parts[1] == "revisions" && r.Method == http.MethodGet:
+		h.listRevisions(w, r, parts[0])
+	case len(parts) <= 2:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (h *MessageHandlers) editMessage(w http.ResponseWriter, r *http.Request, editor domain.Editor, id string) {
+	var request editMessageRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if len(strings.TrimSpace(request.Content)) > chatws.MaxContentLength {
+		http.Error(w, "Message content is too long", http.StatusBadRequest)
+		return
+	}
+
+	message, err := h.editor.EditMessage(r.Context(), editor, id, request.Content)
+	if err != nil {
+		writeEditError(w, "editing", id, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(message)
+}
+
+func (h *MessageHandlers) deleteMessage(w http.ResponseWriter, r *http.Request, editor domain.Editor, id string) {
+	message, err := h.editor.DeleteMessage(r.Context(), editor, id)
+	if err != nil {
+		writeEditError(w, "deleting", id, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(message)
+}
+
+func (h *MessageHandlers) redactMessage(w http.ResponseWriter, r *http.Request, editor domain.Editor, id string) {
+	var request redactMessageRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	message, err := h.editor.RedactMessage(r.Context(), editor, id, request.Spans)
+	if err != nil {
+		writeEditError(w, "redacting", id, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(message)
+}
+
+// messageRevisions is the audit trail of a message
+type messageRevisions struct {
+	MessageID string                   `json:"message_id"`
+	Revisions []domain.MessageRevision `json:"revisions"`
+}
+
+func (h *MessageHandlers) listRevisions(w http.ResponseWriter, r *http.Request, id string) {
+	revisions, err := h.editor.GetMessageRevisions(r.Context(), id)
+	if err != nil {
+		writeEditError(w, "listing revisions of", id, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(messageRevisions{MessageID: id, Revisions: revisions})
+}
+
+// writeEditError answers a request to change a message that failed
+func writeEditError(w http.ResponseWriter, action, id string, err error) {
+	switch {
+	case errors.Is(err, domain.ErrMessageNotFound):
+		http.Error(w, "Message not found", http.StatusNotFound)
+	case errors.Is(err, domain.ErrInvalidEdit):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, domain.ErrEditNotAllowed):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, domain.ErrEditWindowClosed):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		log.Printf("Error %s message %s: %v", action, id, err)
+		http.Error(w, "Error changing message", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,400 @@
+package http
+
+import (
+	"chat-service/internal/core/domain"
+	"chat-service/internal/core/ports"
+	"chat-service/pkg/chatws"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SessionHandlers handles the chat API for conversations, which the gateway
+// calls sessions
+type SessionHandlers struct {
+	chatService ports.ChatService
+	presence    ports.PresenceTracker
+	submitter   ports.MessageSubmitter
+}
+
+// NewSessionHandlers creates a new SessionHandlers
+func NewSessionHandlers(chatService ports.ChatService, presence ports.PresenceTracker, submitter ports.MessageSubmitter) *SessionHandlers {
+	return &SessionHandlers{
+		chatService: chatService,
+		presence:    presence,
+		submitter:   submitter,
+	}
+}
+
+// RegisterRoutes registers HTTP routes
+func (h *SessionHandlers) RegisterRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("/sessions", h.handleSessions)
+	mux.HandleFunc("/sessions/", h.handleSession)
+	mux.HandleFunc("/messages", h.handleMessages)
+}
+
+// handleSessions lists a customer's conversations or starts one
+func (h *SessionHandlers) handleSessions(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		h.listSessions(w, r)
+	case http.MethodPost:
+		h.createSession(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleMessages lists or posts the messages of the conversation named by
+// conversation_id
+func (h *SessionHandlers) handleMessages(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		id := r.URL.Query().Get("conversation_id")
+		if id == "" {
+			http.Error(w, "Missing conversation_id parameter", http.StatusBadRequest)
+			return
+		}
+		h.listMessages(w, r, id)
+	case http.MethodPost:
+		h.postMessage(w, r, "")
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleSession routes requests for a specific conversation
+func (h *SessionHandlers) handleSession(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/sessions/"), "/")
+	if parts[0] == "" {
+		http.Error(w, "Missing session ID", http.StatusBadRequest)
+		return
+	}
+
+	switch {
+	case len(parts) == 1:
+		h.getSession(w, r, parts[0])
+	case len(parts) == 2 && parts[1] == "close":
+		h.closeSession(w, r, parts[0])
+	case len(parts) == 2 && parts[1] == "messages" && r.Method == http.MethodPost:
+		h.postMessage(w, r, parts[0])
+	case len(parts) == 2 && parts[1] == "presence":
+		h.getPresence(w, r, parts[0])
+	case len(parts) == 2 && parts[1] == "unread":
+		h.getUnread(w, r, parts[0])
+	case len(parts) == 2 && parts[1] == "messages":
+		h.listMessages(w, r, parts[0])
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// sessionList is a customer's conversations
+type sessionList struct {
+	Sessions []domain.Conversation `json:"sessions"`
+}
+
+// listSessions lists the conversations of the customer_id, latest first
+func (h *SessionHandlers) listSessions(w http.ResponseWriter, r *http.Request) {
+	customerID := r.URL.Query().Get("customer_id")
+	if customerID == "" {
+		http.Error(w, "Missing customer_id parameter", http.StatusBadRequest)
+		return
+	}
+
+	conversations, err := h.chatService.ListConversations(customerID)
+	if err != nil {
+		log.Printf("Error listing conversations: %v", err)
+		http.Error(w, "Error listing sessions", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(sessionList{Sessions: conversations})
+}
+
+// createSessionRequest starts a conversation for a customer
+type createSessionRequest struct {
+	CustomerID string `json:"customer_id"`
+}
+
+// createSession starts a conversation, or returns the customer's active one
+// just like connecting a socket does
+func (h *SessionHandlers) createSession(w http.ResponseWriter, r *http.Request) {
+	var request createSessionRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if request.CustomerID == "" {
+		http.Error(w, "Missing customer_id", http.StatusBadRequest)
+		return
+	}
+
+	conversation, err := h.chatService.CreateConversation(request.CustomerID)
+	if err != nil {
+		log.Printf("Error creating conversation: %v", err)
+		http.Error(w, "Error creating session", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(conversation)
+}
+
+// getSession returns a conversation
+func (h *SessionHandlers) getSession(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	conversation, ok := h.findSession(w, id)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(conversation)
+}
+
+// closeSession ends a conversation and returns it closed
+func (h *SessionHandlers) closeSession(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	conversation, ok := h.findSession(w, id)
+	if !ok {
+		return
+	}
+
+	if conversation.Status != "closed" {
+		if err := h.chatService.CloseConversation(conversation.ID); err != nil {
+			log.Printf("Error closing conversation: %v", err)
+			http.Error(w, "Error closing session", http.StatusInternalServerError)
+			return
+		}
+		closed, err := h.chatService.GetConversation(id)
+		if err != nil {
+			log.Printf("Error fetching closed conversation: %v", err)
+			http.Error(w, "Error closing session", http.StatusInternalServerError)
+			return
+		}
+		conversation = closed
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(conversation)
+}
+
+// postMessageRequest is a customer message posted over REST. The user
+// defaults to the one the gateway authenticated. Attachments are the IDs of
+// files uploaded to the conversation; with any, the content may be empty.
+type postMessageRequest struct {
+	ConversationID string   `json:"conversation_id"`
+	UserID         string   `json:"user_id"`
+	Content        string   `json:"content"`
+	Attachments    []string `json:"attachments,omitempty"`
+}
+
+// postMessage stores a customer's message and has the bot answer it, as if
+// it had been sent over a socket. The answer can be read from the
+// conversation's messages.
+func (h *SessionHandlers) postMessage(w http.ResponseWriter, r *http.Request, id string) {
+	var request postMessageRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if id == "" {
+		id = request.ConversationID
+	}
+	if request.UserID == "" {
+		request.UserID = r.Header.Get("X-User-ID")
+	}
+
+	content := strings.TrimSpace(request.Content)
+	switch {
+	case id == "":
+		http.Error(w, "Missing conversation_id", http.StatusBadRequest)
+		return
+	case request.UserID == "":
+		http.Error(w, "Missing user_id", http.StatusBadRequest)
+		return
+	case content == "" && len(request.Attachments) == 0:
+		http.Error(w, "Message content is required", http.StatusBadRequest)
+		return
+	case len(content) > chatws.MaxContentLength:
+		http.Error(w, "Message content is too long", http.StatusBadRequest)
+		return
+	}
+
+	conversation, ok := h.findSession(w, id)
+	if !ok {
+		return
+	}
+	if conversation.Status == "closed" {
+		http.Error(w, domain.ErrConversationClosed.Error(), http.StatusConflict)
+		return
+	}
+
+	message := &domain.Message{
+		Content:    content,
+		UserID:     request.UserID,
+		CustomerID: conversation.CustomerID,
+		Type:       domain.UserMessage,
+		Timestamp:  time.Now(),
+		Metadata: map[string]string{
+			"conversation_id": conversation.ID,
+			"originalSender":  request.UserID,
+			"clientID":        r.RemoteAddr,
+		},
+	}
+	for _, id := range request.Attachments {
+		message.Attachments = append(message.Attachments, domain.Attachment{ID: id})
+	}
+	if err := h.submitter.SubmitMessage(message); err != nil {
+		if errors.Is(err, domain.ErrInvalidAttachment) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		log.Printf("Error submitting message: %v", err)
+		http.Error(w, "Error storing message", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(message)
+}
+
+// sessionPresence is who is connected to a conversation
+type sessionPresence struct {
+	ConversationID string               `json:"conversation_id"`
+	Online         []domain.Participant `json:"online"`
+}
+
+// getPresence lists the participants connected to a conversation
+func (h *SessionHandlers) getPresence(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	conversation, ok := h.findSession(w, id)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(sessionPresence{
+		ConversationID: conversation.ID,
+		Online:         h.presence.Presence(conversation.ID),
+	})
+}
+
+// sessionUnread is how many messages of a conversation a user has not read
+type sessionUnread struct {
+	ConversationID string `json:"conversation_id"`
+	UserID         string `json:"user_id"`
+	Unread         int    `json:"unread"`
+}
+
+// getUnread counts the messages of a conversation the user_id has not read
+func (h *SessionHandlers) getUnread(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID := r.URL.Query().Get("user_id")
+	if userID == "" {
+		http.Error(w, "Missing user_id parameter", http.StatusBadRequest)
+		return
+	}
+
+	conversation, ok := h.findSession(w, id)
+	if !ok {
+		return
+	}
+
+	unread, err := h.chatService.GetUnreadCount(conversation.ID, userID)
+	if err != nil {
+		log.Printf("Error counting unread messages: %v", err)
+		http.Error(w, "Error counting unread messages", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(sessionUnread{
+		ConversationID: conversation.ID,
+		UserID:         userID,
+		Unread:         unread,
+	})
+}
+
+// listMessages reads a page of a conversation's messages. The before or
+// after query parameter is the ID of the message the page continues from.
+func (h *SessionHandlers) listMessages(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := domain.MessageQuery{
+		ConversationID: id,
+		Before:         r.URL.Query().Get("before"),
+		After:          r.URL.Query().Get("after"),
+	}
+	if limit := r.URL.Query().Get("limit"); limit != "" {
+		var err error
+		if query.Limit, err = strconv.Atoi(limit); err != nil {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if _, ok := h.findSession(w, id); !ok {
+		return
+	}
+
+	page, err := h.chatService.GetMessages(query)
+	if err != nil {
+		if errors.Is(err, domain.ErrInvalidCursor) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		log.Printf("Error fetching messages: %v", err)
+		http.Error(w, "Error fetching messages", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(page)
+}
+
+// findSession loads a conversation, answering 404 when there is no such
+// conversation and 500 when it could not be read
+func (h *SessionHandlers) findSession(w http.ResponseWriter, id string) (*domain.Conversation, bool) {
+	conversation, err := h.chatService.GetConversation(id)
+	if err == nil && conversation == nil {
+		err = domain.ErrNoConversation
+	}
+	if err != nil {
+		if errors.Is(err, domain.ErrNoConversation) {
+			http.Error(w, "Session not found", http.StatusNotFound)
+		} else {
+			log.Printf("Error fetching conversation %s: %v", id, err)
+			http.Error(w, "Error fetching session", http.StatusInternalServerError)
+		}
+		return nil, false
+	}
+	return conversation, true
+}
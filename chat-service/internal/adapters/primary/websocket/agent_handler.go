@@ -0,0 +1,78 @@
+// internal/adapters/primary/websocket/agent_handler.go
+package websocket
+
+import (
+	"chat-service/internal/core/domain"
+	"chat-service/internal/core/ports"
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ServeAgentWS connects an authenticated agent to a customer's conversation
+// and puts them in control of it
+func ServeAgentWS(hub *Hub, authenticator ports.AgentAuthenticator, w http.ResponseWriter, r *http.Request) {
+	agentID, err := authenticator.AuthenticateAgent(bearerToken(r))
+	if err != nil {
+		log.Printf("Agent authentication failed: %v", err)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	conversationID := r.URL.Query().Get("conversation_id")
+	if conversationID == "" {
+		http.Error(w, "Missing conversation_id parameter", http.StatusBadRequest)
+		return
+	}
+
+	conversation, err := hub.chatService.GetConversation(conversationID)
+	if err != nil || conversation == nil {
+		http.Error(w, "Conversation not found", http.StatusNotFound)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	conversation, err = hub.takeover.JoinConversation(ctx, agentID, conversationID, r.URL.Query().Get("mode"))
+	if err != nil {
+		log.Printf("Agent %s could not join conversation %s: %v", agentID, conversationID, err)
+		switch {
+		case errors.Is(err, domain.ErrInvalidMode):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, domain.ErrConversationClosed):
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			http.Error(w, "Error joining conversation", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	log.Printf("WS REGISTER: Agent %s joined conversation %s, addr=%s",
+		agentID, conversationID, conn.RemoteAddr().String())
+	client := newClient(hub, conn, agentID, conversation.CustomerID, conversation.ID)
+	client.agentID = agentID
+	client.history = hub.loadHistory(conversation.ID)
+	hub.register(client)
+	hub.SendModeChanged(conversation)
+
+	go client.writePump()
+	go client.readPump()
+}
+
+// bearerToken reads the token from the Authorization header, falling back to
+// the token query parameter for browsers that can't set WebSocket headers
+func bearerToken(r *http.Request) string {
+	if header := r.Header.Get("Authorization"); strings.HasPrefix(header, "Bearer ") {
+		return strings.TrimPrefix(header, "Bearer ")
+	}
+	return r.URL.Query().Get("token")
+}
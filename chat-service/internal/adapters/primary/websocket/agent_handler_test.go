@@ -0,0 +1,225 @@
+// internal/adapters/primary/websocket/agent_handler_test.go
+package websocket_test
+
+import (
+	webSock "chat-service/internal/adapters/primary/websocket"
+	"chat-service/internal/core/domain"
+	"chat-service/internal/core/ports"
+	"chat-service/pkg/chatws"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// stubAuthenticator accepts a single token
+type stubAuthenticator struct{}
+
+func (stubAuthenticator) AuthenticateAgent(token string) (string, error) {
+	if token != "agent-token" {
+		return "", domain.ErrNotAgent
+	}
+	return "agent-1", nil
+}
+
+// stubTakeover records the conversation mode without a repository
+type stubTakeover struct {
+	mutex        sync.Mutex
+	conversation domain.Conversation
+}
+
+func (s *stubTakeover) JoinConversation(ctx context.Context, agentID, conversationID, mode string) (*domain.Conversation, error) {
+	if mode == "" {
+		mode = domain.ConversationModeAgent
+	}
+	return s.set(agentID, mode), nil
+}
+
+func (s *stubTakeover) SetMode(ctx context.Context, agentID, conversationID, mode string) (*domain.Conversation, error) {
+	s.mutex.Lock()
+	inControl := s.conversation.AgentID == agentID
+	s.mutex.Unlock()
+	if !inControl {
+		return nil, domain.ErrNotInControl
+	}
+	if mode == domain.ConversationModeBot {
+		agentID = ""
+	}
+	return s.set(agentID, mode), nil
+}
+
+func (s *stubTakeover) HandBack(ctx context.Context, agentID, conversationID string) (*domain.Conversation, error) {
+	return s.SetMode(ctx, agentID, conversationID, domain.ConversationModeBot)
+}
+
+func (s *stubTakeover) SendAgentMessage(ctx context.Context, agentID, conversationID, content string) (*domain.Message, error) {
+	return &domain.Message{
+		ID:         "agent-msg-1",
+		Content:    content,
+		UserID:     agentID,
+		CustomerID: "customer123",
+		Type:       domain.AgentMessage,
+		Timestamp:  time.Now(),
+	}, nil
+}
+
+func (s *stubTakeover) mode() string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.conversation.Mode
+}
+
+func (s *stubTakeover) set(agentID, mode string) *domain.Conversation {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.conversation.AgentID = agentID
+	s.conversation.Mode = mode
+	conversation := s.conversation
+	return &conversation
+}
+
+var _ ports.AgentTakeover = (*stubTakeover)(nil)
+
+func TestServeAgentWS(t *testing.T) {
+	conversation := &domain.Conversation{
+		ID:         "conv123",
+		CustomerID: "customer123",
+		StartedAt:  time.Now(),
+		Status:     "active",
+		Mode:       domain.ConversationModeBot,
+	}
+
+	setup := func(t *testing.T) (*httptest.Server, *stubTakeover) {
+		mockChatService := new(MockChatService)
+		mockChatService.On("CreateConversation", "customer123").Return(conversation, nil)
+		mockChatService.On("GetConversation", "conv123").Return(conversation, nil)
+		mockChatService.On("GetConversation", "missing").Return(nil, domain.ErrConversationClosed)
+		mockChatService.On("GetMessages", mock.Anything).Return(&domain.MessagePage{Messages: []domain.Message{}}, nil)
+
+		takeover := &stubTakeover{conversation: *conversation}
+		hub := webSock.NewHub(mockChatService, new(MockBotService))
+		hub.SetTakeover(takeover)
+		go hub.Run()
+
+		mux := http.NewServeMux()
+		mux.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
+			webSock.ServeWS(hub, w, r)
+		})
+		mux.HandleFunc("/ws/agent", func(w http.ResponseWriter, r *http.Request) {
+			webSock.ServeAgentWS(hub, stubAuthenticator{}, w, r)
+		})
+		server := httptest.NewServer(mux)
+		t.Cleanup(server.Close)
+		return server, takeover
+	}
+
+	t.Run("rejects unauthenticated agents", func(t *testing.T) {
+		server, _ := setup(t)
+		wsURL := strings.Replace(server.URL, "http://", "ws://", 1) + "/ws/agent?conversation_id=conv123"
+
+		_, resp, err := websocket.DefaultDialer.Dial(wsURL, nil)
+		require.Error(t, err)
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+		_, resp, err = websocket.DefaultDialer.Dial(wsURL+"&token=wrong", nil)
+		require.Error(t, err)
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("rejects unknown conversations", func(t *testing.T) {
+		server, _ := setup(t)
+		wsURL := strings.Replace(server.URL, "http://", "ws://", 1) + "/ws/agent?conversation_id=missing"
+
+		_, resp, err := websocket.DefaultDialer.Dial(wsURL, http.Header{"Authorization": {"Bearer agent-token"}})
+		require.Error(t, err)
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+
+	t.Run("agent talks to the customer and hands back", func(t *testing.T) {
+		server, takeover := setup(t)
+		baseURL := strings.Replace(server.URL, "http://", "ws://", 1)
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+
+		customer, err := chatws.Dial(ctx, baseURL+"/ws?user_id=user123&customer_id=customer123", nil)
+		require.NoError(t, err)
+		defer customer.Close()
+		_, err = customer.Expect(ctx, chatws.TypeHistory)
+		require.NoError(t, err)
+
+		agent, err := chatws.Dial(ctx, baseURL+"/ws/agent?conversation_id=conv123",
+			http.Header{"Authorization": {"Bearer agent-token"}})
+		require.NoError(t, err)
+		defer agent.Close()
+
+		var mode chatws.ModeChanged
+		frame, err := customer.Expect(ctx, chatws.TypeModeChanged)
+		require.NoError(t, err)
+		require.NoError(t, frame.Decode(&mode))
+		assert.Equal(t, domain.ConversationModeAgent, mode.Mode)
+		assert.Equal(t, "agent-1", mode.AgentID)
+		_, err = agent.Expect(ctx, chatws.TypeModeChanged)
+		require.NoError(t, err)
+
+		id, err := agent.Send("Hi, I'm here to help")
+		require.NoError(t, err)
+		ack, err := agent.AwaitAck(ctx, id)
+		require.NoError(t, err)
+		assert.Equal(t, "agent-msg-1", ack.MessageID)
+
+		frame, err = customer.Expect(ctx, chatws.TypeMessage)
+		require.NoError(t, err)
+		var message domain.Message
+		require.NoError(t, frame.Decode(&message))
+		assert.Equal(t, "Hi, I'm here to help", message.Content)
+		assert.Equal(t, domain.AgentMessage, message.Type)
+
+		id, err = agent.SendCommand(chatws.CommandHandBack, "")
+		require.NoError(t, err)
+		_, err = agent.AwaitAck(ctx, id)
+		require.NoError(t, err)
+
+		frame, err = customer.Expect(ctx, chatws.TypeModeChanged)
+		require.NoError(t, err)
+		require.NoError(t, frame.Decode(&mode))
+		assert.Equal(t, domain.ConversationModeBot, mode.Mode)
+		assert.Equal(t, domain.ConversationModeBot, takeover.mode())
+
+		// The agent is no longer in control
+		id, err = agent.SendCommand(chatws.CommandMode, domain.ConversationModeHybrid)
+		require.NoError(t, err)
+		_, err = agent.AwaitAck(ctx, id)
+		assert.True(t, chatws.IsRejection(err, chatws.ErrorRejected), "got %v", err)
+
+		id, err = agent.SendCommand("dance", "")
+		require.NoError(t, err)
+		_, err = agent.AwaitAck(ctx, id)
+		assert.True(t, chatws.IsRejection(err, chatws.ErrorInvalidFrame), "got %v", err)
+	})
+
+	t.Run("hands back when the agent disconnects", func(t *testing.T) {
+		server, takeover := setup(t)
+		baseURL := strings.Replace(server.URL, "http://", "ws://", 1)
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+
+		agent, err := chatws.Dial(ctx, baseURL+"/ws/agent?conversation_id=conv123&token=agent-token", nil)
+		require.NoError(t, err)
+		_, err = agent.Expect(ctx, chatws.TypeModeChanged)
+		require.NoError(t, err)
+		assert.Equal(t, domain.ConversationModeAgent, takeover.mode())
+
+		agent.Close()
+		assert.Eventually(t, func() bool {
+			return takeover.mode() == domain.ConversationModeBot
+		}, 2*time.Second, 20*time.Millisecond)
+	})
+}
@@ -2,10 +2,12 @@
 package websocket
 
 import (
-	"bytes"
 	"chat-service/internal/core/domain"
-	"encoding/json"
+	"chat-service/pkg/chatws"
+	"context"
+	"errors"
 	"log"
+	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -27,18 +29,113 @@ const (
 
 // Client represents a connected WebSocket client
 type Client struct {
-	hub            *Hub
-	conn           *websocket.Conn
-	send           chan []byte
-	userID         string
-	customerID     string
-	conversationID string
+	hub        *Hub
+	conn       *websocket.Conn
+	send       chan []byte
+	userID     string
+	customerID string
+
+	// The conversation the client is in and whether it is in the registry.
+	// A customer's clients move along when their messages start a new
+	// conversation.
+	conversationMutex sync.Mutex
+	conversationID    string
+	registered        bool
+
+	// Set for agents watching the customer's conversation
+	agentID string
+
+	// The conversation whose presence the client is counted in, if any.
+	// Guarded by the hub's presenceMutex.
+	presentIn string
+
+	// The latest messages, sent on registration
+	history *domain.MessagePage
+
+	// Closed once the client is shut down, which stops writePump. The send
+	// channel itself is never closed, so queueing a frame can't panic.
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+// newClient creates a client for an upgraded connection
+func newClient(hub *Hub, conn *websocket.Conn, userID, customerID, conversationID string) *Client {
+	return &Client{
+		hub:            hub,
+		conn:           conn,
+		send:           make(chan []byte, 256),
+		userID:         userID,
+		customerID:     customerID,
+		conversationID: conversationID,
+		closed:         make(chan struct{}),
+	}
+}
+
+// conversation returns the conversation the client is in
+func (c *Client) conversation() string {
+	c.conversationMutex.Lock()
+	defer c.conversationMutex.Unlock()
+	return c.conversationID
+}
+
+// membership returns the conversation the client is in and whether it is
+// still registered
+func (c *Client) membership() (string, bool) {
+	c.conversationMutex.Lock()
+	defer c.conversationMutex.Unlock()
+	return c.conversationID, c.registered
+}
+
+// enqueue queues a frame for writePump without blocking. A client too slow
+// to keep its buffer from filling up is shut down.
+func (c *Client) enqueue(payload []byte) {
+	if payload == nil {
+		return
+	}
+	select {
+	case <-c.closed:
+		return
+	default:
+	}
+
+	select {
+	case c.send <- payload:
+	default:
+		log.Printf("Send buffer of %s full, closing the connection", c.userID)
+		c.close()
+	}
+}
+
+// close shuts the client down. It is safe to call more than once and from
+// any goroutine.
+func (c *Client) close() {
+	c.closeOnce.Do(func() {
+		close(c.closed)
+	})
+}
+
+// isAgent reports whether the client is an agent rather than the customer
+func (c *Client) isAgent() bool {
+	return c.agentID != ""
+}
+
+// role is the client's participant role in the conversation
+func (c *Client) role() string {
+	if c.isAgent() {
+		return domain.ParticipantAgent
+	}
+	return domain.ParticipantCustomer
+}
+
+// participantKey identifies the client's user in its conversation's presence
+func (c *Client) participantKey() string {
+	return c.role() + "/" + c.userID
 }
 
 // readPump pumps messages from the websocket connection to the hub.
 func (c *Client) readPump() {
 	defer func() {
-		c.hub.unregister <- c
+		c.hub.unregister(c)
 		c.conn.Close()
 	}()
 
@@ -58,25 +155,252 @@ func (c *Client) readPump() {
 			break
 		}
 
-		var msg domain.Message
-		if err := json.Unmarshal(message, &msg); err != nil {
-			log.Printf("error decoding message: %v", err)
+		envelope, err := decodeFrame(message)
+		if err != nil {
+			log.Printf("error decoding frame: %v", err)
+			c.enqueue(rejectionFrame(frameID(envelope), err))
 			continue
 		}
 
-		// Add user and customer IDs from the connection
-		msg.UserID = c.userID
-		msg.CustomerID = c.customerID
-		msg.Metadata = map[string]string{
-			"conversation_id": c.conversationID,
-			"originalSender":        c.userID,
-			"clientID":     c.conn.RemoteAddr().String(),
+		switch {
+		case envelope.Type == chatws.TypeTypingStart || envelope.Type == chatws.TypeTypingStop:
+			c.handleTypingFrame(envelope)
+		case envelope.Type == chatws.TypeReceipt:
+			err = c.handleReceiptFrame(envelope)
+		case envelope.Type == chatws.TypeHistoryRequest:
+			err = c.handleHistoryRequest(envelope)
+		case envelope.Type == chatws.TypeSurveyResponse && !c.isAgent():
+			err = c.handleSurveyResponse(envelope)
+		case c.isAgent():
+			err = c.handleAgentFrame(envelope)
+		default:
+			err = c.handleCustomerFrame(envelope)
 		}
+		if err != nil {
+			log.Printf("Frame from %s rejected in conversation %s: %v", c.userID, c.conversation(), err)
+			c.enqueue(rejectionFrame(envelope.ID, err))
+		}
+	}
+}
+
+// handleTypingFrame relays the client starting or stopping to type. Typing
+// frames are not acknowledged.
+func (c *Client) handleTypingFrame(envelope *chatws.Envelope) {
+	c.hub.applyTyping(typingUpdate{
+		indicator: domain.TypingIndicator{
+			ConversationID: c.conversation(),
+			CustomerID:     c.customerID,
+			UserID:         c.userID,
+			Role:           c.role(),
+			Typing:         envelope.Type == chatws.TypeTypingStart,
+		},
+		sender: c,
+	})
+}
+
+// handleReceiptFrame records that the client received or read messages and
+// tells the rest of the conversation about the ones that changed
+func (c *Client) handleReceiptFrame(envelope *chatws.Envelope) error {
+	var payload chatws.Receipt
+	if err := envelope.Decode(&payload); err != nil {
+		return &frameRejection{chatws.ErrorInvalidFrame, "invalid receipt payload"}
+	}
+	if len(payload.MessageIDs) > chatws.MaxReceiptMessages {
+		return &frameRejection{chatws.ErrorInvalidFrame, "receipt covers too many messages"}
+	}
 
-		// Process the message in the hub
-		jsonMsg, _ := json.Marshal(msg)
-		c.hub.broadcast <- jsonMsg
+	receipt := &domain.Receipt{
+		ConversationID: c.conversation(),
+		MessageIDs:     payload.MessageIDs,
+		Status:         domain.DeliveryStatus(payload.Status),
+		UserID:         c.userID,
+		Timestamp:      time.Now(),
 	}
+	updated, err := c.hub.chatService.UpdateDeliveryStatus(receipt)
+	if err != nil {
+		if errors.Is(err, domain.ErrInvalidReceipt) {
+			return &frameRejection{chatws.ErrorInvalidFrame, err.Error()}
+		}
+		log.Printf("Error storing receipt from %s: %v", c.userID, err)
+		return &frameRejection{chatws.ErrorInternal, "receipt could not be stored"}
+	}
+
+	if envelope.ID != "" {
+		c.enqueue(encodeFrame(chatws.TypeAck, envelope.ID, chatws.Ack{Timestamp: receipt.Timestamp}))
+	}
+	if len(updated) > 0 {
+		changed := *receipt
+		changed.MessageIDs = updated
+		c.hub.sendReceipt(&changed, c)
+	}
+	return nil
+}
+
+// handleHistoryRequest answers with a page of the conversation's messages
+func (c *Client) handleHistoryRequest(envelope *chatws.Envelope) error {
+	var request chatws.HistoryRequest
+	if len(envelope.Payload) > 0 {
+		if err := envelope.Decode(&request); err != nil {
+			return &frameRejection{chatws.ErrorInvalidFrame, "invalid history request"}
+		}
+	}
+
+	page, err := c.hub.chatService.GetMessages(domain.MessageQuery{
+		ConversationID: c.conversation(),
+		Before:         request.Before,
+		After:          request.After,
+		Limit:          request.Limit,
+	})
+	if err != nil {
+		if errors.Is(err, domain.ErrInvalidCursor) {
+			return &frameRejection{chatws.ErrorInvalidFrame, err.Error()}
+		}
+		log.Printf("Error fetching history for %s: %v", c.userID, err)
+		return &frameRejection{chatws.ErrorInternal, "history could not be read"}
+	}
+
+	c.enqueue(encodeFrame(chatws.TypeHistory, envelope.ID, page))
+	return nil
+}
+
+// handleSurveyResponse stores the customer's rating of a conversation and
+// acknowledges it with the ID of the stored response
+func (c *Client) handleSurveyResponse(envelope *chatws.Envelope) error {
+	if c.hub.survey == nil {
+		return &frameRejection{chatws.ErrorUnknownType, "unsupported frame type " + envelope.Type}
+	}
+	var payload chatws.SurveyResponse
+	if err := envelope.Decode(&payload); err != nil {
+		return &frameRejection{chatws.ErrorInvalidFrame, "invalid survey response payload"}
+	}
+	if payload.ConversationID == "" {
+		payload.ConversationID = c.conversation()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	response := &domain.SatisfactionResponse{
+		ConversationID: payload.ConversationID,
+		CustomerID:     c.customerID,
+		Rating:         payload.Rating,
+		Comment:        payload.Comment,
+	}
+	if err := c.hub.survey.SubmitSurvey(ctx, response); err != nil {
+		switch {
+		case errors.Is(err, domain.ErrInvalidSurvey):
+			return &frameRejection{chatws.ErrorInvalidFrame, err.Error()}
+		case errors.Is(err, domain.ErrSurveyAnswered):
+			return err
+		}
+		log.Printf("Error storing survey response from %s: %v", c.userID, err)
+		return &frameRejection{chatws.ErrorInternal, "survey response could not be stored"}
+	}
+
+	c.enqueue(encodeFrame(chatws.TypeAck, envelope.ID, chatws.Ack{
+		MessageID: response.ID,
+		Timestamp: response.CreatedAt,
+	}))
+	return nil
+}
+
+// handleCustomerFrame passes a customer's message to the hub to be stored
+// and answered. The hub tells the client itself whether it was stored.
+func (c *Client) handleCustomerFrame(envelope *chatws.Envelope) error {
+	if envelope.Type != chatws.TypeMessage {
+		return &frameRejection{chatws.ErrorUnknownType, "unsupported frame type " + envelope.Type}
+	}
+
+	payload, err := decodeMessage(envelope)
+	if err != nil {
+		return err
+	}
+
+	// Add user and customer IDs from the connection
+	message := &domain.Message{
+		Content:    payload.Content,
+		UserID:     c.userID,
+		CustomerID: c.customerID,
+		Type:       domain.UserMessage,
+		Timestamp:  time.Now(),
+		Metadata: map[string]string{
+			"conversation_id":   c.conversation(),
+			"originalSender":    c.userID,
+			"clientID":          c.conn.RemoteAddr().String(),
+			"client_message_id": envelope.ID,
+		},
+	}
+	for _, id := range payload.Attachments {
+		message.Attachments = append(message.Attachments, domain.Attachment{ID: id})
+	}
+	c.hub.accept(c, envelope.ID, message)
+	return nil
+}
+
+// handleAgentFrame runs an agent's command or sends their message to the
+// customer, acknowledging it to the agent
+func (c *Client) handleAgentFrame(envelope *chatws.Envelope) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	takeover := c.hub.takeover
+	switch envelope.Type {
+	case chatws.TypeMessage:
+		payload, err := decodeMessage(envelope)
+		if err != nil {
+			return err
+		}
+		// Uploads belong to the customer's side of the conversation
+		if len(payload.Attachments) > 0 {
+			return &frameRejection{chatws.ErrorInvalidMessage, "agents cannot send attachments"}
+		}
+		sent, err := takeover.SendAgentMessage(ctx, c.agentID, c.conversation(), payload.Content)
+		if err != nil {
+			return err
+		}
+		c.enqueue(encodeFrame(chatws.TypeAck, envelope.ID, chatws.Ack{
+			MessageID: sent.ID,
+			Timestamp: sent.Timestamp,
+		}))
+		c.hub.sendAgentMessage(sent, c)
+		return nil
+
+	case chatws.TypeCommand:
+		var command chatws.Command
+		if err := envelope.Decode(&command); err != nil {
+			return &frameRejection{chatws.ErrorInvalidFrame, "invalid command payload"}
+		}
+
+		var conversation *domain.Conversation
+		var err error
+		switch command.Command {
+		case chatws.CommandJoin:
+			conversation, err = takeover.JoinConversation(ctx, c.agentID, c.conversation(), command.Mode)
+		case chatws.CommandMode:
+			conversation, err = takeover.SetMode(ctx, c.agentID, c.conversation(), command.Mode)
+		case chatws.CommandHandBack:
+			conversation, err = takeover.HandBack(ctx, c.agentID, c.conversation())
+		default:
+			return &frameRejection{chatws.ErrorInvalidFrame, "unknown command " + command.Command}
+		}
+		if err != nil {
+			return err
+		}
+		c.enqueue(encodeFrame(chatws.TypeAck, envelope.ID, chatws.Ack{Timestamp: time.Now()}))
+		c.hub.SendModeChanged(conversation)
+		return nil
+
+	default:
+		return &frameRejection{chatws.ErrorUnknownType, "unsupported frame type " + envelope.Type}
+	}
+}
+
+// frameID is the ID to answer a frame with, if it could be read at all
+func frameID(envelope *chatws.Envelope) string {
+	if envelope == nil {
+		return ""
+	}
+	return envelope.ID
 }
 
 // writePump pumps messages from the hub to the websocket connection.
@@ -89,36 +413,21 @@ func (c *Client) writePump() {
 
 	for {
 		select {
-		case message, ok := <-c.send:
-			// Add debug logging
-			log.Printf("CLIENT DEBUG: Received message to write, size=%d", len(message))
+		case <-c.closed:
+			// The client was shut down
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			c.conn.WriteMessage(websocket.CloseMessage, []byte{})
+			return
 
+		case message := <-c.send:
 			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
-			if !ok {
-				// The hub closed the channel.
-				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
-				return
-			}
 
-			w, err := c.conn.NextWriter(websocket.TextMessage)
-			if err != nil {
+			// Every frame is its own websocket message so clients can
+			// parse them one at a time
+			if err := c.conn.WriteMessage(websocket.TextMessage, message); err != nil {
 				return
 			}
-			w.Write(message)
-
-			// Debug successful write
-			log.Printf("CLIENT DEBUG: Successfully wrote message to websocket")
-
-			// Add queued messages to the current websocket message.
-			n := len(c.send)
-			for i := 0; i < n; i++ {
-				w.Write(bytes.TrimSpace([]byte{'\n'}))
-				w.Write(<-c.send)
-			}
 
-			if err := w.Close(); err != nil {
-				return
-			}
 		case <-ticker.C:
 			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
 			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
@@ -0,0 +1,99 @@
+package websocket
+
+import (
+	"chat-service/internal/core/domain"
+	"chat-service/pkg/chatws"
+	"encoding/json"
+	"errors"
+	"log"
+	"strings"
+)
+
+// frameRejection is a client frame that failed validation
+type frameRejection struct {
+	code    string
+	message string
+}
+
+func (r *frameRejection) Error() string {
+	return r.message
+}
+
+// encodeFrame builds a protocol frame. Every payload is built by this
+// package, so failures are logged rather than returned.
+func encodeFrame(frameType, id string, payload interface{}) []byte {
+	data, err := chatws.Encode(frameType, id, payload)
+	if err != nil {
+		log.Printf("Error encoding %s frame: %v", frameType, err)
+		return nil
+	}
+	return data
+}
+
+// messageFrame builds the frame a stored message is delivered to clients in
+func messageFrame(message *domain.Message) []byte {
+	switch message.Type {
+	case domain.BotMessage:
+		return encodeFrame(chatws.TypeBotComplete, message.ID, message)
+	case domain.SystemMessage:
+		return encodeFrame(chatws.TypeSystem, message.ID, message)
+	default:
+		return encodeFrame(chatws.TypeMessage, message.ID, message)
+	}
+}
+
+// errorFrame rejects the client frame with the given ID
+func errorFrame(id, code, message string) []byte {
+	return encodeFrame(chatws.TypeError, id, chatws.Error{Code: code, Message: message})
+}
+
+// decodeFrame parses and validates the envelope of a client frame
+func decodeFrame(data []byte) (*chatws.Envelope, error) {
+	var envelope chatws.Envelope
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return nil, &frameRejection{chatws.ErrorInvalidFrame, "frame is not a JSON envelope"}
+	}
+	if envelope.Version != 0 && envelope.Version != chatws.Version {
+		return &envelope, &frameRejection{chatws.ErrorUnsupportedVersion, "unsupported protocol version"}
+	}
+	if len(envelope.ID) > chatws.MaxIDLength {
+		envelope.ID = ""
+		return &envelope, &frameRejection{chatws.ErrorInvalidFrame, "frame ID is too long"}
+	}
+	return &envelope, nil
+}
+
+// decodeMessage reads the payload of a message frame. Messages need content
+// unless they carry attachments.
+func decodeMessage(envelope *chatws.Envelope) (*chatws.SendMessage, error) {
+	if envelope.ID == "" {
+		return nil, &frameRejection{chatws.ErrorInvalidMessage, "message frames need an ID"}
+	}
+
+	var payload chatws.SendMessage
+	if err := envelope.Decode(&payload); err != nil {
+		return nil, &frameRejection{chatws.ErrorInvalidMessage, "invalid message payload"}
+	}
+
+	payload.Content = strings.TrimSpace(payload.Content)
+	switch {
+	case payload.Content == "" && len(payload.Attachments) == 0:
+		return nil, &frameRejection{chatws.ErrorInvalidMessage, "message content is required"}
+	case len(payload.Content) > chatws.MaxContentLength:
+		return nil, &frameRejection{chatws.ErrorInvalidMessage, "message content is too long"}
+	case len(payload.Attachments) > domain.MaxMessageAttachments:
+		return nil, &frameRejection{chatws.ErrorInvalidMessage, "too many attachments"}
+	}
+	return &payload, nil
+}
+
+// rejectionFrame turns an error handling a client frame into an error frame.
+// Validation failures keep their code; anything else is reported as a
+// rejection with the error text.
+func rejectionFrame(id string, err error) []byte {
+	var rejection *frameRejection
+	if errors.As(err, &rejection) {
+		return errorFrame(id, rejection.code, rejection.message)
+	}
+	return errorFrame(id, chatws.ErrorRejected, err.Error())
+}
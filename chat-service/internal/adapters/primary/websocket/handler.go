@@ -49,16 +49,9 @@ func ServeWS(hub *Hub, w http.ResponseWriter, r *http.Request) {
 	}
 	log.Printf("WS REGISTER: New client registered userID=%s, customerID=%s, addr=%s",
 		userID, customerID, conn.RemoteAddr().String())
-	client := &Client{
-		hub:            hub,
-		conn:           conn,
-		send:           make(chan []byte, 256),
-		userID:         userID,
-		customerID:     customerID,
-		conversationID: conversation.ID,
-	}
-
-	client.hub.register <- client
+	client := newClient(hub, conn, userID, customerID, conversation.ID)
+	client.history = hub.loadHistory(conversation.ID)
+	hub.register(client)
 
 	// Start goroutines to handle messages
 	go client.writePump()
@@ -5,8 +5,11 @@ import (
 	webSock "chat-service/internal/adapters/primary/websocket"
 	"chat-service/internal/core/domain"
 	"chat-service/internal/core/ports"
+	"chat-service/pkg/chatws"
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
@@ -17,6 +20,7 @@ import (
 	"github.com/gorilla/websocket"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 // Mock chat service for testing
@@ -34,6 +38,14 @@ func (m *MockChatService) GetChatHistory(customerID string) ([]domain.Message, e
 	return args.Get(0).([]domain.Message), args.Error(1)
 }
 
+func (m *MockChatService) GetMessages(query domain.MessageQuery) (*domain.MessagePage, error) {
+	args := m.Called(query)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.MessagePage), args.Error(1)
+}
+
 func (m *MockChatService) GetConversation(conversationID string) (*domain.Conversation, error) {
 	args := m.Called(conversationID)
 	if args.Get(0) == nil {
@@ -55,6 +67,27 @@ func (m *MockChatService) CloseConversation(conversationID string) error {
 	return args.Error(0)
 }
 
+func (m *MockChatService) ListConversations(customerID string) ([]domain.Conversation, error) {
+	args := m.Called(customerID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]domain.Conversation), args.Error(1)
+}
+
+func (m *MockChatService) UpdateDeliveryStatus(receipt *domain.Receipt) ([]string, error) {
+	args := m.Called(receipt)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *MockChatService) GetUnreadCount(conversationID, userID string) (int, error) {
+	args := m.Called(conversationID, userID)
+	return args.Int(0), args.Error(1)
+}
+
 // Ensure MockChatService implements ChatService interface
 var _ ports.ChatService = (*MockChatService)(nil)
 
@@ -147,7 +180,7 @@ func TestServeWS(t *testing.T) {
 		mockChatService.On("CreateConversation", "customer123").Return(conversation, nil).Once()
 
 		// Mock empty chat history
-		mockChatService.On("GetChatHistory", "customer123").Return([]domain.Message{}, nil).Once()
+		mockChatService.On("GetMessages", mock.Anything).Return(&domain.MessagePage{Messages: []domain.Message{}}, nil).Once()
 
 		// Create a test HTTP server
 		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -187,36 +220,18 @@ func TestBotInteraction(t *testing.T) {
 		Status:     "active",
 	}
 	mockChatService.On("CreateConversation", "customer123").Return(conversation, nil)
-	mockChatService.On("GetChatHistory", "customer123").Return([]domain.Message{}, nil)
+	mockChatService.On("GetMessages", mock.Anything).Return(&domain.MessagePage{Messages: []domain.Message{}}, nil)
 
 	// Mock successful message saving
 	mockChatService.On("SaveMessage", mock.MatchedBy(func(msg *domain.Message) bool {
 		return msg.CustomerID == "customer123" && msg.Type == domain.UserMessage
-	})).Return(nil)
-
-	// Mock bot processing with captured message
-	mockBotService.On("ProcessMessage", mock.Anything, mock.MatchedBy(func(msg *domain.Message) bool {
-		return msg.CustomerID == "customer123" && msg.Content == "Hello bot"
 	})).Return(nil).Run(func(args mock.Arguments) {
-		// Create the bot response
-		botResponse := &domain.Message{
-			ID:         "bot-msg-123",
-			Content:    "Hello human, how can I help?",
-			UserID:     "bot-1",
-			CustomerID: "customer123",
-			Type:       domain.BotMessage,
-			Timestamp:  time.Now(),
-		}
-
-		// Actually use the botResponse variable when setting up the mock
-		mockChatService.On("SaveMessage", botResponse).Return(nil).Once()
+		args.Get(0).(*domain.Message).ID = "user-msg-1"
+	})
 
-		// OR if you need to use a matcher but still want to reference the response:
-		mockChatService.On("SaveMessage", mock.MatchedBy(func(msg *domain.Message) bool {
-			return msg.ID == botResponse.ID &&
-				msg.Type == domain.BotMessage &&
-				msg.UserID == "bot-1"
-		})).Return(nil).Once()
+	processed := make(chan *domain.Message, 1)
+	mockBotService.On("ProcessMessage", mock.Anything, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		processed <- args.Get(1).(*domain.Message)
 	})
 
 	// Set up subscription mocking
@@ -228,66 +243,886 @@ func TestBotInteraction(t *testing.T) {
 			messageHandler = args.Get(0).(func(*domain.Message))
 		})
 
-	// Create a test HTTP server
+	hub := webSock.NewHub(mockChatService, mockBotService)
+	if err := hub.SubscribeToBotMessages(); err != nil {
+		t.Fatalf("Failed to subscribe to messages: %v", err)
+	}
+	go hub.Run()
+
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		hub := webSock.NewHub(mockChatService, mockBotService)
-		if err := hub.SubscribeToBotMessages(); err != nil {
-			t.Fatalf("Failed to subscribe to messages: %v", err)
-		}
-		go hub.Run()
 		webSock.ServeWS(hub, w, r)
 	}))
 	defer server.Close()
 
-	// Connect to WebSocket
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
 	u, _ := url.Parse(server.URL)
 	u.Scheme = "ws"
 	u.Path = "/ws"
 	u.RawQuery = "user_id=user123&customer_id=customer123"
+	client, err := chatws.Dial(ctx, u.String(), nil)
+	require.NoError(t, err)
+	defer client.Close()
+
+	history, err := client.Expect(ctx, chatws.TypeHistory)
+	require.NoError(t, err)
+	var page chatws.History
+	require.NoError(t, history.Decode(&page))
+	assert.Empty(t, page.Messages)
+
+	// The message is acknowledged once stored
+	id, err := client.Send("Hello bot")
+	require.NoError(t, err)
+	ack, err := client.AwaitAck(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, "user-msg-1", ack.MessageID)
+
+	select {
+	case msg := <-processed:
+		assert.Equal(t, "Hello bot", msg.Content)
+		assert.Equal(t, "user123", msg.UserID)
+		assert.Equal(t, id, msg.Metadata["client_message_id"])
+	case <-ctx.Done():
+		t.Fatal("bot did not process the message")
+	}
+
+	// Simulate a bot response generated by another replica coming through
+	// the message subscription
+	require.NotNil(t, messageHandler)
+	messageHandler(&domain.Message{
+		ID:         "bot-msg-123",
+		Content:    "Hello human, how can I help?",
+		UserID:     "bot-1",
+		CustomerID: "customer123",
+		Type:       domain.BotMessage,
+		Timestamp:  time.Now(),
+	})
+
+	frame, err := client.Expect(ctx, chatws.TypeBotComplete)
+	require.NoError(t, err)
+	assert.Equal(t, "bot-msg-123", frame.ID)
+	var botResponse domain.Message
+	require.NoError(t, frame.Decode(&botResponse))
+	assert.Equal(t, domain.BotMessage, botResponse.Type)
+	assert.Equal(t, "Hello human, how can I help?", botResponse.Content)
+}
+
+func TestInvalidFrames(t *testing.T) {
+	mockChatService := new(MockChatService)
+	conversation := &domain.Conversation{ID: "conv123", CustomerID: "customer123", Status: "active"}
+	mockChatService.On("CreateConversation", "customer123").Return(conversation, nil)
+	mockChatService.On("GetMessages", mock.Anything).Return(&domain.MessagePage{Messages: []domain.Message{}}, nil)
+
+	hub := webSock.NewHub(mockChatService, new(MockBotService))
+	go hub.Run()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		webSock.ServeWS(hub, w, r)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	wsURL := strings.Replace(server.URL, "http://", "ws://", 1) + "/ws?user_id=user123&customer_id=customer123"
+	client, err := chatws.Dial(ctx, wsURL, nil)
+	require.NoError(t, err)
+	defer client.Close()
+
+	_, err = client.Expect(ctx, chatws.TypeHistory)
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		name      string
+		frameType string
+		payload   interface{}
+		code      string
+	}{
+		{"empty content", chatws.TypeMessage, chatws.SendMessage{Content: "   "}, chatws.ErrorInvalidMessage},
+		{"content too long", chatws.TypeMessage, chatws.SendMessage{Content: strings.Repeat("a", chatws.MaxContentLength+1)}, chatws.ErrorInvalidMessage},
+		{"unknown type", "dance", nil, chatws.ErrorUnknownType},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			id := "frame-" + strings.ReplaceAll(tc.name, " ", "-")
+			require.NoError(t, client.SendFrame(tc.frameType, id, tc.payload))
+			_, err := client.AwaitAck(ctx, id)
+			assert.True(t, chatws.IsRejection(err, tc.code), "got %v", err)
+		})
+	}
+
+	t.Run("unsupported version", func(t *testing.T) {
+		// The client package always sends the current version
+		frame, _ := json.Marshal(map[string]interface{}{"v": 99, "type": "message", "id": "v99"})
+		conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+		require.NoError(t, err)
+		defer conn.Close()
+		require.NoError(t, conn.WriteMessage(websocket.TextMessage, frame))
+
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		for {
+			var envelope chatws.Envelope
+			require.NoError(t, conn.ReadJSON(&envelope))
+			if envelope.Type != chatws.TypeError {
+				continue
+			}
+			var rejection chatws.Error
+			require.NoError(t, envelope.Decode(&rejection))
+			assert.Equal(t, "v99", envelope.ID)
+			assert.Equal(t, chatws.ErrorUnsupportedVersion, rejection.Code)
+			return
+		}
+	})
+
+	mockChatService.AssertNotCalled(t, "SaveMessage", mock.Anything)
+}
+
+func TestBotStreamingFrames(t *testing.T) {
+	mockChatService := new(MockChatService)
+	mockBotService := new(MockBotService)
+
+	conversation := &domain.Conversation{
+		ID:         "conv123",
+		CustomerID: "customer123",
+		StartedAt:  time.Now(),
+		Status:     "active",
+	}
+	mockChatService.On("CreateConversation", "customer123").Return(conversation, nil)
+	mockChatService.On("GetMessages", mock.Anything).Return(&domain.MessagePage{Messages: []domain.Message{}}, nil)
+
+	hub := webSock.NewHub(mockChatService, mockBotService)
+	go hub.Run()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		webSock.ServeWS(hub, w, r)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	wsURL := strings.Replace(server.URL, "http://", "ws://", 1) + "/ws?user_id=user123&customer_id=customer123"
+	client, err := chatws.Dial(ctx, wsURL, nil)
+	require.NoError(t, err)
+	defer client.Close()
+
+	// History and the presence snapshot mean the hub has registered the client
+	_, err = client.Expect(ctx, chatws.TypeHistory)
+	require.NoError(t, err)
+	_, err = client.Expect(ctx, chatws.TypePresence)
+	require.NoError(t, err)
+
+	hub.SendBotChunk(&domain.MessageChunk{MessageID: "bot-msg-1", CustomerID: "customer123", Content: "Hello ", Index: 0})
+	hub.SendBotChunk(&domain.MessageChunk{MessageID: "bot-msg-1", CustomerID: "customer123", Content: "there", Index: 1})
+	hub.SendBotComplete(&domain.Message{
+		ID:         "bot-msg-1",
+		Content:    "Hello there",
+		UserID:     "bot-1",
+		CustomerID: "customer123",
+		Type:       domain.BotMessage,
+		Timestamp:  time.Now(),
+	})
 
-	conn, _, err := websocket.DefaultDialer.Dial(u.String(), nil)
-	assert.NoError(t, err)
-	defer conn.Close()
+	var frames []*chatws.Envelope
+	for len(frames) < 3 {
+		frame, err := client.Next(ctx)
+		require.NoError(t, err)
+		frames = append(frames, frame)
+	}
+
+	var chunk chatws.BotChunk
+	assert.Equal(t, chatws.TypeBotChunk, frames[0].Type)
+	require.NoError(t, frames[0].Decode(&chunk))
+	assert.Equal(t, "Hello ", chunk.Content)
+	assert.Equal(t, chatws.TypeBotChunk, frames[1].Type)
+	assert.Equal(t, "bot-msg-1", frames[1].ID)
+	require.NoError(t, frames[1].Decode(&chunk))
+	assert.Equal(t, 1, chunk.Index)
+	assert.Equal(t, chatws.TypeBotComplete, frames[2].Type)
+	assert.Equal(t, "bot-msg-1", frames[2].ID)
+	var message domain.Message
+	require.NoError(t, frames[2].Decode(&message))
+	assert.Equal(t, "Hello there", message.Content)
+}
+
+func TestReceipts(t *testing.T) {
+	mockChatService := new(MockChatService)
+	conversation := &domain.Conversation{ID: "conv123", CustomerID: "customer123", Status: "active"}
+	mockChatService.On("CreateConversation", "customer123").Return(conversation, nil)
+	mockChatService.On("GetMessages", mock.Anything).Return(&domain.MessagePage{Messages: []domain.Message{}}, nil)
+	mockChatService.On("UpdateDeliveryStatus", mock.MatchedBy(func(receipt *domain.Receipt) bool {
+		return receipt.Status == domain.DeliveryRead
+	})).Return([]string{"bot-msg-2"}, nil)
+	mockChatService.On("UpdateDeliveryStatus", mock.MatchedBy(func(receipt *domain.Receipt) bool {
+		return receipt.Status == domain.DeliverySent
+	})).Return(nil, domain.ErrInvalidReceipt)
+
+	hub := webSock.NewHub(mockChatService, new(MockBotService))
+	go hub.Run()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		webSock.ServeWS(hub, w, r)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	// The same customer on two devices
+	wsURL := strings.Replace(server.URL, "http://", "ws://", 1) + "/ws?user_id=user123&customer_id=customer123"
+	phone, err := chatws.Dial(ctx, wsURL, nil)
+	require.NoError(t, err)
+	defer phone.Close()
+	_, err = phone.Expect(ctx, chatws.TypePresence)
+	require.NoError(t, err)
+
+	laptop, err := chatws.Dial(ctx, wsURL, nil)
+	require.NoError(t, err)
+	defer laptop.Close()
+	_, err = laptop.Expect(ctx, chatws.TypePresence)
+	require.NoError(t, err)
+
+	t.Run("changes are relayed to the other clients", func(t *testing.T) {
+		id, err := phone.SendReceipt(chatws.StatusRead, "bot-msg-1", "bot-msg-2")
+		require.NoError(t, err)
+		_, err = phone.AwaitAck(ctx, id)
+		require.NoError(t, err)
+
+		frame, err := laptop.Expect(ctx, chatws.TypeReceipt)
+		require.NoError(t, err)
+		var receipt chatws.Receipt
+		require.NoError(t, frame.Decode(&receipt))
+		assert.Equal(t, []string{"bot-msg-2"}, receipt.MessageIDs)
+		assert.Equal(t, chatws.StatusRead, receipt.Status)
+		assert.Equal(t, "user123", receipt.UserID)
+
+		mockChatService.AssertCalled(t, "UpdateDeliveryStatus", mock.MatchedBy(func(receipt *domain.Receipt) bool {
+			return receipt.ConversationID == "conv123" && receipt.UserID == "user123" && len(receipt.MessageIDs) == 2
+		}))
+	})
+
+	t.Run("invalid receipts are rejected", func(t *testing.T) {
+		id, err := phone.SendReceipt(chatws.StatusSent, "bot-msg-1")
+		require.NoError(t, err)
+		_, err = phone.AwaitAck(ctx, id)
+		assert.True(t, chatws.IsRejection(err, chatws.ErrorInvalidFrame), "got %v", err)
+
+		ids := make([]string, chatws.MaxReceiptMessages+1)
+		for i := range ids {
+			ids[i] = "msg"
+		}
+		id, err = phone.SendReceipt(chatws.StatusRead, ids...)
+		require.NoError(t, err)
+		_, err = phone.AwaitAck(ctx, id)
+		assert.True(t, chatws.IsRejection(err, chatws.ErrorInvalidFrame), "got %v", err)
+	})
+}
+
+func TestHistoryPaging(t *testing.T) {
+	mockChatService := new(MockChatService)
+	conversation := &domain.Conversation{ID: "conv123", CustomerID: "customer123", Status: "active"}
+	mockChatService.On("CreateConversation", "customer123").Return(conversation, nil)
+
+	// Only the latest messages of the active conversation are sent on connect
+	mockChatService.On("GetMessages", domain.MessageQuery{ConversationID: "conv123", Limit: 2}).Return(&domain.MessagePage{
+		Messages: []domain.Message{{ID: "msg-4", Content: "four"}, {ID: "msg-5", Content: "five"}},
+		HasMore:  true,
+	}, nil).Once()
+	mockChatService.On("GetMessages", domain.MessageQuery{ConversationID: "conv123", Before: "msg-4", Limit: 3}).Return(&domain.MessagePage{
+		Messages: []domain.Message{{ID: "msg-1", Content: "one"}, {ID: "msg-2", Content: "two"}, {ID: "msg-3", Content: "three"}},
+	}, nil).Once()
+	mockChatService.On("GetMessages", domain.MessageQuery{ConversationID: "conv123", After: "unknown"}).
+		Return(nil, domain.ErrInvalidCursor).Once()
+
+	hub := webSock.NewHub(mockChatService, new(MockBotService))
+	hub.SetHistoryLimit(2)
+	go hub.Run()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		webSock.ServeWS(hub, w, r)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	wsURL := strings.Replace(server.URL, "http://", "ws://", 1) + "/ws?user_id=user123&customer_id=customer123"
+	client, err := chatws.Dial(ctx, wsURL, nil)
+	require.NoError(t, err)
+	defer client.Close()
+
+	frame, err := client.Expect(ctx, chatws.TypeHistory)
+	require.NoError(t, err)
+	var latest chatws.History
+	require.NoError(t, frame.Decode(&latest))
+	require.Len(t, latest.Messages, 2)
+	assert.Equal(t, "msg-4", latest.Messages[0].ID)
+	assert.True(t, latest.HasMore)
+
+	older, err := client.RequestHistory(ctx, chatws.HistoryRequest{Before: latest.Messages[0].ID, Limit: 3})
+	require.NoError(t, err)
+	require.Len(t, older.Messages, 3)
+	assert.Equal(t, "msg-1", older.Messages[0].ID)
+	assert.False(t, older.HasMore)
+
+	_, err = client.RequestHistory(ctx, chatws.HistoryRequest{After: "unknown"})
+	assert.True(t, chatws.IsRejection(err, chatws.ErrorInvalidFrame), "got %v", err)
+
+	mockChatService.AssertExpectations(t)
+}
 
-	// Send a message
-	userMessage := domain.Message{
-		Content:    "Hello bot",
+func TestSubmitMessage(t *testing.T) {
+	conversation := &domain.Conversation{
+		ID:         "conv123",
+		CustomerID: "customer123",
+		StartedAt:  time.Now(),
+		Status:     "active",
+	}
+	mockChatService := new(MockChatService)
+	mockChatService.On("CreateConversation", "customer123").Return(conversation, nil)
+	mockChatService.On("GetMessages", mock.Anything).Return(&domain.MessagePage{Messages: []domain.Message{}}, nil)
+	mockChatService.On("SaveMessage", mock.MatchedBy(func(msg *domain.Message) bool {
+		return msg.Content == "Hello over REST"
+	})).Return(nil).Run(func(args mock.Arguments) {
+		args.Get(0).(*domain.Message).ID = "rest-msg-1"
+	})
+	mockChatService.On("SaveMessage", mock.Anything).Return(errors.New("database down"))
+
+	processed := make(chan *domain.Message, 1)
+	generations := make(chan context.Context, 1)
+	mockBotService := new(MockBotService)
+	mockBotService.On("ProcessMessage", mock.Anything, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		generations <- args.Get(0).(context.Context)
+		processed <- args.Get(1).(*domain.Message)
+	})
+
+	hub := webSock.NewHub(mockChatService, mockBotService)
+	go hub.Run()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		webSock.ServeWS(hub, w, r)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	wsURL := strings.Replace(server.URL, "http://", "ws://", 1) + "?user_id=user123&customer_id=customer123"
+	client, err := chatws.Dial(ctx, wsURL, nil)
+	require.NoError(t, err)
+	defer client.Close()
+	_, err = client.Expect(ctx, chatws.TypeHistory)
+	require.NoError(t, err)
+
+	message := &domain.Message{
+		Content:    "Hello over REST",
 		UserID:     "user123",
 		CustomerID: "customer123",
 		Type:       domain.UserMessage,
+		Timestamp:  time.Now(),
+	}
+	require.NoError(t, hub.SubmitMessage(message))
+	assert.Equal(t, "rest-msg-1", message.ID)
+
+	// The bot answers it like a socket message, under a context cancelled
+	// when the customer's connection goes
+	select {
+	case msg := <-processed:
+		assert.Equal(t, "Hello over REST", msg.Content)
+		assert.Equal(t, "user123", msg.UserID)
+		assert.NotNil(t, (<-generations).Done())
+	case <-ctx.Done():
+		t.Fatal("bot did not process the message")
 	}
-	err = conn.WriteJSON(userMessage)
-	assert.NoError(t, err)
-
-	// Read and discard the echo of our own message
-	var echoResponse domain.Message
-	err = conn.ReadJSON(&echoResponse)
-	assert.NoError(t, err)
-	assert.Equal(t, "Hello bot", echoResponse.Content) // Optional verification
-
-	// Allow time for processing
-	time.Sleep(100 * time.Millisecond)
-
-	// Verify expectations
-	mockBotService.AssertExpectations(t)
-
-	// Simulate bot response coming through the message subscription
-	if messageHandler != nil {
-		botResponse := &domain.Message{
-			ID:         "bot-msg-123",
-			Content:    "Hello human, how can I help?",
-			UserID:     "bot-1",
+
+	// And the customer's open connections are shown it
+	frame, err := client.Expect(ctx, chatws.TypeMessage)
+	require.NoError(t, err)
+	assert.Equal(t, "rest-msg-1", frame.ID)
+
+	t.Run("storage failures are returned", func(t *testing.T) {
+		err := hub.SubmitMessage(&domain.Message{
+			Content:    "Lost",
+			UserID:     "user123",
 			CustomerID: "customer123",
-			Type:       domain.BotMessage,
+			Type:       domain.UserMessage,
+		})
+		assert.EqualError(t, err, "database down")
+	})
+
+	t.Run("customers with no connection keep no bot work to cancel", func(t *testing.T) {
+		require.NoError(t, hub.SubmitMessage(&domain.Message{
+			Content:    "Hello over REST",
+			UserID:     "user456",
+			CustomerID: "customer456",
+			Type:       domain.UserMessage,
 			Timestamp:  time.Now(),
+		}))
+		select {
+		case <-processed:
+			assert.Nil(t, (<-generations).Done())
+		case <-ctx.Done():
+			t.Fatal("bot did not process the message")
 		}
-		messageHandler(botResponse)
+	})
+}
 
-		// Now read the bot's response (second message)
-		var botResponseReceived domain.Message
-		err = conn.ReadJSON(&botResponseReceived)
-		assert.NoError(t, err)
-		assert.Equal(t, domain.BotMessage, botResponseReceived.Type)
-		assert.Equal(t, "Hello human, how can I help?", botResponseReceived.Content)
+func TestMessagesDeliveredOnce(t *testing.T) {
+	conversation := &domain.Conversation{
+		ID:         "conv123",
+		CustomerID: "customer123",
+		StartedAt:  time.Now(),
+		Status:     "active",
 	}
+	mockChatService := new(MockChatService)
+	mockChatService.On("CreateConversation", "customer123").Return(conversation, nil)
+	mockChatService.On("GetMessages", mock.Anything).Return(&domain.MessagePage{Messages: []domain.Message{}}, nil)
+
+	var messageHandler func(*domain.Message)
+	mockChatService.On("SubscribeToMessages", mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		messageHandler = args.Get(0).(func(*domain.Message))
+	})
+
+	hub := webSock.NewHub(mockChatService, new(MockBotService))
+	require.NoError(t, hub.SubscribeToBotMessages())
+	go hub.Run()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		webSock.ServeWS(hub, w, r)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	wsURL := strings.Replace(server.URL, "http://", "ws://", 1) + "?user_id=user123&customer_id=customer123"
+	client, err := chatws.Dial(ctx, wsURL, nil)
+	require.NoError(t, err)
+	defer client.Close()
+	_, err = client.Expect(ctx, chatws.TypeHistory)
+	require.NoError(t, err)
+
+	reply := &domain.Message{
+		ID:         "bot-msg-1",
+		Content:    "Delivered directly",
+		UserID:     "bot-1",
+		CustomerID: "customer123",
+		Type:       domain.BotMessage,
+		Timestamp:  time.Now(),
+	}
+	notice := &domain.Message{
+		ID:         "notice-1",
+		Content:    "From another replica",
+		UserID:     "system",
+		CustomerID: "customer123",
+		Type:       domain.SystemMessage,
+		Timestamp:  time.Now(),
+	}
+
+	// The direct delivery and the broker's copy of the same reply, then a
+	// notice the broker redelivers
+	hub.SendBotComplete(reply)
+	messageHandler(reply)
+	messageHandler(notice)
+	messageHandler(notice)
+	hub.SendSystemMessage(&domain.Message{
+		ID:         "notice-2",
+		Content:    "Last",
+		UserID:     "system",
+		CustomerID: "customer123",
+		Type:       domain.SystemMessage,
+		Timestamp:  time.Now(),
+	})
+
+	var ids []string
+	for len(ids) < 3 {
+		frame, err := client.Next(ctx)
+		require.NoError(t, err)
+		if frame.Type == chatws.TypeBotComplete || frame.Type == chatws.TypeSystem {
+			ids = append(ids, frame.ID)
+		}
+	}
+	assert.Equal(t, []string{"bot-msg-1", "notice-1", "notice-2"}, ids)
+}
+
+func TestBotBusy(t *testing.T) {
+	conversation := &domain.Conversation{
+		ID:         "conv123",
+		CustomerID: "customer123",
+		StartedAt:  time.Now(),
+		Status:     "active",
+	}
+	mockChatService := new(MockChatService)
+	mockChatService.On("CreateConversation", "customer123").Return(conversation, nil)
+	mockChatService.On("GetMessages", mock.Anything).Return(&domain.MessagePage{Messages: []domain.Message{}}, nil)
+	mockChatService.On("SaveMessage", mock.MatchedBy(func(msg *domain.Message) bool {
+		return msg.Type == domain.UserMessage
+	})).Return(nil).Run(func(args mock.Arguments) {
+		args.Get(0).(*domain.Message).ID = "msg-1"
+	})
+	mockChatService.On("SaveMessage", mock.MatchedBy(func(msg *domain.Message) bool {
+		return msg.Type == domain.SystemMessage
+	})).Return(nil).Run(func(args mock.Arguments) {
+		args.Get(0).(*domain.Message).ID = "notice-1"
+	})
+
+	// The bot's queue stays full
+	mockBotService := new(MockBotService)
+	mockBotService.On("ProcessMessage", mock.Anything, mock.Anything).Return(domain.ErrBotBusy)
+
+	hub := webSock.NewHub(mockChatService, mockBotService)
+	go hub.Run()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		webSock.ServeWS(hub, w, r)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	wsURL := strings.Replace(server.URL, "http://", "ws://", 1) + "?user_id=user123&customer_id=customer123"
+	client, err := chatws.Dial(ctx, wsURL, nil)
+	require.NoError(t, err)
+	defer client.Close()
+	_, err = client.Expect(ctx, chatws.TypeHistory)
+	require.NoError(t, err)
+
+	// The message is still stored and acknowledged, then the customer is
+	// told the bot could not take it
+	_, err = client.Send("Hello")
+	require.NoError(t, err)
+
+	frame, err := client.Expect(ctx, chatws.TypeSystem)
+	require.NoError(t, err)
+	var notice domain.Message
+	require.NoError(t, frame.Decode(&notice))
+	assert.Equal(t, "notice-1", notice.ID)
+	assert.Equal(t, "conv123", notice.Metadata["conversation_id"])
+	assert.Equal(t, "msg-1", notice.Metadata["reply_to"])
+}
+
+func TestMessageAttachments(t *testing.T) {
+	conversation := &domain.Conversation{
+		ID:         "conv123",
+		CustomerID: "customer123",
+		StartedAt:  time.Now(),
+		Status:     "active",
+	}
+	mockChatService := new(MockChatService)
+	mockChatService.On("CreateConversation", "customer123").Return(conversation, nil)
+	mockChatService.On("GetMessages", mock.Anything).Return(&domain.MessagePage{Messages: []domain.Message{}}, nil)
+	mockChatService.On("SaveMessage", mock.MatchedBy(func(msg *domain.Message) bool {
+		return len(msg.Attachments) == 1 && msg.Attachments[0].ID == "upload-1"
+	})).Return(nil).Run(func(args mock.Arguments) {
+		msg := args.Get(0).(*domain.Message)
+		msg.ID = "msg-1"
+		msg.Attachments[0].FileName = "broken.jpg"
+	})
+	mockChatService.On("SaveMessage", mock.Anything).
+		Return(fmt.Errorf("%w: unknown attachment upload-2", domain.ErrInvalidAttachment))
+
+	// Nothing in the messages for the bot to answer
+	mockBotService := new(MockBotService)
+
+	hub := webSock.NewHub(mockChatService, mockBotService)
+	go hub.Run()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		webSock.ServeWS(hub, w, r)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	wsURL := strings.Replace(server.URL, "http://", "ws://", 1) + "?user_id=user123&customer_id=customer123"
+	client, err := chatws.Dial(ctx, wsURL, nil)
+	require.NoError(t, err)
+	defer client.Close()
+	_, err = client.Expect(ctx, chatws.TypeHistory)
+	require.NoError(t, err)
+
+	// A message may carry only attachments
+	id, err := client.SendWithAttachments("", []string{"upload-1"})
+	require.NoError(t, err)
+	frame, err := client.Expect(ctx, chatws.TypeAck)
+	require.NoError(t, err)
+	assert.Equal(t, id, frame.ID)
+
+	// Attachments the service will not take are rejected like bad messages
+	id, err = client.SendWithAttachments("See this", []string{"upload-2"})
+	require.NoError(t, err)
+	frame, err = client.Expect(ctx, chatws.TypeError)
+	require.NoError(t, err)
+	assert.Equal(t, id, frame.ID)
+	var rejection chatws.Error
+	require.NoError(t, frame.Decode(&rejection))
+	assert.Equal(t, chatws.ErrorInvalidMessage, rejection.Code)
+
+	_, err = client.SendWithAttachments("", nil)
+	require.NoError(t, err)
+	frame, err = client.Expect(ctx, chatws.TypeError)
+	require.NoError(t, err)
+	require.NoError(t, frame.Decode(&rejection))
+	assert.Equal(t, chatws.ErrorInvalidMessage, rejection.Code)
+
+	mockBotService.AssertNotCalled(t, "ProcessMessage", mock.Anything, mock.Anything)
+}
+
+// fakeSurvey keeps one response per conversation
+type fakeSurvey struct {
+	responses map[string]domain.SatisfactionResponse
+}
+
+func (s *fakeSurvey) Survey(conversationID string) domain.Survey {
+	return domain.Survey{ConversationID: conversationID, Question: "How did we do?", MinRating: 1, MaxRating: 5}
+}
+
+func (s *fakeSurvey) SubmitSurvey(ctx context.Context, response *domain.SatisfactionResponse) error {
+	if response.Rating < 1 || response.Rating > 5 {
+		return domain.ErrInvalidSurvey
+	}
+	if _, ok := s.responses[response.ConversationID]; ok {
+		return domain.ErrSurveyAnswered
+	}
+	response.ID = "response-1"
+	s.responses[response.ConversationID] = *response
+	return nil
+}
+
+var _ ports.SatisfactionSurvey = (*fakeSurvey)(nil)
+
+// fakeConversationEvents hands the hub's handler to the test
+type fakeConversationEvents struct {
+	handler func(*domain.ConversationEvent)
+}
+
+func (e *fakeConversationEvents) SubscribeToConversationEvents(handler func(*domain.ConversationEvent)) error {
+	e.handler = handler
+	return nil
+}
+
+func TestSatisfactionSurvey(t *testing.T) {
+	conversation := &domain.Conversation{
+		ID:         "conv123",
+		CustomerID: "customer123",
+		StartedAt:  time.Now(),
+		Status:     "active",
+	}
+	mockChatService := new(MockChatService)
+	mockChatService.On("CreateConversation", "customer123").Return(conversation, nil)
+	mockChatService.On("GetMessages", mock.Anything).Return(&domain.MessagePage{Messages: []domain.Message{}}, nil)
+
+	hub := webSock.NewHub(mockChatService, new(MockBotService))
+	survey := &fakeSurvey{responses: make(map[string]domain.SatisfactionResponse)}
+	hub.SetSurvey(survey)
+	events := &fakeConversationEvents{}
+	require.NoError(t, hub.SubscribeToConversationEvents(events))
+	go hub.Run()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		webSock.ServeWS(hub, w, r)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	wsURL := strings.Replace(server.URL, "http://", "ws://", 1) + "?user_id=user123&customer_id=customer123"
+	client, err := chatws.Dial(ctx, wsURL, nil)
+	require.NoError(t, err)
+	defer client.Close()
+	_, err = client.Expect(ctx, chatws.TypeHistory)
+	require.NoError(t, err)
+
+	// Only closing the conversation asks for a rating
+	events.handler(&domain.ConversationEvent{
+		Type:           domain.ConversationReopenedEvent,
+		ConversationID: "conv123",
+		CustomerID:     "customer123",
+	})
+	events.handler(&domain.ConversationEvent{
+		Type:           domain.ConversationClosedEvent,
+		ConversationID: "conv123",
+		CustomerID:     "customer123",
+		Reason:         domain.CloseReasonIdle,
+	})
+	frame, err := client.Expect(ctx, chatws.TypeSurvey)
+	require.NoError(t, err)
+	var asked chatws.Survey
+	require.NoError(t, frame.Decode(&asked))
+	assert.Equal(t, "conv123", asked.ConversationID)
+	assert.Equal(t, 1, asked.MinRating)
+	assert.Equal(t, 5, asked.MaxRating)
+
+	t.Run("out of range ratings are rejected", func(t *testing.T) {
+		id, err := client.AnswerSurvey("", 9, "")
+		require.NoError(t, err)
+		_, err = client.AwaitAck(ctx, id)
+		var rejection *chatws.Error
+		require.ErrorAs(t, err, &rejection)
+		assert.Equal(t, chatws.ErrorInvalidFrame, rejection.Code)
+	})
+
+	t.Run("the rating is stored for the connection's conversation", func(t *testing.T) {
+		id, err := client.AnswerSurvey("", 5, "Great help")
+		require.NoError(t, err)
+		ack, err := client.AwaitAck(ctx, id)
+		require.NoError(t, err)
+		assert.Equal(t, "response-1", ack.MessageID)
+
+		stored := survey.responses["conv123"]
+		assert.Equal(t, "customer123", stored.CustomerID)
+		assert.Equal(t, 5, stored.Rating)
+		assert.Equal(t, "Great help", stored.Comment)
+	})
+
+	t.Run("a conversation is rated once", func(t *testing.T) {
+		id, err := client.AnswerSurvey("conv123", 1, "")
+		require.NoError(t, err)
+		_, err = client.AwaitAck(ctx, id)
+		var rejection *chatws.Error
+		require.ErrorAs(t, err, &rejection)
+		assert.Equal(t, chatws.ErrorRejected, rejection.Code)
+	})
+}
+
+func TestConversationFollowed(t *testing.T) {
+	conversation := &domain.Conversation{ID: "conv123", CustomerID: "customer123", Status: "active"}
+	mockChatService := new(MockChatService)
+	mockChatService.On("CreateConversation", "customer123").Return(conversation, nil)
+	mockChatService.On("GetMessages", mock.Anything).Return(&domain.MessagePage{Messages: []domain.Message{}}, nil)
+	mockChatService.On("UpdateDeliveryStatus", mock.Anything).Return([]string{"bot-msg-1"}, nil)
+
+	// The conversation was closed while idle, so the message starts another
+	mockChatService.On("SaveMessage", mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		message := args.Get(0).(*domain.Message)
+		message.ID = "user-msg-1"
+		message.Metadata["conversation_id"] = "conv456"
+	})
+	mockBotService := new(MockBotService)
+	mockBotService.On("ProcessMessage", mock.Anything, mock.Anything).Return(nil)
+
+	hub := webSock.NewHub(mockChatService, mockBotService)
+	survey := &fakeSurvey{responses: make(map[string]domain.SatisfactionResponse)}
+	hub.SetSurvey(survey)
+	go hub.Run()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		webSock.ServeWS(hub, w, r)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	// The same customer on two devices
+	wsURL := strings.Replace(server.URL, "http://", "ws://", 1) + "/ws?user_id=user123&customer_id=customer123"
+	phone, err := chatws.Dial(ctx, wsURL, nil)
+	require.NoError(t, err)
+	defer phone.Close()
+	laptop, err := chatws.Dial(ctx, wsURL, nil)
+	require.NoError(t, err)
+	defer laptop.Close()
+	_, err = laptop.Expect(ctx, chatws.TypePresence)
+	require.NoError(t, err)
+
+	id, err := phone.Send("Hello again")
+	require.NoError(t, err)
+	_, err = phone.AwaitAck(ctx, id)
+	require.NoError(t, err)
+
+	// Both connections are now in the new conversation
+	assert.Empty(t, hub.Presence("conv123"))
+	require.Len(t, hub.Presence("conv456"), 1)
+
+	_, err = laptop.RequestHistory(ctx, chatws.HistoryRequest{})
+	require.NoError(t, err)
+	mockChatService.AssertCalled(t, "GetMessages", domain.MessageQuery{ConversationID: "conv456"})
+
+	id, err = laptop.SendReceipt(chatws.StatusRead, "bot-msg-1")
+	require.NoError(t, err)
+	_, err = laptop.AwaitAck(ctx, id)
+	require.NoError(t, err)
+	mockChatService.AssertCalled(t, "UpdateDeliveryStatus", mock.MatchedBy(func(receipt *domain.Receipt) bool {
+		return receipt.ConversationID == "conv456"
+	}))
+
+	id, err = laptop.AnswerSurvey("", 4, "")
+	require.NoError(t, err)
+	_, err = laptop.AwaitAck(ctx, id)
+	require.NoError(t, err)
+	assert.Contains(t, survey.responses, "conv456")
+}
+
+func TestMessageChanges(t *testing.T) {
+	conversation := &domain.Conversation{
+		ID:         "conv123",
+		CustomerID: "customer123",
+		StartedAt:  time.Now(),
+		Status:     "active",
+	}
+	mockChatService := new(MockChatService)
+	mockChatService.On("CreateConversation", "customer123").Return(conversation, nil)
+	mockChatService.On("GetMessages", mock.Anything).Return(&domain.MessagePage{Messages: []domain.Message{}}, nil)
+
+	hub := webSock.NewHub(mockChatService, new(MockBotService))
+	events := &fakeConversationEvents{}
+	require.NoError(t, hub.SubscribeToConversationEvents(events))
+	go hub.Run()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		webSock.ServeWS(hub, w, r)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	wsURL := strings.Replace(server.URL, "http://", "ws://", 1) + "?user_id=user123&customer_id=customer123"
+	client, err := chatws.Dial(ctx, wsURL, nil)
+	require.NoError(t, err)
+	defer client.Close()
+	_, err = client.Expect(ctx, chatws.TypeHistory)
+	require.NoError(t, err)
+
+	changedAt := time.Now().UTC().Truncate(time.Second)
+	events.handler(&domain.ConversationEvent{
+		Type:           domain.MessageUpdatedEvent,
+		ConversationID: "conv123",
+		CustomerID:     "customer123",
+		Message: &domain.Message{
+			ID:         "msg-1",
+			Content:    "My card is [redacted]",
+			UserID:     "user123",
+			CustomerID: "customer123",
+			Type:       domain.UserMessage,
+			EditedAt:   &changedAt,
+		},
+		OccurredAt: changedAt,
+	})
+	frame, err := client.Expect(ctx, chatws.TypeMessageUpdated)
+	require.NoError(t, err)
+	assert.Equal(t, "msg-1", frame.ID)
+	var updated chatws.Message
+	require.NoError(t, frame.Decode(&updated))
+	assert.Equal(t, "My card is [redacted]", updated.Content)
+	require.NotNil(t, updated.EditedAt)
+	assert.True(t, changedAt.Equal(*updated.EditedAt))
+
+	events.handler(&domain.ConversationEvent{
+		Type:           domain.MessageDeletedEvent,
+		ConversationID: "conv123",
+		CustomerID:     "customer123",
+		Message:        &domain.Message{ID: "msg-1", CustomerID: "customer123", DeletedAt: &changedAt},
+		OccurredAt:     changedAt,
+	})
+	frame, err = client.Expect(ctx, chatws.TypeMessageDeleted)
+	require.NoError(t, err)
+	assert.Equal(t, "msg-1", frame.ID)
+	var deleted chatws.MessageDeleted
+	require.NoError(t, frame.Decode(&deleted))
+	assert.Equal(t, "conv123", deleted.ConversationID)
+	assert.True(t, changedAt.Equal(deleted.DeletedAt))
 }
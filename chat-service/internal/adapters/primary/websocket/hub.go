@@ -2,176 +2,479 @@
 package websocket
 
 import (
+	"chat-service/internal/cache"
 	"chat-service/internal/core/domain"
 	"chat-service/internal/core/ports"
+	"chat-service/pkg/chatws"
 	"context"
-	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
+	"sync"
+	"time"
 )
 
-// Hub maintains the set of active clients and broadcasts messages
+// Hub maintains the set of active clients and broadcasts messages. Every
+// method is safe to call from any goroutine: clients are kept in a sharded
+// registry and frames are queued on each client without blocking, so no
+// single loop serializes the connections or waits on the database.
 type Hub struct {
-	// Registered clients
-	clients map[*Client]bool
-
-	// Register requests from clients
-	register chan *Client
-
-	// Unregister requests from clients
-	unregister chan *Client
-
-	// Inbound messages from clients
-	broadcast chan []byte
+	// Connected clients by customer and conversation
+	clients *registry
 
 	// Chat service
 	chatService ports.ChatService
 
 	// Add bot agent
 	botAgent ports.BotService // New field for bot agent
+
+	// Per-customer contexts cancelled when the customer's last client leaves
+	generationMutex sync.Mutex
+	generations     map[string]*generation
+
+	// Agent takeover of conversations
+	takeover ports.AgentTakeover
+
+	// Satisfaction survey sent when a conversation closes
+	survey ports.SatisfactionSurvey
+
+	// Participants of each conversation by role and user, and whether each
+	// client is counted in them
+	presenceMutex sync.RWMutex
+	presence      map[string]map[string]*presenceEntry
+
+	// Indicators still typing, by typingKey
+	typingMutex sync.Mutex
+	typists     map[string]*typist
+
+	// How many of the latest messages new connections are sent
+	historyLimit int
+
+	// Hides personal data in the messages logged
+	redactor ports.TextRedactor
+
+	// IDs of the messages recently delivered, so a message published by
+	// another replica or redelivered by the broker is not delivered twice
+	delivered *cache.Cache[struct{}]
+}
+
+// How many delivered message IDs are remembered, and for how long
+const (
+	deliveredCapacity = 10000
+	deliveredTTL      = 10 * time.Minute
+)
+
+// What the customer is told when the bot's queue stays full
+const botBusyNotice = "Our assistant is busy right now, please try again shortly."
+
+// generation tracks in-flight bot work for one customer
+type generation struct {
+	ctx    context.Context
+	cancel context.CancelFunc
 }
 
+var _ ports.MessageHub = (*Hub)(nil)
+var _ ports.PresenceTracker = (*Hub)(nil)
+var _ ports.MessageSubmitter = (*Hub)(nil)
+
 // NewHub creates a new Hub
 func NewHub(chatService ports.ChatService, botAgent ports.BotService) *Hub {
 	return &Hub{
-		register:    make(chan *Client),
-		unregister:  make(chan *Client),
-		clients:     make(map[*Client]bool),
-		broadcast:   make(chan []byte),
+		clients:     newRegistry(),
 		chatService: chatService,
 		botAgent:    botAgent, // Include bot agent
+		generations: make(map[string]*generation),
+		presence:    make(map[string]map[string]*presenceEntry),
+		typists:     make(map[string]*typist),
+
+		historyLimit: domain.DefaultMessagePageSize,
+		delivered:    cache.New[struct{}](deliveredCapacity, deliveredTTL),
+	}
+}
+
+// SetTakeover enables agent clients
+func (h *Hub) SetTakeover(takeover ports.AgentTakeover) {
+	h.takeover = takeover
+}
+
+// SetSurvey has customers asked to rate their conversations when they close
+func (h *Hub) SetSurvey(survey ports.SatisfactionSurvey) {
+	h.survey = survey
+}
+
+// SetHistoryLimit sets how many of the latest messages new connections are
+// sent
+func (h *Hub) SetHistoryLimit(limit int) {
+	h.historyLimit = limit
+}
+
+// SetRedactor has personal data hidden in the messages logged. Without one
+// only their length is logged.
+func (h *Hub) SetRedactor(redactor ports.TextRedactor) {
+	h.redactor = redactor
+}
+
+// loggable is what is logged of a message's content
+func (h *Hub) loggable(content string) string {
+	if h.redactor == nil {
+		return fmt.Sprintf("(%d characters)", len(content))
 	}
+	return h.redactor.Redact(content)
 }
 
-// Run starts the hub
+// Run stops typing indicators that were not refreshed in time. It never
+// returns.
 func (h *Hub) Run() {
-	for {
-		select {
-		case client := <-h.register:
-			h.clients[client] = true
-
-			// Always get chat history for newly connected client
-			history, err := h.chatService.GetChatHistory(client.customerID)
-			// Send history only if there are messages and no error
-			if err == nil && len(history) > 0 {
-				historyJSON, err := json.Marshal(history)
-				if err == nil {
-					client.send <- historyJSON
-				}
-			}
+	sweep := time.NewTicker(typingSweepInterval)
+	defer sweep.Stop()
 
-		case client := <-h.unregister:
-			if _, ok := h.clients[client]; ok {
-				delete(h.clients, client)
-				close(client.send)
-			}
+	for now := range sweep.C {
+		h.expireTyping(now)
+	}
+}
+
+// Connections counts the clients connected to this replica
+func (h *Hub) Connections() int {
+	return h.clients.len()
+}
+
+// register sends a new client the history loaded for it and adds it to the
+// registry. The history is queued first so it is always the first frame.
+func (h *Hub) register(client *Client) {
+	// Always send the history, even if empty, so the client knows the
+	// initial load is done
+	client.enqueue(encodeFrame(chatws.TypeHistory, "", client.history))
+	client.history = nil
+
+	h.clients.add(client)
+	h.joinPresence(client)
+}
+
+// unregister drops a client once its connection is gone. It may be called
+// more than once; only the first call has any effect.
+func (h *Hub) unregister(client *Client) {
+	if !h.clients.remove(client) {
+		return
+	}
+	client.close()
+
+	if client.isAgent() {
+		h.releaseAgentIfGone(client)
+	} else {
+		h.cancelGenerationIfIdle(client.customerID)
+	}
+	h.stopTypingFrom(client)
+	h.leavePresence(client)
+}
 
-		case message := <-h.broadcast:
-			// Process and save the message
-			var msg domain.Message
-			if err := json.Unmarshal(message, &msg); err == nil {
-				// Save the message
-				if err := h.chatService.SaveMessage(&msg); err != nil {
-					log.Printf("Error saving message: %v", err)
-					continue
-				}
-
-				// If it's a user message, process with bot agent
-				if msg.Type == domain.UserMessage {
-					log.Printf("HUB: Processing user message: %s from user: %s, customer: %s",
-						msg.Content, msg.UserID, msg.CustomerID)
-
-					// Process with bot agent synchronously for testing
-					if err := h.botAgent.ProcessMessage(context.Background(), &msg); err != nil {
-						log.Printf("Error processing message with bot: %v", err)
-					} else {
-						log.Printf("HUB: Bot successfully processed message")
-					}
-				}
+// accept stores a message from a client, or submitted without one, tells
+// the sender it is stored and has the bot answer it. It runs on the sender's
+// goroutine, so one slow save holds up nobody else.
+func (h *Hub) accept(sender *Client, frameID string, msg *domain.Message) error {
+	// Save the message, then tell the sender it is stored
+	if err := h.chatService.SaveMessage(msg); err != nil {
+		log.Printf("Error saving message: %v", err)
+		if sender != nil {
+			if errors.Is(err, domain.ErrInvalidAttachment) {
+				sender.enqueue(errorFrame(frameID, chatws.ErrorInvalidMessage, err.Error()))
+			} else {
+				sender.enqueue(errorFrame(frameID, chatws.ErrorInternal, "message could not be stored"))
 			}
+		}
+		return err
+	}
+	h.delivered.Add(msg.ID, struct{}{})
+	h.followConversation(msg)
+	if sender != nil {
+		sender.enqueue(encodeFrame(chatws.TypeAck, frameID, chatws.Ack{
+			MessageID: msg.ID,
+			Timestamp: msg.Timestamp,
+		}))
+		h.stopTypingFrom(sender)
+	}
 
-			// Broadcast original message to all clients
-			// (Bot response will come through message subscription)
-			for client := range h.clients {
-				// Only send to clients in the same conversation
-				if client.customerID == msg.CustomerID {
-					if client.userID == msg.UserID && msg.Type == domain.UserMessage {
-						// Skip sending to the original sender
-						continue
-					}
-					select {
-					case client.send <- message:
-					default:
-						close(client.send)
-						delete(h.clients, client)
-					}
-				}
+	// If it's a user message, process with bot agent. Messages with only
+	// attachments give the bot nothing to read.
+	if msg.Type == domain.UserMessage && msg.Content != "" {
+		log.Printf("HUB: Processing user message: %s from user: %s, customer: %s",
+			h.loggable(msg.Content), msg.UserID, msg.CustomerID)
+
+		// Only queue the message for the bot, so the sender goes on reading
+		// frames while the response is generated. A full queue holds the
+		// sender back until there is room or the bot gives up on it.
+		ctx := h.generationContext(msg.CustomerID)
+		if err := h.botAgent.ProcessMessage(ctx, msg); err != nil {
+			log.Printf("Error queueing message %s for bot: %v", msg.ID, err)
+			if errors.Is(err, domain.ErrBotBusy) {
+				defer h.sendBotBusy(msg)
 			}
 		}
 	}
+
+	// Show the message on the customer's other connections
+	// (Bot response will come through message subscription)
+	h.deliverToCustomer(msg.CustomerID, encodeFrame(chatws.TypeMessage, msg.ID, msg), sender)
+	return nil
+}
+
+// sendBotBusy tells the customer the bot could not take their message
+func (h *Hub) sendBotBusy(msg *domain.Message) {
+	notice := &domain.Message{
+		Content:    botBusyNotice,
+		UserID:     "system",
+		CustomerID: msg.CustomerID,
+		Type:       domain.SystemMessage,
+		Metadata: map[string]string{
+			"conversation_id": msg.Metadata["conversation_id"],
+			"reply_to":        msg.ID,
+		},
+	}
+	if err := h.chatService.SaveMessage(notice); err != nil {
+		log.Printf("Error saving bot busy notice: %v", err)
+		return
+	}
+	h.deliverMessage(notice, nil)
+}
+
+// SubmitMessage stores a message that arrived without a connection, such as
+// one posted over REST, and has the bot answer it just as if it had been
+// sent over a socket. The customer's connections are shown the message.
+func (h *Hub) SubmitMessage(message *domain.Message) error {
+	return h.accept(nil, "", message)
 }
 
-// SubscribeToBotMessages sets up subscription for bot messages
+// SubscribeToBotMessages delivers the messages stored by other replicas,
+// such as bot responses generated there, to this replica's clients
 func (h *Hub) SubscribeToBotMessages() error {
 	return h.chatService.SubscribeToMessages(func(msg *domain.Message) {
-		// When a message comes from the subscription (e.g., bot response)
-		// broadcast it to clients in the same conversation
-		messageJSON, err := json.Marshal(msg)
-		if err != nil {
-			log.Printf("Error marshaling bot message: %v", err)
-			return
+		h.followConversation(msg)
+		h.deliverMessage(msg, nil)
+	})
+}
+
+// followConversation moves the customer's clients to the conversation a
+// message of theirs was stored in, which is a new one once the last was
+// closed. Agents stay with the conversation they joined.
+func (h *Hub) followConversation(msg *domain.Message) {
+	conversationID := msg.Metadata["conversation_id"]
+	if msg.Type != domain.UserMessage || conversationID == "" {
+		return
+	}
+	for _, client := range h.clients.customerClients(msg.CustomerID) {
+		if client.isAgent() || !h.clients.move(client, conversationID) {
+			continue
 		}
+		h.stopTypingFrom(client)
+		h.movePresence(client)
+	}
+}
 
-		for client := range h.clients {
-			// Only send to clients in the same conversation
-			if client.customerID == msg.CustomerID {
-				select {
-				case client.send <- messageJSON:
-				default:
-					close(client.send)
-					delete(h.clients, client)
-				}
+// SubscribeToConversationEvents sends the survey to the customer's clients on
+// this replica when a conversation closes, and tells them about messages
+// changed, wherever it happened
+func (h *Hub) SubscribeToConversationEvents(events ports.ConversationEventSubscriber) error {
+	return events.SubscribeToConversationEvents(func(event *domain.ConversationEvent) {
+		switch event.Type {
+		case domain.ConversationClosedEvent:
+			if h.survey != nil {
+				h.sendSurvey(event.CustomerID, h.survey.Survey(event.ConversationID))
+			}
+		case domain.MessageUpdatedEvent, domain.MessageDeletedEvent:
+			if event.Message != nil {
+				h.sendMessageChange(event)
 			}
 		}
 	})
 }
 
-// SendBotResponse sends a bot response directly to the appropriate clients
-func (h *Hub) SendBotResponse(response *domain.Message) {
-	// Debug the incoming response
-	log.Printf("HUB DEBUG: Received bot response to send: ID=%s, Content=%s, Customer=%s",
-		response.ID, response.Content, response.CustomerID)
+// sendMessageChange tells every client of a conversation that a message was
+// edited, redacted or deleted
+func (h *Hub) sendMessageChange(event *domain.ConversationEvent) {
+	message := event.Message
+	frame := encodeFrame(chatws.TypeMessageUpdated, message.ID, message)
+	if event.Type == domain.MessageDeletedEvent {
+		frame = encodeFrame(chatws.TypeMessageDeleted, message.ID, chatws.MessageDeleted{
+			ConversationID: event.ConversationID,
+			DeletedAt:      event.OccurredAt,
+		})
+	}
+	h.deliverToCustomer(event.CustomerID, frame, nil)
+}
 
-	messageJSON, err := json.Marshal(response)
-	if err != nil {
-		log.Printf("Error marshaling direct bot response: %v", err)
+// sendSurvey asks the customer's clients, but not the agents watching them,
+// to rate a conversation
+func (h *Hub) sendSurvey(customerID string, survey domain.Survey) {
+	frame := encodeFrame(chatws.TypeSurvey, "", chatws.Survey{
+		ConversationID: survey.ConversationID,
+		Question:       survey.Question,
+		MinRating:      survey.MinRating,
+		MaxRating:      survey.MaxRating,
+	})
+	if frame == nil {
 		return
 	}
+	for _, client := range h.clients.customerClients(customerID) {
+		if !client.isAgent() {
+			client.enqueue(frame)
+		}
+	}
+}
 
-	// Debug the JSON
-	log.Printf("HUB DEBUG: Marshaled response: %s", string(messageJSON))
-
-	clientCount := 0
-	for client := range h.clients {
-		// Debug client information
-		log.Printf("HUB DEBUG: Checking client with customerID=%s against response customerID=%s",
-			client.customerID, response.CustomerID)
-
-		if client.customerID == response.CustomerID {
-			clientCount++
-			select {
-			case client.send <- messageJSON:
-				log.Printf("HUB DEBUG: Successfully sent to client %p", client)
-			default:
-				log.Printf("HUB DEBUG: Failed to send to client - buffer full")
-				close(client.send)
-				delete(h.clients, client)
-			}
+// SendBotChunk relays a streamed piece of a bot response to the customer
+func (h *Hub) SendBotChunk(chunk *domain.MessageChunk) {
+	frame := encodeFrame(chatws.TypeBotChunk, chunk.MessageID, chatws.BotChunk{
+		Index:   chunk.Index,
+		Content: chunk.Content,
+	})
+	h.deliverToCustomer(chunk.CustomerID, frame, nil)
+}
+
+// SendBotComplete sends the finished bot response to the appropriate clients
+func (h *Hub) SendBotComplete(response *domain.Message) {
+	h.deliverMessage(response, nil)
+}
+
+// SendSystemMessage sends a system notice to the customer's clients
+func (h *Hub) SendSystemMessage(message *domain.Message) {
+	h.deliverMessage(message, nil)
+}
+
+// deliverMessage delivers a stored message to the customer's clients unless
+// it was already delivered, whichever way it arrived first
+func (h *Hub) deliverMessage(message *domain.Message, exclude *Client) {
+	if !h.delivered.Add(message.ID, struct{}{}) {
+		return
+	}
+	h.deliverToCustomer(message.CustomerID, messageFrame(message), exclude)
+}
+
+// deliverToCustomer queues a payload for every client of a customer, agents
+// included, except the excluded one
+func (h *Hub) deliverToCustomer(customerID string, payload []byte, exclude *Client) {
+	if payload == nil {
+		return
+	}
+	for _, client := range h.clients.customerClients(customerID) {
+		if client != exclude {
+			client.enqueue(payload)
+		}
+	}
+}
+
+// generationContext returns the context bot work for a customer runs under.
+// Only customers with a connection get one that is cancelled when they
+// leave; work for the others, such as messages posted over REST, has no
+// connection to wait for and keeps no entry.
+func (h *Hub) generationContext(customerID string) context.Context {
+	h.generationMutex.Lock()
+	defer h.generationMutex.Unlock()
+
+	gen, ok := h.generations[customerID]
+	if !ok {
+		if !h.customerConnected(customerID) {
+			return context.Background()
 		}
+		ctx, cancel := context.WithCancel(context.Background())
+		gen = &generation{ctx: ctx, cancel: cancel}
+		h.generations[customerID] = gen
 	}
+	return gen.ctx
+}
 
-	if clientCount == 0 {
-		log.Printf("HUB WARNING: No clients found for customerID=%s", response.CustomerID)
-	} else {
-		log.Printf("HUB INFO: Bot response sent to %d clients", clientCount)
+// cancelGenerationIfIdle stops bot work once a customer has no clients left.
+// Agents watching the conversation don't keep it going.
+func (h *Hub) cancelGenerationIfIdle(customerID string) {
+	h.generationMutex.Lock()
+	defer h.generationMutex.Unlock()
+
+	if h.customerConnected(customerID) {
+		return
+	}
+	if gen, ok := h.generations[customerID]; ok {
+		gen.cancel()
+		delete(h.generations, customerID)
+	}
+}
+
+// customerConnected reports whether the customer has a client of their own,
+// not counting agents
+func (h *Hub) customerConnected(customerID string) bool {
+	for _, client := range h.clients.customerClients(customerID) {
+		if !client.isAgent() {
+			return true
+		}
+	}
+	return false
+}
+
+// releaseAgentIfGone hands the conversation back to the bot once the agent in
+// control has no connection left to it, so the customer is never left
+// without anyone answering
+func (h *Hub) releaseAgentIfGone(agent *Client) {
+	for _, client := range h.clients.conversationClients(agent.conversation()) {
+		if client.agentID == agent.agentID {
+			return
+		}
+	}
+	if h.takeover == nil {
+		return
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		conversation, err := h.takeover.HandBack(ctx, agent.agentID, agent.conversation())
+		if err != nil {
+			// Another agent took over or the conversation ended
+			log.Printf("Not handing conversation %s back after agent %s left: %v",
+				agent.conversation(), agent.agentID, err)
+			return
+		}
+		h.SendModeChanged(conversation)
+	}()
+}
+
+// SendModeChanged tells every client of a conversation who is answering
+func (h *Hub) SendModeChanged(conversation *domain.Conversation) {
+	frame := encodeFrame(chatws.TypeModeChanged, "", chatws.ModeChanged{
+		ConversationID: conversation.ID,
+		Mode:           conversation.Mode,
+		AgentID:        conversation.AgentID,
+	})
+	h.deliverToCustomer(conversation.CustomerID, frame, nil)
+}
+
+// sendAgentMessage delivers an agent's message to everyone in the
+// conversation except the agent who sent it
+func (h *Hub) sendAgentMessage(message *domain.Message, sender *Client) {
+	h.deliverMessage(message, sender)
+}
+
+// sendReceipt tells everyone in the conversation but the client that sent
+// it that messages were delivered or read
+func (h *Hub) sendReceipt(receipt *domain.Receipt, sender *Client) {
+	frame := encodeFrame(chatws.TypeReceipt, "", chatws.Receipt{
+		MessageIDs: receipt.MessageIDs,
+		Status:     string(receipt.Status),
+		UserID:     receipt.UserID,
+		Timestamp:  receipt.Timestamp,
+	})
+	h.deliverToCustomer(sender.customerID, frame, sender)
+}
+
+// loadHistory reads the latest messages of a conversation for a new
+// connection, before it is registered
+func (h *Hub) loadHistory(conversationID string) *domain.MessagePage {
+	page, err := h.chatService.GetMessages(domain.MessageQuery{
+		ConversationID: conversationID,
+		Limit:          h.historyLimit,
+	})
+	if err != nil || page == nil {
+		if err != nil {
+			log.Printf("Error fetching chat history: %v", err)
+		}
+		return &domain.MessagePage{Messages: []domain.Message{}}
 	}
+	return page
 }
@@ -0,0 +1,250 @@
+package websocket
+
+import (
+	"chat-service/internal/core/domain"
+	"chat-service/pkg/chatws"
+	"sort"
+	"time"
+)
+
+// How often expired typing indicators are stopped
+const typingSweepInterval = time.Second
+
+// presenceEntry is a participant of a conversation and how many connections
+// they have open to it
+type presenceEntry struct {
+	participant domain.Participant
+	connections int
+}
+
+// typingUpdate is a typing frame from a client or an indicator from the bot
+type typingUpdate struct {
+	indicator domain.TypingIndicator
+	sender    *Client
+}
+
+// typist is someone typing, until they stop or expires passes
+type typist struct {
+	indicator domain.TypingIndicator
+	sender    *Client
+	expires   time.Time
+}
+
+// Presence returns who is connected to a conversation, earliest first. It is
+// safe to call from any goroutine.
+func (h *Hub) Presence(conversationID string) []domain.Participant {
+	h.presenceMutex.RLock()
+	defer h.presenceMutex.RUnlock()
+	return onlineParticipants(h.presence[conversationID])
+}
+
+// SendTyping relays the bot starting or stopping to type
+func (h *Hub) SendTyping(indicator *domain.TypingIndicator) {
+	h.applyTyping(typingUpdate{indicator: *indicator})
+}
+
+// joinPresence counts a new connection to its conversation. The connection
+// is sent everyone online; the others only hear about someone new. Frames
+// are queued with the lock held so they go out in the order presence
+// changed.
+func (h *Hub) joinPresence(client *Client) {
+	h.presenceMutex.Lock()
+	defer h.presenceMutex.Unlock()
+	h.countPresence(client)
+}
+
+// leavePresence drops a closed connection, telling the conversation once the
+// participant has none left
+func (h *Hub) leavePresence(client *Client) {
+	h.presenceMutex.Lock()
+	defer h.presenceMutex.Unlock()
+	h.uncountPresence(client)
+}
+
+// movePresence counts a client that moved in its new conversation instead of
+// the one it left
+func (h *Hub) movePresence(client *Client) {
+	h.presenceMutex.Lock()
+	defer h.presenceMutex.Unlock()
+	if client.presentIn == "" || client.presentIn == client.conversation() {
+		return
+	}
+	h.uncountPresence(client)
+	h.countPresence(client)
+}
+
+// countPresence adds a registered client to its conversation's presence.
+// The caller holds presenceMutex.
+func (h *Hub) countPresence(client *Client) {
+	conversationID, registered := client.membership()
+	if !registered {
+		return
+	}
+	key := client.participantKey()
+
+	participants, ok := h.presence[conversationID]
+	if !ok {
+		participants = make(map[string]*presenceEntry)
+		h.presence[conversationID] = participants
+	}
+	entry, present := participants[key]
+	if !present {
+		entry = &presenceEntry{participant: domain.Participant{
+			UserID: client.userID,
+			Role:   client.role(),
+			Since:  time.Now(),
+		}}
+		participants[key] = entry
+	}
+	entry.connections++
+	online := onlineParticipants(participants)
+
+	client.presentIn = conversationID
+	client.enqueue(presenceFrame(conversationID, "", nil, online))
+	if !present {
+		h.deliverToCustomer(client.customerID,
+			presenceFrame(conversationID, chatws.PresenceJoin, &entry.participant, online), client)
+	}
+}
+
+// uncountPresence removes a client from the presence of the conversation it
+// was counted in. The caller holds presenceMutex.
+func (h *Hub) uncountPresence(client *Client) {
+	conversationID := client.presentIn
+	if conversationID == "" {
+		return
+	}
+	client.presentIn = ""
+	key := client.participantKey()
+
+	participants := h.presence[conversationID]
+	entry, ok := participants[key]
+	if !ok {
+		return
+	}
+	entry.connections--
+	if entry.connections > 0 {
+		return
+	}
+	delete(participants, key)
+	if len(participants) == 0 {
+		delete(h.presence, conversationID)
+	}
+	online := onlineParticipants(participants)
+
+	if len(online) > 0 {
+		h.deliverToCustomer(client.customerID,
+			presenceFrame(conversationID, chatws.PresenceLeave, &entry.participant, online), nil)
+	}
+}
+
+// applyTyping starts, refreshes or stops a typing indicator. Only changes are
+// relayed, so clients repeating typing_start don't flood the conversation.
+func (h *Hub) applyTyping(update typingUpdate) {
+	h.typingMutex.Lock()
+	defer h.typingMutex.Unlock()
+
+	key := typingKey(&update.indicator)
+	current, typing := h.typists[key]
+
+	if !update.indicator.Typing {
+		if typing {
+			h.stopTyping(key, current)
+		}
+		return
+	}
+
+	h.typists[key] = &typist{
+		indicator: update.indicator,
+		sender:    update.sender,
+		expires:   time.Now().Add(domain.TypingTTL),
+	}
+	if !typing {
+		h.relayTyping(&update.indicator, update.sender)
+	}
+}
+
+// expireTyping stops indicators that were not refreshed in time
+func (h *Hub) expireTyping(now time.Time) {
+	h.typingMutex.Lock()
+	defer h.typingMutex.Unlock()
+
+	for key, t := range h.typists {
+		if now.After(t.expires) {
+			h.stopTyping(key, t)
+		}
+	}
+}
+
+// stopTypingFrom stops indicators started by a client, once it sent its
+// message or went away
+func (h *Hub) stopTypingFrom(client *Client) {
+	h.typingMutex.Lock()
+	defer h.typingMutex.Unlock()
+
+	for key, t := range h.typists {
+		if t.sender == client {
+			h.stopTyping(key, t)
+		}
+	}
+}
+
+// stopTyping forgets an indicator and tells the conversation it stopped. The
+// typing lock must be held.
+func (h *Hub) stopTyping(key string, t *typist) {
+	delete(h.typists, key)
+	stopped := t.indicator
+	stopped.Typing = false
+	h.relayTyping(&stopped, t.sender)
+}
+
+// relayTyping tells everyone in the conversation but the sender who is typing
+func (h *Hub) relayTyping(indicator *domain.TypingIndicator, sender *Client) {
+	frameType := chatws.TypeTypingStop
+	if indicator.Typing {
+		frameType = chatws.TypeTypingStart
+	}
+	h.deliverToCustomer(indicator.CustomerID, encodeFrame(frameType, "", chatws.Typing{
+		ConversationID: indicator.ConversationID,
+		UserID:         indicator.UserID,
+		Role:           indicator.Role,
+		Name:           indicator.Name,
+	}), sender)
+}
+
+// typingKey identifies one participant typing in one customer's conversation
+func typingKey(indicator *domain.TypingIndicator) string {
+	return indicator.CustomerID + "/" + indicator.Role + "/" + indicator.UserID
+}
+
+// onlineParticipants lists participants, earliest first
+func onlineParticipants(participants map[string]*presenceEntry) []domain.Participant {
+	online := make([]domain.Participant, 0, len(participants))
+	for _, entry := range participants {
+		online = append(online, entry.participant)
+	}
+	sort.Slice(online, func(i, j int) bool {
+		if !online[i].Since.Equal(online[j].Since) {
+			return online[i].Since.Before(online[j].Since)
+		}
+		return online[i].UserID < online[j].UserID
+	})
+	return online
+}
+
+// presenceFrame builds a presence frame for the participants online
+func presenceFrame(conversationID, event string, changed *domain.Participant, online []domain.Participant) []byte {
+	presence := chatws.Presence{
+		ConversationID: conversationID,
+		Event:          event,
+		Online:         make([]chatws.Participant, len(online)),
+	}
+	if changed != nil {
+		participant := chatws.Participant(*changed)
+		presence.Participant = &participant
+	}
+	for i, participant := range online {
+		presence.Online[i] = chatws.Participant(participant)
+	}
+	return encodeFrame(chatws.TypePresence, "", presence)
+}
@@ -0,0 +1,138 @@
+// internal/adapters/primary/websocket/presence_test.go
+package websocket_test
+
+import (
+	webSock "chat-service/internal/adapters/primary/websocket"
+	"chat-service/internal/core/domain"
+	"chat-service/pkg/chatws"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// expectPresence returns the next presence frame for the given event, or
+// the connect snapshot when event is empty
+func expectPresence(t *testing.T, ctx context.Context, client *chatws.Client, event string) chatws.Presence {
+	t.Helper()
+	for {
+		frame, err := client.Expect(ctx, chatws.TypePresence)
+		require.NoError(t, err)
+		var presence chatws.Presence
+		require.NoError(t, frame.Decode(&presence))
+		if presence.Event == event {
+			return presence
+		}
+	}
+}
+
+// expectTyping returns the next typing frame of the given type
+func expectTyping(t *testing.T, ctx context.Context, client *chatws.Client, frameType string) chatws.Typing {
+	t.Helper()
+	frame, err := client.Expect(ctx, frameType)
+	require.NoError(t, err)
+	var typing chatws.Typing
+	require.NoError(t, frame.Decode(&typing))
+	return typing
+}
+
+func TestPresenceAndTyping(t *testing.T) {
+	conversation := &domain.Conversation{
+		ID:         "conv123",
+		CustomerID: "customer123",
+		StartedAt:  time.Now(),
+		Status:     "active",
+		Mode:       domain.ConversationModeBot,
+	}
+	mockChatService := new(MockChatService)
+	mockChatService.On("CreateConversation", "customer123").Return(conversation, nil)
+	mockChatService.On("GetConversation", "conv123").Return(conversation, nil)
+	mockChatService.On("GetMessages", mock.Anything).Return(&domain.MessagePage{Messages: []domain.Message{}}, nil)
+
+	hub := webSock.NewHub(mockChatService, new(MockBotService))
+	hub.SetTakeover(&stubTakeover{conversation: *conversation})
+	go hub.Run()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
+		webSock.ServeWS(hub, w, r)
+	})
+	mux.HandleFunc("/ws/agent", func(w http.ResponseWriter, r *http.Request) {
+		webSock.ServeAgentWS(hub, stubAuthenticator{}, w, r)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	baseURL := strings.Replace(server.URL, "http://", "ws://", 1)
+
+	// Long enough to outlast the typing indicator expiring
+	ctx, cancel := context.WithTimeout(context.Background(), domain.TypingTTL+5*time.Second)
+	defer cancel()
+
+	customer, err := chatws.Dial(ctx, baseURL+"/ws?user_id=user123&customer_id=customer123", nil)
+	require.NoError(t, err)
+	defer customer.Close()
+
+	snapshot := expectPresence(t, ctx, customer, "")
+	require.Len(t, snapshot.Online, 1)
+	assert.Equal(t, "user123", snapshot.Online[0].UserID)
+	assert.Equal(t, domain.ParticipantCustomer, snapshot.Online[0].Role)
+
+	agent, err := chatws.Dial(ctx, baseURL+"/ws/agent?conversation_id=conv123&token=agent-token", nil)
+	require.NoError(t, err)
+
+	joined := expectPresence(t, ctx, customer, chatws.PresenceJoin)
+	require.NotNil(t, joined.Participant)
+	assert.Equal(t, "agent-1", joined.Participant.UserID)
+	assert.Equal(t, domain.ParticipantAgent, joined.Participant.Role)
+	assert.Len(t, joined.Online, 2)
+	assert.Len(t, hub.Presence("conv123"), 2)
+
+	t.Run("client typing is relayed to the other side", func(t *testing.T) {
+		require.NoError(t, agent.Typing(true))
+		started := expectTyping(t, ctx, customer, chatws.TypeTypingStart)
+		assert.Equal(t, "agent-1", started.UserID)
+		assert.Equal(t, domain.ParticipantAgent, started.Role)
+
+		require.NoError(t, agent.Typing(false))
+		stopped := expectTyping(t, ctx, customer, chatws.TypeTypingStop)
+		assert.Equal(t, "agent-1", stopped.UserID)
+	})
+
+	t.Run("bot typing reaches everyone", func(t *testing.T) {
+		hub.SendTyping(&domain.TypingIndicator{
+			ConversationID: "conv123",
+			CustomerID:     "customer123",
+			UserID:         "bot-1",
+			Role:           domain.ParticipantBot,
+			Name:           "Support Bot",
+			Typing:         true,
+		})
+		for _, client := range []*chatws.Client{customer, agent} {
+			started := expectTyping(t, ctx, client, chatws.TypeTypingStart)
+			assert.Equal(t, "Support Bot", started.Name)
+		}
+
+		if testing.Short() {
+			t.Skip("waiting for the typing indicator to expire")
+		}
+		stopped := expectTyping(t, ctx, customer, chatws.TypeTypingStop)
+		assert.Equal(t, "bot-1", stopped.UserID)
+	})
+
+	t.Run("leaving is announced", func(t *testing.T) {
+		require.NoError(t, agent.Close())
+
+		left := expectPresence(t, ctx, customer, chatws.PresenceLeave)
+		require.NotNil(t, left.Participant)
+		assert.Equal(t, "agent-1", left.Participant.UserID)
+		require.Len(t, left.Online, 1)
+		assert.Equal(t, "user123", left.Online[0].UserID)
+		assert.Len(t, hub.Presence("conv123"), 1)
+	})
+}
@@ -0,0 +1,149 @@
+package websocket
+
+import (
+	"hash/fnv"
+	"sync"
+)
+
+// How many independently locked shards each client index is split into
+const registryShards = 32
+
+// registry indexes the connected clients by customer and by conversation.
+// It is safe for concurrent use; lookups return snapshots so frames are
+// never written while a shard is locked.
+type registry struct {
+	byCustomer     clientIndex
+	byConversation clientIndex
+}
+
+// clientIndex is a set of clients per key, sharded by the key's hash
+type clientIndex struct {
+	shards [registryShards]indexShard
+}
+
+type indexShard struct {
+	mutex   sync.RWMutex
+	clients map[string]map[*Client]struct{}
+}
+
+func newRegistry() *registry {
+	r := &registry{}
+	r.byCustomer.init()
+	r.byConversation.init()
+	return r
+}
+
+// add registers a client under its customer and conversation
+func (r *registry) add(client *Client) {
+	client.conversationMutex.Lock()
+	defer client.conversationMutex.Unlock()
+	r.byCustomer.add(client.customerID, client)
+	r.byConversation.add(client.conversationID, client)
+	client.registered = true
+}
+
+// remove drops a client, reporting whether it was registered. Only the
+// first of several removals of the same client reports true.
+func (r *registry) remove(client *Client) bool {
+	client.conversationMutex.Lock()
+	defer client.conversationMutex.Unlock()
+	removed := r.byCustomer.remove(client.customerID, client)
+	r.byConversation.remove(client.conversationID, client)
+	client.registered = false
+	return removed
+}
+
+// move files a registered client under another conversation, reporting
+// whether it moved. Clients already removed stay out of the registry.
+func (r *registry) move(client *Client, conversationID string) bool {
+	client.conversationMutex.Lock()
+	defer client.conversationMutex.Unlock()
+	if !client.registered || client.conversationID == conversationID {
+		return false
+	}
+	r.byConversation.remove(client.conversationID, client)
+	r.byConversation.add(conversationID, client)
+	client.conversationID = conversationID
+	return true
+}
+
+// customerClients returns the clients of a customer, agents included
+func (r *registry) customerClients(customerID string) []*Client {
+	return r.byCustomer.get(customerID)
+}
+
+// conversationClients returns the clients connected to a conversation
+func (r *registry) conversationClients(conversationID string) []*Client {
+	return r.byConversation.get(conversationID)
+}
+
+// len counts the registered clients
+func (r *registry) len() int {
+	return r.byCustomer.len()
+}
+
+func (x *clientIndex) init() {
+	for i := range x.shards {
+		x.shards[i].clients = make(map[string]map[*Client]struct{})
+	}
+}
+
+func (x *clientIndex) shard(key string) *indexShard {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return &x.shards[h.Sum32()%registryShards]
+}
+
+func (x *clientIndex) add(key string, client *Client) {
+	shard := x.shard(key)
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+
+	clients, ok := shard.clients[key]
+	if !ok {
+		clients = make(map[*Client]struct{})
+		shard.clients[key] = clients
+	}
+	clients[client] = struct{}{}
+}
+
+func (x *clientIndex) remove(key string, client *Client) bool {
+	shard := x.shard(key)
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+
+	clients := shard.clients[key]
+	if _, ok := clients[client]; !ok {
+		return false
+	}
+	delete(clients, client)
+	if len(clients) == 0 {
+		delete(shard.clients, key)
+	}
+	return true
+}
+
+func (x *clientIndex) get(key string) []*Client {
+	shard := x.shard(key)
+	shard.mutex.RLock()
+	defer shard.mutex.RUnlock()
+
+	clients := make([]*Client, 0, len(shard.clients[key]))
+	for client := range shard.clients[key] {
+		clients = append(clients, client)
+	}
+	return clients
+}
+
+func (x *clientIndex) len() int {
+	count := 0
+	for i := range x.shards {
+		shard := &x.shards[i]
+		shard.mutex.RLock()
+		for _, clients := range shard.clients {
+			count += len(clients)
+		}
+		shard.mutex.RUnlock()
+	}
+	return count
+}
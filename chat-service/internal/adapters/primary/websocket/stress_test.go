@@ -0,0 +1,216 @@
+// internal/adapters/primary/websocket/stress_test.go
+package websocket_test
+
+import (
+	webSock "chat-service/internal/adapters/primary/websocket"
+	"chat-service/internal/core/domain"
+	"chat-service/pkg/chatws"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// TestHubStress connects thousands of clients for hundreds of customers and
+// has every customer chat at once while some connections drop, checking
+// under -race that each client gets its customer's reply exactly once and
+// nothing meant for anyone else
+func TestHubStress(t *testing.T) {
+	customers, connectionsPerCustomer := 200, 10
+	if testing.Short() {
+		customers, connectionsPerCustomer = 25, 8
+	}
+	// The last connections of every customer drop while the bot answers
+	const dropped = 2
+
+	mockChatService := new(MockChatService)
+	for i := 0; i < customers; i++ {
+		customerID := fmt.Sprintf("customer-%d", i)
+		mockChatService.On("CreateConversation", customerID).Return(&domain.Conversation{
+			ID:         "conv-" + customerID,
+			CustomerID: customerID,
+			StartedAt:  time.Now(),
+			Status:     "active",
+		}, nil)
+	}
+	mockChatService.On("GetMessages", mock.Anything).Return(&domain.MessagePage{Messages: []domain.Message{}}, nil)
+	var saved atomic.Int64
+	mockChatService.On("SaveMessage", mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		args.Get(0).(*domain.Message).ID = fmt.Sprintf("msg-%d", saved.Add(1))
+	})
+	var messageHandler func(*domain.Message)
+	mockChatService.On("SubscribeToMessages", mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		messageHandler = args.Get(0).(func(*domain.Message))
+	})
+
+	// The bot answers every customer, and the broker hands this replica a
+	// copy of the answer as well
+	var hub *webSock.Hub
+	var answered sync.WaitGroup
+	answered.Add(customers)
+	mockBotService := new(MockBotService)
+	mockBotService.On("ProcessMessage", mock.Anything, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		defer answered.Done()
+		message := args.Get(1).(*domain.Message)
+		reply := &domain.Message{
+			ID:         "reply-" + message.CustomerID,
+			Content:    "Hello " + message.CustomerID,
+			UserID:     "bot-1",
+			CustomerID: message.CustomerID,
+			Type:       domain.BotMessage,
+			Timestamp:  time.Now(),
+		}
+		hub.SendBotComplete(reply)
+		messageHandler(reply)
+	})
+
+	hub = webSock.NewHub(mockChatService, mockBotService)
+	require.NoError(t, hub.SubscribeToBotMessages())
+	go hub.Run()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		webSock.ServeWS(hub, w, r)
+	}))
+	defer server.Close()
+	baseURL := strings.Replace(server.URL, "http://", "ws://", 1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	// Connect everyone, a few at a time so the listener keeps up
+	clients := make([][]*chatws.Client, customers)
+	for i := range clients {
+		clients[i] = make([]*chatws.Client, connectionsPerCustomer)
+	}
+	var dialing sync.WaitGroup
+	dialErrors := make(chan error, customers*connectionsPerCustomer)
+	slots := make(chan struct{}, 64)
+	for i := 0; i < customers; i++ {
+		for j := 0; j < connectionsPerCustomer; j++ {
+			dialing.Add(1)
+			go func(i, j int) {
+				defer dialing.Done()
+				slots <- struct{}{}
+				defer func() { <-slots }()
+
+				client, err := chatws.Dial(ctx, fmt.Sprintf("%s?user_id=user-%d-%d&customer_id=customer-%d", baseURL, i, j, i), nil)
+				if err == nil {
+					_, err = client.Expect(ctx, chatws.TypeHistory)
+				}
+				if err != nil {
+					dialErrors <- err
+					return
+				}
+				clients[i][j] = client
+			}(i, j)
+		}
+	}
+	dialing.Wait()
+	close(dialErrors)
+	for err := range dialErrors {
+		require.NoError(t, err)
+	}
+	assert.Equal(t, customers*connectionsPerCustomer, hub.Connections())
+
+	// Every connection reads until its customer's closing notice, counting
+	// the replies it got
+	type result struct {
+		customer int
+		replies  int
+		err      error
+	}
+	results := make(chan result, customers*connectionsPerCustomer)
+	var reading sync.WaitGroup
+	for i := 0; i < customers; i++ {
+		for j := 0; j < connectionsPerCustomer-dropped; j++ {
+			reading.Add(1)
+			go func(i int, client *chatws.Client) {
+				defer reading.Done()
+				customerID := fmt.Sprintf("customer-%d", i)
+				replies := 0
+				for {
+					frame, err := client.Next(ctx)
+					if err != nil {
+						results <- result{customer: i, err: err}
+						return
+					}
+					switch frame.Type {
+					case chatws.TypeMessage, chatws.TypeBotComplete, chatws.TypeSystem:
+						var message domain.Message
+						if err := frame.Decode(&message); err != nil {
+							results <- result{customer: i, err: err}
+							return
+						}
+						if message.CustomerID != customerID {
+							results <- result{customer: i, err: fmt.Errorf("got a message for %s", message.CustomerID)}
+							return
+						}
+						if frame.Type == chatws.TypeBotComplete {
+							replies++
+						}
+						if frame.Type == chatws.TypeSystem {
+							results <- result{customer: i, replies: replies}
+							return
+						}
+					}
+				}
+			}(i, clients[i][j])
+		}
+	}
+
+	// Everyone types and sends at once while the last connections drop
+	var sending sync.WaitGroup
+	for i := 0; i < customers; i++ {
+		sending.Add(1)
+		go func(i int) {
+			defer sending.Done()
+			sender := clients[i][0]
+			assert.NoError(t, sender.Typing(true))
+			_, err := sender.Send(fmt.Sprintf("Hello from customer-%d", i))
+			assert.NoError(t, err)
+
+			for j := connectionsPerCustomer - dropped; j < connectionsPerCustomer; j++ {
+				clients[i][j].Close()
+			}
+		}(i)
+	}
+	sending.Wait()
+
+	// Close every conversation once all replies are out; a client reading
+	// the notice has seen everything sent before it
+	answered.Wait()
+	for i := 0; i < customers; i++ {
+		hub.SendSystemMessage(&domain.Message{
+			ID:         fmt.Sprintf("done-%d", i),
+			Content:    "done",
+			UserID:     "system",
+			CustomerID: fmt.Sprintf("customer-%d", i),
+			Type:       domain.SystemMessage,
+			Timestamp:  time.Now(),
+		})
+	}
+	reading.Wait()
+	close(results)
+	for r := range results {
+		require.NoError(t, r.err, "customer-%d", r.customer)
+		assert.Equal(t, 1, r.replies, "customer-%d", r.customer)
+	}
+
+	for i := range clients {
+		for j := 0; j < connectionsPerCustomer-dropped; j++ {
+			clients[i][j].Close()
+		}
+	}
+	assert.Eventually(t, func() bool {
+		return hub.Connections() == 0
+	}, 10*time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,80 @@
+package auth
+
+import (
+	"chat-service/internal/core/domain"
+	"chat-service/internal/core/ports"
+	"fmt"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// JWTAuthenticator verifies agents with the HMAC-signed tokens issued by
+// user-service. The agent ID is the token subject and its role claim must
+// name one of the allowed roles.
+type JWTAuthenticator struct {
+	secret []byte
+	roles  map[string]bool
+}
+
+var _ ports.AgentAuthenticator = (*JWTAuthenticator)(nil)
+
+// NewJWTAuthenticator creates an authenticator accepting the given roles
+func NewJWTAuthenticator(secret []byte, roles []string) *JWTAuthenticator {
+	allowed := make(map[string]bool, len(roles))
+	for _, role := range roles {
+		allowed[strings.ToLower(role)] = true
+	}
+	return &JWTAuthenticator{secret: secret, roles: allowed}
+}
+
+// AuthenticateAgent returns the agent ID of a valid agent token
+func (a *JWTAuthenticator) AuthenticateAgent(tokenString string) (string, error) {
+	token, err := jwt.Parse(tokenString, func(t *jwt.Token) (interface{}, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", t.Header["alg"])
+		}
+		return a.secret, nil
+	})
+	if err != nil || !token.Valid {
+		return "", fmt.Errorf("%w: invalid token", domain.ErrNotAgent)
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return "", fmt.Errorf("%w: invalid claims", domain.ErrNotAgent)
+	}
+
+	agentID, _ := claims["sub"].(string)
+	if agentID == "" {
+		return "", fmt.Errorf("%w: token has no subject", domain.ErrNotAgent)
+	}
+
+	for _, role := range tokenRoles(claims) {
+		if a.roles[strings.ToLower(role)] {
+			return agentID, nil
+		}
+	}
+	return "", fmt.Errorf("%w: missing agent role", domain.ErrNotAgent)
+}
+
+// tokenRoles reads the "role" claim set by user-service and the "roles" claim
+// understood by the gateway, which may be a list or a comma separated string
+func tokenRoles(claims jwt.MapClaims) []string {
+	var roles []string
+	for _, key := range []string{"role", "roles"} {
+		switch value := claims[key].(type) {
+		case string:
+			for _, role := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ' ' }) {
+				roles = append(roles, role)
+			}
+		case []interface{}:
+			for _, item := range value {
+				if role, ok := item.(string); ok {
+					roles = append(roles, role)
+				}
+			}
+		}
+	}
+	return roles
+}
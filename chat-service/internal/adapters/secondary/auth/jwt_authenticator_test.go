@@ -0,0 +1,47 @@
+package auth
+
+import (
+	"chat-service/internal/core/domain"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func signToken(t *testing.T, secret string, claims jwt.MapClaims) string {
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+	require.NoError(t, err)
+	return token
+}
+
+func TestJWTAuthenticator(t *testing.T) {
+	authenticator := NewJWTAuthenticator([]byte("secret"), []string{"agent", "admin"})
+	expires := time.Now().Add(time.Hour).Unix()
+
+	t.Run("accepts agent roles", func(t *testing.T) {
+		for _, claims := range []jwt.MapClaims{
+			{"sub": "agent-1", "role": "agent", "exp": expires},
+			{"sub": "agent-1", "roles": "user,admin", "exp": expires},
+			{"sub": "agent-1", "roles": []interface{}{"Agent"}, "exp": expires},
+		} {
+			agentID, err := authenticator.AuthenticateAgent(signToken(t, "secret", claims))
+			require.NoError(t, err)
+			assert.Equal(t, "agent-1", agentID)
+		}
+	})
+
+	t.Run("rejects other tokens", func(t *testing.T) {
+		for name, token := range map[string]string{
+			"customer":     signToken(t, "secret", jwt.MapClaims{"sub": "customer1", "role": "user", "exp": expires}),
+			"wrong secret": signToken(t, "other", jwt.MapClaims{"sub": "agent-1", "role": "agent", "exp": expires}),
+			"expired":      signToken(t, "secret", jwt.MapClaims{"sub": "agent-1", "role": "agent", "exp": time.Now().Add(-time.Hour).Unix()}),
+			"no subject":   signToken(t, "secret", jwt.MapClaims{"role": "agent", "exp": expires}),
+			"not a token":  "garbage",
+		} {
+			_, err := authenticator.AuthenticateAgent(token)
+			assert.ErrorIs(t, err, domain.ErrNotAgent, name)
+		}
+	})
+}
@@ -0,0 +1,174 @@
+package blobstore_test
+
+import (
+	"bytes"
+	"chat-service/internal/adapters/secondary/blobstore"
+	"chat-service/internal/core/domain"
+	"chat-service/internal/core/ports"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testBlobStore runs the behaviour every blob store shares
+func testBlobStore(t *testing.T, store ports.BlobStore) {
+	ctx := context.Background()
+	key := "conversations/conv1/screen shot.png"
+	data := []byte("\x89PNG\r\n\x1a\nnot really an image")
+
+	require.NoError(t, store.Put(ctx, key, bytes.NewReader(data), int64(len(data)), "image/png"))
+
+	body, err := store.Get(ctx, key)
+	require.NoError(t, err)
+	stored, err := io.ReadAll(body)
+	body.Close()
+	require.NoError(t, err)
+	assert.Equal(t, data, stored)
+
+	require.NoError(t, store.Delete(ctx, key))
+	_, err = store.Get(ctx, key)
+	assert.ErrorIs(t, err, domain.ErrBlobNotFound)
+
+	// Deleting twice is fine
+	assert.NoError(t, store.Delete(ctx, key))
+}
+
+func TestFilesystemStore(t *testing.T) {
+	store, err := blobstore.NewFilesystemStore(t.TempDir())
+	require.NoError(t, err)
+	testBlobStore(t, store)
+
+	t.Run("keys cannot escape the root", func(t *testing.T) {
+		err := store.Put(context.Background(), "../outside", strings.NewReader("x"), 1, "text/plain")
+		assert.Error(t, err)
+	})
+
+	t.Run("short bodies are not stored", func(t *testing.T) {
+		ctx := context.Background()
+		err := store.Put(ctx, "short", strings.NewReader("abc"), 10, "text/plain")
+		assert.Error(t, err)
+		_, err = store.Get(ctx, "short")
+		assert.ErrorIs(t, err, domain.ErrBlobNotFound)
+	})
+}
+
+// fakeS3 is a MinIO-style stand-in keeping objects in memory. It checks
+// requests carry a valid Signature Version 4 for its credentials.
+type fakeS3 struct {
+	accessKey string
+	secretKey string
+	mutex     sync.Mutex
+	objects   map[string][]byte
+}
+
+var authorizationPattern = regexp.MustCompile(`^AWS4-HMAC-SHA256 Credential=([^/]+)/(\d{8})/([^/]+)/s3/aws4_request, SignedHeaders=([^,]+), Signature=([0-9a-f]{64})$`)
+
+func (s *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(r) {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte("<Error><Code>SignatureDoesNotMatch</Code></Error>"))
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	switch r.Method {
+	case http.MethodPut:
+		data, _ := io.ReadAll(r.Body)
+		if hash := r.Header.Get("X-Amz-Content-Sha256"); hash != "UNSIGNED-PAYLOAD" {
+			sum := sha256.Sum256(data)
+			if hash != hex.EncodeToString(sum[:]) {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+		}
+		s.objects[r.URL.Path] = data
+	case http.MethodGet:
+		data, ok := s.objects[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write(data)
+	case http.MethodDelete:
+		delete(s.objects, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// authorized recomputes the request's signature
+func (s *fakeS3) authorized(r *http.Request) bool {
+	match := authorizationPattern.FindStringSubmatch(r.Header.Get("Authorization"))
+	if match == nil || match[1] != s.accessKey {
+		return false
+	}
+	date, region, signedHeaders := match[2], match[3], match[4]
+
+	var canonicalHeaders strings.Builder
+	for _, name := range strings.Split(signedHeaders, ";") {
+		value := r.Header.Get(name)
+		if name == "host" {
+			value = r.Host
+		}
+		canonicalHeaders.WriteString(name + ":" + value + "\n")
+	}
+	canonicalRequest := strings.Join([]string{
+		r.Method, r.URL.EscapedPath(), r.URL.RawQuery, canonicalHeaders.String(),
+		signedHeaders, r.Header.Get("X-Amz-Content-Sha256"),
+	}, "\n")
+	hash := sha256.Sum256([]byte(canonicalRequest))
+	scope := date + "/" + region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + r.Header.Get("X-Amz-Date") + "\n" + scope + "\n" + hex.EncodeToString(hash[:])
+
+	key := []byte("AWS4" + s.secretKey)
+	for _, part := range []string{date, region, "s3", "aws4_request", stringToSign} {
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(part))
+		key = mac.Sum(nil)
+	}
+	return hex.EncodeToString(key) == match[5]
+}
+
+func TestS3Store(t *testing.T) {
+	fake := &fakeS3{accessKey: "minio", secretKey: "minio-secret", objects: make(map[string][]byte)}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	store, err := blobstore.NewS3Store(blobstore.S3Options{
+		Endpoint:  server.URL,
+		Bucket:    "attachments",
+		AccessKey: "minio",
+		SecretKey: "minio-secret",
+	})
+	require.NoError(t, err)
+	testBlobStore(t, store)
+
+	t.Run("objects live in the bucket", func(t *testing.T) {
+		ctx := context.Background()
+		require.NoError(t, store.Put(ctx, "a/b.txt", strings.NewReader("hello"), 5, "text/plain"))
+		assert.Equal(t, []byte("hello"), fake.objects["/attachments/a/b.txt"])
+	})
+
+	t.Run("wrong credentials are refused", func(t *testing.T) {
+		wrong, err := blobstore.NewS3Store(blobstore.S3Options{
+			Endpoint:  server.URL,
+			Bucket:    "attachments",
+			AccessKey: "minio",
+			SecretKey: "guessed",
+		})
+		require.NoError(t, err)
+		err = wrong.Put(context.Background(), "x", strings.NewReader("x"), 1, "text/plain")
+		assert.ErrorContains(t, err, "403")
+	})
+}
@@ -0,0 +1,95 @@
+package blobstore
+
+import (
+	"chat-service/internal/core/domain"
+	"chat-service/internal/core/ports"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// FilesystemStore keeps blobs as files under a root directory, one file per
+// key. It suits a single replica or replicas sharing a volume.
+type FilesystemStore struct {
+	root string
+}
+
+var _ ports.BlobStore = (*FilesystemStore)(nil)
+
+// NewFilesystemStore creates a store under root, creating the directory if
+// it does not exist
+func NewFilesystemStore(root string) (*FilesystemStore, error) {
+	if err := os.MkdirAll(root, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create blob directory: %w", err)
+	}
+	return &FilesystemStore{root: root}, nil
+}
+
+// Put writes the blob to a temporary file and renames it into place, so a
+// failed upload never leaves a partial file under the key
+func (s *FilesystemStore) Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return err
+	}
+
+	file, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+
+	written, err := io.Copy(file, body)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if written != size {
+		return fmt.Errorf("blob %s is %d bytes, expected %d", key, written, size)
+	}
+	return os.Rename(file.Name(), path)
+}
+
+// Get opens the file stored under the key
+func (s *FilesystemStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, domain.ErrBlobNotFound
+	}
+	return file, err
+}
+
+// Delete removes the file stored under the key, if any
+func (s *FilesystemStore) Delete(ctx context.Context, key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// path maps a key to a file under the root, refusing keys that would
+// escape it
+func (s *FilesystemStore) path(key string) (string, error) {
+	cleaned := filepath.Clean("/" + key)
+	if cleaned == "/" || strings.Contains(key, "..") {
+		return "", fmt.Errorf("invalid blob key %q", key)
+	}
+	return filepath.Join(s.root, filepath.FromSlash(cleaned)), nil
+}
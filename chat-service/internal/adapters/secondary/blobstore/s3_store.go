@@ -0,0 +1,212 @@
+package blobstore
+
+import (
+	"chat-service/internal/core/domain"
+	"chat-service/internal/core/ports"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// S3Options configures an S3Store. Endpoint is the base URL of the service,
+// such as https://s3.eu-west-1.amazonaws.com or a MinIO server.
+type S3Options struct {
+	Endpoint  string
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+}
+
+// S3Store keeps blobs in a bucket of an S3-compatible object store. Objects
+// are addressed path-style, which AWS and MinIO both accept, and requests
+// are signed with AWS Signature Version 4.
+type S3Store struct {
+	endpoint   *url.URL
+	options    S3Options
+	httpClient *http.Client
+}
+
+var _ ports.BlobStore = (*S3Store)(nil)
+
+// unsignedPayload marks a request whose body is not part of the signature
+const unsignedPayload = "UNSIGNED-PAYLOAD"
+
+// NewS3Store creates a store for the bucket described by options
+func NewS3Store(options S3Options) (*S3Store, error) {
+	endpoint, err := url.Parse(strings.TrimRight(options.Endpoint, "/"))
+	if err != nil || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid S3 endpoint %q", options.Endpoint)
+	}
+	if options.Bucket == "" {
+		return nil, fmt.Errorf("missing S3 bucket")
+	}
+	if options.Region == "" {
+		options.Region = "us-east-1"
+	}
+	return &S3Store{
+		endpoint:   endpoint,
+		options:    options,
+		httpClient: &http.Client{Timeout: 60 * time.Second},
+	}, nil
+}
+
+// Put uploads the blob as an object. Bodies that can be rewound are signed
+// along with the request; others are sent unsigned.
+func (s *S3Store) Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error {
+	payloadHash := unsignedPayload
+	if seeker, ok := body.(io.ReadSeeker); ok {
+		hash := sha256.New()
+		if _, err := io.Copy(hash, seeker); err != nil {
+			return err
+		}
+		if _, err := seeker.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		payloadHash = hex.EncodeToString(hash.Sum(nil))
+	}
+
+	req, err := s.request(ctx, http.MethodPut, key, body, payloadHash)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-Type", contentType)
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return checkResponse(resp, http.MethodPut, key)
+}
+
+// Get downloads the object stored under the key
+func (s *S3Store) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	req, err := s.request(ctx, http.MethodGet, key, nil, emptyPayloadHash)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil, domain.ErrBlobNotFound
+	}
+	if err := checkResponse(resp, http.MethodGet, key); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// Delete removes the object stored under the key, if any
+func (s *S3Store) Delete(ctx context.Context, key string) error {
+	req, err := s.request(ctx, http.MethodDelete, key, nil, emptyPayloadHash)
+	if err != nil {
+		return err
+	}
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil
+	}
+	return checkResponse(resp, http.MethodDelete, key)
+}
+
+// emptyPayloadHash is the SHA-256 of an empty body
+var emptyPayloadHash = hex.EncodeToString(sha256.New().Sum(nil))
+
+// request builds a signed request for the object under the key
+func (s *S3Store) request(ctx context.Context, method, key string, body io.Reader, payloadHash string) (*http.Request, error) {
+	target := *s.endpoint
+	target.Path = s.endpoint.Path + "/" + s.options.Bucket + "/" + strings.TrimPrefix(key, "/")
+	target.RawPath = s.endpoint.Path + "/" + escapePath(s.options.Bucket+"/"+strings.TrimPrefix(key, "/"))
+
+	req, err := http.NewRequestWithContext(ctx, method, target.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	s.sign(req, payloadHash)
+	return req, nil
+}
+
+// sign adds the AWS Signature Version 4 headers to the request. Only the
+// host and the x-amz headers are signed.
+func (s *S3Store) sign(req *http.Request, payloadHash string) {
+	now := time.Now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		"host:" + req.URL.Host + "\n" +
+			"x-amz-content-sha256:" + payloadHash + "\n" +
+			"x-amz-date:" + amzDate + "\n",
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + s.options.Region + "/s3/aws4_request"
+	canonicalHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(canonicalHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+s.options.SecretKey), date)
+	key = hmacSHA256(key, s.options.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.options.AccessKey, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// escapePath encodes every byte of an object path except the unreserved
+// characters and slashes, as Signature Version 4 expects
+func escapePath(path string) string {
+	var builder strings.Builder
+	for i := 0; i < len(path); i++ {
+		c := path[i]
+		if ('A' <= c && c <= 'Z') || ('a' <= c && c <= 'z') || ('0' <= c && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' || c == '/' {
+			builder.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&builder, "%%%02X", c)
+	}
+	return builder.String()
+}
+
+// checkResponse turns a failed response into an error with the start of
+// the error document the store sent
+func checkResponse(resp *http.Response, method, key string) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return fmt.Errorf("s3 %s %s failed with status %d: %s", method, key, resp.StatusCode, strings.TrimSpace(string(detail)))
+}
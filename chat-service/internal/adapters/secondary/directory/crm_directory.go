@@ -0,0 +1,72 @@
+package directory
+
+import (
+	"chat-service/internal/core/ports"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// CRMDirectory looks participants up in the CRM service, which keeps the
+// customers and the support agents
+type CRMDirectory struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+var _ ports.ParticipantDirectory = (*CRMDirectory)(nil)
+
+// crmPerson is the part of a CRM customer or agent that names them
+type crmPerson struct {
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	Email     string `json:"email"`
+}
+
+// NewCRMDirectory creates a directory for the CRM service at baseURL
+func NewCRMDirectory(baseURL string) *CRMDirectory {
+	return &CRMDirectory{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// CustomerName returns the customer's full name, or their email without one
+func (d *CRMDirectory) CustomerName(ctx context.Context, customerID string) (string, error) {
+	return d.name(ctx, "customers", customerID)
+}
+
+// AgentName returns the agent's full name, or their email without one
+func (d *CRMDirectory) AgentName(ctx context.Context, agentID string) (string, error) {
+	return d.name(ctx, "agents", agentID)
+}
+
+func (d *CRMDirectory) name(ctx context.Context, collection, id string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet,
+		d.baseURL+"/"+collection+"/"+url.PathEscape(id), nil)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := d.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to reach the CRM: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("CRM returned status %d for %s %s", resp.StatusCode, collection, id)
+	}
+
+	var person crmPerson
+	if err := json.NewDecoder(resp.Body).Decode(&person); err != nil {
+		return "", fmt.Errorf("failed to decode CRM response: %w", err)
+	}
+	if name := strings.TrimSpace(person.FirstName + " " + person.LastName); name != "" {
+		return name, nil
+	}
+	return person.Email, nil
+}
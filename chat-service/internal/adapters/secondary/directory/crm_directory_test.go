@@ -0,0 +1,41 @@
+package directory_test
+
+import (
+	"chat-service/internal/adapters/secondary/directory"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCRMDirectory(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/customers/customer1":
+			json.NewEncoder(w).Encode(map[string]string{"id": "customer1", "first_name": "Ada", "last_name": "Lovelace"})
+		case "/agents/agent1":
+			json.NewEncoder(w).Encode(map[string]string{"id": "agent1", "email": "grace@example.com"})
+		default:
+			http.Error(w, `{"success":false,"error":"Not found"}`, http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	crm := directory.NewCRMDirectory(server.URL + "/")
+	ctx := context.Background()
+
+	name, err := crm.CustomerName(ctx, "customer1")
+	require.NoError(t, err)
+	assert.Equal(t, "Ada Lovelace", name)
+
+	name, err = crm.AgentName(ctx, "agent1")
+	require.NoError(t, err)
+	assert.Equal(t, "grace@example.com", name, "agents without a name are named by their email")
+
+	_, err = crm.CustomerName(ctx, "unknown")
+	assert.Error(t, err)
+}
@@ -0,0 +1,113 @@
+package embedding
+
+import (
+	"chat-service/internal/core/ports"
+	"context"
+	"hash/fnv"
+	"math"
+	"strings"
+	"unicode"
+)
+
+// HashingEmbedder produces embeddings locally using the hashing trick over
+// unigrams and bigrams with log-scaled term frequencies. It needs no model or
+// network access, which makes it suitable for offline use and tests.
+type HashingEmbedder struct {
+	dimensions int
+}
+
+var _ ports.Embedder = (*HashingEmbedder)(nil)
+
+// NewHashingEmbedder creates an embedder producing vectors of the given size
+func NewHashingEmbedder(dimensions int) *HashingEmbedder {
+	if dimensions <= 0 {
+		dimensions = 256
+	}
+	return &HashingEmbedder{dimensions: dimensions}
+}
+
+// Name returns the embedder identifier
+func (e *HashingEmbedder) Name() string {
+	return "hashing"
+}
+
+// Embed returns one L2-normalised vector per input text
+func (e *HashingEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		vectors[i] = e.embed(text)
+	}
+	return vectors, nil
+}
+
+func (e *HashingEmbedder) embed(text string) []float32 {
+	counts := make(map[string]int)
+	tokens := tokenize(text)
+	for i, token := range tokens {
+		counts[token]++
+		if i > 0 {
+			counts[tokens[i-1]+" "+token]++
+		}
+	}
+
+	vector := make([]float64, e.dimensions)
+	for feature, count := range counts {
+		h := fnv.New64a()
+		h.Write([]byte(feature))
+		sum := h.Sum64()
+
+		// The top bit picks the sign so collisions tend to cancel out
+		sign := 1.0
+		if sum>>63 == 1 {
+			sign = -1.0
+		}
+		vector[sum%uint64(e.dimensions)] += sign * (1 + math.Log(float64(count)))
+	}
+
+	var norm float64
+	for _, v := range vector {
+		norm += v * v
+	}
+	norm = math.Sqrt(norm)
+
+	result := make([]float32, e.dimensions)
+	if norm == 0 {
+		return result
+	}
+	for i, v := range vector {
+		result[i] = float32(v / norm)
+	}
+	return result
+}
+
+// stopWords are too common to say anything about what a text is about
+var stopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "be": true, "can": true,
+	"do": true, "does": true, "for": true, "how": true, "i": true, "in": true,
+	"is": true, "it": true, "me": true, "my": true, "of": true, "on": true,
+	"or": true, "the": true, "to": true, "we": true, "what": true, "when": true,
+	"will": true, "with": true, "you": true, "your": true,
+}
+
+// tokenize lowercases text, splits it on anything that isn't a letter or
+// digit, drops stop words and folds simple plurals
+func tokenize(text string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	tokens := fields[:0]
+	for _, field := range fields {
+		if stopWords[field] {
+			continue
+		}
+		if len(field) > 3 && strings.HasSuffix(field, "s") && !strings.HasSuffix(field, "ss") {
+			field = strings.TrimSuffix(field, "s")
+		}
+		tokens = append(tokens, field)
+	}
+	return tokens
+}
@@ -0,0 +1,45 @@
+package embedding
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func dot(a, b []float32) float64 {
+	var sum float64
+	for i := range a {
+		sum += float64(a[i]) * float64(b[i])
+	}
+	return sum
+}
+
+func TestHashingEmbedder(t *testing.T) {
+	embedder := NewHashingEmbedder(128)
+
+	vectors, err := embedder.Embed(context.Background(), []string{
+		"How do I reset my password?",
+		"I forgot my password and need to reset it",
+		"What payment methods do you accept?",
+		"",
+	})
+	require.NoError(t, err)
+	require.Len(t, vectors, 4)
+
+	for _, vector := range vectors[:3] {
+		assert.Len(t, vector, 128)
+		assert.InDelta(t, 1.0, math.Sqrt(dot(vector, vector)), 1e-5)
+	}
+	assert.Zero(t, dot(vectors[3], vectors[3]), "empty text embeds to the zero vector")
+
+	related := dot(vectors[0], vectors[1])
+	unrelated := dot(vectors[0], vectors[2])
+	assert.Greater(t, related, unrelated)
+
+	again, err := embedder.Embed(context.Background(), []string{"How do I reset my password?"})
+	require.NoError(t, err)
+	assert.Equal(t, vectors[0], again[0], "embeddings are deterministic")
+}
@@ -0,0 +1,73 @@
+package embedding
+
+import (
+	"chat-service/internal/core/ports"
+	"context"
+	"fmt"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+	"github.com/openai/openai-go/packages/param"
+)
+
+// OpenAIEmbedder uses the OpenAI embeddings API or a compatible server
+type OpenAIEmbedder struct {
+	client     openai.Client
+	model      string
+	dimensions int
+}
+
+var _ ports.Embedder = (*OpenAIEmbedder)(nil)
+
+// NewOpenAIEmbedder creates an embedder; baseURL may be empty to use api.openai.com
+// and dimensions may be zero to use the model's native size
+func NewOpenAIEmbedder(apiKey, baseURL, model string, dimensions int) *OpenAIEmbedder {
+	requestOptions := []option.RequestOption{option.WithAPIKey(apiKey)}
+	if baseURL != "" {
+		requestOptions = append(requestOptions, option.WithBaseURL(baseURL))
+	}
+
+	return &OpenAIEmbedder{
+		client:     openai.NewClient(requestOptions...),
+		model:      model,
+		dimensions: dimensions,
+	}
+}
+
+// Name returns the embedder identifier
+func (e *OpenAIEmbedder) Name() string {
+	return "openai"
+}
+
+// Embed returns one vector per input text, in input order
+func (e *OpenAIEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	if len(texts) == 0 {
+		return nil, nil
+	}
+
+	params := openai.EmbeddingNewParams{
+		Input: openai.EmbeddingNewParamsInputUnion{OfArrayOfStrings: texts},
+		Model: e.model,
+	}
+	if e.dimensions > 0 {
+		params.Dimensions = param.Opt[int64]{Value: int64(e.dimensions)}
+	}
+
+	resp, err := e.client.Embeddings.New(ctx, params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create embeddings: %w", err)
+	}
+	if len(resp.Data) != len(texts) {
+		return nil, fmt.Errorf("expected %d embeddings, got %d", len(texts), len(resp.Data))
+	}
+
+	vectors := make([][]float32, len(texts))
+	for _, item := range resp.Data {
+		vector := make([]float32, len(item.Embedding))
+		for i, v := range item.Embedding {
+			vector[i] = float32(v)
+		}
+		vectors[item.Index] = vector
+	}
+	return vectors, nil
+}
@@ -0,0 +1,88 @@
+package llm
+
+import (
+	"chat-service/internal/core/domain"
+	"chat-service/internal/core/ports"
+	"context"
+	"strings"
+	"sync"
+)
+
+// FakeProvider is a deterministic provider for tests and offline development.
+// It replays the scripted responses in order, cycling when it reaches the end,
+// and echoes the last user message when no script is configured.
+type FakeProvider struct {
+	responses []string
+	next      int
+	calls     [][]domain.LLMMessage
+	mutex     sync.Mutex
+}
+
+var _ ports.LLMProvider = (*FakeProvider)(nil)
+
+// NewFakeProvider creates a fake provider with the given scripted responses
+func NewFakeProvider(responses ...string) *FakeProvider {
+	return &FakeProvider{responses: responses}
+}
+
+// Name returns the provider identifier
+func (p *FakeProvider) Name() string {
+	return "fake"
+}
+
+// Complete returns the next scripted response
+func (p *FakeProvider) Complete(ctx context.Context, messages []domain.LLMMessage) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	recorded := make([]domain.LLMMessage, len(messages))
+	copy(recorded, messages)
+	p.calls = append(p.calls, recorded)
+
+	if len(p.responses) == 0 {
+		for i := len(messages) - 1; i >= 0; i-- {
+			if messages[i].Role == domain.LLMRoleUser {
+				return "You said: " + messages[i].Content, nil
+			}
+		}
+		return "", domain.ErrLLMEmptyResponse
+	}
+
+	response := p.responses[p.next%len(p.responses)]
+	p.next++
+	return response, nil
+}
+
+// Stream returns the next scripted response, emitting it one word at a time
+func (p *FakeProvider) Stream(ctx context.Context, messages []domain.LLMMessage, onDelta func(delta string) error) (string, error) {
+	response, err := p.Complete(ctx, messages)
+	if err != nil {
+		return "", err
+	}
+
+	words := strings.SplitAfter(response, " ")
+	for _, word := range words {
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+		if err := onDelta(word); err != nil {
+			return "", err
+		}
+	}
+
+	return response, nil
+}
+
+// Calls returns the conversations the provider has been asked to complete
+func (p *FakeProvider) Calls() [][]domain.LLMMessage {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	result := make([][]domain.LLMMessage, len(p.calls))
+	copy(result, p.calls)
+	return result
+}
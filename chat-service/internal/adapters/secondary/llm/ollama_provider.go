@@ -0,0 +1,172 @@
+package llm
+
+import (
+	"bytes"
+	"chat-service/internal/core/domain"
+	"chat-service/internal/core/ports"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// OllamaProvider talks to a local model served through Ollama's /api/chat
+// endpoint. llama.cpp and similar servers that expose an OpenAI-compatible
+// API should use OpenAIProvider with a custom base URL instead.
+type OllamaProvider struct {
+	baseURL    string
+	httpClient *http.Client
+	options    Options
+}
+
+var _ ports.LLMProvider = (*OllamaProvider)(nil)
+
+type ollamaChatRequest struct {
+	Model    string              `json:"model"`
+	Messages []domain.LLMMessage `json:"messages"`
+	Stream   bool                `json:"stream"`
+	Options  map[string]any      `json:"options,omitempty"`
+}
+
+type ollamaChatResponse struct {
+	Message domain.LLMMessage `json:"message"`
+	Done    bool              `json:"done"`
+	Error   string            `json:"error,omitempty"`
+}
+
+// NewOllamaProvider creates a provider for the Ollama server at baseURL
+func NewOllamaProvider(baseURL string, options Options) *OllamaProvider {
+	return &OllamaProvider{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: &http.Client{Timeout: 60 * time.Second},
+		options:    options,
+	}
+}
+
+// Name returns the provider identifier
+func (p *OllamaProvider) Name() string {
+	return "ollama"
+}
+
+// Complete sends the conversation and returns the model's reply
+func (p *OllamaProvider) Complete(ctx context.Context, messages []domain.LLMMessage) (string, error) {
+	resp, err := p.send(ctx, messages, false)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var chatResp ollamaChatResponse
+	if err := json.NewDecoder(resp.Body).Decode(&chatResp); err != nil {
+		return "", fmt.Errorf("failed to decode ollama response: %w", err)
+	}
+	if chatResp.Error != "" {
+		return "", fmt.Errorf("ollama error: %s", chatResp.Error)
+	}
+	if chatResp.Message.Content == "" {
+		return "", domain.ErrLLMEmptyResponse
+	}
+
+	return chatResp.Message.Content, nil
+}
+
+// Stream sends the conversation and relays each NDJSON chunk as it arrives
+func (p *OllamaProvider) Stream(ctx context.Context, messages []domain.LLMMessage, onDelta func(delta string) error) (string, error) {
+	resp, err := p.send(ctx, messages, true)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var full strings.Builder
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var chunk ollamaChatResponse
+		if err := decoder.Decode(&chunk); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return full.String(), fmt.Errorf("failed to decode ollama stream: %w", err)
+		}
+		if chunk.Error != "" {
+			return full.String(), fmt.Errorf("ollama error: %s", chunk.Error)
+		}
+
+		if chunk.Message.Content != "" {
+			full.WriteString(chunk.Message.Content)
+			if err := onDelta(chunk.Message.Content); err != nil {
+				return full.String(), err
+			}
+		}
+		if chunk.Done {
+			break
+		}
+	}
+
+	if full.Len() == 0 {
+		return "", domain.ErrLLMEmptyResponse
+	}
+	return full.String(), nil
+}
+
+// send posts a chat request and returns the response once the status is OK
+func (p *OllamaProvider) send(ctx context.Context, messages []domain.LLMMessage, stream bool) (*http.Response, error) {
+	body, err := json.Marshal(ollamaChatRequest{
+		Model:    p.options.Model,
+		Messages: messages,
+		Stream:   stream,
+		Options:  p.modelOptions(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal ollama request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+"/api/chat", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("ollama request failed: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		if resp.StatusCode == http.StatusTooManyRequests {
+			return nil, domain.ErrLLMRateLimited
+		}
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("ollama returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(detail)))
+	}
+
+	return resp, nil
+}
+
+// modelOptions maps the configured options onto Ollama's option names
+func (p *OllamaProvider) modelOptions() map[string]any {
+	opts := map[string]any{
+		"temperature": p.options.Temperature,
+	}
+	if p.options.MaxTokens > 0 {
+		opts["num_predict"] = p.options.MaxTokens
+	}
+	if p.options.TopP > 0 {
+		opts["top_p"] = p.options.TopP
+	}
+	if p.options.FrequencyPenalty != 0 {
+		opts["frequency_penalty"] = p.options.FrequencyPenalty
+	}
+	if p.options.PresencePenalty != 0 {
+		opts["presence_penalty"] = p.options.PresencePenalty
+	}
+	if len(p.options.Stop) > 0 {
+		opts["stop"] = p.options.Stop
+	}
+	return opts
+}
@@ -0,0 +1,142 @@
+package llm
+
+import (
+	"chat-service/internal/core/domain"
+	"chat-service/internal/core/ports"
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+	"github.com/openai/openai-go/packages/param"
+)
+
+// OpenAIProvider talks to the OpenAI chat completions API or any server
+// exposing a compatible endpoint (e.g. llama.cpp server, vLLM, Ollama /v1)
+type OpenAIProvider struct {
+	client  openai.Client
+	options Options
+}
+
+var _ ports.LLMProvider = (*OpenAIProvider)(nil)
+
+// NewOpenAIProvider creates a provider; baseURL may be empty to use api.openai.com
+func NewOpenAIProvider(apiKey, baseURL string, options Options) *OpenAIProvider {
+	requestOptions := []option.RequestOption{
+		option.WithAPIKey(apiKey),
+		// Retries are handled by the bot agent so that they respect its rate limiter
+		option.WithMaxRetries(0),
+	}
+	if baseURL != "" {
+		requestOptions = append(requestOptions, option.WithBaseURL(baseURL))
+	}
+
+	return &OpenAIProvider{
+		client:  openai.NewClient(requestOptions...),
+		options: options,
+	}
+}
+
+// Name returns the provider identifier
+func (p *OpenAIProvider) Name() string {
+	return "openai"
+}
+
+// Complete sends the conversation and returns the first choice
+func (p *OpenAIProvider) Complete(ctx context.Context, messages []domain.LLMMessage) (string, error) {
+	completion, err := p.client.Chat.Completions.New(ctx, p.buildParams(messages))
+	if err != nil {
+		return "", mapOpenAIError(err)
+	}
+
+	if len(completion.Choices) == 0 || completion.Choices[0].Message.Content == "" {
+		return "", domain.ErrLLMEmptyResponse
+	}
+
+	return completion.Choices[0].Message.Content, nil
+}
+
+// Stream sends the conversation and relays content deltas as they arrive
+func (p *OpenAIProvider) Stream(ctx context.Context, messages []domain.LLMMessage, onDelta func(delta string) error) (string, error) {
+	stream := p.client.Chat.Completions.NewStreaming(ctx, p.buildParams(messages))
+	defer stream.Close()
+
+	var full strings.Builder
+	for stream.Next() {
+		chunk := stream.Current()
+		if len(chunk.Choices) == 0 || chunk.Choices[0].Delta.Content == "" {
+			continue
+		}
+
+		delta := chunk.Choices[0].Delta.Content
+		full.WriteString(delta)
+		if err := onDelta(delta); err != nil {
+			return full.String(), err
+		}
+	}
+
+	if err := stream.Err(); err != nil {
+		return full.String(), mapOpenAIError(err)
+	}
+	if full.Len() == 0 {
+		return "", domain.ErrLLMEmptyResponse
+	}
+
+	return full.String(), nil
+}
+
+// buildParams maps the configured options onto an OpenAI request
+func (p *OpenAIProvider) buildParams(messages []domain.LLMMessage) openai.ChatCompletionNewParams {
+	params := openai.ChatCompletionNewParams{
+		Model:    p.options.Model,
+		Messages: toOpenAIMessages(messages),
+	}
+
+	if p.options.MaxTokens > 0 {
+		params.MaxTokens = param.Opt[int64]{Value: int64(p.options.MaxTokens)}
+	}
+	params.Temperature = param.Opt[float64]{Value: p.options.Temperature}
+	if p.options.TopP > 0 {
+		params.TopP = param.Opt[float64]{Value: p.options.TopP}
+	}
+	if p.options.FrequencyPenalty != 0 {
+		params.FrequencyPenalty = param.Opt[float64]{Value: p.options.FrequencyPenalty}
+	}
+	if p.options.PresencePenalty != 0 {
+		params.PresencePenalty = param.Opt[float64]{Value: p.options.PresencePenalty}
+	}
+	if len(p.options.Stop) > 0 {
+		params.Stop = openai.ChatCompletionNewParamsStopUnion{OfChatCompletionNewsStopArray: p.options.Stop}
+	}
+	if p.options.User != "" {
+		params.User = param.Opt[string]{Value: p.options.User}
+	}
+
+	return params
+}
+
+// mapOpenAIError translates API errors into domain errors where possible
+func mapOpenAIError(err error) error {
+	var apiErr *openai.Error
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusTooManyRequests {
+		return domain.ErrLLMRateLimited
+	}
+	return err
+}
+
+func toOpenAIMessages(messages []domain.LLMMessage) []openai.ChatCompletionMessageParamUnion {
+	result := make([]openai.ChatCompletionMessageParamUnion, 0, len(messages))
+	for _, msg := range messages {
+		switch msg.Role {
+		case domain.LLMRoleSystem:
+			result = append(result, openai.SystemMessage(msg.Content))
+		case domain.LLMRoleAssistant:
+			result = append(result, openai.AssistantMessage(msg.Content))
+		default:
+			result = append(result, openai.UserMessage(msg.Content))
+		}
+	}
+	return result
+}
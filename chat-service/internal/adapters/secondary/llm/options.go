@@ -0,0 +1,13 @@
+package llm
+
+// Options holds the generation parameters shared by all providers
+type Options struct {
+	Model            string
+	MaxTokens        int
+	Temperature      float64
+	TopP             float64
+	FrequencyPenalty float64
+	PresencePenalty  float64
+	Stop             []string
+	User             string
+}
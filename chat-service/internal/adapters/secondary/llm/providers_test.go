@@ -0,0 +1,86 @@
+package llm_test
+
+import (
+	"chat-service/internal/adapters/secondary/llm"
+	"chat-service/internal/core/domain"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOllamaProvider(t *testing.T) {
+	t.Run("sends configured options", func(t *testing.T) {
+		var received map[string]any
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/api/chat", r.URL.Path)
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+			json.NewEncoder(w).Encode(map[string]any{
+				"message": map[string]string{"role": "assistant", "content": "Hi from llama"},
+				"done":    true,
+			})
+		}))
+		defer server.Close()
+
+		provider := llm.NewOllamaProvider(server.URL+"/", llm.Options{
+			Model:       "llama3",
+			MaxTokens:   64,
+			Temperature: 0.2,
+		})
+
+		reply, err := provider.Complete(context.Background(), []domain.LLMMessage{
+			{Role: domain.LLMRoleSystem, Content: "Be brief"},
+			{Role: domain.LLMRoleUser, Content: "Hello"},
+		})
+
+		require.NoError(t, err)
+		assert.Equal(t, "Hi from llama", reply)
+		assert.Equal(t, "llama3", received["model"])
+		assert.Equal(t, false, received["stream"])
+		options := received["options"].(map[string]any)
+		assert.Equal(t, 0.2, options["temperature"])
+		assert.Equal(t, float64(64), options["num_predict"])
+	})
+
+	t.Run("rate limited", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusTooManyRequests)
+		}))
+		defer server.Close()
+
+		provider := llm.NewOllamaProvider(server.URL, llm.Options{Model: "llama3"})
+		_, err := provider.Complete(context.Background(), []domain.LLMMessage{{Role: domain.LLMRoleUser, Content: "Hello"}})
+
+		assert.ErrorIs(t, err, domain.ErrLLMRateLimited)
+	})
+}
+
+func TestFakeProvider(t *testing.T) {
+	t.Run("replays script in order", func(t *testing.T) {
+		provider := llm.NewFakeProvider("first", "second")
+		messages := []domain.LLMMessage{{Role: domain.LLMRoleUser, Content: "Hello"}}
+
+		for _, expected := range []string{"first", "second", "first"} {
+			reply, err := provider.Complete(context.Background(), messages)
+			require.NoError(t, err)
+			assert.Equal(t, expected, reply)
+		}
+		assert.Len(t, provider.Calls(), 3)
+	})
+
+	t.Run("echoes without script", func(t *testing.T) {
+		provider := llm.NewFakeProvider()
+
+		reply, err := provider.Complete(context.Background(), []domain.LLMMessage{
+			{Role: domain.LLMRoleSystem, Content: "Be brief"},
+			{Role: domain.LLMRoleUser, Content: "Where is my order?"},
+		})
+
+		require.NoError(t, err)
+		assert.Equal(t, "You said: Where is my order?", reply)
+	})
+}
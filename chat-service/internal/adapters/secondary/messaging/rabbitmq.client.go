@@ -9,12 +9,17 @@ import (
 	"log"
 	"time"
 
+	"github.com/google/uuid"
 	amqp "github.com/rabbitmq/amqp091-go"
 )
 
 type RabbitMQClient struct {
 	conn    AMQPConnection // Interface instead of concrete *amqp.Connection
 	channel AMQPChannel    // Interface instead of concrete *amqp.Channel
+
+	// Identifies this chat-service replica on the messages it publishes and
+	// names the queue it consumes them from
+	instanceID string
 }
 var _ ports.MessagePublisher = (*RabbitMQClient)(nil)
 
@@ -49,19 +54,32 @@ func NewRabbitMQClient(url string) (*RabbitMQClient, error) {
 	log.Println("Successfully connected to RabbitMQ")
 
 	return &RabbitMQClient{
-		conn:    conn, // amqp.Connection satisfies AMQPConnection
-		channel: ch,   // amqp.Channel satisfies AMQPChannel
+		conn:       conn, // amqp.Connection satisfies AMQPConnection
+		channel:    ch,   // amqp.Channel satisfies AMQPChannel
+		instanceID: uuid.New().String(),
 	}, nil
 }
 
 // NewRabbitMQClientWithDependencies creates a client with provided dependencies (for testing)
 func NewRabbitMQClientWithDependencies(conn AMQPConnection, ch AMQPChannel) *RabbitMQClient {
 	return &RabbitMQClient{
-		conn:    conn,
-		channel: ch,
+		conn:       conn,
+		channel:    ch,
+		instanceID: uuid.New().String(),
 	}
 }
 
+// SetInstanceID names this replica. It must be unique among the running
+// replicas and set before subscribing.
+func (r *RabbitMQClient) SetInstanceID(instanceID string) {
+	r.instanceID = instanceID
+}
+
+// InstanceID returns the name of this replica
+func (r *RabbitMQClient) InstanceID() string {
+	return r.instanceID
+}
+
 func (r *RabbitMQClient) PublishChatMessage(message *domain.Message) error {
 	body, err := json.Marshal(message)
 	if err != nil {
@@ -78,18 +96,24 @@ func (r *RabbitMQClient) PublishChatMessage(message *domain.Message) error {
 			Body:         body,
 			DeliveryMode: amqp.Persistent,
 			Timestamp:    time.Now(),
+			MessageId:    message.ID,
+			AppId:        r.instanceID,
 		},
 	)
 }
 
+// SubscribeToMessages consumes the chat messages published by every replica
+// from a queue of this replica's own, so each one can deliver them to the
+// clients connected to it. Messages this replica published are skipped, as
+// it already delivered them directly.
 func (r *RabbitMQClient) SubscribeToMessages(handler func(*domain.Message)) error {
 	q, err := r.channel.QueueDeclare(
-		"chat_service_queue", // name
-		true,                 // durable
-		false,                // delete when unused
-		false,                // exclusive
-		false,                // no-wait
-		nil,                  // arguments
+		"chat_service."+r.instanceID, // name
+		false,                        // durable
+		true,                         // delete when unused
+		true,                         // exclusive
+		false,                        // no-wait
+		nil,                          // arguments
 	)
 	if err != nil {
 		return err
@@ -97,7 +121,7 @@ func (r *RabbitMQClient) SubscribeToMessages(handler func(*domain.Message)) erro
 
 	err = r.channel.QueueBind(
 		q.Name,          // queue name
-		"bot.responses", // routing key
+		"chat.messages", // routing key
 		"chat_events",   // exchange
 		false,           // no-wait
 		nil,             // arguments
@@ -121,6 +145,10 @@ func (r *RabbitMQClient) SubscribeToMessages(handler func(*domain.Message)) erro
 
 	go func() {
 		for d := range msgs {
+			if d.AppId == r.instanceID {
+				continue
+			}
+
 			var message domain.Message
 			if err := json.Unmarshal(d.Body, &message); err != nil {
 				log.Printf("Error unmarshaling message: %v", err)
@@ -134,6 +162,173 @@ func (r *RabbitMQClient) SubscribeToMessages(handler func(*domain.Message)) erro
 	return nil
 }
 
+// PublishEscalation asks the CRM to open a ticket for a conversation
+func (r *RabbitMQClient) PublishEscalation(escalation *domain.Escalation) error {
+	body, err := json.Marshal(escalation)
+	if err != nil {
+		return err
+	}
+
+	return r.channel.Publish(
+		"chat_events",      // exchange
+		"chat.escalations", // routing key
+		false,              // mandatory
+		false,              // immediate
+		amqp.Publishing{
+			ContentType:  "application/json",
+			Body:         body,
+			DeliveryMode: amqp.Persistent,
+			Timestamp:    time.Now(),
+		},
+	)
+}
+
+// PublishConversationEvent announces a conversation closing or reopening
+// under the event's type as routing key
+func (r *RabbitMQClient) PublishConversationEvent(event *domain.ConversationEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	return r.channel.Publish(
+		"chat_events", // exchange
+		event.Type,    // routing key
+		false,         // mandatory
+		false,         // immediate
+		amqp.Publishing{
+			ContentType:  "application/json",
+			Body:         body,
+			DeliveryMode: amqp.Persistent,
+			Timestamp:    event.OccurredAt,
+		},
+	)
+}
+
+// SubscribeToConversationEvents consumes every conversation event, including
+// the ones this replica published, from a queue of this replica's own
+func (r *RabbitMQClient) SubscribeToConversationEvents(handler func(*domain.ConversationEvent)) error {
+	q, err := r.channel.QueueDeclare(
+		"chat_service.conversations."+r.instanceID, // name
+		false, // durable
+		true,  // delete when unused
+		true,  // exclusive
+		false, // no-wait
+		nil,   // arguments
+	)
+	if err != nil {
+		return err
+	}
+
+	err = r.channel.QueueBind(
+		q.Name,           // queue name
+		"conversation.*", // routing key
+		"chat_events",    // exchange
+		false,            // no-wait
+		nil,              // arguments
+	)
+	if err != nil {
+		return err
+	}
+
+	events, err := r.channel.Consume(
+		q.Name, // queue
+		"",     // consumer
+		true,   // auto-ack
+		false,  // exclusive
+		false,  // no-local
+		false,  // no-wait
+		nil,    // args
+	)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		for d := range events {
+			var event domain.ConversationEvent
+			if err := json.Unmarshal(d.Body, &event); err != nil {
+				log.Printf("Error unmarshaling conversation event: %v", err)
+				continue
+			}
+
+			handler(&event)
+		}
+	}()
+
+	return nil
+}
+
+// SubscribeToEscalationTickets consumes the tickets crm-service opens for
+// escalated conversations from its own exchange
+func (r *RabbitMQClient) SubscribeToEscalationTickets(handler func(*domain.EscalationTicket)) error {
+	err := r.channel.ExchangeDeclare(
+		"crm_events", // name
+		"topic",      // type
+		true,         // durable
+		false,        // auto-deleted
+		false,        // internal
+		false,        // no-wait
+		nil,          // arguments
+	)
+	if err != nil {
+		return err
+	}
+
+	q, err := r.channel.QueueDeclare(
+		"chat_service_escalations", // name
+		true,                       // durable
+		false,                      // delete when unused
+		false,                      // exclusive
+		false,                      // no-wait
+		nil,                        // arguments
+	)
+	if err != nil {
+		return err
+	}
+
+	err = r.channel.QueueBind(
+		q.Name,              // queue name
+		"tickets.escalated", // routing key
+		"crm_events",        // exchange
+		false,               // no-wait
+		nil,                 // arguments
+	)
+	if err != nil {
+		return err
+	}
+
+	msgs, err := r.channel.Consume(
+		q.Name, // queue
+		"",     // consumer
+		true,   // auto-ack
+		false,  // exclusive
+		false,  // no-local
+		false,  // no-wait
+		nil,    // args
+	)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		for d := range msgs {
+			// crm-service wraps its payloads in an event envelope
+			var event struct {
+				Data domain.EscalationTicket `json:"data"`
+			}
+			if err := json.Unmarshal(d.Body, &event); err != nil {
+				log.Printf("Error unmarshaling escalation ticket: %v", err)
+				continue
+			}
+
+			handler(&event.Data)
+		}
+	}()
+
+	return nil
+}
+
 func (r *RabbitMQClient) Close() {
 	if r.channel != nil {
 		r.channel.Close()
@@ -145,3 +340,4 @@ func (r *RabbitMQClient) Close() {
 
 // Ensure RabbitMQClient implements MessagePublisher interface
 var _ ports.MessagePublisher = (*RabbitMQClient)(nil)
+var _ ports.EscalationPublisher = (*RabbitMQClient)(nil)
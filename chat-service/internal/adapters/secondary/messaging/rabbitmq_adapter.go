@@ -12,6 +12,9 @@ type RabbitMQAdapter struct {
 
 // Ensure it implements the interface
 var _ ports.MessagePublisher = (*RabbitMQAdapter)(nil)
+var _ ports.EscalationPublisher = (*RabbitMQAdapter)(nil)
+var _ ports.ConversationEventPublisher = (*RabbitMQAdapter)(nil)
+var _ ports.ConversationEventSubscriber = (*RabbitMQAdapter)(nil)
 
 func NewRabbitMQAdapter(client *RabbitMQClient) *RabbitMQAdapter {
 	return &RabbitMQAdapter{client: client}
@@ -25,6 +28,22 @@ func (a *RabbitMQAdapter) SubscribeToMessages(handler func(*domain.Message)) err
 	return a.client.SubscribeToMessages(handler)
 }
 
+func (a *RabbitMQAdapter) PublishEscalation(escalation *domain.Escalation) error {
+	return a.client.PublishEscalation(escalation)
+}
+
+func (a *RabbitMQAdapter) SubscribeToEscalationTickets(handler func(*domain.EscalationTicket)) error {
+	return a.client.SubscribeToEscalationTickets(handler)
+}
+
+func (a *RabbitMQAdapter) PublishConversationEvent(event *domain.ConversationEvent) error {
+	return a.client.PublishConversationEvent(event)
+}
+
+func (a *RabbitMQAdapter) SubscribeToConversationEvents(handler func(*domain.ConversationEvent)) error {
+	return a.client.SubscribeToConversationEvents(handler)
+}
+
 // Close closes the underlying RabbitMQ client connection.
 func (a *RabbitMQAdapter) Close() {
 	a.client.Close()
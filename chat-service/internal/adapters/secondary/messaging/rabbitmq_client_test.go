@@ -85,8 +85,9 @@ func TestRabbitMQClientWithMocks(t *testing.T) {
 
 		// Create client with our custom constructor that uses interfaces
 		client := &RabbitMQClient{
-			conn:    mockConn,
-			channel: mockChan,
+			conn:       mockConn,
+			channel:    mockChan,
+			instanceID: "instance-a",
 		}
 
 		// Test message
@@ -110,7 +111,9 @@ func TestRabbitMQClientWithMocks(t *testing.T) {
 				err := json.Unmarshal(msg.Body, &decodedMsg)
 				return err == nil &&
 					msg.ContentType == "application/json" &&
-					decodedMsg.ID == message.ID
+					decodedMsg.ID == message.ID &&
+					msg.MessageId == message.ID &&
+					msg.AppId == "instance-a"
 			})).Return(nil)
 
 		// Act
@@ -127,34 +130,35 @@ func TestRabbitMQClientWithMocks(t *testing.T) {
 		mockChan := new(MockAMQPChannel)
 
 		client := &RabbitMQClient{
-			conn:    mockConn,
-			channel: mockChan,
+			conn:       mockConn,
+			channel:    mockChan,
+			instanceID: "instance-a",
 		}
 
-		// Create a queue for the result
-		queue := amqp.Queue{Name: "chat_service_queue"}
+		// Every replica consumes from a queue of its own
+		queue := amqp.Queue{Name: "chat_service.instance-a"}
 
 		// Create a channel for test deliveries
 		deliveries := make(chan amqp.Delivery)
 
 		// Setup expectations with correct parameter types
 		mockChan.On("QueueDeclare",
-			"chat_service_queue",
-			true,
-			false,
+			"chat_service.instance-a",
 			false,
+			true,
+			true,
 			false,
 			amqp.Table(nil)).Return(queue, nil)
 
 		mockChan.On("QueueBind",
-			"chat_service_queue",
-			"bot.responses",
+			"chat_service.instance-a",
+			"chat.messages",
 			"chat_events",
 			false,
 			amqp.Table(nil)).Return(nil)
 
 		mockChan.On("Consume",
-			"chat_service_queue",
+			"chat_service.instance-a",
 			"",
 			true,
 			false,
@@ -163,38 +167,248 @@ func TestRabbitMQClientWithMocks(t *testing.T) {
 			amqp.Table(nil)).Return((<-chan amqp.Delivery)(deliveries), nil)
 
 		// Message handler for testing
-		var receivedMsg *domain.Message
+		received := make(chan *domain.Message, 2)
 		handler := func(msg *domain.Message) {
-			receivedMsg = msg
+			received <- msg
 		}
 
 		// Act - subscribe to messages
 		err := client.SubscribeToMessages(handler)
 		assert.NoError(t, err)
 
-		// Simulate a message delivery
+		// Simulate a message published by this replica, then one published
+		// by another
+		own, _ := json.Marshal(&domain.Message{ID: "msg0", Content: "Already delivered"})
 		testMessage := &domain.Message{
 			ID:        "msg1",
 			Content:   "Hello from bot",
 			Type:      domain.BotMessage,
 			Timestamp: time.Now(),
 		}
-
 		msgBytes, _ := json.Marshal(testMessage)
 
-		// Send test message through the channel
 		go func() {
-			deliveries <- amqp.Delivery{Body: msgBytes}
+			deliveries <- amqp.Delivery{Body: own, AppId: "instance-a"}
+			deliveries <- amqp.Delivery{Body: msgBytes, AppId: "instance-b"}
 		}()
 
-		// Wait a bit for processing
-		time.Sleep(100 * time.Millisecond)
-
-		// Assert the message was processed
-		assert.NotNil(t, receivedMsg)
-		if receivedMsg != nil {
+		// Assert only the other replica's message was handled
+		select {
+		case receivedMsg := <-received:
 			assert.Equal(t, testMessage.ID, receivedMsg.ID)
 			assert.Equal(t, testMessage.Content, receivedMsg.Content)
+		case <-time.After(time.Second):
+			t.Fatal("message was not delivered")
+		}
+		assert.Empty(t, received)
+
+		mockChan.AssertExpectations(t)
+	})
+
+	t.Run("PublishEscalation", func(t *testing.T) {
+		mockConn := new(MockAMQPConnection)
+		mockChan := new(MockAMQPChannel)
+
+		client := &RabbitMQClient{
+			conn:    mockConn,
+			channel: mockChan,
+		}
+
+		escalation := &domain.Escalation{
+			ID:             "esc-1",
+			ConversationID: "conv-1",
+			CustomerID:     "customer1",
+			Reason:         domain.EscalationCustomerRequest,
+			Transcript: []domain.Message{
+				{ID: "msg1", Content: "I want to talk to a human", Type: domain.UserMessage},
+			},
+		}
+
+		mockChan.On("Publish",
+			"chat_events",
+			"chat.escalations",
+			false,
+			false,
+			mock.MatchedBy(func(msg amqp.Publishing) bool {
+				var decoded domain.Escalation
+				err := json.Unmarshal(msg.Body, &decoded)
+				return err == nil &&
+					decoded.ConversationID == "conv-1" &&
+					len(decoded.Transcript) == 1
+			})).Return(nil)
+
+		err := client.PublishEscalation(escalation)
+
+		assert.NoError(t, err)
+		mockChan.AssertExpectations(t)
+	})
+
+	t.Run("PublishConversationEvent", func(t *testing.T) {
+		mockConn := new(MockAMQPConnection)
+		mockChan := new(MockAMQPChannel)
+
+		client := &RabbitMQClient{
+			conn:    mockConn,
+			channel: mockChan,
+		}
+
+		event := &domain.ConversationEvent{
+			Type:           domain.ConversationClosedEvent,
+			ConversationID: "conv-1",
+			CustomerID:     "customer1",
+			Reason:         domain.CloseReasonIdle,
+			OccurredAt:     time.Now(),
+		}
+
+		mockChan.On("Publish",
+			"chat_events",
+			"conversation.closed",
+			false,
+			false,
+			mock.MatchedBy(func(msg amqp.Publishing) bool {
+				var decoded domain.ConversationEvent
+				err := json.Unmarshal(msg.Body, &decoded)
+				return err == nil &&
+					decoded.ConversationID == "conv-1" &&
+					decoded.Reason == domain.CloseReasonIdle
+			})).Return(nil)
+
+		err := client.PublishConversationEvent(event)
+
+		assert.NoError(t, err)
+		mockChan.AssertExpectations(t)
+	})
+
+	t.Run("SubscribeToConversationEvents", func(t *testing.T) {
+		mockConn := new(MockAMQPConnection)
+		mockChan := new(MockAMQPChannel)
+
+		client := &RabbitMQClient{
+			conn:       mockConn,
+			channel:    mockChan,
+			instanceID: "instance-a",
+		}
+
+		queue := amqp.Queue{Name: "chat_service.conversations.instance-a"}
+		deliveries := make(chan amqp.Delivery)
+
+		mockChan.On("QueueDeclare",
+			"chat_service.conversations.instance-a",
+			false,
+			true,
+			true,
+			false,
+			amqp.Table(nil)).Return(queue, nil)
+
+		mockChan.On("QueueBind",
+			"chat_service.conversations.instance-a",
+			"conversation.*",
+			"chat_events",
+			false,
+			amqp.Table(nil)).Return(nil)
+
+		mockChan.On("Consume",
+			"chat_service.conversations.instance-a",
+			"",
+			true,
+			false,
+			false,
+			false,
+			amqp.Table(nil)).Return((<-chan amqp.Delivery)(deliveries), nil)
+
+		received := make(chan *domain.ConversationEvent, 1)
+		err := client.SubscribeToConversationEvents(func(event *domain.ConversationEvent) {
+			received <- event
+		})
+		assert.NoError(t, err)
+
+		// Events published by this replica are consumed too
+		body, _ := json.Marshal(domain.ConversationEvent{
+			Type:           domain.ConversationClosedEvent,
+			ConversationID: "conv-1",
+		})
+		go func() {
+			deliveries <- amqp.Delivery{Body: body, AppId: "instance-a"}
+		}()
+
+		select {
+		case event := <-received:
+			assert.Equal(t, domain.ConversationClosedEvent, event.Type)
+			assert.Equal(t, "conv-1", event.ConversationID)
+		case <-time.After(time.Second):
+			t.Fatal("conversation event was not delivered")
+		}
+
+		mockChan.AssertExpectations(t)
+	})
+
+	t.Run("SubscribeToEscalationTickets", func(t *testing.T) {
+		mockConn := new(MockAMQPConnection)
+		mockChan := new(MockAMQPChannel)
+
+		client := &RabbitMQClient{
+			conn:    mockConn,
+			channel: mockChan,
+		}
+
+		queue := amqp.Queue{Name: "chat_service_escalations"}
+		deliveries := make(chan amqp.Delivery)
+
+		mockChan.On("ExchangeDeclare",
+			"crm_events",
+			"topic",
+			true,
+			false,
+			false,
+			false,
+			amqp.Table(nil)).Return(nil)
+
+		mockChan.On("QueueDeclare",
+			"chat_service_escalations",
+			true,
+			false,
+			false,
+			false,
+			amqp.Table(nil)).Return(queue, nil)
+
+		mockChan.On("QueueBind",
+			"chat_service_escalations",
+			"tickets.escalated",
+			"crm_events",
+			false,
+			amqp.Table(nil)).Return(nil)
+
+		mockChan.On("Consume",
+			"chat_service_escalations",
+			"",
+			true,
+			false,
+			false,
+			false,
+			amqp.Table(nil)).Return((<-chan amqp.Delivery)(deliveries), nil)
+
+		received := make(chan *domain.EscalationTicket, 1)
+		err := client.SubscribeToEscalationTickets(func(ticket *domain.EscalationTicket) {
+			received <- ticket
+		})
+		assert.NoError(t, err)
+
+		body, _ := json.Marshal(map[string]interface{}{
+			"event_type":    "escalated",
+			"resource_id":   "ticket-1",
+			"resource_type": "ticket",
+			"data":          domain.EscalationTicket{ConversationID: "conv-1", TicketID: "ticket-1"},
+		})
+		go func() {
+			deliveries <- amqp.Delivery{Body: body}
+		}()
+
+		select {
+		case ticket := <-received:
+			assert.Equal(t, "ticket-1", ticket.TicketID)
+			assert.Equal(t, "conv-1", ticket.ConversationID)
+		case <-time.After(time.Second):
+			t.Fatal("escalation ticket was not delivered")
 		}
 
 		mockChan.AssertExpectations(t)
@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"chat-service/internal/core/domain"
+	"chat-service/internal/core/ports"
+	"context"
+	"database/sql"
+)
+
+// PostgresAttachmentRepository implements AttachmentRepository. The files
+// themselves live in a blob store under each attachment's storage key.
+type PostgresAttachmentRepository struct {
+	db *sql.DB
+}
+
+var _ ports.AttachmentRepository = (*PostgresAttachmentRepository)(nil)
+
+// NewPostgresAttachmentRepository creates a new PostgresAttachmentRepository
+func NewPostgresAttachmentRepository(db *sql.DB) *PostgresAttachmentRepository {
+	return &PostgresAttachmentRepository{db: db}
+}
+
+// InitSchema creates the required tables if they don't exist
+func (r *PostgresAttachmentRepository) InitSchema(ctx context.Context) error {
+	_, err := r.db.ExecContext(ctx, `
+        CREATE TABLE IF NOT EXISTS attachments (
+            id VARCHAR(36) PRIMARY KEY,
+            conversation_id VARCHAR(36) NOT NULL REFERENCES conversations(id) ON DELETE CASCADE,
+            customer_id VARCHAR(36) NOT NULL,
+            uploaded_by VARCHAR(36) NOT NULL,
+            file_name VARCHAR(255) NOT NULL,
+            content_type VARCHAR(100) NOT NULL,
+            size BIGINT NOT NULL,
+            storage_key TEXT NOT NULL,
+            created_at TIMESTAMP NOT NULL
+        )
+    `)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.ExecContext(ctx, `
+        CREATE INDEX IF NOT EXISTS idx_attachments_conversation
+            ON attachments (conversation_id)
+    `)
+	return err
+}
+
+// SaveAttachment stores an uploaded attachment
+func (r *PostgresAttachmentRepository) SaveAttachment(ctx context.Context, attachment *domain.Attachment) error {
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO attachments (id, conversation_id, customer_id, uploaded_by, file_name, content_type, size, storage_key, created_at)
+         VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+		attachment.ID, attachment.ConversationID, attachment.CustomerID, attachment.UploadedBy,
+		attachment.FileName, attachment.ContentType, attachment.Size, attachment.StorageKey, attachment.CreatedAt,
+	)
+	return err
+}
+
+// GetAttachment returns an attachment by ID
+func (r *PostgresAttachmentRepository) GetAttachment(ctx context.Context, id string) (*domain.Attachment, error) {
+	var attachment domain.Attachment
+	err := r.db.QueryRowContext(ctx,
+		`SELECT id, conversation_id, customer_id, uploaded_by, file_name, content_type, size, storage_key, created_at
+         FROM attachments WHERE id = $1`,
+		id,
+	).Scan(&attachment.ID, &attachment.ConversationID, &attachment.CustomerID, &attachment.UploadedBy,
+		&attachment.FileName, &attachment.ContentType, &attachment.Size, &attachment.StorageKey, &attachment.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, domain.ErrAttachmentNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &attachment, nil
+}
@@ -0,0 +1,178 @@
+package repository
+
+import (
+	"chat-service/internal/core/domain"
+	"chat-service/internal/core/ports"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// PostgresFlowRepository implements FlowRepository. Flow definitions are
+// stored as JSON documents; conversation progress sits next to the
+// conversations table.
+type PostgresFlowRepository struct {
+	db *sql.DB
+}
+
+var _ ports.FlowRepository = (*PostgresFlowRepository)(nil)
+
+// NewPostgresFlowRepository creates a new PostgresFlowRepository
+func NewPostgresFlowRepository(db *sql.DB) *PostgresFlowRepository {
+	return &PostgresFlowRepository{db: db}
+}
+
+// InitSchema creates the required tables if they don't exist
+func (r *PostgresFlowRepository) InitSchema(ctx context.Context) error {
+	_, err := r.db.ExecContext(ctx, `
+        CREATE TABLE IF NOT EXISTS dialog_flows (
+            id VARCHAR(100) PRIMARY KEY,
+            definition JSONB NOT NULL,
+            updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+        )
+    `)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.ExecContext(ctx, `
+        CREATE TABLE IF NOT EXISTS conversation_flow_states (
+            conversation_id VARCHAR(36) PRIMARY KEY REFERENCES conversations(id) ON DELETE CASCADE,
+            flow_id VARCHAR(100) NOT NULL,
+            step_id VARCHAR(100) NOT NULL,
+            slots JSONB NOT NULL DEFAULT '{}',
+            attempts INTEGER NOT NULL DEFAULT 0,
+            status VARCHAR(20) NOT NULL,
+            started_at TIMESTAMP NOT NULL,
+            updated_at TIMESTAMP NOT NULL
+        )
+    `)
+	return err
+}
+
+// GetFlows fetches all flow definitions
+func (r *PostgresFlowRepository) GetFlows(ctx context.Context) ([]domain.Flow, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT definition, updated_at FROM dialog_flows ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var flows []domain.Flow
+	for rows.Next() {
+		var definition []byte
+		var flow domain.Flow
+		var updatedAt time.Time
+		if err := rows.Scan(&definition, &updatedAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(definition, &flow); err != nil {
+			return nil, fmt.Errorf("failed to decode flow definition: %w", err)
+		}
+		flow.UpdatedAt = updatedAt
+		flows = append(flows, flow)
+	}
+
+	return flows, rows.Err()
+}
+
+// SaveFlow creates or replaces a flow definition
+func (r *PostgresFlowRepository) SaveFlow(ctx context.Context, flow *domain.Flow) error {
+	flow.UpdatedAt = time.Now()
+
+	definition, err := json.Marshal(flow)
+	if err != nil {
+		return fmt.Errorf("failed to encode flow definition: %w", err)
+	}
+
+	_, err = r.db.ExecContext(ctx,
+		`INSERT INTO dialog_flows (id, definition, updated_at)
+         VALUES ($1, $2, $3)
+         ON CONFLICT (id) DO UPDATE SET definition = EXCLUDED.definition, updated_at = EXCLUDED.updated_at`,
+		flow.ID, string(definition), flow.UpdatedAt,
+	)
+	return err
+}
+
+// DeleteFlow deletes a flow definition
+func (r *PostgresFlowRepository) DeleteFlow(ctx context.Context, id string) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM dialog_flows WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return errors.New("flow not found")
+	}
+
+	return nil
+}
+
+// GetFlowState fetches the flow progress of a conversation
+func (r *PostgresFlowRepository) GetFlowState(ctx context.Context, conversationID string) (*domain.FlowState, error) {
+	var state domain.FlowState
+	var slots []byte
+	err := r.db.QueryRowContext(ctx,
+		`SELECT conversation_id, flow_id, step_id, slots, attempts, status, started_at, updated_at
+         FROM conversation_flow_states
+         WHERE conversation_id = $1`,
+		conversationID,
+	).Scan(
+		&state.ConversationID,
+		&state.FlowID,
+		&state.StepID,
+		&slots,
+		&state.Attempts,
+		&state.Status,
+		&state.StartedAt,
+		&state.UpdatedAt,
+	)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil // Not in a flow
+		}
+		return nil, err
+	}
+
+	if err := json.Unmarshal(slots, &state.Slots); err != nil {
+		return nil, fmt.Errorf("failed to decode flow slots: %w", err)
+	}
+
+	return &state, nil
+}
+
+// SaveFlowState creates or replaces the flow progress of a conversation
+func (r *PostgresFlowRepository) SaveFlowState(ctx context.Context, state *domain.FlowState) error {
+	state.UpdatedAt = time.Now()
+
+	slots, err := json.Marshal(state.Slots)
+	if err != nil {
+		return fmt.Errorf("failed to encode flow slots: %w", err)
+	}
+
+	_, err = r.db.ExecContext(ctx,
+		`INSERT INTO conversation_flow_states
+             (conversation_id, flow_id, step_id, slots, attempts, status, started_at, updated_at)
+         VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+         ON CONFLICT (conversation_id) DO UPDATE SET
+             flow_id = EXCLUDED.flow_id,
+             step_id = EXCLUDED.step_id,
+             slots = EXCLUDED.slots,
+             attempts = EXCLUDED.attempts,
+             status = EXCLUDED.status,
+             started_at = EXCLUDED.started_at,
+             updated_at = EXCLUDED.updated_at`,
+		state.ConversationID, state.FlowID, state.StepID, string(slots),
+		state.Attempts, state.Status, state.StartedAt, state.UpdatedAt,
+	)
+	return err
+}
@@ -5,6 +5,12 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/lib/pq"
@@ -13,6 +19,9 @@ import (
 // PostgresKnowledgeRepository implements KnowledgeRepository
 type PostgresKnowledgeRepository struct {
 	db *sql.DB
+
+	// vectorSearch is set when the embeddings table uses the pgvector type
+	vectorSearch bool
 }
 
 // NewPostgresKnowledgeRepository creates a new PostgresKnowledgeRepository
@@ -46,8 +55,57 @@ func (r *PostgresKnowledgeRepository) InitSchema(ctx context.Context) error {
         CREATE INDEX IF NOT EXISTS idx_knowledge_entries_keywords 
         ON knowledge_entries USING GIN (keywords)
     `)
-    
-    return err
+    if err != nil {
+        return err
+    }
+
+    _, err = r.db.ExecContext(ctx, `
+        CREATE TABLE IF NOT EXISTS knowledge_synonyms (
+            term VARCHAR(100) PRIMARY KEY,
+            synonyms TEXT[] NOT NULL,
+            updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+        )
+    `)
+    if err != nil {
+        return err
+    }
+
+    return r.initEmbeddingSchema(ctx)
+}
+
+// initEmbeddingSchema creates the embeddings table, using pgvector when the
+// extension can be installed and a plain REAL[] column otherwise
+func (r *PostgresKnowledgeRepository) initEmbeddingSchema(ctx context.Context) error {
+	columnType := "REAL[]"
+	if _, err := r.db.ExecContext(ctx, `CREATE EXTENSION IF NOT EXISTS vector`); err == nil {
+		columnType = "vector"
+	} else {
+		log.Printf("pgvector not available, falling back to in-memory similarity search: %v", err)
+	}
+
+	_, err := r.db.ExecContext(ctx, `
+        CREATE TABLE IF NOT EXISTS knowledge_embeddings (
+            entry_id VARCHAR(100) PRIMARY KEY REFERENCES knowledge_entries(id) ON DELETE CASCADE,
+            embedding `+columnType+` NOT NULL,
+            updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+        )
+    `)
+	if err != nil {
+		return err
+	}
+
+	// The table may predate the extension, so check what it actually holds
+	var udtName string
+	err = r.db.QueryRowContext(ctx, `
+        SELECT udt_name FROM information_schema.columns
+        WHERE table_name = 'knowledge_embeddings' AND column_name = 'embedding'
+    `).Scan(&udtName)
+	if err != nil {
+		return err
+	}
+	r.vectorSearch = udtName == "vector"
+
+	return nil
 }
 // GetAllEntries fetches all knowledge base entries
 func (r *PostgresKnowledgeRepository) GetAllEntries(ctx context.Context) ([]domain.KnowledgeEntry, error) {
@@ -222,3 +280,196 @@ func (r *PostgresKnowledgeRepository) SearchEntries(ctx context.Context, query s
 
 	return entries, nil
 }
+
+
+// GetSynonyms fetches all synonym groups
+func (r *PostgresKnowledgeRepository) GetSynonyms(ctx context.Context) ([]domain.Synonym, error) {
+	query := `SELECT term, synonyms, updated_at FROM knowledge_synonyms ORDER BY term`
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var synonyms []domain.Synonym
+	for rows.Next() {
+		var synonym domain.Synonym
+		if err := rows.Scan(&synonym.Term, pq.Array(&synonym.Synonyms), &synonym.UpdatedAt); err != nil {
+			return nil, err
+		}
+		synonyms = append(synonyms, synonym)
+	}
+
+	return synonyms, rows.Err()
+}
+
+// SaveSynonym creates or replaces a synonym group
+func (r *PostgresKnowledgeRepository) SaveSynonym(ctx context.Context, synonym *domain.Synonym) error {
+	query := `INSERT INTO knowledge_synonyms (term, synonyms, updated_at)
+              VALUES ($1, $2, $3)
+              ON CONFLICT (term) DO UPDATE SET synonyms = EXCLUDED.synonyms, updated_at = EXCLUDED.updated_at`
+
+	synonym.UpdatedAt = time.Now()
+
+	_, err := r.db.ExecContext(ctx, query, synonym.Term, pq.Array(synonym.Synonyms), synonym.UpdatedAt)
+	return err
+}
+
+// DeleteSynonym deletes a synonym group
+func (r *PostgresKnowledgeRepository) DeleteSynonym(ctx context.Context, term string) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM knowledge_synonyms WHERE term = $1`, term)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return errors.New("synonym not found")
+	}
+
+	return nil
+}
+
+// SaveEmbedding stores or replaces the embedding for a knowledge entry
+func (r *PostgresKnowledgeRepository) SaveEmbedding(ctx context.Context, entryID string, embedding []float32) error {
+	var value interface{} = pq.Array(embedding)
+	placeholder := "$2"
+	if r.vectorSearch {
+		value = vectorLiteral(embedding)
+		placeholder = "$2::vector"
+	}
+
+	query := `INSERT INTO knowledge_embeddings (entry_id, embedding, updated_at)
+              VALUES ($1, ` + placeholder + `, NOW())
+              ON CONFLICT (entry_id) DO UPDATE SET embedding = EXCLUDED.embedding, updated_at = NOW()`
+
+	_, err := r.db.ExecContext(ctx, query, entryID, value)
+	return err
+}
+
+// SearchByEmbedding returns the entries closest to the embedding by cosine similarity
+func (r *PostgresKnowledgeRepository) SearchByEmbedding(ctx context.Context, embedding []float32, limit int) ([]domain.ScoredMatch, error) {
+	if r.vectorSearch {
+		return r.searchWithVector(ctx, embedding, limit)
+	}
+	return r.searchInMemory(ctx, embedding, limit)
+}
+
+// searchWithVector lets pgvector rank entries by cosine distance
+func (r *PostgresKnowledgeRepository) searchWithVector(ctx context.Context, embedding []float32, limit int) ([]domain.ScoredMatch, error) {
+	query := `
+        SELECT e.id, e.question, e.answer, e.keywords, e.category, e.created_at, e.updated_at,
+               1 - (k.embedding <=> $1::vector) AS score
+        FROM knowledge_embeddings k
+        JOIN knowledge_entries e ON e.id = k.entry_id
+        ORDER BY k.embedding <=> $1::vector
+        LIMIT $2
+    `
+
+	rows, err := r.db.QueryContext(ctx, query, vectorLiteral(embedding), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var matches []domain.ScoredMatch
+	for rows.Next() {
+		var match domain.ScoredMatch
+		if err := rows.Scan(
+			&match.Entry.ID,
+			&match.Entry.Question,
+			&match.Entry.Answer,
+			pq.Array(&match.Entry.Keywords),
+			&match.Entry.Category,
+			&match.Entry.CreatedAt,
+			&match.Entry.UpdatedAt,
+			&match.Score,
+		); err != nil {
+			return nil, err
+		}
+		matches = append(matches, match)
+	}
+
+	return matches, rows.Err()
+}
+
+// searchInMemory loads every stored embedding and ranks them in Go
+func (r *PostgresKnowledgeRepository) searchInMemory(ctx context.Context, embedding []float32, limit int) ([]domain.ScoredMatch, error) {
+	query := `
+        SELECT e.id, e.question, e.answer, e.keywords, e.category, e.created_at, e.updated_at, k.embedding
+        FROM knowledge_embeddings k
+        JOIN knowledge_entries e ON e.id = k.entry_id
+    `
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var matches []domain.ScoredMatch
+	for rows.Next() {
+		var match domain.ScoredMatch
+		var stored pq.Float32Array
+		if err := rows.Scan(
+			&match.Entry.ID,
+			&match.Entry.Question,
+			&match.Entry.Answer,
+			pq.Array(&match.Entry.Keywords),
+			&match.Entry.Category,
+			&match.Entry.CreatedAt,
+			&match.Entry.UpdatedAt,
+			&stored,
+		); err != nil {
+			return nil, err
+		}
+		match.Score = CosineSimilarity(embedding, stored)
+		matches = append(matches, match)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].Score > matches[j].Score
+	})
+	if limit > 0 && len(matches) > limit {
+		matches = matches[:limit]
+	}
+
+	return matches, nil
+}
+
+// CosineSimilarity returns the cosine of the angle between two vectors, or 0
+// when they differ in length or either is all zeros
+func CosineSimilarity(a, b []float32) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
+
+// vectorLiteral formats an embedding in pgvector's text representation
+func vectorLiteral(embedding []float32) string {
+	parts := make([]string, len(embedding))
+	for i, v := range embedding {
+		parts[i] = strconv.FormatFloat(float64(v), 'f', -1, 32)
+	}
+	return fmt.Sprintf("[%s]", strings.Join(parts, ","))
+}
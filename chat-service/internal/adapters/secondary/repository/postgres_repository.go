@@ -6,10 +6,14 @@ import (
 	"chat-service/internal/core/ports"
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"html"
+	"strings"
+	"time"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
 type PostgresRepository struct {
@@ -77,6 +81,104 @@ func initTables(db *sql.DB) error {
             type VARCHAR(20) NOT NULL,
             timestamp TIMESTAMP NOT NULL
         )
+    `)
+	if err != nil {
+		return err
+	}
+
+	// Metadata was added after the initial schema
+	_, err = db.Exec(`ALTER TABLE messages ADD COLUMN IF NOT EXISTS metadata JSONB`)
+	if err != nil {
+		return err
+	}
+
+	// So were agent takeovers
+	_, err = db.Exec(`
+        ALTER TABLE conversations
+            ADD COLUMN IF NOT EXISTS mode VARCHAR(10) NOT NULL DEFAULT 'bot',
+            ADD COLUMN IF NOT EXISTS agent_id VARCHAR(36)
+    `)
+	if err != nil {
+		return err
+	}
+
+	// And delivery receipts
+	_, err = db.Exec(`
+        ALTER TABLE messages
+            ADD COLUMN IF NOT EXISTS delivery_status VARCHAR(10) NOT NULL DEFAULT 'sent',
+            ADD COLUMN IF NOT EXISTS delivered_at TIMESTAMP,
+            ADD COLUMN IF NOT EXISTS read_at TIMESTAMP
+    `)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(`
+        CREATE INDEX IF NOT EXISTS idx_messages_unread
+            ON messages (conversation_id) WHERE delivery_status <> 'read'
+    `)
+	if err != nil {
+		return err
+	}
+
+	// Idle conversations are found by their latest message
+	_, err = db.Exec(`
+        CREATE INDEX IF NOT EXISTS idx_messages_conversation_timestamp
+            ON messages (conversation_id, timestamp)
+    `)
+	if err != nil {
+		return err
+	}
+
+	// Escalated conversations are handed over to the CRM only once
+	_, err = db.Exec(`ALTER TABLE conversations ADD COLUMN IF NOT EXISTS escalated_at TIMESTAMP`)
+	if err != nil {
+		return err
+	}
+
+	// Messages carry the attachments uploaded with them
+	_, err = db.Exec(`ALTER TABLE messages ADD COLUMN IF NOT EXISTS attachments JSONB`)
+	if err != nil {
+		return err
+	}
+
+	// Messages can be edited, redacted and deleted, keeping the versions
+	// they replace in an audit table
+	_, err = db.Exec(`
+        ALTER TABLE messages
+            ADD COLUMN IF NOT EXISTS edited_at TIMESTAMP,
+            ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP
+    `)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(`
+        CREATE TABLE IF NOT EXISTS message_revisions (
+            id VARCHAR(36) PRIMARY KEY,
+            message_id VARCHAR(36) NOT NULL REFERENCES messages(id) ON DELETE CASCADE,
+            action VARCHAR(10) NOT NULL,
+            content TEXT NOT NULL,
+            attachments JSONB,
+            spans JSONB,
+            redacted BOOLEAN NOT NULL DEFAULT FALSE,
+            changed_by VARCHAR(36) NOT NULL,
+            changed_at TIMESTAMP NOT NULL
+        )
+    `)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(`
+        CREATE INDEX IF NOT EXISTS idx_message_revisions_message
+            ON message_revisions (message_id, changed_at)
+    `)
+	if err != nil {
+		return err
+	}
+
+	// Full-text search of transcripts; queries must use the same expression
+	_, err = db.Exec(`
+        CREATE INDEX IF NOT EXISTS idx_messages_content_search
+            ON messages USING GIN (to_tsvector('english', content))
     `)
 	return err
 }
@@ -88,20 +190,41 @@ func (r *PostgresRepository) SaveMessage(ctx context.Context, message *domain.Me
 	if err != nil {
 		return err
 	}
+	return insertMessage(ctx, r.db, message, conversation.ID)
+}
+
+// execer runs statements on the database or in a transaction
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// insertMessage stores a message in the conversation
+func insertMessage(ctx context.Context, db execer, message *domain.Message, conversationID string) error {
+	metadata, err := encodeMetadata(message.Metadata)
+	if err != nil {
+		return err
+	}
+	attachments, err := encodeAttachments(message.Attachments)
+	if err != nil {
+		return err
+	}
+	if message.Status == "" {
+		message.Status = domain.DeliverySent
+	}
 
 	// Insert the message - use ExecContext to pass the context
-	_, err = r.db.ExecContext(ctx,
-		`INSERT INTO messages (id, content, user_id, customer_id, conversation_id, type, timestamp)
-         VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+	_, err = db.ExecContext(ctx,
+		`INSERT INTO messages (id, content, user_id, customer_id, conversation_id, type, timestamp, delivery_status, metadata, attachments)
+         VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
 		message.ID, message.Content, message.UserID, message.CustomerID,
-		conversation.ID, message.Type, message.Timestamp,
+		conversationID, message.Type, message.Timestamp, message.Status, metadata, attachments,
 	)
 	return err
 }
 
 func (r *PostgresRepository) GetMessagesByCustomer(ctx context.Context, customerID string) ([]domain.Message, error) {
 	rows, err := r.db.QueryContext(ctx,
-		`SELECT id, content, user_id, customer_id, type, timestamp
+		`SELECT id, content, user_id, customer_id, type, timestamp, delivery_status, metadata, attachments, edited_at, deleted_at
          FROM messages
          WHERE customer_id = $1
          ORDER BY timestamp ASC`,
@@ -112,21 +235,12 @@ func (r *PostgresRepository) GetMessagesByCustomer(ctx context.Context, customer
 	}
 	defer rows.Close()
 
-	var messages []domain.Message
-	for rows.Next() {
-		var msg domain.Message
-		if err := rows.Scan(&msg.ID, &msg.Content, &msg.UserID, &msg.CustomerID, &msg.Type, &msg.Timestamp); err != nil {
-			return nil, err
-		}
-		messages = append(messages, msg)
-	}
-
-	return messages, nil
+	return scanMessages(rows)
 }
 
 func (r *PostgresRepository) GetMessagesByConversation(ctx context.Context, conversationID string) ([]domain.Message, error) {
 	rows, err := r.db.QueryContext(ctx,
-		`SELECT id, content, user_id, customer_id, type, timestamp 
+		`SELECT id, content, user_id, customer_id, type, timestamp, delivery_status, metadata, attachments, edited_at, deleted_at
          FROM messages 
          WHERE conversation_id = $1
          ORDER BY timestamp ASC`,
@@ -137,64 +251,512 @@ func (r *PostgresRepository) GetMessagesByConversation(ctx context.Context, conv
 	}
 	defer rows.Close()
 
+	return scanMessages(rows)
+}
+
+// StreamMessages reads a conversation's messages row by row, so the
+// connection stays in use until fn has seen the last one
+func (r *PostgresRepository) StreamMessages(ctx context.Context, conversationID string, fn func(*domain.Message) error) error {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT id, content, user_id, customer_id, type, timestamp, delivery_status, metadata, attachments, edited_at, deleted_at
+         FROM messages
+         WHERE conversation_id = $1
+         ORDER BY timestamp ASC, id ASC`,
+		conversationID,
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		msg, err := scanMessage(rows)
+		if err != nil {
+			return err
+		}
+		if msg.Metadata == nil {
+			msg.Metadata = make(map[string]string)
+		}
+		msg.Metadata["conversation_id"] = conversationID
+		if err := fn(msg); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// GetMessagePage pages through a conversation by (timestamp, id), so
+// messages sharing a timestamp are neither skipped nor repeated
+func (r *PostgresRepository) GetMessagePage(ctx context.Context, query domain.MessageQuery) (*domain.MessagePage, error) {
+	cursor := query.Before
+	if query.After != "" {
+		cursor = query.After
+	}
+
+	// Without a cursor the page ends at the latest message
+	var cursorTime interface{}
+	if cursor != "" {
+		var timestamp time.Time
+		err := r.db.QueryRowContext(ctx,
+			`SELECT timestamp FROM messages WHERE id = $1 AND conversation_id = $2`,
+			cursor, query.ConversationID,
+		).Scan(&timestamp)
+		if err == sql.ErrNoRows {
+			return nil, domain.ErrInvalidCursor
+		}
+		if err != nil {
+			return nil, err
+		}
+		cursorTime = timestamp
+	}
+
+	// Read one extra message to know whether there are more
+	statement := `SELECT id, content, user_id, customer_id, type, timestamp, delivery_status, metadata, attachments, edited_at, deleted_at
+         FROM messages
+         WHERE conversation_id = $1 AND ($2::timestamp IS NULL OR (timestamp, id) < ($2, $3))
+         ORDER BY timestamp DESC, id DESC
+         LIMIT $4`
+	if query.After != "" {
+		statement = `SELECT id, content, user_id, customer_id, type, timestamp, delivery_status, metadata, attachments, edited_at, deleted_at
+         FROM messages
+         WHERE conversation_id = $1 AND (timestamp, id) > ($2, $3)
+         ORDER BY timestamp ASC, id ASC
+         LIMIT $4`
+	}
+
+	rows, err := r.db.QueryContext(ctx, statement, query.ConversationID, cursorTime, cursor, query.Limit+1)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	messages, err := scanMessages(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &domain.MessagePage{Messages: messages, HasMore: len(messages) > query.Limit}
+	if page.HasMore {
+		page.Messages = page.Messages[:query.Limit]
+	}
+	if query.After == "" {
+		// Read newest first; pages are always oldest first
+		for i, j := 0, len(page.Messages)-1; i < j; i, j = i+1, j-1 {
+			page.Messages[i], page.Messages[j] = page.Messages[j], page.Messages[i]
+		}
+	}
+	if page.Messages == nil {
+		page.Messages = []domain.Message{}
+	}
+	return page, nil
+}
+
+// scanMessages reads message rows selected with their delivery status,
+// metadata, attachments and edit times as the last columns
+func scanMessages(rows *sql.Rows) ([]domain.Message, error) {
 	var messages []domain.Message
 	for rows.Next() {
-		var msg domain.Message
-		if err := rows.Scan(&msg.ID, &msg.Content, &msg.UserID, &msg.CustomerID, &msg.Type, &msg.Timestamp); err != nil {
+		msg, err := scanMessage(rows)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, *msg)
+	}
+
+	return messages, rows.Err()
+}
+
+// scanMessage reads a message row like scanMessages, followed by any extra
+// columns into extra
+func scanMessage(row rowScanner, extra ...interface{}) (*domain.Message, error) {
+	var msg domain.Message
+	var metadata, attachments []byte
+	var editedAt, deletedAt sql.NullTime
+	columns := append([]interface{}{
+		&msg.ID, &msg.Content, &msg.UserID, &msg.CustomerID, &msg.Type, &msg.Timestamp,
+		&msg.Status, &metadata, &attachments, &editedAt, &deletedAt,
+	}, extra...)
+	if err := row.Scan(columns...); err != nil {
+		return nil, err
+	}
+
+	if len(metadata) > 0 {
+		if err := json.Unmarshal(metadata, &msg.Metadata); err != nil {
+			return nil, fmt.Errorf("failed to decode metadata for message %s: %w", msg.ID, err)
+		}
+	}
+	if len(attachments) > 0 {
+		if err := json.Unmarshal(attachments, &msg.Attachments); err != nil {
+			return nil, fmt.Errorf("failed to decode attachments for message %s: %w", msg.ID, err)
+		}
+	}
+	if editedAt.Valid {
+		msg.EditedAt = &editedAt.Time
+	}
+	if deletedAt.Valid {
+		msg.DeletedAt = &deletedAt.Time
+	}
+	return &msg, nil
+}
+
+// GetMessage reads a message, naming its conversation in the metadata
+func (r *PostgresRepository) GetMessage(ctx context.Context, id string) (*domain.Message, error) {
+	var conversationID sql.NullString
+	msg, err := scanMessage(r.db.QueryRowContext(ctx,
+		`SELECT id, content, user_id, customer_id, type, timestamp, delivery_status, metadata, attachments, edited_at, deleted_at,
+                conversation_id
+         FROM messages
+         WHERE id = $1`,
+		id,
+	), &conversationID)
+	if err == sql.ErrNoRows {
+		return nil, domain.ErrMessageNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if conversationID.Valid {
+		if msg.Metadata == nil {
+			msg.Metadata = make(map[string]string)
+		}
+		msg.Metadata["conversation_id"] = conversationID.String
+	}
+	return msg, nil
+}
+
+// ReviseMessage updates a message and records the revision in one
+// transaction
+func (r *PostgresRepository) ReviseMessage(ctx context.Context, message *domain.Message, revision *domain.MessageRevision) error {
+	attachments, err := encodeAttachments(message.Attachments)
+	if err != nil {
+		return err
+	}
+	previousAttachments, err := encodeAttachments(revision.Attachments)
+	if err != nil {
+		return err
+	}
+	var spans interface{}
+	if len(revision.Spans) > 0 {
+		encoded, err := json.Marshal(revision.Spans)
+		if err != nil {
+			return fmt.Errorf("failed to encode redaction spans: %w", err)
+		}
+		spans = string(encoded)
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// The redacted text is taken from the content as it was before
+	var redactedTexts []string
+	if revision.Action == domain.RevisionRedact {
+		var previous string
+		err := tx.QueryRowContext(ctx, `SELECT content FROM messages WHERE id = $1 FOR UPDATE`, message.ID).Scan(&previous)
+		if err == sql.ErrNoRows {
+			return domain.ErrMessageNotFound
+		}
+		if err != nil {
+			return err
+		}
+		redactedTexts = domain.RedactedTexts(previous, revision.Spans)
+	}
+
+	result, err := tx.ExecContext(ctx,
+		`UPDATE messages
+         SET content = $1, attachments = $2, edited_at = $3, deleted_at = $4
+         WHERE id = $5`,
+		message.Content, attachments, nullTimePointer(message.EditedAt),
+		nullTimePointer(message.DeletedAt), message.ID,
+	)
+	if err != nil {
+		return err
+	}
+	if updated, err := result.RowsAffected(); err == nil && updated == 0 {
+		return domain.ErrMessageNotFound
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO message_revisions (id, message_id, action, content, attachments, spans, redacted, changed_by, changed_at)
+         VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+		revision.ID, message.ID, revision.Action, revision.Content, previousAttachments,
+		spans, revision.Redacted, revision.ChangedBy, revision.ChangedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	// Whatever was redacted must not survive in an earlier version
+	if len(redactedTexts) > 0 {
+		if err := redactRevisions(ctx, tx, message.ID, revision.ID, redactedTexts); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// redactRevisions redacts the texts in the earlier revisions of a message
+// that contain them, leaving the others as they were
+func redactRevisions(ctx context.Context, tx *sql.Tx, messageID, revisionID string, texts []string) error {
+	rows, err := tx.QueryContext(ctx,
+		`SELECT id, content FROM message_revisions WHERE message_id = $1 AND id <> $2`,
+		messageID, revisionID,
+	)
+	if err != nil {
+		return err
+	}
+	redacted := make(map[string]string)
+	for rows.Next() {
+		var id, content string
+		if err := rows.Scan(&id, &content); err != nil {
+			rows.Close()
+			return err
+		}
+		if content, ok := domain.RedactTexts(content, texts); ok {
+			redacted[id] = content
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for id, content := range redacted {
+		_, err := tx.ExecContext(ctx,
+			`UPDATE message_revisions SET content = $1, redacted = TRUE WHERE id = $2`,
+			content, id,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *PostgresRepository) GetMessageRevisions(ctx context.Context, messageID string) ([]domain.MessageRevision, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT id, message_id, action, content, attachments, spans, redacted, changed_by, changed_at
+         FROM message_revisions
+         WHERE message_id = $1
+         ORDER BY changed_at ASC, id ASC`,
+		messageID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []domain.MessageRevision{}
+	for rows.Next() {
+		var revision domain.MessageRevision
+		var attachments, spans []byte
+		err := rows.Scan(&revision.ID, &revision.MessageID, &revision.Action, &revision.Content,
+			&attachments, &spans, &revision.Redacted, &revision.ChangedBy, &revision.ChangedAt)
+		if err != nil {
 			return nil, err
 		}
-		messages = append(messages, msg)
+		if len(attachments) > 0 {
+			if err := json.Unmarshal(attachments, &revision.Attachments); err != nil {
+				return nil, fmt.Errorf("failed to decode attachments of revision %s: %w", revision.ID, err)
+			}
+		}
+		if len(spans) > 0 {
+			if err := json.Unmarshal(spans, &revision.Spans); err != nil {
+				return nil, fmt.Errorf("failed to decode redaction spans of revision %s: %w", revision.ID, err)
+			}
+		}
+		revisions = append(revisions, revision)
 	}
+	return revisions, rows.Err()
+}
+
+// Markers ts_headline puts around matched words. They are private-use
+// characters so they survive escaping the snippet and can't be typed into a
+// message by accident.
+const (
+	headlineStart = "\ue000"
+	headlineStop  = "\ue001"
+)
+
+// searchHeadlineOptions shape the snippets of search hits
+var searchHeadlineOptions = "StartSel=" + headlineStart + ", StopSel=" + headlineStop +
+	`, MaxWords=35, MinWords=15, MaxFragments=2, FragmentDelimiter=" … "`
 
-	return messages, nil
+// SearchMessages matches the search query against the content index and
+// ranks the hits with ts_rank
+func (r *PostgresRepository) SearchMessages(ctx context.Context, search domain.MessageSearch) (*domain.MessageSearchResults, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT m.id, m.content, m.user_id, m.customer_id, m.type, m.timestamp, m.delivery_status, m.metadata, m.attachments, m.edited_at, m.deleted_at,
+                c.id, c.status, ts_headline('english', m.content, q, $2),
+                ts_rank(to_tsvector('english', m.content), q) AS rank
+         FROM messages m
+         JOIN conversations c ON c.id = m.conversation_id
+         CROSS JOIN websearch_to_tsquery('english', $1) q
+         WHERE to_tsvector('english', m.content) @@ q
+           AND m.deleted_at IS NULL
+           AND ($3 = '' OR m.customer_id = $3)
+           AND ($4::timestamp IS NULL OR m.timestamp >= $4)
+           AND ($5::timestamp IS NULL OR m.timestamp < $5)
+           AND ($6 = '' OR m.type = $6)
+           AND ($7 = '' OR c.status = $7)
+         ORDER BY rank DESC, m.timestamp DESC, m.id
+         LIMIT $8 OFFSET $9`,
+		search.Query, searchHeadlineOptions, search.CustomerID, nullTime(search.From), nullTime(search.To),
+		string(search.Type), search.ConversationStatus, search.Limit+1, search.Offset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := &domain.MessageSearchResults{Hits: []domain.MessageSearchHit{}}
+	for rows.Next() {
+		var hit domain.MessageSearchHit
+		var headline string
+		msg, err := scanMessage(rows, &hit.ConversationID, &hit.ConversationStatus, &headline, &hit.Rank)
+		if err != nil {
+			return nil, err
+		}
+		hit.Message = *msg
+		hit.Snippet = highlightSnippet(headline)
+		results.Hits = append(results.Hits, hit)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(results.Hits) > search.Limit {
+		results.Hits = results.Hits[:search.Limit]
+		results.HasMore = true
+	}
+	return results, nil
+}
+
+// highlightSnippet escapes a headline as HTML and turns its markers into
+// <mark> elements
+func highlightSnippet(headline string) string {
+	snippet := html.EscapeString(headline)
+	snippet = strings.ReplaceAll(snippet, headlineStart, "<mark>")
+	return strings.ReplaceAll(snippet, headlineStop, "</mark>")
+}
+
+func (r *PostgresRepository) UpdateDeliveryStatus(ctx context.Context, receipt *domain.Receipt) ([]string, error) {
+	// Statuses only move forward: a read message is never marked delivered
+	previous := []string{string(domain.DeliverySent)}
+	if receipt.Status == domain.DeliveryRead {
+		previous = append(previous, string(domain.DeliveryDelivered))
+	}
+
+	rows, err := r.db.QueryContext(ctx,
+		`UPDATE messages
+         SET delivery_status = $1,
+             delivered_at = COALESCE(delivered_at, $2),
+             read_at = CASE WHEN $1 = 'read' THEN $2 ELSE read_at END
+         WHERE conversation_id = $3 AND id = ANY($4) AND user_id <> $5
+           AND delivery_status = ANY($6)
+         RETURNING id`,
+		receipt.Status, receipt.Timestamp, receipt.ConversationID,
+		pq.Array(receipt.MessageIDs), receipt.UserID, pq.Array(previous),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var updated []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		updated = append(updated, id)
+	}
+	return updated, rows.Err()
+}
+
+func (r *PostgresRepository) CountUnread(ctx context.Context, conversationID, userID string) (int, error) {
+	var count int
+	err := r.db.QueryRowContext(ctx,
+		`SELECT COUNT(*)
+         FROM messages
+         WHERE conversation_id = $1 AND user_id <> $2 AND delivery_status <> 'read'
+           AND deleted_at IS NULL`,
+		conversationID, userID,
+	).Scan(&count)
+	return count, err
+}
+
+// encodeMetadata converts message metadata to JSON, storing NULL when empty
+func encodeMetadata(metadata map[string]string) (interface{}, error) {
+	if len(metadata) == 0 {
+		return nil, nil
+	}
+	encoded, err := json.Marshal(metadata)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode message metadata: %w", err)
+	}
+	return string(encoded), nil
+}
+
+// encodeAttachments converts a message's attachments to JSON, storing NULL
+// when there are none. Download links expire, so they are left out.
+func encodeAttachments(attachments []domain.Attachment) (interface{}, error) {
+	if len(attachments) == 0 {
+		return nil, nil
+	}
+	stored := make([]domain.Attachment, len(attachments))
+	for i, attachment := range attachments {
+		attachment.URL = ""
+		stored[i] = attachment
+	}
+	encoded, err := json.Marshal(stored)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode message attachments: %w", err)
+	}
+	return string(encoded), nil
 }
 
 // Implement ConversationRepository interface
 func (r *PostgresRepository) CreateConversation(ctx context.Context, conversation *domain.Conversation) error {
+	if conversation.Mode == "" {
+		conversation.Mode = domain.ConversationModeBot
+	}
+
 	_, err := r.db.ExecContext(ctx,
-		`INSERT INTO conversations (id, customer_id, started_at, status)
-         VALUES ($1, $2, $3, $4)`,
+		`INSERT INTO conversations (id, customer_id, started_at, status, mode, agent_id)
+         VALUES ($1, $2, $3, $4, $5, $6)`,
 		conversation.ID, conversation.CustomerID, conversation.StartedAt, conversation.Status,
+		conversation.Mode, nullString(conversation.AgentID),
 	)
 	return err
 }
 
 func (r *PostgresRepository) GetConversation(ctx context.Context, id string) (*domain.Conversation, error) {
 	row := r.db.QueryRowContext(ctx,
-		`SELECT id, customer_id, started_at, ended_at, status 
+		`SELECT id, customer_id, started_at, ended_at, status, mode, agent_id
          FROM conversations 
          WHERE id = $1`,
 		id,
 	)
 
-	var conversation domain.Conversation
-	var endedAt sql.NullTime
-
-	err := row.Scan(
-		&conversation.ID,
-		&conversation.CustomerID,
-		&conversation.StartedAt,
-		&endedAt,
-		&conversation.Status,
-	)
-
+	conversation, err := scanConversation(row)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, errors.New("conversation not found")
+			return nil, domain.ErrNoConversation
 		}
 		return nil, err
 	}
 
-	if endedAt.Valid {
-		conversation.EndedAt = endedAt.Time
-	}
-
-	return &conversation, nil
+	return conversation, nil
 }
 
 func (r *PostgresRepository) GetActiveConversationByCustomer(ctx context.Context, customerID string) (*domain.Conversation, error) {
 	row := r.db.QueryRowContext(ctx,
-		`SELECT id, customer_id, started_at, ended_at, status 
+		`SELECT id, customer_id, started_at, ended_at, status, mode, agent_id
          FROM conversations 
          WHERE customer_id = $1 AND status = 'active'
          ORDER BY started_at DESC
@@ -202,8 +764,141 @@ func (r *PostgresRepository) GetActiveConversationByCustomer(ctx context.Context
 		customerID,
 	)
 
+	conversation, err := scanConversation(row)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("no active conversation found")
+		}
+		return nil, err
+	}
+
+	return conversation, nil
+}
+
+func (r *PostgresRepository) ListConversationsByCustomer(ctx context.Context, customerID string) ([]domain.Conversation, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT id, customer_id, started_at, ended_at, status, mode, agent_id
+         FROM conversations
+         WHERE customer_id = $1
+         ORDER BY started_at DESC`,
+		customerID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	conversations := []domain.Conversation{}
+	for rows.Next() {
+		conversation, err := scanConversation(rows)
+		if err != nil {
+			return nil, err
+		}
+		conversations = append(conversations, *conversation)
+	}
+	return conversations, rows.Err()
+}
+
+func (r *PostgresRepository) ListIdleConversations(ctx context.Context, idleSince time.Time, limit int) ([]domain.Conversation, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT c.id, c.customer_id, c.started_at, c.ended_at, c.status, c.mode, c.agent_id
+         FROM conversations c
+         CROSS JOIN LATERAL (
+             SELECT COALESCE(MAX(m.timestamp), c.started_at) AS last_activity
+             FROM messages m
+             WHERE m.conversation_id = c.id
+         ) activity
+         WHERE c.status = 'active' AND activity.last_activity < $1
+         ORDER BY activity.last_activity
+         LIMIT $2`,
+		idleSince, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	conversations := []domain.Conversation{}
+	for rows.Next() {
+		conversation, err := scanConversation(rows)
+		if err != nil {
+			return nil, err
+		}
+		conversations = append(conversations, *conversation)
+	}
+	return conversations, rows.Err()
+}
+
+func (r *PostgresRepository) CloseIdleConversation(ctx context.Context, conversationID string, idleSince time.Time, notice *domain.Message) (bool, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx,
+		`UPDATE conversations c
+         SET status = 'closed', ended_at = $3
+         WHERE c.id = $1 AND c.status <> 'closed'
+           AND COALESCE(
+               (SELECT MAX(m.timestamp) FROM messages m WHERE m.conversation_id = c.id),
+               c.started_at
+           ) < $2`,
+		conversationID, idleSince, notice.Timestamp,
+	)
+	if err != nil {
+		return false, err
+	}
+	closed, err := result.RowsAffected()
+	if err != nil || closed == 0 {
+		return false, err
+	}
+
+	if err := insertMessage(ctx, tx, notice, conversationID); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// FindConversations pages through conversations by (started_at, id)
+func (r *PostgresRepository) FindConversations(ctx context.Context, filter domain.ConversationFilter) ([]domain.Conversation, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT id, customer_id, started_at, ended_at, status, mode, agent_id
+         FROM conversations
+         WHERE ($1 = '' OR customer_id = $1)
+           AND ($2::timestamp IS NULL OR started_at >= $2)
+           AND ($3::timestamp IS NULL OR started_at < $3)
+           AND ($4 = '' OR (started_at, id) > (SELECT started_at, id FROM conversations WHERE id = $4))
+         ORDER BY started_at, id
+         LIMIT $5`,
+		filter.CustomerID, nullTime(filter.From), nullTime(filter.To), filter.After, filter.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	conversations := []domain.Conversation{}
+	for rows.Next() {
+		conversation, err := scanConversation(rows)
+		if err != nil {
+			return nil, err
+		}
+		conversations = append(conversations, *conversation)
+	}
+	return conversations, rows.Err()
+}
+
+// rowScanner is a single row or the current row of a result set
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanConversation reads a conversation row selected with its mode and agent
+func scanConversation(row rowScanner) (*domain.Conversation, error) {
 	var conversation domain.Conversation
 	var endedAt sql.NullTime
+	var agentID sql.NullString
 
 	err := row.Scan(
 		&conversation.ID,
@@ -211,34 +906,76 @@ func (r *PostgresRepository) GetActiveConversationByCustomer(ctx context.Context
 		&conversation.StartedAt,
 		&endedAt,
 		&conversation.Status,
+		&conversation.Mode,
+		&agentID,
 	)
-
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, errors.New("no active conversation found")
-		}
 		return nil, err
 	}
 
 	if endedAt.Valid {
 		conversation.EndedAt = endedAt.Time
 	}
+	conversation.AgentID = agentID.String
 
 	return &conversation, nil
 }
 
 func (r *PostgresRepository) UpdateConversation(ctx context.Context, conversation *domain.Conversation) error {
+	if conversation.Mode == "" {
+		conversation.Mode = domain.ConversationModeBot
+	}
+
 	_, err := r.db.ExecContext(ctx,
 		`UPDATE conversations 
-         SET status = $1, ended_at = $2
-         WHERE id = $3`,
+         SET status = $1, ended_at = $2, mode = $3, agent_id = $4
+         WHERE id = $5`,
 		conversation.Status,
-		conversation.EndedAt,
+		nullTime(conversation.EndedAt),
+		conversation.Mode,
+		nullString(conversation.AgentID),
 		conversation.ID,
 	)
 	return err
 }
 
+func (r *PostgresRepository) MarkEscalated(ctx context.Context, conversationID string, escalated bool) (bool, error) {
+	query := `UPDATE conversations SET escalated_at = $2 WHERE id = $1 AND escalated_at IS NULL`
+	args := []interface{}{conversationID, time.Now()}
+	if !escalated {
+		query = `UPDATE conversations SET escalated_at = NULL WHERE id = $1 AND escalated_at IS NOT NULL`
+		args = args[:1]
+	}
+
+	result, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return false, err
+	}
+	changed, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return changed > 0, nil
+}
+
+// nullString stores empty strings as NULL
+func nullString(value string) sql.NullString {
+	return sql.NullString{String: value, Valid: value != ""}
+}
+
+// nullTime stores zero times as NULL
+func nullTime(value time.Time) sql.NullTime {
+	return sql.NullTime{Time: value, Valid: !value.IsZero()}
+}
+
+// nullTimePointer stores nil times as NULL
+func nullTimePointer(value *time.Time) sql.NullTime {
+	if value == nil {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: *value, Valid: true}
+}
+
 // Implement both interfaces with a single struct
 var _ ports.MessageRepository = (*PostgresRepository)(nil)
 var _ ports.ConversationRepository = (*PostgresRepository)(nil)
@@ -6,6 +6,7 @@ import (
 	"chat-service/internal/core/domain"
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"os"
 	"strings"
@@ -207,6 +208,172 @@ func (suite *RepositoryTestSuite) TestConversationOperations() {
 	assert.Error(suite.T(), err)
 }
 
+func (suite *RepositoryTestSuite) TestMarkEscalated() {
+	ctx := context.Background()
+	conversation := &domain.Conversation{
+		ID:         uuid.New().String(),
+		CustomerID: uuid.New().String(),
+		StartedAt:  time.Now(),
+		Status:     "active",
+	}
+	assert.NoError(suite.T(), suite.repository.CreateConversation(ctx, conversation))
+
+	// Only the first replica to escalate marks the conversation
+	marked, err := suite.repository.MarkEscalated(ctx, conversation.ID, true)
+	assert.NoError(suite.T(), err)
+	assert.True(suite.T(), marked)
+	marked, err = suite.repository.MarkEscalated(ctx, conversation.ID, true)
+	assert.NoError(suite.T(), err)
+	assert.False(suite.T(), marked)
+
+	// A failed escalation can be tried again
+	cleared, err := suite.repository.MarkEscalated(ctx, conversation.ID, false)
+	assert.NoError(suite.T(), err)
+	assert.True(suite.T(), cleared)
+	marked, err = suite.repository.MarkEscalated(ctx, conversation.ID, true)
+	assert.NoError(suite.T(), err)
+	assert.True(suite.T(), marked)
+}
+
+func (suite *RepositoryTestSuite) TestDeliveryReceipts() {
+	customerID := uuid.New().String()
+	conversation := &domain.Conversation{
+		ID:         uuid.New().String(),
+		CustomerID: customerID,
+		StartedAt:  time.Now(),
+		Status:     "active",
+	}
+	ctx := context.Background()
+	assert.NoError(suite.T(), suite.repository.CreateConversation(ctx, conversation))
+
+	question := &domain.Message{ID: uuid.New().String(), Content: "Hi", UserID: "user123", CustomerID: customerID, Type: domain.UserMessage, Timestamp: time.Now()}
+	answer := &domain.Message{ID: uuid.New().String(), Content: "Hello", UserID: "bot-1", CustomerID: customerID, Type: domain.BotMessage, Timestamp: time.Now()}
+	assert.NoError(suite.T(), suite.repository.SaveMessage(ctx, question))
+	assert.NoError(suite.T(), suite.repository.SaveMessage(ctx, answer))
+
+	unread, err := suite.repository.CountUnread(ctx, conversation.ID, "user123")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 1, unread)
+
+	// The customer's own message is never marked by their receipt
+	receipt := &domain.Receipt{
+		ConversationID: conversation.ID,
+		MessageIDs:     []string{question.ID, answer.ID},
+		Status:         domain.DeliveryRead,
+		UserID:         "user123",
+		Timestamp:      time.Now(),
+	}
+	updated, err := suite.repository.UpdateDeliveryStatus(ctx, receipt)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), []string{answer.ID}, updated)
+
+	// Statuses never move backwards
+	receipt.Status = domain.DeliveryDelivered
+	updated, err = suite.repository.UpdateDeliveryStatus(ctx, receipt)
+	assert.NoError(suite.T(), err)
+	assert.Empty(suite.T(), updated)
+
+	unread, err = suite.repository.CountUnread(ctx, conversation.ID, "user123")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 0, unread)
+
+	messages, err := suite.repository.GetMessagesByConversation(ctx, conversation.ID)
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), messages, 2)
+	for _, message := range messages {
+		if message.ID == answer.ID {
+			assert.Equal(suite.T(), domain.DeliveryRead, message.Status)
+		} else {
+			assert.Equal(suite.T(), domain.DeliverySent, message.Status)
+		}
+	}
+}
+
+func (suite *RepositoryTestSuite) TestMessagePaging() {
+	customerID := uuid.New().String()
+	conversation := &domain.Conversation{
+		ID:         uuid.New().String(),
+		CustomerID: customerID,
+		StartedAt:  time.Now(),
+		Status:     "active",
+	}
+	ctx := context.Background()
+	assert.NoError(suite.T(), suite.repository.CreateConversation(ctx, conversation))
+
+	// Two messages share a timestamp so the ID breaks the tie
+	start := time.Now().Add(-time.Hour).Truncate(time.Second)
+	var ids []string
+	for i := 0; i < 5; i++ {
+		message := &domain.Message{
+			ID:         fmt.Sprintf("%s-%d", conversation.ID[:8], i),
+			Content:    fmt.Sprintf("message %d", i),
+			UserID:     "user123",
+			CustomerID: customerID,
+			Type:       domain.UserMessage,
+			Timestamp:  start.Add(time.Duration(min(i, 3)) * time.Minute),
+		}
+		assert.NoError(suite.T(), suite.repository.SaveMessage(ctx, message))
+		ids = append(ids, message.ID)
+	}
+
+	latest, err := suite.repository.GetMessagePage(ctx, domain.MessageQuery{ConversationID: conversation.ID, Limit: 2})
+	assert.NoError(suite.T(), err)
+	assert.True(suite.T(), latest.HasMore)
+	assert.Equal(suite.T(), ids[3:], pageIDs(latest))
+
+	older, err := suite.repository.GetMessagePage(ctx, domain.MessageQuery{ConversationID: conversation.ID, Before: ids[3], Limit: 10})
+	assert.NoError(suite.T(), err)
+	assert.False(suite.T(), older.HasMore)
+	assert.Equal(suite.T(), ids[:3], pageIDs(older))
+
+	newer, err := suite.repository.GetMessagePage(ctx, domain.MessageQuery{ConversationID: conversation.ID, After: ids[0], Limit: 3})
+	assert.NoError(suite.T(), err)
+	assert.True(suite.T(), newer.HasMore)
+	assert.Equal(suite.T(), ids[1:4], pageIDs(newer))
+
+	_, err = suite.repository.GetMessagePage(ctx, domain.MessageQuery{ConversationID: conversation.ID, Before: "unknown", Limit: 3})
+	assert.ErrorIs(suite.T(), err, domain.ErrInvalidCursor)
+}
+
+func (suite *RepositoryTestSuite) TestListConversationsByCustomer() {
+	ctx := context.Background()
+	customerID := uuid.New().String()
+	closed := &domain.Conversation{
+		ID:         uuid.New().String(),
+		CustomerID: customerID,
+		StartedAt:  time.Now().Add(-time.Hour),
+		EndedAt:    time.Now().Add(-30 * time.Minute),
+		Status:     "closed",
+	}
+	active := &domain.Conversation{
+		ID:         uuid.New().String(),
+		CustomerID: customerID,
+		StartedAt:  time.Now(),
+		Status:     "active",
+	}
+	assert.NoError(suite.T(), suite.repository.CreateConversation(ctx, closed))
+	assert.NoError(suite.T(), suite.repository.CreateConversation(ctx, active))
+
+	conversations, err := suite.repository.ListConversationsByCustomer(ctx, customerID)
+	assert.NoError(suite.T(), err)
+	if assert.Len(suite.T(), conversations, 2) {
+		assert.Equal(suite.T(), active.ID, conversations[0].ID)
+		assert.Equal(suite.T(), closed.ID, conversations[1].ID)
+	}
+
+	none, err := suite.repository.ListConversationsByCustomer(ctx, "nobody")
+	assert.NoError(suite.T(), err)
+	assert.Empty(suite.T(), none)
+}
+
+func pageIDs(page *domain.MessagePage) []string {
+	ids := make([]string, len(page.Messages))
+	for i, message := range page.Messages {
+		ids[i] = message.ID
+	}
+	return ids
+}
+
 func TestRepositorySuite(t *testing.T) {
 	// t.Skip("Skipping due to Docker socket issues with Colima - TO BE FIXED")
 
@@ -216,3 +383,465 @@ func TestRepositorySuite(t *testing.T) {
 	}
 	suite.Run(t, new(RepositoryTestSuite))
 }
+
+func (suite *RepositoryTestSuite) TestListIdleConversations() {
+	ctx := context.Background()
+	started := time.Now().Add(-2 * time.Hour)
+	newConversation := func() *domain.Conversation {
+		conversation := &domain.Conversation{
+			ID:         uuid.New().String(),
+			CustomerID: uuid.New().String(),
+			StartedAt:  started,
+			Status:     "active",
+		}
+		assert.NoError(suite.T(), suite.repository.CreateConversation(ctx, conversation))
+		return conversation
+	}
+	writeAt := func(conversation *domain.Conversation, at time.Time) {
+		assert.NoError(suite.T(), suite.repository.SaveMessage(ctx, &domain.Message{
+			ID:         uuid.New().String(),
+			Content:    "Hello",
+			UserID:     "user1",
+			CustomerID: conversation.CustomerID,
+			Type:       domain.UserMessage,
+			Timestamp:  at,
+		}))
+	}
+
+	silent := newConversation()
+	quiet := newConversation()
+	writeAt(quiet, time.Now().Add(-90*time.Minute))
+	busy := newConversation()
+	writeAt(busy, time.Now())
+
+	conversations, err := suite.repository.ListIdleConversations(ctx, time.Now().Add(-time.Hour), 1000)
+	assert.NoError(suite.T(), err)
+	var idle []string
+	for _, conversation := range conversations {
+		switch conversation.ID {
+		case silent.ID, quiet.ID, busy.ID:
+			idle = append(idle, conversation.ID)
+		}
+	}
+	// The longest idle come first
+	assert.Equal(suite.T(), []string{silent.ID, quiet.ID}, idle)
+}
+
+func (suite *RepositoryTestSuite) TestCloseIdleConversation() {
+	ctx := context.Background()
+	idleSince := time.Now().Add(-time.Hour)
+	newConversation := func(lastMessage time.Time) *domain.Conversation {
+		conversation := &domain.Conversation{
+			ID:         uuid.New().String(),
+			CustomerID: uuid.New().String(),
+			StartedAt:  time.Now().Add(-2 * time.Hour),
+			Status:     "active",
+		}
+		assert.NoError(suite.T(), suite.repository.CreateConversation(ctx, conversation))
+		assert.NoError(suite.T(), suite.repository.SaveMessage(ctx, &domain.Message{
+			ID:         uuid.New().String(),
+			Content:    "Hello",
+			UserID:     "user1",
+			CustomerID: conversation.CustomerID,
+			Type:       domain.UserMessage,
+			Timestamp:  lastMessage,
+		}))
+		return conversation
+	}
+	notice := func(conversation *domain.Conversation) *domain.Message {
+		return &domain.Message{
+			ID:         uuid.New().String(),
+			Content:    "Closed for being quiet",
+			UserID:     "system",
+			CustomerID: conversation.CustomerID,
+			Type:       domain.SystemMessage,
+			Timestamp:  time.Now(),
+		}
+	}
+	quiet := newConversation(time.Now().Add(-90 * time.Minute))
+	busy := newConversation(time.Now())
+
+	closing := notice(quiet)
+	closed, err := suite.repository.CloseIdleConversation(ctx, quiet.ID, idleSince, closing)
+	assert.NoError(suite.T(), err)
+	assert.True(suite.T(), closed)
+	stored, err := suite.repository.GetConversation(ctx, quiet.ID)
+	if assert.NoError(suite.T(), err) {
+		assert.Equal(suite.T(), "closed", stored.Status)
+		assert.False(suite.T(), stored.EndedAt.IsZero())
+	}
+	// The notice is the conversation's last message
+	messages, err := suite.repository.GetMessagesByConversation(ctx, quiet.ID)
+	if assert.NoError(suite.T(), err) && assert.Len(suite.T(), messages, 2) {
+		assert.Equal(suite.T(), closing.ID, messages[1].ID)
+	}
+
+	// Another replica finds it already closed
+	closed, err = suite.repository.CloseIdleConversation(ctx, quiet.ID, idleSince, notice(quiet))
+	assert.NoError(suite.T(), err)
+	assert.False(suite.T(), closed)
+
+	// The customer wrote since it was listed
+	closed, err = suite.repository.CloseIdleConversation(ctx, busy.ID, idleSince, notice(busy))
+	assert.NoError(suite.T(), err)
+	assert.False(suite.T(), closed)
+	stored, err = suite.repository.GetConversation(ctx, busy.ID)
+	if assert.NoError(suite.T(), err) {
+		assert.Equal(suite.T(), "active", stored.Status)
+	}
+	messages, err = suite.repository.GetMessagesByConversation(ctx, busy.ID)
+	if assert.NoError(suite.T(), err) {
+		assert.Len(suite.T(), messages, 1)
+	}
+}
+
+func (suite *RepositoryTestSuite) TestSatisfactionReport() {
+	ctx := context.Background()
+	knowledge := repository.NewPostgresKnowledgeRepository(suite.db)
+	assert.NoError(suite.T(), knowledge.InitSchema(ctx))
+	satisfaction := repository.NewPostgresSatisfactionRepository(suite.db)
+	assert.NoError(suite.T(), satisfaction.InitSchema(ctx))
+
+	entry := &domain.KnowledgeEntry{
+		ID:       uuid.New().String(),
+		Question: "How do I pay?",
+		Answer:   "By card.",
+		Keywords: []string{"pay"},
+		Category: "billing",
+	}
+	assert.NoError(suite.T(), knowledge.CreateEntry(ctx, entry))
+
+	since := time.Now().Add(-time.Minute)
+	rate := func(rating int, replies ...domain.Message) *domain.Conversation {
+		conversation := &domain.Conversation{
+			ID:         uuid.New().String(),
+			CustomerID: uuid.New().String(),
+			StartedAt:  time.Now(),
+			Status:     "active",
+		}
+		assert.NoError(suite.T(), suite.repository.CreateConversation(ctx, conversation))
+		for _, reply := range replies {
+			reply.ID = uuid.New().String()
+			reply.Content = "Reply"
+			reply.CustomerID = conversation.CustomerID
+			reply.Timestamp = time.Now()
+			assert.NoError(suite.T(), suite.repository.SaveMessage(ctx, &reply))
+		}
+
+		assert.NoError(suite.T(), satisfaction.SaveResponse(ctx, &domain.SatisfactionResponse{
+			ID:             uuid.New().String(),
+			ConversationID: conversation.ID,
+			CustomerID:     conversation.CustomerID,
+			Rating:         rating,
+			CreatedAt:      time.Now(),
+		}))
+		return conversation
+	}
+
+	helped := rate(5,
+		domain.Message{UserID: "bot-1", Type: domain.BotMessage, Metadata: map[string]string{"kb_entries": entry.ID}},
+		domain.Message{UserID: "agent-1", Type: domain.AgentMessage},
+	)
+	rate(2, domain.Message{UserID: "bot-1", Type: domain.BotMessage})
+
+	// Each conversation is rated once
+	err := satisfaction.SaveResponse(ctx, &domain.SatisfactionResponse{
+		ID:             uuid.New().String(),
+		ConversationID: helped.ID,
+		CustomerID:     helped.CustomerID,
+		Rating:         1,
+		CreatedAt:      time.Now(),
+	})
+	assert.ErrorIs(suite.T(), err, domain.ErrSurveyAnswered)
+
+	report, err := satisfaction.Report(ctx, since)
+	if !assert.NoError(suite.T(), err) {
+		return
+	}
+	assert.Equal(suite.T(), domain.SatisfactionScore{Responses: 2, Average: 3.5, CSAT: 50}, report.Overall)
+	assert.Equal(suite.T(), []domain.SatisfactionScore{{Key: "bot-1", Responses: 2, Average: 3.5, CSAT: 50}}, report.Bots)
+	assert.Equal(suite.T(), []domain.SatisfactionScore{{Key: "agent-1", Responses: 1, Average: 5, CSAT: 100}}, report.Agents)
+	assert.Equal(suite.T(), []domain.SatisfactionScore{{Key: "billing", Responses: 1, Average: 5, CSAT: 100}}, report.Categories)
+}
+
+func (suite *RepositoryTestSuite) TestAttachments() {
+	ctx := context.Background()
+	attachments := repository.NewPostgresAttachmentRepository(suite.db)
+	assert.NoError(suite.T(), attachments.InitSchema(ctx))
+
+	conversation := &domain.Conversation{
+		ID:         uuid.New().String(),
+		CustomerID: uuid.New().String(),
+		StartedAt:  time.Now(),
+		Status:     "active",
+	}
+	assert.NoError(suite.T(), suite.repository.CreateConversation(ctx, conversation))
+
+	attachment := &domain.Attachment{
+		ID:             uuid.New().String(),
+		ConversationID: conversation.ID,
+		CustomerID:     conversation.CustomerID,
+		UploadedBy:     "user-1",
+		FileName:       "error.png",
+		ContentType:    "image/png",
+		Size:           1234,
+		StorageKey:     "conversations/" + conversation.ID + "/error.png",
+		CreatedAt:      time.Now().Truncate(time.Microsecond),
+	}
+	assert.NoError(suite.T(), attachments.SaveAttachment(ctx, attachment))
+
+	stored, err := attachments.GetAttachment(ctx, attachment.ID)
+	if assert.NoError(suite.T(), err) {
+		assert.Equal(suite.T(), attachment.StorageKey, stored.StorageKey)
+		assert.Equal(suite.T(), attachment.Size, stored.Size)
+		assert.True(suite.T(), attachment.CreatedAt.Equal(stored.CreatedAt))
+	}
+	_, err = attachments.GetAttachment(ctx, uuid.New().String())
+	assert.ErrorIs(suite.T(), err, domain.ErrAttachmentNotFound)
+
+	// Messages keep their attachments but not the links to them
+	signed := *stored
+	signed.URL = "/attachments/" + stored.ID + "?signature=abc"
+	message := &domain.Message{
+		ID:          uuid.New().String(),
+		Content:     "Here is the error",
+		UserID:      "user-1",
+		CustomerID:  conversation.CustomerID,
+		Type:        domain.UserMessage,
+		Timestamp:   time.Now(),
+		Attachments: []domain.Attachment{signed},
+	}
+	assert.NoError(suite.T(), suite.repository.SaveMessage(ctx, message))
+
+	messages, err := suite.repository.GetMessagesByConversation(ctx, conversation.ID)
+	if assert.NoError(suite.T(), err) && assert.Len(suite.T(), messages, 1) {
+		assert.Len(suite.T(), messages[0].Attachments, 1)
+		assert.Equal(suite.T(), stored.ID, messages[0].Attachments[0].ID)
+		assert.Equal(suite.T(), "error.png", messages[0].Attachments[0].FileName)
+		assert.Empty(suite.T(), messages[0].Attachments[0].URL)
+	}
+}
+
+func (suite *RepositoryTestSuite) TestMessageRevisions() {
+	ctx := context.Background()
+	conversation := &domain.Conversation{
+		ID:         uuid.New().String(),
+		CustomerID: uuid.New().String(),
+		StartedAt:  time.Now(),
+		Status:     "active",
+	}
+	assert.NoError(suite.T(), suite.repository.CreateConversation(ctx, conversation))
+
+	message := &domain.Message{
+		ID:         uuid.New().String(),
+		Content:    "I was charged twice",
+		UserID:     "user-1",
+		CustomerID: conversation.CustomerID,
+		Type:       domain.UserMessage,
+		Timestamp:  time.Now(),
+	}
+	assert.NoError(suite.T(), suite.repository.SaveMessage(ctx, message))
+
+	stored, err := suite.repository.GetMessage(ctx, message.ID)
+	if !assert.NoError(suite.T(), err) {
+		return
+	}
+	assert.Equal(suite.T(), conversation.ID, stored.Metadata["conversation_id"])
+	_, err = suite.repository.GetMessage(ctx, uuid.New().String())
+	assert.ErrorIs(suite.T(), err, domain.ErrMessageNotFound)
+
+	revise := func(action, content string, spans []domain.RedactionSpan) {
+		changedAt := time.Now()
+		revision := &domain.MessageRevision{
+			ID:        uuid.New().String(),
+			MessageID: message.ID,
+			Action:    action,
+			Content:   stored.Content,
+			Spans:     spans,
+			Redacted:  action == domain.RevisionRedact,
+			ChangedBy: "admin-1",
+			ChangedAt: changedAt,
+		}
+		if revision.Redacted {
+			revision.Content = ""
+		}
+		stored.Content = content
+		stored.EditedAt = &changedAt
+		assert.NoError(suite.T(), suite.repository.ReviseMessage(ctx, stored, revision))
+	}
+	revise(domain.RevisionEdit, "My card is 4111 1111 1111 1111", nil)
+	revise(domain.RevisionEdit, "My card is 4111 1111 1111 1111, charged twice", nil)
+	revise(domain.RevisionRedact, "My card is [redacted], charged twice", []domain.RedactionSpan{{Start: 11, End: 30}})
+
+	messages, err := suite.repository.GetMessagesByConversation(ctx, conversation.ID)
+	if assert.NoError(suite.T(), err) && assert.Len(suite.T(), messages, 1) {
+		assert.Equal(suite.T(), "My card is [redacted], charged twice", messages[0].Content)
+		assert.NotNil(suite.T(), messages[0].EditedAt)
+		assert.Nil(suite.T(), messages[0].DeletedAt)
+	}
+
+	// The redaction scrubbed the card number from the version that had it
+	// and left the one without it alone
+	revisions, err := suite.repository.GetMessageRevisions(ctx, message.ID)
+	if assert.NoError(suite.T(), err) && assert.Len(suite.T(), revisions, 3) {
+		assert.Equal(suite.T(), domain.RevisionEdit, revisions[0].Action)
+		assert.Equal(suite.T(), "I was charged twice", revisions[0].Content)
+		assert.False(suite.T(), revisions[0].Redacted)
+
+		assert.Equal(suite.T(), domain.RevisionEdit, revisions[1].Action)
+		assert.Equal(suite.T(), "My card is [redacted]", revisions[1].Content)
+		assert.True(suite.T(), revisions[1].Redacted)
+
+		assert.Equal(suite.T(), domain.RevisionRedact, revisions[2].Action)
+		assert.Empty(suite.T(), revisions[2].Content)
+		assert.True(suite.T(), revisions[2].Redacted)
+		assert.Equal(suite.T(), []domain.RedactionSpan{{Start: 11, End: 30}}, revisions[2].Spans)
+	}
+}
+
+func (suite *RepositoryTestSuite) TestSearchMessages() {
+	ctx := context.Background()
+	start := func() *domain.Conversation {
+		conversation := &domain.Conversation{
+			ID:         uuid.New().String(),
+			CustomerID: uuid.New().String(),
+			StartedAt:  time.Now(),
+			Status:     "active",
+		}
+		assert.NoError(suite.T(), suite.repository.CreateConversation(ctx, conversation))
+		return conversation
+	}
+	write := func(conversation *domain.Conversation, messageType domain.MessageType, content string, at time.Time) *domain.Message {
+		message := &domain.Message{
+			ID:         uuid.New().String(),
+			Content:    content,
+			UserID:     "user-1",
+			CustomerID: conversation.CustomerID,
+			Type:       messageType,
+			Timestamp:  at,
+		}
+		assert.NoError(suite.T(), suite.repository.SaveMessage(ctx, message))
+		return message
+	}
+
+	lastMonth := time.Now().AddDate(0, -1, 0)
+	charged := start()
+	complaint := write(charged, domain.UserMessage, "I was charged twice <b>again</b>, a double charge!", lastMonth)
+	write(charged, domain.BotMessage, "Sorry about the double charges, I have asked for a refund.", lastMonth.Add(time.Minute))
+	other := start()
+	write(other, domain.UserMessage, "Can I change my delivery address?", time.Now())
+	old := write(other, domain.UserMessage, "Double charge on my card", lastMonth.AddDate(0, -2, 0))
+
+	search := func(search domain.MessageSearch) []string {
+		search.Query = "double charge"
+		if search.Limit == 0 {
+			search.Limit = 10
+		}
+		results, err := suite.repository.SearchMessages(ctx, search)
+		if !assert.NoError(suite.T(), err) {
+			return nil
+		}
+		var ids []string
+		for _, hit := range results.Hits {
+			ids = append(ids, hit.Message.ID)
+		}
+		return ids
+	}
+
+	all := search(domain.MessageSearch{})
+	assert.Len(suite.T(), all, 3)
+	assert.NotContains(suite.T(), all, "")
+
+	assert.Len(suite.T(), search(domain.MessageSearch{CustomerID: charged.CustomerID}), 2)
+	assert.Equal(suite.T(), []string{complaint.ID},
+		search(domain.MessageSearch{CustomerID: charged.CustomerID, Type: domain.UserMessage}))
+	assert.Equal(suite.T(), []string{old.ID},
+		search(domain.MessageSearch{CustomerID: other.CustomerID, To: lastMonth.AddDate(0, -1, 0)}))
+	assert.Len(suite.T(), search(domain.MessageSearch{From: lastMonth.Add(-time.Hour), ConversationStatus: "active"}), 2)
+	assert.Empty(suite.T(), search(domain.MessageSearch{ConversationStatus: "closed", CustomerID: charged.CustomerID}))
+
+	results, err := suite.repository.SearchMessages(ctx, domain.MessageSearch{
+		Query: "double charge", CustomerID: charged.CustomerID, Type: domain.UserMessage, Limit: 1,
+	})
+	if assert.NoError(suite.T(), err) && assert.Len(suite.T(), results.Hits, 1) {
+		hit := results.Hits[0]
+		assert.Equal(suite.T(), charged.ID, hit.ConversationID)
+		assert.Equal(suite.T(), "active", hit.ConversationStatus)
+		assert.Contains(suite.T(), hit.Snippet, "<mark>double</mark> <mark>charge</mark>")
+		// The content is escaped around the highlights
+		assert.Contains(suite.T(), hit.Snippet, "&lt;b&gt;")
+		assert.False(suite.T(), results.HasMore)
+	}
+}
+
+func (suite *RepositoryTestSuite) TestTranscriptQueries() {
+	ctx := context.Background()
+	customerID := uuid.New().String()
+	// Far in the past, so no other test's conversations fall in the range
+	start := time.Date(2001, 5, 1, 10, 0, 0, 0, time.UTC)
+	var ids []string
+	for i := 0; i < 3; i++ {
+		conversation := &domain.Conversation{
+			ID:         uuid.New().String(),
+			CustomerID: customerID,
+			StartedAt:  start.Add(time.Duration(i) * time.Hour),
+			Status:     "closed",
+		}
+		if i == 2 {
+			conversation.Status = "active"
+		}
+		assert.NoError(suite.T(), suite.repository.CreateConversation(ctx, conversation))
+		ids = append(ids, conversation.ID)
+	}
+
+	find := func(filter domain.ConversationFilter) []string {
+		conversations, err := suite.repository.FindConversations(ctx, filter)
+		assert.NoError(suite.T(), err)
+		found := []string{}
+		for _, conversation := range conversations {
+			found = append(found, conversation.ID)
+		}
+		return found
+	}
+
+	assert.Equal(suite.T(), ids, find(domain.ConversationFilter{CustomerID: customerID, Limit: 10}))
+	assert.Equal(suite.T(), ids[:2], find(domain.ConversationFilter{CustomerID: customerID, Limit: 2}))
+	assert.Equal(suite.T(), ids[2:], find(domain.ConversationFilter{CustomerID: customerID, After: ids[1], Limit: 2}))
+	assert.Equal(suite.T(), ids[1:2], find(domain.ConversationFilter{
+		From: start.Add(30 * time.Minute), To: start.Add(2 * time.Hour), Limit: 10,
+	}))
+	assert.Empty(suite.T(), find(domain.ConversationFilter{CustomerID: "nobody", Limit: 10}))
+
+	// Messages are saved to the customer's active conversation
+	var written []string
+	for i := 0; i < 3; i++ {
+		message := &domain.Message{
+			ID:         uuid.New().String(),
+			Content:    "Hello",
+			UserID:     "user1",
+			CustomerID: customerID,
+			Type:       domain.UserMessage,
+			Timestamp:  start.Add(3*time.Hour - time.Duration(i)*time.Minute),
+		}
+		assert.NoError(suite.T(), suite.repository.SaveMessage(ctx, message))
+		written = append([]string{message.ID}, written...)
+	}
+
+	var streamed []string
+	err := suite.repository.StreamMessages(ctx, ids[2], func(message *domain.Message) error {
+		assert.Equal(suite.T(), ids[2], message.Metadata["conversation_id"])
+		streamed = append(streamed, message.ID)
+		return nil
+	})
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), written, streamed)
+
+	stop := errors.New("stop")
+	calls := 0
+	err = suite.repository.StreamMessages(ctx, ids[2], func(message *domain.Message) error {
+		calls++
+		return stop
+	})
+	assert.ErrorIs(suite.T(), err, stop)
+	assert.Equal(suite.T(), 1, calls)
+}
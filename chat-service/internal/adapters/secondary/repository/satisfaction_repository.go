@@ -0,0 +1,137 @@
+package repository
+
+import (
+	"chat-service/internal/core/domain"
+	"chat-service/internal/core/ports"
+	"context"
+	"database/sql"
+	"strconv"
+	"time"
+)
+
+// PostgresSatisfactionRepository implements SatisfactionRepository, keeping
+// at most one survey response per conversation
+type PostgresSatisfactionRepository struct {
+	db *sql.DB
+}
+
+var _ ports.SatisfactionRepository = (*PostgresSatisfactionRepository)(nil)
+
+// NewPostgresSatisfactionRepository creates a new PostgresSatisfactionRepository
+func NewPostgresSatisfactionRepository(db *sql.DB) *PostgresSatisfactionRepository {
+	return &PostgresSatisfactionRepository{db: db}
+}
+
+// InitSchema creates the required tables if they don't exist
+func (r *PostgresSatisfactionRepository) InitSchema(ctx context.Context) error {
+	_, err := r.db.ExecContext(ctx, `
+        CREATE TABLE IF NOT EXISTS satisfaction_responses (
+            id VARCHAR(36) PRIMARY KEY,
+            conversation_id VARCHAR(36) NOT NULL UNIQUE REFERENCES conversations(id) ON DELETE CASCADE,
+            customer_id VARCHAR(36) NOT NULL,
+            rating SMALLINT NOT NULL CHECK (rating BETWEEN 1 AND 5),
+            comment TEXT,
+            created_at TIMESTAMP NOT NULL
+        )
+    `)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.ExecContext(ctx, `
+        CREATE INDEX IF NOT EXISTS idx_satisfaction_responses_created_at
+            ON satisfaction_responses (created_at)
+    `)
+	return err
+}
+
+// SaveResponse stores a response unless the conversation already has one
+func (r *PostgresSatisfactionRepository) SaveResponse(ctx context.Context, response *domain.SatisfactionResponse) error {
+	result, err := r.db.ExecContext(ctx,
+		`INSERT INTO satisfaction_responses (id, conversation_id, customer_id, rating, comment, created_at)
+         VALUES ($1, $2, $3, $4, $5, $6)
+         ON CONFLICT (conversation_id) DO NOTHING`,
+		response.ID, response.ConversationID, response.CustomerID, response.Rating,
+		nullString(response.Comment), response.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+	stored, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if stored == 0 {
+		return domain.ErrSurveyAnswered
+	}
+	return nil
+}
+
+// Aggregates of the ratings in a set of responses
+var satisfactionAggregates = `COUNT(*), COALESCE(AVG(s.rating), 0),
+             COALESCE(100.0 * AVG(CASE WHEN s.rating >= ` + strconv.Itoa(domain.SatisfiedRating) + ` THEN 1 ELSE 0 END), 0)`
+
+// Report aggregates the responses given since the time, overall and by the
+// bots, agents and knowledge categories of the conversations rated
+func (r *PostgresSatisfactionRepository) Report(ctx context.Context, since time.Time) (*domain.SatisfactionReport, error) {
+	report := &domain.SatisfactionReport{Since: since}
+
+	err := r.db.QueryRowContext(ctx,
+		`SELECT `+satisfactionAggregates+`
+         FROM satisfaction_responses s
+         WHERE s.created_at >= $1`,
+		since,
+	).Scan(&report.Overall.Responses, &report.Overall.Average, &report.Overall.CSAT)
+	if err != nil {
+		return nil, err
+	}
+
+	if report.Bots, err = r.scores(ctx, since, `
+        SELECT DISTINCT conversation_id, user_id AS key
+        FROM messages WHERE type = 'bot'`); err != nil {
+		return nil, err
+	}
+	if report.Agents, err = r.scores(ctx, since, `
+        SELECT DISTINCT conversation_id, user_id AS key
+        FROM messages WHERE type = 'agent'`); err != nil {
+		return nil, err
+	}
+	// Bot answers from the knowledge base name the entries they came from
+	if report.Categories, err = r.scores(ctx, since, `
+        SELECT DISTINCT m.conversation_id, COALESCE(NULLIF(k.category, ''), 'uncategorized') AS key
+        FROM messages m
+        CROSS JOIN LATERAL unnest(string_to_array(m.metadata->>'kb_entries', ',')) AS used(entry_id)
+        LEFT JOIN knowledge_entries k ON k.id = used.entry_id
+        WHERE m.type = 'bot' AND m.metadata ? 'kb_entries'`); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// scores aggregates the responses since the time by the key the groups
+// query pairs each conversation with
+func (r *PostgresSatisfactionRepository) scores(ctx context.Context, since time.Time, groups string) ([]domain.SatisfactionScore, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT g.key, `+satisfactionAggregates+`
+         FROM satisfaction_responses s
+         JOIN (`+groups+`) g ON g.conversation_id = s.conversation_id
+         WHERE s.created_at >= $1
+         GROUP BY g.key
+         ORDER BY g.key`,
+		since,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	scores := []domain.SatisfactionScore{}
+	for rows.Next() {
+		var score domain.SatisfactionScore
+		if err := rows.Scan(&score.Key, &score.Responses, &score.Average, &score.CSAT); err != nil {
+			return nil, err
+		}
+		scores = append(scores, score)
+	}
+	return scores, rows.Err()
+}
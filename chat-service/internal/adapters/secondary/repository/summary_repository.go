@@ -0,0 +1,65 @@
+package repository
+
+import (
+	"chat-service/internal/core/domain"
+	"chat-service/internal/core/ports"
+	"context"
+	"database/sql"
+	"errors"
+)
+
+// PostgresSummaryRepository implements SummaryRepository, keeping one rolling
+// summary per conversation
+type PostgresSummaryRepository struct {
+	db *sql.DB
+}
+
+var _ ports.SummaryRepository = (*PostgresSummaryRepository)(nil)
+
+// NewPostgresSummaryRepository creates a new PostgresSummaryRepository
+func NewPostgresSummaryRepository(db *sql.DB) *PostgresSummaryRepository {
+	return &PostgresSummaryRepository{db: db}
+}
+
+// InitSchema creates the required tables if they don't exist
+func (r *PostgresSummaryRepository) InitSchema(ctx context.Context) error {
+	_, err := r.db.ExecContext(ctx, `
+        CREATE TABLE IF NOT EXISTS conversation_summaries (
+            conversation_id VARCHAR(36) PRIMARY KEY REFERENCES conversations(id) ON DELETE CASCADE,
+            content TEXT NOT NULL,
+            last_message_id VARCHAR(36) NOT NULL,
+            covered_until TIMESTAMP NOT NULL,
+            updated_at TIMESTAMP NOT NULL
+        )
+    `)
+	return err
+}
+
+// GetSummary fetches the summary of a conversation, or nil if there is none
+func (r *PostgresSummaryRepository) GetSummary(ctx context.Context, conversationID string) (*domain.ConversationSummary, error) {
+	summary := &domain.ConversationSummary{ConversationID: conversationID}
+	err := r.db.QueryRowContext(ctx,
+		`SELECT content, last_message_id, covered_until, updated_at
+         FROM conversation_summaries WHERE conversation_id = $1`,
+		conversationID,
+	).Scan(&summary.Content, &summary.LastMessageID, &summary.CoveredUntil, &summary.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return summary, nil
+}
+
+// SaveSummary creates or replaces the summary of a conversation
+func (r *PostgresSummaryRepository) SaveSummary(ctx context.Context, summary *domain.ConversationSummary) error {
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO conversation_summaries (conversation_id, content, last_message_id, covered_until, updated_at)
+         VALUES ($1, $2, $3, $4, $5)
+         ON CONFLICT (conversation_id) DO UPDATE
+         SET content = $2, last_message_id = $3, covered_until = $4, updated_at = $5`,
+		summary.ConversationID, summary.Content, summary.LastMessageID, summary.CoveredUntil, summary.UpdatedAt,
+	)
+	return err
+}
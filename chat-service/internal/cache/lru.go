@@ -0,0 +1,191 @@
+// internal/cache/lru.go
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// Stats reports how well a cache is doing
+type Stats struct {
+	Size          int     `json:"size"`
+	Capacity      int     `json:"capacity"`
+	Hits          uint64  `json:"hits"`
+	Misses        uint64  `json:"misses"`
+	Evictions     uint64  `json:"evictions"`
+	Expirations   uint64  `json:"expirations"`
+	Invalidations uint64  `json:"invalidations"`
+	HitRate       float64 `json:"hit_rate"`
+}
+
+// Cache is a bounded least-recently-used cache whose entries also expire
+// after a fixed time to live. Entries can carry tags so that everything
+// derived from the same source is invalidated together. It is safe for
+// concurrent use.
+type Cache[V any] struct {
+	mutex    sync.Mutex
+	capacity int
+	ttl      time.Duration
+	items    map[string]*list.Element
+	// Most recently used at the front
+	order *list.List
+	stats Stats
+}
+
+type item[V any] struct {
+	key     string
+	value   V
+	tags    []string
+	expires time.Time
+}
+
+// New creates a cache holding at most capacity entries for ttl each. A ttl
+// of zero keeps entries until they are evicted.
+func New[V any](capacity int, ttl time.Duration) *Cache[V] {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &Cache[V]{
+		capacity: capacity,
+		ttl:      ttl,
+		items:    make(map[string]*list.Element),
+		order:    list.New(),
+	}
+}
+
+// Get returns the value stored under key if it hasn't expired
+func (c *Cache[V]) Get(key string) (V, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	var zero V
+	element, ok := c.items[key]
+	if !ok {
+		c.stats.Misses++
+		return zero, false
+	}
+
+	entry := element.Value.(*item[V])
+	if !entry.expires.IsZero() && time.Now().After(entry.expires) {
+		c.remove(element)
+		c.stats.Expirations++
+		c.stats.Misses++
+		return zero, false
+	}
+
+	c.order.MoveToFront(element)
+	c.stats.Hits++
+	return entry.value, true
+}
+
+// Set stores value under key with the given tags, evicting the least
+// recently used entry when the cache is full
+func (c *Cache[V]) Set(key string, value V, tags ...string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.set(key, value, tags)
+}
+
+// Add stores value under key unless it already holds an entry that hasn't
+// expired, and reports whether it did. Unlike a Get followed by a Set, no
+// other caller can add the same key in between.
+func (c *Cache[V]) Add(key string, value V, tags ...string) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if element, ok := c.items[key]; ok {
+		entry := element.Value.(*item[V])
+		if entry.expires.IsZero() || !time.Now().After(entry.expires) {
+			return false
+		}
+		c.remove(element)
+		c.stats.Expirations++
+	}
+	c.set(key, value, tags)
+	return true
+}
+
+// set stores an entry, the lock being held
+func (c *Cache[V]) set(key string, value V, tags []string) {
+	var expires time.Time
+	if c.ttl > 0 {
+		expires = time.Now().Add(c.ttl)
+	}
+
+	if element, ok := c.items[key]; ok {
+		element.Value = &item[V]{key: key, value: value, tags: tags, expires: expires}
+		c.order.MoveToFront(element)
+		return
+	}
+
+	c.items[key] = c.order.PushFront(&item[V]{key: key, value: value, tags: tags, expires: expires})
+	for c.order.Len() > c.capacity {
+		c.remove(c.order.Back())
+		c.stats.Evictions++
+	}
+}
+
+// Remove removes the entry stored under key, reporting whether there was one
+func (c *Cache[V]) Remove(key string) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	element, ok := c.items[key]
+	if !ok {
+		return false
+	}
+	c.remove(element)
+	return true
+}
+
+// Invalidate removes every entry carrying the tag and returns how many
+// were removed
+func (c *Cache[V]) Invalidate(tag string) int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	removed := 0
+	for element := c.order.Front(); element != nil; {
+		next := element.Next()
+		for _, t := range element.Value.(*item[V]).tags {
+			if t == tag {
+				c.remove(element)
+				removed++
+				break
+			}
+		}
+		element = next
+	}
+	c.stats.Invalidations += uint64(removed)
+	return removed
+}
+
+// Purge removes every entry
+func (c *Cache[V]) Purge() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.stats.Invalidations += uint64(c.order.Len())
+	c.items = make(map[string]*list.Element)
+	c.order.Init()
+}
+
+// Stats returns the current size and counters
+func (c *Cache[V]) Stats() Stats {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	stats := c.stats
+	stats.Size = c.order.Len()
+	stats.Capacity = c.capacity
+	if lookups := stats.Hits + stats.Misses; lookups > 0 {
+		stats.HitRate = float64(stats.Hits) / float64(lookups)
+	}
+	return stats
+}
+
+func (c *Cache[V]) remove(element *list.Element) {
+	c.order.Remove(element)
+	delete(c.items, element.Value.(*item[V]).key)
+}
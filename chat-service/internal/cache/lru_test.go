@@ -0,0 +1,106 @@
+// internal/cache/lru_test.go
+package cache_test
+
+import (
+	"chat-service/internal/cache"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCache(t *testing.T) {
+	t.Run("evicts the least recently used entry", func(t *testing.T) {
+		c := cache.New[string](2, 0)
+		c.Set("a", "1")
+		c.Set("b", "2")
+
+		// Touching a makes b the oldest
+		_, ok := c.Get("a")
+		assert.True(t, ok)
+		c.Set("c", "3")
+
+		_, ok = c.Get("b")
+		assert.False(t, ok)
+		value, ok := c.Get("a")
+		assert.True(t, ok)
+		assert.Equal(t, "1", value)
+
+		stats := c.Stats()
+		assert.Equal(t, 2, stats.Size)
+		assert.Equal(t, 2, stats.Capacity)
+		assert.Equal(t, uint64(1), stats.Evictions)
+		assert.Equal(t, uint64(2), stats.Hits)
+		assert.Equal(t, uint64(1), stats.Misses)
+		assert.InDelta(t, 2.0/3.0, stats.HitRate, 0.001)
+	})
+
+	t.Run("adds only missing or expired entries", func(t *testing.T) {
+		c := cache.New[string](10, 20*time.Millisecond)
+		assert.True(t, c.Add("a", "1"))
+		assert.False(t, c.Add("a", "2"))
+		value, _ := c.Get("a")
+		assert.Equal(t, "1", value)
+
+		time.Sleep(30 * time.Millisecond)
+		assert.True(t, c.Add("a", "3"))
+		value, _ = c.Get("a")
+		assert.Equal(t, "3", value)
+	})
+
+	t.Run("expires entries after the time to live", func(t *testing.T) {
+		c := cache.New[string](10, 20*time.Millisecond)
+		c.Set("a", "1")
+
+		_, ok := c.Get("a")
+		assert.True(t, ok)
+
+		time.Sleep(30 * time.Millisecond)
+		_, ok = c.Get("a")
+		assert.False(t, ok)
+		assert.Equal(t, uint64(1), c.Stats().Expirations)
+		assert.Equal(t, 0, c.Stats().Size)
+	})
+
+	t.Run("invalidates by tag", func(t *testing.T) {
+		c := cache.New[string](10, time.Minute)
+		c.Set("shipping", "2 days", "knowledge", "entry:shipping")
+		c.Set("returns", "30 days", "knowledge", "entry:returns")
+		c.Set("ai", "hello", "customer:1")
+
+		assert.Equal(t, 1, c.Invalidate("entry:shipping"))
+		_, ok := c.Get("shipping")
+		assert.False(t, ok)
+		_, ok = c.Get("returns")
+		assert.True(t, ok)
+
+		assert.Equal(t, 1, c.Invalidate("knowledge"))
+		_, ok = c.Get("ai")
+		assert.True(t, ok)
+
+		c.Purge()
+		assert.Equal(t, 0, c.Stats().Size)
+		assert.Equal(t, uint64(3), c.Stats().Invalidations)
+	})
+
+	t.Run("replacing a key keeps one entry", func(t *testing.T) {
+		c := cache.New[string](10, 0)
+		c.Set("a", "1", "old")
+		c.Set("a", "2")
+
+		assert.Equal(t, 0, c.Invalidate("old"))
+		value, _ := c.Get("a")
+		assert.Equal(t, "2", value)
+		assert.Equal(t, 1, c.Stats().Size)
+	})
+
+	t.Run("removes by key", func(t *testing.T) {
+		c := cache.New[int](10, 0)
+		c.Set("a", 1)
+
+		assert.True(t, c.Remove("a"))
+		assert.False(t, c.Remove("a"))
+		_, found := c.Get("a")
+		assert.False(t, found)
+	})
+}
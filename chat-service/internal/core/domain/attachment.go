@@ -0,0 +1,45 @@
+package domain
+
+import (
+	"strings"
+	"time"
+)
+
+// MaxAttachmentSize is the largest file accepted, in bytes
+const MaxAttachmentSize = 10 << 20
+
+// MaxMessageAttachments is the most attachments one message may carry
+const MaxMessageAttachments = 5
+
+// AttachmentTypes are the MIME types customers may upload. The type is
+// sniffed from the file itself rather than taken from the upload.
+var AttachmentTypes = map[string]bool{
+	"image/png":       true,
+	"image/jpeg":      true,
+	"image/gif":       true,
+	"image/webp":      true,
+	"application/pdf": true,
+	"text/plain":      true,
+}
+
+// IsInlineType reports whether files of the MIME type are shown in the
+// browser rather than downloaded
+func IsInlineType(contentType string) bool {
+	return strings.HasPrefix(contentType, "image/")
+}
+
+// Attachment is a file uploaded to a conversation. Messages reference the
+// attachments they carry; URL is a signed link to download the file, set
+// whenever the attachment leaves the service.
+type Attachment struct {
+	ID             string    `json:"id"`
+	ConversationID string    `json:"conversation_id,omitempty"`
+	CustomerID     string    `json:"customer_id,omitempty"`
+	UploadedBy     string    `json:"uploaded_by,omitempty"`
+	FileName       string    `json:"file_name,omitempty"`
+	ContentType    string    `json:"content_type,omitempty"`
+	Size           int64     `json:"size,omitempty"`
+	StorageKey     string    `json:"-"`
+	CreatedAt      time.Time `json:"created_at"`
+	URL            string    `json:"url,omitempty"`
+}
@@ -0,0 +1,31 @@
+package domain
+
+import "errors"
+
+// Common errors
+var (
+	ErrLLMRateLimited     = errors.New("llm provider rate limited")
+	ErrLLMEmptyResponse   = errors.New("llm provider returned an empty response")
+	ErrInvalidFlow        = errors.New("invalid flow definition")
+	ErrInvalidMode        = errors.New("invalid conversation mode")
+	ErrNotAgent           = errors.New("not authenticated as an agent")
+	ErrNotInControl       = errors.New("agent is not in control of the conversation")
+	ErrConversationClosed = errors.New("conversation is closed")
+	ErrInvalidReceipt     = errors.New("invalid delivery receipt")
+	ErrInvalidCursor      = errors.New("invalid message cursor")
+	ErrBotBusy            = errors.New("bot is busy")
+	ErrInvalidSurvey      = errors.New("invalid survey response")
+	ErrSurveyAnswered     = errors.New("survey already answered")
+	ErrInvalidAttachment  = errors.New("invalid attachment")
+	ErrAttachmentNotFound = errors.New("attachment not found")
+	ErrInvalidSignature   = errors.New("invalid or expired download link")
+	ErrBlobNotFound       = errors.New("blob not found")
+	ErrMessageNotFound    = errors.New("message not found")
+	ErrInvalidEdit        = errors.New("invalid message change")
+	ErrEditNotAllowed     = errors.New("not allowed to change the message")
+	ErrEditWindowClosed   = errors.New("message can no longer be changed")
+	ErrInvalidSearch      = errors.New("invalid message search")
+	ErrInvalidExport      = errors.New("invalid transcript export")
+	ErrNoConversation     = errors.New("conversation not found")
+	ErrUploadNotAllowed   = errors.New("not allowed to upload to the conversation")
+)
@@ -0,0 +1,30 @@
+package domain
+
+import "time"
+
+// Reasons a conversation is handed over to a human
+const (
+	EscalationCustomerRequest  = "customer_request"
+	EscalationRepeatedFallback = "repeated_fallback"
+	EscalationFlow             = "flow"
+)
+
+// Escalation asks the CRM to open a ticket for a conversation the bot could
+// not resolve. The transcript holds every message of the conversation so far.
+type Escalation struct {
+	ID             string    `json:"id"`
+	ConversationID string    `json:"conversation_id"`
+	CustomerID     string    `json:"customer_id"`
+	Reason         string    `json:"reason"`
+	FlowID         string    `json:"flow_id,omitempty"`
+	Transcript     []Message `json:"transcript"`
+	RequestedAt    time.Time `json:"requested_at"`
+}
+
+// EscalationTicket is the CRM's answer to an escalation
+type EscalationTicket struct {
+	EscalationID   string `json:"escalation_id"`
+	ConversationID string `json:"conversation_id"`
+	CustomerID     string `json:"customer_id"`
+	TicketID       string `json:"ticket_id"`
+}
@@ -0,0 +1,71 @@
+package domain
+
+import "time"
+
+// Validation types for flow slots
+const (
+	SlotAny    = "any"
+	SlotNumber = "number"
+	SlotEmail  = "email"
+	SlotRegex  = "regex"
+	SlotChoice = "choice"
+)
+
+// Flow statuses for a conversation
+const (
+	FlowActive    = "active"
+	FlowCompleted = "completed"
+	FlowCancelled = "cancelled"
+	FlowFailed    = "failed"
+	FlowEscalated = "escalated"
+)
+
+// Flow is a guided conversation that collects slots from the customer one
+// step at a time. It starts when a message matches one of its intents.
+// Steps may hand the conversation to a human by pointing to "escalate", and
+// EscalateOnFailure does the same when the customer runs out of attempts.
+type Flow struct {
+	ID                string     `json:"id" yaml:"id"`
+	Name              string     `json:"name" yaml:"name"`
+	Description       string     `json:"description,omitempty" yaml:"description,omitempty"`
+	Intents           []string   `json:"intents" yaml:"intents"`
+	Steps             []FlowStep `json:"steps" yaml:"steps"`
+	CompletionMessage string     `json:"completion_message" yaml:"completion_message"`
+	FailureMessage    string     `json:"failure_message,omitempty" yaml:"failure_message,omitempty"`
+	EscalationMessage string     `json:"escalation_message,omitempty" yaml:"escalation_message,omitempty"`
+	EscalateOnFailure bool       `json:"escalate_on_failure,omitempty" yaml:"escalate_on_failure,omitempty"`
+	Enabled           bool       `json:"enabled" yaml:"enabled"`
+	UpdatedAt         time.Time  `json:"updated_at" yaml:"-"`
+}
+
+// FlowStep asks for a single slot. Prompt, ErrorMessage and the flow's
+// messages may reference collected slots as {{slot_name}}.
+type FlowStep struct {
+	ID           string            `json:"id" yaml:"id"`
+	Slot         string            `json:"slot" yaml:"slot"`
+	Prompt       string            `json:"prompt" yaml:"prompt"`
+	Validation   SlotValidation    `json:"validation" yaml:"validation"`
+	ErrorMessage string            `json:"error_message,omitempty" yaml:"error_message,omitempty"`
+	MaxAttempts  int               `json:"max_attempts,omitempty" yaml:"max_attempts,omitempty"`
+	Next         string            `json:"next,omitempty" yaml:"next,omitempty"`
+	Branches     map[string]string `json:"branches,omitempty" yaml:"branches,omitempty"`
+}
+
+// SlotValidation describes which answers a step accepts
+type SlotValidation struct {
+	Type    string   `json:"type" yaml:"type"`
+	Pattern string   `json:"pattern,omitempty" yaml:"pattern,omitempty"`
+	Choices []string `json:"choices,omitempty" yaml:"choices,omitempty"`
+}
+
+// FlowState is the progress of a conversation through a flow
+type FlowState struct {
+	ConversationID string            `json:"conversation_id"`
+	FlowID         string            `json:"flow_id"`
+	StepID         string            `json:"step_id"`
+	Slots          map[string]string `json:"slots"`
+	Attempts       int               `json:"attempts"`
+	Status         string            `json:"status"`
+	StartedAt      time.Time         `json:"started_at"`
+	UpdatedAt      time.Time         `json:"updated_at"`
+}
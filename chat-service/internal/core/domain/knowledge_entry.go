@@ -12,3 +12,17 @@ type KnowledgeEntry struct {
 	CreatedAt time.Time `json:"created_at" db:"created_at"`
 	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
 }
+
+// ScoredMatch is a knowledge entry ranked against a query
+type ScoredMatch struct {
+	Entry KnowledgeEntry `json:"entry"`
+	Score float64        `json:"score"`
+}
+
+// Synonym lists words and phrases that mean the same as Term when matching
+// questions against the knowledge base
+type Synonym struct {
+	Term      string    `json:"term" db:"term"`
+	Synonyms  []string  `json:"synonyms" db:"synonyms"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
@@ -0,0 +1,31 @@
+package domain
+
+import "time"
+
+// Events announcing a conversation's lifecycle, named after the routing key
+// they are published under
+const (
+	ConversationClosedEvent   = "conversation.closed"
+	ConversationReopenedEvent = "conversation.reopened"
+	// A message of the conversation was edited, redacted or deleted
+	MessageUpdatedEvent = "conversation.message_updated"
+	MessageDeletedEvent = "conversation.message_deleted"
+)
+
+// Reasons a conversation is closed
+const (
+	CloseReasonIdle   = "idle"
+	CloseReasonManual = "manual"
+)
+
+// ConversationEvent tells other services a conversation closed or came back,
+// or that one of its messages changed. Message events carry the message as
+// it is now.
+type ConversationEvent struct {
+	Type           string    `json:"type"`
+	ConversationID string    `json:"conversation_id"`
+	CustomerID     string    `json:"customer_id"`
+	Reason         string    `json:"reason,omitempty"`
+	Message        *Message  `json:"message,omitempty"`
+	OccurredAt     time.Time `json:"occurred_at"`
+}
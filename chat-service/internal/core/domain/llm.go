@@ -0,0 +1,16 @@
+package domain
+
+// LLMRole identifies the author of a turn sent to a language model
+type LLMRole string
+
+const (
+	LLMRoleSystem    LLMRole = "system"
+	LLMRoleUser      LLMRole = "user"
+	LLMRoleAssistant LLMRole = "assistant"
+)
+
+// LLMMessage is a provider-agnostic chat turn
+type LLMMessage struct {
+	Role    LLMRole `json:"role"`
+	Content string  `json:"content"`
+}
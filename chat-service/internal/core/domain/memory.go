@@ -0,0 +1,14 @@
+package domain
+
+import "time"
+
+// ConversationSummary condenses the older turns of a conversation so the
+// language model keeps their gist without their tokens. It covers every
+// message up to and including LastMessageID.
+type ConversationSummary struct {
+	ConversationID string    `json:"conversation_id"`
+	Content        string    `json:"content"`
+	LastMessageID  string    `json:"last_message_id"`
+	CoveredUntil   time.Time `json:"covered_until"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
@@ -9,16 +9,83 @@ const (
 	UserMessage   MessageType = "user"
 	SystemMessage MessageType = "system"
 	BotMessage    MessageType = "bot"
+	AgentMessage  MessageType = "agent"
+)
+
+// Conversation modes decide who answers the customer. The bot is muted while
+// an agent is in control; in hybrid mode both the bot and the agent reply.
+const (
+	ConversationModeBot    = "bot"
+	ConversationModeAgent  = "agent"
+	ConversationModeHybrid = "hybrid"
+)
+
+// DeliveryStatus is how far a message got towards the other side of the
+// conversation. Each status implies the ones before it.
+type DeliveryStatus string
+
+const (
+	DeliverySent      DeliveryStatus = "sent"
+	DeliveryDelivered DeliveryStatus = "delivered"
+	DeliveryRead      DeliveryStatus = "read"
 )
 
 type Message struct {
-	ID         string      `json:"id"`
-	Content    string      `json:"content"`
-	UserID     string      `json:"user_id"`
-	CustomerID string      `json:"customer_id"`
-	Type       MessageType `json:"type"`
-	Timestamp  time.Time   `json:"timestamp"`
-	Metadata  map[string]string `json:"metadata,omitempty"`
+	ID         string            `json:"id"`
+	Content    string            `json:"content"`
+	UserID     string            `json:"user_id"`
+	CustomerID string            `json:"customer_id"`
+	Type       MessageType       `json:"type"`
+	Timestamp  time.Time         `json:"timestamp"`
+	Status     DeliveryStatus    `json:"status,omitempty"`
+	Metadata   map[string]string `json:"metadata,omitempty"`
+	// Attachments sent by customers only carry their IDs until the message
+	// is stored
+	Attachments []Attachment `json:"attachments,omitempty"`
+	// When the message was last edited or redacted, and when it was deleted.
+	// Deleted messages keep their place in the conversation without content.
+	EditedAt  *time.Time `json:"edited_at,omitempty"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+// Receipt reports messages of a conversation reaching a delivery status on
+// the side of the user who received them
+type Receipt struct {
+	ConversationID string         `json:"conversation_id"`
+	MessageIDs     []string       `json:"message_ids"`
+	Status         DeliveryStatus `json:"status"`
+	UserID         string         `json:"user_id"`
+	Timestamp      time.Time      `json:"timestamp"`
+}
+
+// Page sizes for reading a conversation's messages
+const (
+	DefaultMessagePageSize = 50
+	MaxMessagePageSize     = 200
+)
+
+// MessageQuery selects a page of a conversation's messages. Before and After
+// are message IDs used as cursors; with neither the latest messages are read.
+type MessageQuery struct {
+	ConversationID string
+	Before         string
+	After          string
+	Limit          int
+}
+
+// MessagePage is a page of messages, oldest first. HasMore says whether there
+// are more messages beyond the page in the direction it was read.
+type MessagePage struct {
+	Messages []Message `json:"messages"`
+	HasMore  bool      `json:"has_more"`
+}
+
+// MessageChunk is an incremental piece of a message that is still being generated
+type MessageChunk struct {
+	MessageID  string `json:"message_id"`
+	CustomerID string `json:"customer_id"`
+	Content    string `json:"content"`
+	Index      int    `json:"index"`
 }
 
 type Conversation struct {
@@ -27,4 +94,46 @@ type Conversation struct {
 	StartedAt  time.Time `json:"started_at"`
 	EndedAt    time.Time `json:"ended_at,omitempty"`
 	Status     string    `json:"status"` // "active", "closed"
+	Mode       string    `json:"mode"`   // "bot", "agent", "hybrid"
+	AgentID    string    `json:"agent_id,omitempty"`
+}
+
+// Page sizes for searching messages
+const (
+	DefaultSearchLimit = 20
+	MaxSearchLimit     = 100
+)
+
+// MessageSearch is a full-text search of every conversation's messages. The
+// query uses web search syntax: quoted phrases, "or" and a leading - to
+// exclude a word. The other fields narrow the search when set; From is
+// inclusive and To exclusive.
+type MessageSearch struct {
+	Query              string
+	CustomerID         string
+	From               time.Time
+	To                 time.Time
+	Type               MessageType
+	ConversationStatus string
+	Limit              int
+	Offset             int
+}
+
+// MessageSearchHit is a message matching a search. Snippet is the matching
+// part of the content as HTML, with the matched words in <mark> elements.
+type MessageSearchHit struct {
+	Message            Message `json:"message"`
+	ConversationID     string  `json:"conversation_id"`
+	ConversationStatus string  `json:"conversation_status"`
+	Snippet            string  `json:"snippet"`
+	Rank               float64 `json:"rank"`
+	// ConversationURL links to the conversation in the chat API
+	ConversationURL string `json:"conversation_url,omitempty"`
+}
+
+// MessageSearchResults are a page of hits, best first. HasMore says whether
+// there are more after the page.
+type MessageSearchResults struct {
+	Hits    []MessageSearchHit `json:"hits"`
+	HasMore bool               `json:"has_more"`
 }
@@ -0,0 +1,22 @@
+package domain
+
+// Kinds of personal data found in what customers write. Custom kinds are
+// named by the patterns that find them.
+const (
+	PIIEmail = "email"
+	PIIPhone = "phone"
+	PIICard  = "card"
+	PIIIBAN  = "iban"
+)
+
+// PIIPattern finds a custom kind of personal data, such as customer numbers,
+// with a regular expression
+type PIIPattern struct {
+	Kind    string
+	Pattern string
+}
+
+// PIIRedactedKey is the metadata key of a bot reply listing the personal
+// data hidden from the language model while generating it, as kind:count
+// pairs such as "card:1,email:2". The data itself is not recorded.
+const PIIRedactedKey = "pii_redacted"
@@ -0,0 +1,31 @@
+package domain
+
+import "time"
+
+// Participant roles
+const (
+	ParticipantCustomer = "customer"
+	ParticipantAgent    = "agent"
+	ParticipantBot      = "bot"
+)
+
+// TypingTTL is how long a typing indicator lasts without being refreshed.
+// Clients still typing are expected to send it again before then.
+const TypingTTL = 6 * time.Second
+
+// Participant is someone connected to a conversation
+type Participant struct {
+	UserID string    `json:"user_id"`
+	Role   string    `json:"role"`
+	Since  time.Time `json:"since"`
+}
+
+// TypingIndicator says someone in a conversation started or stopped typing
+type TypingIndicator struct {
+	ConversationID string `json:"conversation_id"`
+	CustomerID     string `json:"customer_id"`
+	UserID         string `json:"user_id"`
+	Role           string `json:"role"`
+	Name           string `json:"name,omitempty"`
+	Typing         bool   `json:"typing"`
+}
@@ -0,0 +1,122 @@
+package domain
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// Ways a stored message is changed
+const (
+	RevisionEdit   = "edit"
+	RevisionDelete = "delete"
+	RevisionRedact = "redact"
+)
+
+// RedactionMarker replaces each redacted span of a message
+const RedactionMarker = "[redacted]"
+
+// Editor is who changes a message. Customers may only change the messages
+// they sent, for a while after sending them; admins may change any message
+// at any time, and are the only ones who may redact.
+type Editor struct {
+	UserID string
+	Admin  bool
+}
+
+// RedactionSpan is a part of a message's content to redact, in characters
+// from the start of the content. End is exclusive.
+type RedactionSpan struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// MessageRevision is a version of a message replaced by an edit, deletion or
+// redaction, kept for the audit trail. Content is what the message said
+// before the change. Redaction keeps no content, and redacts the same text
+// in every earlier revision of the message, which are then marked Redacted.
+type MessageRevision struct {
+	ID          string          `json:"id"`
+	MessageID   string          `json:"message_id"`
+	Action      string          `json:"action"`
+	Content     string          `json:"content,omitempty"`
+	Attachments []Attachment    `json:"attachments,omitempty"`
+	Spans       []RedactionSpan `json:"spans,omitempty"`
+	Redacted    bool            `json:"redacted,omitempty"`
+	ChangedBy   string          `json:"changed_by"`
+	ChangedAt   time.Time       `json:"changed_at"`
+}
+
+// Redact replaces the spans of the content with RedactionMarker, merging
+// spans that overlap or touch. It reports false if a span is empty or falls
+// outside the content.
+func Redact(content string, spans []RedactionSpan) (string, bool) {
+	runes := []rune(content)
+	merged, ok := mergeSpans(spans, len(runes))
+	if !ok {
+		return "", false
+	}
+
+	redacted := make([]rune, 0, len(runes))
+	next := 0
+	for _, span := range merged {
+		redacted = append(redacted, runes[next:span.Start]...)
+		redacted = append(redacted, []rune(RedactionMarker)...)
+		next = span.End
+	}
+	redacted = append(redacted, runes[next:]...)
+	return string(redacted), len(merged) > 0
+}
+
+// RedactedTexts returns the parts of the content the spans cover, merged as
+// Redact merges them
+func RedactedTexts(content string, spans []RedactionSpan) []string {
+	runes := []rune(content)
+	merged, ok := mergeSpans(spans, len(runes))
+	if !ok {
+		return nil
+	}
+	texts := make([]string, 0, len(merged))
+	for _, span := range merged {
+		texts = append(texts, string(runes[span.Start:span.End]))
+	}
+	return texts
+}
+
+// RedactTexts replaces every occurrence of the texts in the content with
+// RedactionMarker, longest first. It reports false if none occurs.
+func RedactTexts(content string, texts []string) (string, bool) {
+	sorted := append([]string(nil), texts...)
+	sort.Slice(sorted, func(i, j int) bool { return len(sorted[i]) > len(sorted[j]) })
+
+	found := false
+	for _, text := range sorted {
+		if text != "" && strings.Contains(content, text) {
+			content = strings.ReplaceAll(content, text, RedactionMarker)
+			found = true
+		}
+	}
+	return content, found
+}
+
+// mergeSpans sorts the spans and merges those that overlap or touch. It
+// reports false if a span is empty or falls outside a content of the length.
+func mergeSpans(spans []RedactionSpan, length int) ([]RedactionSpan, bool) {
+	sorted := append([]RedactionSpan(nil), spans...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Start < sorted[j].Start })
+
+	var merged []RedactionSpan
+	for _, span := range sorted {
+		if span.Start < 0 || span.End <= span.Start || span.End > length {
+			return nil, false
+		}
+		if last := len(merged) - 1; last >= 0 && span.Start <= merged[last].End {
+			if span.End > merged[last].End {
+				merged[last].End = span.End
+			}
+			continue
+		}
+		merged = append(merged, span)
+	}
+	return merged, true
+}
@@ -0,0 +1,55 @@
+package domain
+
+import "time"
+
+// Satisfaction ratings customers may give a conversation
+const (
+	MinSatisfactionRating = 1
+	MaxSatisfactionRating = 5
+	// Ratings from here up count the customer as satisfied
+	SatisfiedRating = 4
+)
+
+// MaxSatisfactionCommentLength is the longest survey comment accepted, in
+// bytes
+const MaxSatisfactionCommentLength = 1000
+
+// Survey asks a customer to rate a conversation that closed
+type Survey struct {
+	ConversationID string `json:"conversation_id"`
+	Question       string `json:"question"`
+	MinRating      int    `json:"min_rating"`
+	MaxRating      int    `json:"max_rating"`
+}
+
+// SatisfactionResponse is a customer's answer to the survey sent when their
+// conversation closed. Each conversation is answered at most once.
+type SatisfactionResponse struct {
+	ID             string    `json:"id"`
+	ConversationID string    `json:"conversation_id"`
+	CustomerID     string    `json:"customer_id"`
+	Rating         int       `json:"rating"`
+	Comment        string    `json:"comment,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// SatisfactionScore aggregates the responses about the conversations of one
+// bot, agent or knowledge category. CSAT is the share of satisfied
+// responses, as a percentage.
+type SatisfactionScore struct {
+	Key       string  `json:"key,omitempty"`
+	Responses int     `json:"responses"`
+	Average   float64 `json:"average"`
+	CSAT      float64 `json:"csat"`
+}
+
+// SatisfactionReport aggregates the responses given since a time. A
+// conversation counts towards every bot and agent that answered in it and
+// every category of knowledge the bot answered it from.
+type SatisfactionReport struct {
+	Since      time.Time           `json:"since"`
+	Overall    SatisfactionScore   `json:"overall"`
+	Bots       []SatisfactionScore `json:"bots"`
+	Agents     []SatisfactionScore `json:"agents"`
+	Categories []SatisfactionScore `json:"categories"`
+}
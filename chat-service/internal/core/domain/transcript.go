@@ -0,0 +1,51 @@
+package domain
+
+import "time"
+
+// Formats transcripts are exported in
+const (
+	TranscriptJSON     = "json"
+	TranscriptCSV      = "csv"
+	TranscriptHTML     = "html"
+	TranscriptMarkdown = "markdown"
+)
+
+// TranscriptFormats lists the export formats with the file extension and
+// content type of each
+var TranscriptFormats = map[string]struct{ Extension, ContentType string }{
+	TranscriptJSON:     {"json", "application/json"},
+	TranscriptCSV:      {"csv", "text/csv; charset=utf-8"},
+	TranscriptHTML:     {"html", "text/html; charset=utf-8"},
+	TranscriptMarkdown: {"md", "text/markdown; charset=utf-8"},
+}
+
+// TranscriptQuery selects the conversations to export: either one
+// conversation, or every conversation of a customer, started in a date
+// range, or both. From is inclusive and To exclusive.
+type TranscriptQuery struct {
+	ConversationID string
+	CustomerID     string
+	From           time.Time
+	To             time.Time
+}
+
+// ConversationFilter selects a page of conversations, oldest first. After is
+// the ID of the last conversation of the previous page.
+type ConversationFilter struct {
+	CustomerID string
+	From       time.Time
+	To         time.Time
+	After      string
+	Limit      int
+}
+
+// ParticipantSystem sends the messages of a transcript no one wrote, such as
+// notices of an agent joining
+const ParticipantSystem = "system"
+
+// TranscriptSender is who sent a message of a transcript, named for readers
+type TranscriptSender struct {
+	ID   string `json:"id"`
+	Role string `json:"role"`
+	Name string `json:"name"`
+}
@@ -1,14 +1,80 @@
 // internal/core/ports/primary.go
 package ports
 
-import "chat-service/internal/core/domain"
+import (
+	"chat-service/internal/core/domain"
+	"context"
+	"io"
+)
 
 
 type ChatService interface {
 	SaveMessage(message *domain.Message) error
 	GetChatHistory(customerID string) ([]domain.Message, error)
+	// GetMessages reads a page of a conversation's messages, clamping the
+	// limit to the page sizes allowed
+	GetMessages(query domain.MessageQuery) (*domain.MessagePage, error)
 	GetConversation(conversationID string) (*domain.Conversation, error)
 	CreateConversation(customerID string) (*domain.Conversation, error)
 	CloseConversation(conversationID string) error
+	ListConversations(customerID string) ([]domain.Conversation, error)
 	SubscribeToMessages(handler func(*domain.Message)) error
+	// UpdateDeliveryStatus records a receipt and returns the IDs of the
+	// messages whose status changed
+	UpdateDeliveryStatus(receipt *domain.Receipt) ([]string, error)
+	GetUnreadCount(conversationID, userID string) (int, error)
+}
+
+// AgentTakeover lets human agents take conversations over from the bot
+type AgentTakeover interface {
+	JoinConversation(ctx context.Context, agentID, conversationID, mode string) (*domain.Conversation, error)
+	SetMode(ctx context.Context, agentID, conversationID, mode string) (*domain.Conversation, error)
+	HandBack(ctx context.Context, agentID, conversationID string) (*domain.Conversation, error)
+	SendAgentMessage(ctx context.Context, agentID, conversationID, content string) (*domain.Message, error)
+}
+
+// SatisfactionSurvey collects customers' ratings of closed conversations
+type SatisfactionSurvey interface {
+	// Survey builds the survey sent when a conversation closes
+	Survey(conversationID string) domain.Survey
+	// SubmitSurvey stores a customer's rating of one of their conversations,
+	// setting its ID once stored
+	SubmitSurvey(ctx context.Context, response *domain.SatisfactionResponse) error
+}
+
+// AttachmentUploads receives the files customers attach to messages and
+// serves them through signed links
+type AttachmentUploads interface {
+	// Upload stores the file read from body for the attachment's
+	// conversation, setting the attachment's ID, type, size and link
+	Upload(ctx context.Context, attachment *domain.Attachment, body io.Reader) error
+	// Open verifies a download link and opens the file it points to
+	Open(ctx context.Context, id string, expires int64, signature string) (*domain.Attachment, io.ReadCloser, error)
+}
+
+// MessageEditor changes messages after they were sent, keeping the versions
+// they replace. Each change returns the message as it is now.
+type MessageEditor interface {
+	EditMessage(ctx context.Context, editor domain.Editor, messageID, content string) (*domain.Message, error)
+	DeleteMessage(ctx context.Context, editor domain.Editor, messageID string) (*domain.Message, error)
+	// RedactMessage replaces parts of a message's content; only admins may
+	RedactMessage(ctx context.Context, editor domain.Editor, messageID string, spans []domain.RedactionSpan) (*domain.Message, error)
+	GetMessageRevisions(ctx context.Context, messageID string) ([]domain.MessageRevision, error)
+}
+
+// MessageSearch finds messages across every conversation
+type MessageSearch interface {
+	// SearchMessages checks the search and clamps its limit to the page
+	// sizes allowed. Bad filters are domain.ErrInvalidSearch.
+	SearchMessages(ctx context.Context, search domain.MessageSearch) (*domain.MessageSearchResults, error)
+}
+
+// TranscriptExport renders conversations for disputes and reviews
+type TranscriptExport interface {
+	// ExportTranscripts writes the conversations the query selects to w in
+	// the format, one message at a time. A bad query or format is
+	// domain.ErrInvalidExport, and an unknown conversation
+	// domain.ErrNoConversation; both are reported before anything is
+	// written.
+	ExportTranscripts(ctx context.Context, query domain.TranscriptQuery, format string, w io.Writer) error
 }
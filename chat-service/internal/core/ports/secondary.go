@@ -4,19 +4,82 @@ package ports
 import (
 	"chat-service/internal/core/domain"
 	"context"
+	"io"
+	"time"
 )
 
 type MessageRepository interface {
 	SaveMessage(ctx context.Context, message *domain.Message) error
-	GetMessagesByCustomer(ctx context.Context,customerID string) ([]domain.Message, error)
+	GetMessagesByCustomer(ctx context.Context, customerID string) ([]domain.Message, error)
 	GetMessagesByConversation(ctx context.Context, conversationID string) ([]domain.Message, error)
+	// GetMessagePage reads a page of a conversation's messages. A cursor that
+	// is not a message of the conversation is domain.ErrInvalidCursor.
+	GetMessagePage(ctx context.Context, query domain.MessageQuery) (*domain.MessagePage, error)
+	// UpdateDeliveryStatus moves the receipt's messages forward to its status,
+	// skipping messages sent by the receipt's user, and returns the IDs of
+	// the messages that changed
+	UpdateDeliveryStatus(ctx context.Context, receipt *domain.Receipt) ([]string, error)
+	// CountUnread counts the messages of a conversation the user has not read,
+	// leaving out the ones they sent
+	CountUnread(ctx context.Context, conversationID, userID string) (int, error)
+	// GetMessage fails with domain.ErrMessageNotFound for unknown IDs. The
+	// message's metadata names its conversation.
+	GetMessage(ctx context.Context, id string) (*domain.Message, error)
+	// ReviseMessage stores the message's content, attachments and edit
+	// times, keeping the version it replaces as the revision. A redaction
+	// keeps no content and redacts the text its spans cover in the message's
+	// earlier revisions that contain it.
+	ReviseMessage(ctx context.Context, message *domain.Message, revision *domain.MessageRevision) error
+	// GetMessageRevisions returns the revisions of a message, oldest first
+	GetMessageRevisions(ctx context.Context, messageID string) ([]domain.MessageRevision, error)
+	// SearchMessages finds the messages whose content matches the search
+	// query, best match first, leaving out deleted ones
+	SearchMessages(ctx context.Context, search domain.MessageSearch) (*domain.MessageSearchResults, error)
+	// StreamMessages calls fn with each message of a conversation, oldest
+	// first, without reading them all at once. An error from fn stops the
+	// stream and is returned.
+	StreamMessages(ctx context.Context, conversationID string, fn func(*domain.Message) error) error
 }
 
 type ConversationRepository interface {
-	CreateConversation(ctx context.Context,conversation *domain.Conversation) error
-	GetConversation(ctx context.Context,id string) (*domain.Conversation, error)
-	GetActiveConversationByCustomer(ctx context.Context,customerID string) (*domain.Conversation, error)
-	UpdateConversation(ctx context.Context,conversation *domain.Conversation) error
+	CreateConversation(ctx context.Context, conversation *domain.Conversation) error
+	GetConversation(ctx context.Context, id string) (*domain.Conversation, error)
+	GetActiveConversationByCustomer(ctx context.Context, customerID string) (*domain.Conversation, error)
+	// ListConversationsByCustomer returns a customer's conversations, latest
+	// first
+	ListConversationsByCustomer(ctx context.Context, customerID string) ([]domain.Conversation, error)
+	// ListIdleConversations returns up to limit active conversations with no
+	// message since idleSince, the longest idle first
+	ListIdleConversations(ctx context.Context, idleSince time.Time, limit int) ([]domain.Conversation, error)
+	// CloseIdleConversation closes the conversation as of the notice's time
+	// if it is still open with no message since idleSince, storing the
+	// notice in it as it closes, and reports whether it did
+	CloseIdleConversation(ctx context.Context, conversationID string, idleSince time.Time, notice *domain.Message) (bool, error)
+	// FindConversations returns a page of the conversations the filter
+	// selects, oldest first
+	FindConversations(ctx context.Context, filter domain.ConversationFilter) ([]domain.Conversation, error)
+	UpdateConversation(ctx context.Context, conversation *domain.Conversation) error
+	// MarkEscalated records whether the conversation has been handed over to
+	// the CRM, reporting whether that changed anything. Replicas racing to
+	// escalate the same conversation see true only once.
+	MarkEscalated(ctx context.Context, conversationID string, escalated bool) (bool, error)
+}
+
+type FlowRepository interface {
+	GetFlows(ctx context.Context) ([]domain.Flow, error)
+	// SaveFlow creates the flow or replaces the one with the same ID
+	SaveFlow(ctx context.Context, flow *domain.Flow) error
+	DeleteFlow(ctx context.Context, id string) error
+	// GetFlowState returns nil when the conversation has never entered a flow
+	GetFlowState(ctx context.Context, conversationID string) (*domain.FlowState, error)
+	SaveFlowState(ctx context.Context, state *domain.FlowState) error
+}
+
+// SummaryRepository stores the rolling summaries of conversation memory
+type SummaryRepository interface {
+	// GetSummary returns nil when the conversation has not been summarised yet
+	GetSummary(ctx context.Context, conversationID string) (*domain.ConversationSummary, error)
+	SaveSummary(ctx context.Context, summary *domain.ConversationSummary) error
 }
 
 type MessagePublisher interface {
@@ -25,11 +88,86 @@ type MessagePublisher interface {
 	Close()
 }
 
+// ConversationEventPublisher announces conversations closing and reopening
+type ConversationEventPublisher interface {
+	PublishConversationEvent(event *domain.ConversationEvent) error
+}
+
+// ConversationEventSubscriber hands every replica the conversation events of
+// all of them
+type ConversationEventSubscriber interface {
+	SubscribeToConversationEvents(handler func(*domain.ConversationEvent)) error
+}
+
+// SatisfactionRepository stores survey responses and aggregates them
+type SatisfactionRepository interface {
+	// SaveResponse stores a response, or fails with domain.ErrSurveyAnswered
+	// if the conversation already has one
+	SaveResponse(ctx context.Context, response *domain.SatisfactionResponse) error
+	// Report aggregates the responses given since the time
+	Report(ctx context.Context, since time.Time) (*domain.SatisfactionReport, error)
+}
+
+// AttachmentRepository stores what is known about uploaded files; the files
+// themselves are kept in a BlobStore
+type AttachmentRepository interface {
+	SaveAttachment(ctx context.Context, attachment *domain.Attachment) error
+	// GetAttachment fails with domain.ErrAttachmentNotFound for unknown IDs
+	GetAttachment(ctx context.Context, id string) (*domain.Attachment, error)
+}
+
+// ParticipantDirectory looks up the names of the customers and agents taking
+// part in conversations
+type ParticipantDirectory interface {
+	CustomerName(ctx context.Context, customerID string) (string, error)
+	AgentName(ctx context.Context, agentID string) (string, error)
+}
+
+// BlobStore keeps file contents under keys chosen by the caller
+type BlobStore interface {
+	// Put stores size bytes read from body under the key
+	Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error
+	// Get opens the blob stored under the key, failing with
+	// domain.ErrBlobNotFound if there is none
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// EscalationPublisher hands conversations over to the CRM and reports the
+// tickets it opens for them
+type EscalationPublisher interface {
+	PublishEscalation(escalation *domain.Escalation) error
+	SubscribeToEscalationTickets(handler func(*domain.EscalationTicket)) error
+}
+
 type KnowledgeRepository interface {
-    GetAllEntries(ctx context.Context) ([]domain.KnowledgeEntry, error)
-    GetEntryByID(ctx context.Context, id string) (*domain.KnowledgeEntry, error)
-    CreateEntry(ctx context.Context, entry *domain.KnowledgeEntry) error
-    UpdateEntry(ctx context.Context, entry *domain.KnowledgeEntry) error
-    DeleteEntry(ctx context.Context, id string) error
-    SearchEntries(ctx context.Context, query string) ([]domain.KnowledgeEntry, error)
-}
\ No newline at end of file
+	GetAllEntries(ctx context.Context) ([]domain.KnowledgeEntry, error)
+	GetEntryByID(ctx context.Context, id string) (*domain.KnowledgeEntry, error)
+	CreateEntry(ctx context.Context, entry *domain.KnowledgeEntry) error
+	UpdateEntry(ctx context.Context, entry *domain.KnowledgeEntry) error
+	DeleteEntry(ctx context.Context, id string) error
+	SearchEntries(ctx context.Context, query string) ([]domain.KnowledgeEntry, error)
+	// SaveEmbedding stores (or replaces) the vector for an entry
+	SaveEmbedding(ctx context.Context, entryID string, embedding []float32) error
+	// SearchByEmbedding returns the entries closest to the vector by cosine similarity
+	SearchByEmbedding(ctx context.Context, embedding []float32, limit int) ([]domain.ScoredMatch, error)
+	GetSynonyms(ctx context.Context) ([]domain.Synonym, error)
+	// SaveSynonym creates the synonym group or replaces the one with the same term
+	SaveSynonym(ctx context.Context, synonym *domain.Synonym) error
+	DeleteSynonym(ctx context.Context, term string) error
+}
+
+// LLMProvider generates chat completions from a language model backend
+type LLMProvider interface {
+	Complete(ctx context.Context, messages []domain.LLMMessage) (string, error)
+	// Stream generates a completion, calling onDelta for every token batch as it
+	// arrives, and returns the full text once the model is done
+	Stream(ctx context.Context, messages []domain.LLMMessage, onDelta func(delta string) error) (string, error)
+	Name() string
+}
+
+// Embedder turns text into fixed-size vectors for semantic search
+type Embedder interface {
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+	Name() string
+}
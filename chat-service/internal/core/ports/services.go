@@ -4,6 +4,7 @@ package ports
 import (
 	"chat-service/internal/core/domain"
 	"context"
+	"time"
 )
 
 // BotService defines the methods for a bot agent
@@ -11,6 +12,61 @@ type BotService interface {
 	ProcessMessage(ctx context.Context, message *domain.Message) error
 }
 
+// BotQueue holds the messages waiting for the bot
+type BotQueue interface {
+	// CancelConversation drops a conversation's waiting messages and stops
+	// the one being answered
+	CancelConversation(conversationID string)
+}
+
+// MessageHub delivers bot output to connected clients
 type MessageHub interface {
-    SendBotResponse(message *domain.Message)
-}
\ No newline at end of file
+	SendBotChunk(chunk *domain.MessageChunk)
+	SendBotComplete(message *domain.Message)
+	// SendSystemMessage delivers a notice that is not part of a bot response
+	SendSystemMessage(message *domain.Message)
+	// SendTyping tells the conversation the bot started or stopped typing
+	SendTyping(indicator *domain.TypingIndicator)
+}
+
+// MessageSubmitter takes customer messages that arrive without a socket
+type MessageSubmitter interface {
+	// SubmitMessage stores the message and has it answered exactly like one
+	// sent over a connection, setting its ID once stored
+	SubmitMessage(message *domain.Message) error
+}
+
+// PresenceTracker reports who is connected to a conversation
+type PresenceTracker interface {
+	Presence(conversationID string) []domain.Participant
+}
+
+// AgentAuthenticator verifies that a token belongs to a support agent
+type AgentAuthenticator interface {
+	AuthenticateAgent(token string) (agentID string, err error)
+}
+
+// ConversationMemory is what the language model remembers of a conversation
+type ConversationMemory interface {
+	// Recall returns the conversation the message belongs to as chat turns,
+	// ending with the message itself and trimmed to the memory's token budget
+	Recall(ctx context.Context, message *domain.Message) ([]domain.LLMMessage, error)
+}
+
+// AttachmentLinks ties uploaded files to the messages that carry them
+type AttachmentLinks interface {
+	// Resolve replaces the attachment IDs of a customer's message with the
+	// uploads they name. Uploads of another conversation or customer are
+	// domain.ErrInvalidAttachment.
+	Resolve(ctx context.Context, message *domain.Message) error
+	// Sign sets download links valid for the duration on the attachments of
+	// the messages
+	Sign(messages []domain.Message, validFor time.Duration)
+}
+
+// TextRedactor hides personal data in text that is logged or kept
+type TextRedactor interface {
+	// Redact replaces personal data with markers naming its kind, such as
+	// [EMAIL]
+	Redact(text string) string
+}
@@ -0,0 +1,216 @@
+package services
+
+import (
+	"bytes"
+	"chat-service/internal/core/domain"
+	"chat-service/internal/core/ports"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Attachment link defaults used when the service is created
+const (
+	DefaultAttachmentURLPrefix = "/attachments"
+	DefaultAttachmentLinkTTL   = time.Hour
+)
+
+// maxFileNameLength is the longest file name kept for an upload
+const maxFileNameLength = 255
+
+// AttachmentService stores the files customers upload to their
+// conversations, ties them to the messages that carry them and signs the
+// links they are downloaded through. A link names the attachment and when
+// it expires; anyone holding it can download the file until then.
+type AttachmentService struct {
+	store         ports.BlobStore
+	attachments   ports.AttachmentRepository
+	conversations ports.ConversationRepository
+	signingKey    []byte
+	urlPrefix     string
+	linkTTL       time.Duration
+}
+
+var (
+	_ ports.AttachmentUploads = (*AttachmentService)(nil)
+	_ ports.AttachmentLinks   = (*AttachmentService)(nil)
+)
+
+// NewAttachmentService creates a new attachment service signing links with
+// signingKey
+func NewAttachmentService(store ports.BlobStore, attachments ports.AttachmentRepository, conversations ports.ConversationRepository, signingKey []byte) *AttachmentService {
+	return &AttachmentService{
+		store:         store,
+		attachments:   attachments,
+		conversations: conversations,
+		signingKey:    signingKey,
+		urlPrefix:     DefaultAttachmentURLPrefix,
+		linkTTL:       DefaultAttachmentLinkTTL,
+	}
+}
+
+// SetURLPrefix sets where links point to, such as the gateway route that
+// serves downloads
+func (s *AttachmentService) SetURLPrefix(prefix string) {
+	s.urlPrefix = strings.TrimSuffix(prefix, "/")
+}
+
+// SetLinkTTL sets how long the link returned for an upload is valid
+func (s *AttachmentService) SetLinkTTL(ttl time.Duration) {
+	s.linkTTL = ttl
+}
+
+// Upload checks and stores a file uploaded to an open conversation. Its
+// type is sniffed from the contents, whatever the uploader claimed.
+func (s *AttachmentService) Upload(ctx context.Context, attachment *domain.Attachment, body io.Reader) error {
+	if attachment.UploadedBy == "" {
+		return fmt.Errorf("%w: missing uploader", domain.ErrInvalidAttachment)
+	}
+	conversation, err := s.conversations.GetConversation(ctx, attachment.ConversationID)
+	if err != nil || conversation == nil {
+		return fmt.Errorf("%w: no such conversation", domain.ErrInvalidAttachment)
+	}
+	if attachment.UploadedBy != conversation.CustomerID && attachment.UploadedBy != conversation.AgentID {
+		return domain.ErrUploadNotAllowed
+	}
+	if conversation.Status == "closed" {
+		return domain.ErrConversationClosed
+	}
+
+	data, err := io.ReadAll(io.LimitReader(body, domain.MaxAttachmentSize+1))
+	if err != nil {
+		return fmt.Errorf("failed to read upload: %w", err)
+	}
+	switch {
+	case len(data) == 0:
+		return fmt.Errorf("%w: file is empty", domain.ErrInvalidAttachment)
+	case len(data) > domain.MaxAttachmentSize:
+		return fmt.Errorf("%w: file is larger than %d bytes", domain.ErrInvalidAttachment, domain.MaxAttachmentSize)
+	}
+	contentType, _, err := mime.ParseMediaType(http.DetectContentType(data))
+	if err != nil || !domain.AttachmentTypes[contentType] {
+		return fmt.Errorf("%w: files of type %s are not accepted", domain.ErrInvalidAttachment, contentType)
+	}
+
+	attachment.ID = uuid.New().String()
+	attachment.CustomerID = conversation.CustomerID
+	attachment.FileName = cleanFileName(attachment.FileName)
+	attachment.ContentType = contentType
+	attachment.Size = int64(len(data))
+	attachment.StorageKey = "conversations/" + conversation.ID + "/" + attachment.ID
+	attachment.CreatedAt = time.Now()
+
+	if err := s.store.Put(ctx, attachment.StorageKey, bytes.NewReader(data), attachment.Size, contentType); err != nil {
+		return fmt.Errorf("failed to store upload: %w", err)
+	}
+	if err := s.attachments.SaveAttachment(ctx, attachment); err != nil {
+		if err := s.store.Delete(ctx, attachment.StorageKey); err != nil {
+			log.Printf("Error deleting orphaned upload %s: %v", attachment.StorageKey, err)
+		}
+		return err
+	}
+	attachment.URL = s.link(attachment.ID, time.Now().Add(s.linkTTL))
+	return nil
+}
+
+// Open verifies a download link and opens the file it points to
+func (s *AttachmentService) Open(ctx context.Context, id string, expires int64, signature string) (*domain.Attachment, io.ReadCloser, error) {
+	expected := s.signature(id, expires)
+	given, err := hex.DecodeString(signature)
+	if err != nil || !hmac.Equal(given, expected) || time.Now().Unix() > expires {
+		return nil, nil, domain.ErrInvalidSignature
+	}
+
+	attachment, err := s.attachments.GetAttachment(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	body, err := s.store.Get(ctx, attachment.StorageKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	return attachment, body, nil
+}
+
+// Resolve replaces the attachment IDs of a customer's message with the
+// uploads they name
+func (s *AttachmentService) Resolve(ctx context.Context, message *domain.Message) error {
+	if len(message.Attachments) > domain.MaxMessageAttachments {
+		return fmt.Errorf("%w: at most %d attachments per message", domain.ErrInvalidAttachment, domain.MaxMessageAttachments)
+	}
+
+	conversationID := message.Metadata["conversation_id"]
+	resolved := make([]domain.Attachment, 0, len(message.Attachments))
+	seen := make(map[string]bool)
+	for _, reference := range message.Attachments {
+		if seen[reference.ID] {
+			continue
+		}
+		seen[reference.ID] = true
+
+		attachment, err := s.attachments.GetAttachment(ctx, reference.ID)
+		if err != nil {
+			return fmt.Errorf("%w: unknown attachment %s", domain.ErrInvalidAttachment, reference.ID)
+		}
+		if attachment.CustomerID != message.CustomerID ||
+			(conversationID != "" && attachment.ConversationID != conversationID) {
+			return fmt.Errorf("%w: attachment %s belongs to another conversation", domain.ErrInvalidAttachment, reference.ID)
+		}
+		resolved = append(resolved, *attachment)
+	}
+	message.Attachments = resolved
+	return nil
+}
+
+// Sign sets download links valid for the duration on the attachments of
+// the messages, in place
+func (s *AttachmentService) Sign(messages []domain.Message, validFor time.Duration) {
+	expires := time.Now().Add(validFor)
+	for i := range messages {
+		for j := range messages[i].Attachments {
+			attachment := &messages[i].Attachments[j]
+			attachment.URL = s.link(attachment.ID, expires)
+		}
+	}
+}
+
+// link builds the signed link to download an attachment until expires
+func (s *AttachmentService) link(id string, expires time.Time) string {
+	query := url.Values{}
+	query.Set("expires", strconv.FormatInt(expires.Unix(), 10))
+	query.Set("signature", hex.EncodeToString(s.signature(id, expires.Unix())))
+	return s.urlPrefix + "/" + url.PathEscape(id) + "?" + query.Encode()
+}
+
+// signature is the HMAC of an attachment ID and expiry
+func (s *AttachmentService) signature(id string, expires int64) []byte {
+	mac := hmac.New(sha256.New, s.signingKey)
+	mac.Write([]byte(id + "\n" + strconv.FormatInt(expires, 10)))
+	return mac.Sum(nil)
+}
+
+// cleanFileName keeps the last element of an uploaded file name, which
+// browsers may send with a path
+func cleanFileName(name string) string {
+	name = strings.TrimSpace(path.Base(strings.ReplaceAll(name, "\\", "/")))
+	if name == "" || name == "." || name == "/" {
+		return "attachment"
+	}
+	if len(name) > maxFileNameLength {
+		name = strings.ToValidUTF8(name[:maxFileNameLength], "")
+	}
+	return name
+}
@@ -0,0 +1,271 @@
+package services_test
+
+import (
+	"bytes"
+	"chat-service/internal/core/domain"
+	"chat-service/internal/core/services"
+	"context"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// pngHeader is enough of a PNG file for its type to be sniffed
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+// memoryBlobs keeps blobs in memory
+type memoryBlobs struct {
+	mutex sync.Mutex
+	blobs map[string][]byte
+}
+
+func (b *memoryBlobs) Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return err
+	}
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.blobs[key] = data
+	return nil
+}
+
+func (b *memoryBlobs) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	data, ok := b.blobs[key]
+	if !ok {
+		return nil, domain.ErrBlobNotFound
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (b *memoryBlobs) Delete(ctx context.Context, key string) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	delete(b.blobs, key)
+	return nil
+}
+
+// memoryAttachments keeps attachments in memory
+type memoryAttachments struct {
+	mutex       sync.Mutex
+	attachments map[string]domain.Attachment
+}
+
+func (r *memoryAttachments) SaveAttachment(ctx context.Context, attachment *domain.Attachment) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.attachments[attachment.ID] = *attachment
+	return nil
+}
+
+func (r *memoryAttachments) GetAttachment(ctx context.Context, id string) (*domain.Attachment, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	attachment, ok := r.attachments[id]
+	if !ok {
+		return nil, domain.ErrAttachmentNotFound
+	}
+	return &attachment, nil
+}
+
+func newAttachmentService(conversations ...domain.Conversation) (*services.AttachmentService, *memoryBlobs) {
+	blobs := &memoryBlobs{blobs: make(map[string][]byte)}
+	attachments := &memoryAttachments{attachments: make(map[string]domain.Attachment)}
+	service := services.NewAttachmentService(blobs, attachments, newMemoryConversations(conversations...), []byte("secret"))
+	service.SetURLPrefix("/chat/attachments/")
+	return service, blobs
+}
+
+// openLink downloads the file a signed link points to
+func openLink(t *testing.T, service *services.AttachmentService, link string) (*domain.Attachment, []byte, error) {
+	parsed, err := url.Parse(link)
+	require.NoError(t, err)
+	id := strings.TrimPrefix(parsed.Path, "/chat/attachments/")
+	expires, _ := strconv.ParseInt(parsed.Query().Get("expires"), 10, 64)
+
+	attachment, body, err := service.Open(context.Background(), id, expires, parsed.Query().Get("signature"))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer body.Close()
+	data, err := io.ReadAll(body)
+	require.NoError(t, err)
+	return attachment, data, nil
+}
+
+func TestAttachmentUpload(t *testing.T) {
+	ctx := context.Background()
+	service, blobs := newAttachmentService(
+		domain.Conversation{ID: "conv1", CustomerID: "customer1", Status: "active"},
+		domain.Conversation{ID: "old", CustomerID: "customer1", Status: "closed"},
+		domain.Conversation{ID: "assigned", CustomerID: "customer2", AgentID: "agent1", Status: "active"},
+	)
+
+	t.Run("stores the file under the conversation", func(t *testing.T) {
+		attachment := &domain.Attachment{
+			ConversationID: "conv1",
+			UploadedBy:     "customer1",
+			FileName:       `C:\Users\me\error.png`,
+		}
+		require.NoError(t, service.Upload(ctx, attachment, bytes.NewReader(pngHeader)))
+
+		assert.NotEmpty(t, attachment.ID)
+		assert.Equal(t, "customer1", attachment.CustomerID)
+		assert.Equal(t, "error.png", attachment.FileName)
+		assert.Equal(t, "image/png", attachment.ContentType)
+		assert.Equal(t, int64(len(pngHeader)), attachment.Size)
+		assert.Equal(t, pngHeader, blobs.blobs[attachment.StorageKey])
+		assert.True(t, strings.HasPrefix(attachment.URL, "/chat/attachments/"+attachment.ID+"?"))
+
+		downloaded, data, err := openLink(t, service, attachment.URL)
+		require.NoError(t, err)
+		assert.Equal(t, attachment.ID, downloaded.ID)
+		assert.Equal(t, pngHeader, data)
+	})
+
+	t.Run("rejects files it does not accept", func(t *testing.T) {
+		uploads := map[string][]byte{
+			"empty":      {},
+			"too large":  append(pngHeader, make([]byte, domain.MaxAttachmentSize)...),
+			"executable": []byte("MZ\x90\x00\x03\x00\x00\x00\x04\x00\x00\x00\xff\xff\x00\x00"),
+			"html":       []byte("<html><script>alert(1)</script></html>"),
+		}
+		for name, data := range uploads {
+			attachment := &domain.Attachment{ConversationID: "conv1", UploadedBy: "customer1", FileName: "file.png"}
+			err := service.Upload(ctx, attachment, bytes.NewReader(data))
+			assert.ErrorIs(t, err, domain.ErrInvalidAttachment, name)
+		}
+
+		err := service.Upload(ctx, &domain.Attachment{ConversationID: "missing", UploadedBy: "customer1"}, bytes.NewReader(pngHeader))
+		assert.ErrorIs(t, err, domain.ErrInvalidAttachment)
+		err = service.Upload(ctx, &domain.Attachment{ConversationID: "old", UploadedBy: "customer1"}, bytes.NewReader(pngHeader))
+		assert.ErrorIs(t, err, domain.ErrConversationClosed)
+	})
+
+	t.Run("only the customer and the assigned agent may upload", func(t *testing.T) {
+		attachment := &domain.Attachment{ConversationID: "assigned", UploadedBy: "agent1", FileName: "label.png"}
+		require.NoError(t, service.Upload(ctx, attachment, bytes.NewReader(pngHeader)))
+		assert.Equal(t, "customer2", attachment.CustomerID)
+
+		for _, uploader := range []string{"customer1", "agent2"} {
+			attachment := &domain.Attachment{ConversationID: "assigned", UploadedBy: uploader, FileName: "label.png"}
+			err := service.Upload(ctx, attachment, bytes.NewReader(pngHeader))
+			assert.ErrorIs(t, err, domain.ErrUploadNotAllowed, uploader)
+		}
+	})
+}
+
+func TestAttachmentLinks(t *testing.T) {
+	ctx := context.Background()
+	service, _ := newAttachmentService(domain.Conversation{ID: "conv1", CustomerID: "customer1", Status: "active"})
+	attachment := &domain.Attachment{ConversationID: "conv1", UploadedBy: "customer1", FileName: "notes.txt"}
+	require.NoError(t, service.Upload(ctx, attachment, strings.NewReader("order 1234 arrived broken")))
+	assert.Equal(t, "text/plain", attachment.ContentType)
+
+	t.Run("tampered links are refused", func(t *testing.T) {
+		parsed, _ := url.Parse(attachment.URL)
+		query := parsed.Query()
+		expires, _ := strconv.ParseInt(query.Get("expires"), 10, 64)
+		query.Set("expires", strconv.FormatInt(expires+3600, 10))
+		parsed.RawQuery = query.Encode()
+
+		_, _, err := openLink(t, service, parsed.String())
+		assert.ErrorIs(t, err, domain.ErrInvalidSignature)
+	})
+
+	t.Run("expired links are refused", func(t *testing.T) {
+		messages := []domain.Message{{Attachments: []domain.Attachment{{ID: attachment.ID}}}}
+		service.Sign(messages, -time.Minute)
+
+		_, _, err := openLink(t, service, messages[0].Attachments[0].URL)
+		assert.ErrorIs(t, err, domain.ErrInvalidSignature)
+
+		service.Sign(messages, time.Minute)
+		_, data, err := openLink(t, service, messages[0].Attachments[0].URL)
+		require.NoError(t, err)
+		assert.Equal(t, "order 1234 arrived broken", string(data))
+	})
+}
+
+func TestMessageAttachments(t *testing.T) {
+	conversations := newMemoryConversations(
+		domain.Conversation{ID: "conv1", CustomerID: "customer1", Status: "active"},
+		domain.Conversation{ID: "conv2", CustomerID: "customer2", Status: "active"},
+	)
+	blobs := &memoryBlobs{blobs: make(map[string][]byte)}
+	attachments := services.NewAttachmentService(blobs,
+		&memoryAttachments{attachments: make(map[string]domain.Attachment)}, conversations, []byte("secret"))
+
+	upload := func(conversationID, customerID string) string {
+		attachment := &domain.Attachment{ConversationID: conversationID, UploadedBy: customerID, FileName: "photo.png"}
+		require.NoError(t, attachments.Upload(context.Background(), attachment, bytes.NewReader(pngHeader)))
+		return attachment.ID
+	}
+	mine, theirs := upload("conv1", "customer1"), upload("conv2", "customer2")
+
+	repo := new(MockMessageRepo)
+	var stored domain.Message
+	repo.On("SaveMessage", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		stored = *args.Get(1).(*domain.Message)
+	}).Return(nil)
+	publisher := new(MockMessagePublisher)
+	publisher.On("PublishChatMessage", mock.Anything).Return(nil)
+	chat := services.NewChatService(repo, conversations, publisher)
+
+	message := func(ids ...string) *domain.Message {
+		message := &domain.Message{
+			CustomerID: "customer1",
+			Type:       domain.UserMessage,
+			Metadata:   map[string]string{"conversation_id": "conv1"},
+		}
+		for _, id := range ids {
+			message.Attachments = append(message.Attachments, domain.Attachment{ID: id})
+		}
+		return message
+	}
+
+	t.Run("needs attachments enabled", func(t *testing.T) {
+		err := chat.SaveMessage(message(mine))
+		assert.ErrorIs(t, err, domain.ErrInvalidAttachment)
+	})
+
+	chat.SetAttachments(attachments, time.Hour)
+
+	t.Run("resolves and signs the customer's uploads", func(t *testing.T) {
+		sent := message(mine, mine)
+		require.NoError(t, chat.SaveMessage(sent))
+
+		require.Len(t, sent.Attachments, 1)
+		assert.Equal(t, "photo.png", sent.Attachments[0].FileName)
+		assert.Equal(t, "image/png", sent.Attachments[0].ContentType)
+		assert.NotEmpty(t, sent.Attachments[0].URL)
+		assert.Equal(t, "photo.png", stored.Attachments[0].FileName)
+	})
+
+	t.Run("refuses uploads of other conversations", func(t *testing.T) {
+		for _, id := range []string{theirs, "unknown"} {
+			err := chat.SaveMessage(message(id))
+			assert.ErrorIs(t, err, domain.ErrInvalidAttachment, id)
+		}
+	})
+
+	t.Run("signs the attachments of messages read", func(t *testing.T) {
+		repo.On("GetMessagePage", mock.Anything, mock.Anything).Return(&domain.MessagePage{
+			Messages: []domain.Message{{ID: "msg1", Attachments: []domain.Attachment{{ID: mine}}}},
+		}, nil).Once()
+
+		page, err := chat.GetMessages(domain.MessageQuery{ConversationID: "conv1"})
+		require.NoError(t, err)
+		assert.Contains(t, page.Messages[0].Attachments[0].URL, "signature=")
+	})
+}
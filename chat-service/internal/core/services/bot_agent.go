@@ -7,37 +7,47 @@ import (
 	"context"
 	"errors"
 	"log"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/google/uuid"
-	"github.com/openai/openai-go"        // New import
-	"github.com/openai/openai-go/option" // New import
-	"github.com/openai/openai-go/packages/param"
 	"golang.org/x/time/rate"
 )
 
 type BotAgent struct {
-	ID            string
-	Name          string
-	repository    ports.MessageRepository
-	publisher     ports.MessagePublisher
-	useAI         bool
-	conversations map[string][]openai.ChatCompletionMessageParamUnion
-	mutex         sync.Mutex
-	aiClient      openai.Client
-	rateLimiter   *rate.Limiter
+	ID          string
+	Name        string
+	repository  ports.MessageRepository
+	publisher   ports.MessagePublisher
+	useAI       bool
+	llm         ports.LLMProvider
+	rateLimiter *rate.Limiter
+
+	// memory recalls the conversation for the language model
+	memory ports.ConversationMemory
 
 	// Response caching
-	responseCache map[string]string
-	cacheMutex    sync.RWMutex
+	responseCache *ResponseCache
 
 	// Add a Hub field to BotAgent
 	hub ports.MessageHub // Add this field
 
 	// Add this field to the BotAgent struct
 	knowledgeBase *KnowledgeBase
+
+	// flowEngine runs guided conversations before the knowledge base is consulted
+	flowEngine *FlowEngine
+
+	// escalations hands conversations over to human agents
+	escalations *EscalationService
+
+	// takeover mutes the bot while an agent is in control
+	takeover *TakeoverService
+
+	// redactor keeps personal data out of the logs and the language model
+	redactor *PIIRedactor
 }
 
 var _ ports.BotService = (*BotAgent)(nil)
@@ -49,10 +59,10 @@ func NewBotAgent(id, name string, useAi bool, repo ports.MessageRepository, pub
 		repository:    repo,
 		publisher:     pub,
 		useAI:         useAi,
-		conversations: make(map[string][]openai.ChatCompletionMessageParamUnion),
 		rateLimiter:   rate.NewLimiter(rate.Every(6*time.Second), 1), // Updated to 6 seconds
-		responseCache: make(map[string]string),
+		responseCache: NewResponseCache(DefaultResponseCacheSize, DefaultResponseCacheTTL),
 		knowledgeBase: knowledgeBase,
+		redactor:      NewPIIRedactor(),
 	}
 }
 
@@ -61,9 +71,41 @@ func (b *BotAgent) SetHub(hub ports.MessageHub) {
 	b.hub = hub
 }
 
+// SetResponseCache replaces the default response cache
+func (b *BotAgent) SetResponseCache(cache *ResponseCache) {
+	b.responseCache = cache
+}
+
+// SetConversationMemory sets where the conversation history sent to the
+// language model comes from. Without it only the latest message is sent.
+func (b *BotAgent) SetConversationMemory(memory ports.ConversationMemory) {
+	b.memory = memory
+}
+
+// SetFlowEngine enables guided conversation flows
+func (b *BotAgent) SetFlowEngine(engine *FlowEngine) {
+	b.flowEngine = engine
+}
+
+// SetEscalationService enables handing conversations over to human agents
+func (b *BotAgent) SetEscalationService(escalations *EscalationService) {
+	b.escalations = escalations
+}
+
+// SetTakeoverService lets agents mute the bot by taking conversations over
+func (b *BotAgent) SetTakeoverService(takeover *TakeoverService) {
+	b.takeover = takeover
+}
+
+// SetPIIRedactor replaces the default redactor, which finds the built-in
+// kinds of personal data
+func (b *BotAgent) SetPIIRedactor(redactor *PIIRedactor) {
+	b.redactor = redactor
+}
+
 func (b *BotAgent) ProcessMessage(ctx context.Context, message *domain.Message) error {
 	log.Printf("Bot received message: %s from user: %s (type: %s)",
-		message.Content, message.UserID, message.Type)
+		b.redactor.Redact(message.Content), message.UserID, message.Type)
 
 	// Only process user messages
 	if message.Type != domain.UserMessage {
@@ -71,56 +113,103 @@ func (b *BotAgent) ProcessMessage(ctx context.Context, message *domain.Message)
 		return nil
 	}
 
-	// Create bot response with robust error handling
-	var responseText string
+	// Stay quiet while an agent is answering the customer
+	if b.takeover != nil && b.takeover.botMuted(ctx, message.CustomerID) {
+		log.Printf("Bot muted for customer %s, an agent is in control", message.CustomerID)
+		return nil
+	}
+
+	stopTyping := b.startTyping(message)
+	defer stopTyping()
+
+	responseID := uuid.New().String()
+	stream := newChunkStream(b.hub, responseID, message.CustomerID)
 
 	// Try to generate response with timeout
 	responseCtx, cancel := context.WithTimeout(ctx, 15*time.Second)
 	defer cancel()
 
-	done := make(chan bool, 1)
+	result := make(chan botReply, 1)
 	go func() {
-		defer func() {
-			done <- true
-		}()
-
-		// First check cache
-		cacheKey := message.CustomerID + ":" + message.Content
-		b.cacheMutex.RLock()
-		cachedResp, found := b.responseCache[cacheKey]
-		b.cacheMutex.RUnlock()
-
-		if found {
-			responseText = cachedResp
-			log.Printf("Using cached response for '%s'", message.Content)
-		} else {
-			// Generate new response
-			responseText = b.generateResponse(message.Content)
-
-			// Cache the response
-			b.cacheMutex.Lock()
-			b.responseCache[cacheKey] = responseText
-			b.cacheMutex.Unlock()
+		// Asking for a person always wins, even in the middle of a flow
+		if b.escalations != nil && b.escalations.IsHumanRequest(message.Content) {
+			if b.flowEngine != nil {
+				b.flowEngine.cancel(responseCtx, message.CustomerID)
+			}
+			result <- escalationReply(domain.EscalationCustomerRequest)
+			return
+		}
+
+		// Flows depend on conversation state, so they come first and are never cached
+		if b.flowEngine != nil {
+			if reply, ok := b.flowEngine.handle(responseCtx, message); ok {
+				result <- reply
+				return
+			}
 		}
+
+		// Then check cache
+		if cachedResp, found := b.responseCache.lookup(message.Content); found {
+			log.Printf("Using cached response for '%s'", b.redactor.Redact(message.Content))
+			result <- cachedResp
+			return
+		}
+
+		// Generate new response, streaming AI output as it arrives
+		reply := b.generateResponse(responseCtx, message, stream)
+		if responseCtx.Err() == nil {
+			b.responseCache.store(message.Content, reply)
+		}
+		result <- reply
 	}()
 
 	// Wait for response generation or timeout
+	var reply botReply
 	select {
-	case <-done:
+	case reply = <-result:
 		// Response generated successfully
 	case <-responseCtx.Done():
-		log.Printf("Response generation timed out for: '%s'", message.Content)
-		responseText = "I'm sorry, it's taking me longer than expected to respond. Please try asking again."
+		stream.close()
+		if ctx.Err() != nil {
+			// The customer went away; nothing to deliver or persist
+			log.Printf("Response generation cancelled for: '%s'", b.redactor.Redact(message.Content))
+			return ctx.Err()
+		}
+		log.Printf("Response generation timed out for: '%s'", b.redactor.Redact(message.Content))
+		reply = botReply{Text: "I'm sorry, it's taking me longer than expected to respond. Please try asking again."}
+	}
+	stream.close()
+
+	if ctx.Err() != nil {
+		log.Printf("Response generation cancelled for: '%s'", b.redactor.Redact(message.Content))
+		return ctx.Err()
+	}
+
+	// Hand over once the bot keeps failing to understand the customer
+	if b.escalations != nil && reply.Escalation == "" {
+		if !reply.Fallback {
+			b.escalations.resetFallbacks(conversationKey(message))
+		} else if b.escalations.recordFallback(conversationKey(message)) {
+			reply = escalationReply(domain.EscalationRepeatedFallback)
+		}
+	}
+
+	// Claim the escalation before telling the customer about it, so only
+	// the first request for a person promises a ticket
+	var escalating *domain.Conversation
+	if b.escalations != nil && reply.Escalation != "" {
+		reply, escalating = b.escalations.handover(ctx, message.CustomerID, reply)
 	}
 
 	// Create bot response
 	response := &domain.Message{
-		ID:         uuid.New().String(),
-		Content:    responseText,
+		ID:         responseID,
+		Content:    reply.Text,
 		UserID:     b.ID, // Bot's ID
 		CustomerID: message.CustomerID,
 		Type:       domain.BotMessage,
 		Timestamp:  time.Now(),
+		Metadata:   reply.Metadata,
 	}
 
 	// Store message with error handling
@@ -137,68 +226,130 @@ func (b *BotAgent) ProcessMessage(ctx context.Context, message *domain.Message)
 	}
 
 	// 2. Direct delivery via hub (more reliable)
+	stopTyping()
 	if b.hub != nil {
-		b.hub.SendBotResponse(response)
+		b.hub.SendBotComplete(response)
 		log.Printf("Bot response sent via hub")
 	}
 
+	// Publish after the handover message so it is part of the transcript
+	if escalating != nil {
+		if err := b.escalations.publish(ctx, escalating, reply.Escalation, reply.Metadata["flow_id"]); err != nil {
+			log.Printf("Error escalating conversation: %v", err)
+		}
+	}
+
 	return nil
 }
 
+// startTyping shows the bot typing in the customer's conversation until the
+// returned function is called, refreshing the indicator so it outlives
+// domain.TypingTTL while slow responses are generated
+func (b *BotAgent) startTyping(message *domain.Message) (stop func()) {
+	if b.hub == nil {
+		return func() {}
+	}
+
+	indicator := domain.TypingIndicator{
+		ConversationID: message.Metadata["conversation_id"],
+		CustomerID:     message.CustomerID,
+		UserID:         b.ID,
+		Role:           domain.ParticipantBot,
+		Name:           b.Name,
+		Typing:         true,
+	}
+	send := func(typing bool) {
+		update := indicator
+		update.Typing = typing
+		b.hub.SendTyping(&update)
+	}
+	send(true)
+
+	done := make(chan struct{})
+	refreshed := make(chan struct{})
+	go func() {
+		defer close(refreshed)
+		ticker := time.NewTicker(domain.TypingTTL / 2)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				send(true)
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			// Wait for the refresher so a late refresh can't restart typing
+			close(done)
+			<-refreshed
+			send(false)
+		})
+	}
+}
+
+// fallbackResponse is sent when no knowledge entry matches confidently
+const fallbackResponse = "I'm not sure how to respond to that. Could you try phrasing your question differently?"
+
 // Legacy rule-based response generator
 func (b *BotAgent) generateRuleBasedResponse(input string) string {
-	return b.knowledgeBase.FindBestMatch(input)
+	if b.knowledgeBase == nil {
+		return fallbackResponse
+	}
+	if match, ok := b.knowledgeBase.FindBestMatch(input); ok {
+		return match.Entry.Answer
+	}
+	return fallbackResponse
 }
 
-// SetupAIClient configures the OpenAI client
-func (b *BotAgent) SetupAIClient(apiKey string) {
-	if apiKey == "" {
-		log.Printf("Warning: Empty OpenAI API key provided, AI features will be disabled")
+// SetLLMProvider configures the language model used for AI responses
+func (b *BotAgent) SetLLMProvider(provider ports.LLMProvider) {
+	if provider == nil {
+		log.Printf("Warning: No LLM provider configured, AI features will be disabled")
 		b.useAI = false
 		return
 	}
 
-	b.aiClient = openai.NewClient(
-		option.WithAPIKey(apiKey),
-	)
+	b.llm = provider
 	b.useAI = true
-	log.Printf("AI capabilities enabled for chat bot")
+	log.Printf("AI capabilities enabled for chat bot using %s provider", provider.Name())
 }
 
-// AI-powered response generation with conversation history
-func (b *BotAgent) generateAIResponse(ctx context.Context, message *domain.Message) string {
-	// Wait for rate limiter
-	if err := b.rateLimiter.Wait(ctx); err != nil {
-		log.Printf("Rate limit wait canceled: %v", err)
-		return b.generateRuleBasedResponse(message.Content)
-	}
+// botReply is a generated response and the metadata stored alongside it.
+// Fallback marks replies given because nothing matched, and Escalation the
+// reason the conversation should be handed over to a human. Only knowledge
+// base replies are cached.
+type botReply struct {
+	Text       string
+	Metadata   map[string]string
+	Fallback   bool
+	Escalation string
+	source     replySource
+}
 
-	b.mutex.Lock()
-	if _, exists := b.conversations[message.CustomerID]; !exists {
-		// Initialize with system prompt
-		b.conversations[message.CustomerID] = []openai.ChatCompletionMessageParamUnion{
-			openai.SystemMessage("You are a helpful customer support assistant. Be concise and professional."),
-		}
-		log.Printf("Creating new conversation for customer: %s", message.CustomerID)
+// AI-powered response generation with conversation history. Relevant knowledge
+// entries are added to the prompt and their IDs recorded in the reply metadata.
+// Personal data is replaced with placeholders before anything is sent, and put
+// back in the reply. Tokens are sent as chunks as the provider streams them,
+// each attempt starting the chunks over.
+func (b *BotAgent) generateAIResponse(ctx context.Context, message *domain.Message, chunks *chunkStream) botReply {
+	session := b.redactor.newSession()
+	conversation := append([]domain.LLMMessage{{Role: domain.LLMRoleSystem, Content: systemPrompt}},
+		session.redactConversation(b.recall(ctx, message))...)
+	if session.found() {
+		conversation[0].Content += piiPrompt
 	}
 
-	// Add user's message to history
-	b.conversations[message.CustomerID] = append(b.conversations[message.CustomerID],
-		openai.UserMessage(message.Content),
-	)
-
-	// Cap history length to prevent token overflow (keep last 10 exchanges)
-	if len(b.conversations[message.CustomerID]) > 21 {
-		b.conversations[message.CustomerID] = append(
-			b.conversations[message.CustomerID][:1],
-			b.conversations[message.CustomerID][len(b.conversations[message.CustomerID])-20:]...,
-		)
+	var reply botReply
+	conversation, entryIDs := b.withKnowledge(ctx, conversation, session.redact(message.Content))
+	if len(entryIDs) > 0 {
+		reply.Metadata = map[string]string{"kb_entries": strings.Join(entryIDs, ",")}
 	}
 
-	// Get conversation for this customer
-	conversation := b.conversations[message.CustomerID]
-	b.mutex.Unlock()
-
 	// Create timeout context
 	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
@@ -213,115 +364,207 @@ func (b *BotAgent) generateAIResponse(ctx context.Context, message *domain.Messa
 		// Wait for rate limiter before making request
 		if err := b.rateLimiter.Wait(ctx); err != nil {
 			log.Printf("Rate limit wait canceled: %v", err)
-			return b.generateRuleBasedResponse(message.Content)
+			return b.unavailableReply(message)
 		}
 
-		// Create chat completion with new client
-		chatCompletion, err := b.aiClient.Chat.Completions.New(
-			timeoutCtx,
-			openai.ChatCompletionNewParams{
-				Model:       openai.ChatModelGPT3_5Turbo,    // Remove openai.F()
-				Messages:    conversation,                   // Remove openai.F()
-				MaxTokens:   param.Opt[int64]{Value: 100},   // Remove openai.F()
-				Temperature: param.Opt[float64]{Value: 0.7}, // Properly wrap in param.Opt
-			},
-		)
-
-		if err == nil && len(chatCompletion.Choices) > 0 {
-			responseContent = chatCompletion.Choices[0].Message.Content
+		// Clients drop what a failed attempt streamed
+		chunks.restart()
+		stream := session.restoreStream(chunks.send)
+		content, err := b.llm.Stream(timeoutCtx, conversation, stream.send)
+		if err == nil {
+			stream.flush()
+			responseContent = content
 			break // Success, exit retry loop
 		}
 
-		if err != nil {
-			if strings.Contains(err.Error(), "429") {
-				log.Printf("Rate limited by OpenAI (attempt %d/%d), retrying in %v", i+1, maxRetries, backoff)
-				time.Sleep(backoff)
-				backoff *= 2 // Exponential backoff
-			} else {
-				log.Printf("AI service error: %v", err)
-				return b.generateRuleBasedResponse(message.Content)
-			}
-		} else {
-			log.Printf("API returned empty choices (attempt %d/%d)", i+1, maxRetries)
-			err = errors.New("empty response from API")
+		switch {
+		case errors.Is(err, domain.ErrLLMRateLimited):
+			log.Printf("Rate limited by %s (attempt %d/%d), retrying in %v", b.llm.Name(), i+1, maxRetries, backoff)
+			time.Sleep(backoff)
+			backoff *= 2 // Exponential backoff
+		case errors.Is(err, domain.ErrLLMEmptyResponse):
+			log.Printf("LLM returned an empty response (attempt %d/%d)", i+1, maxRetries)
+		default:
+			log.Printf("AI service error: %v", err)
+			return b.unavailableReply(message)
 		}
 	}
 
 	// After retry loop, check if we got a valid response
 	if responseContent == "" {
 		log.Printf("Failed to get response after %d retries", maxRetries)
-		return b.generateRuleBasedResponse(message.Content) + " (AI service unavailable)"
+		return b.unavailableReply(message)
 	}
 
-	// Add AI response to conversation history
-	b.mutex.Lock()
-	b.conversations[message.CustomerID] = append(b.conversations[message.CustomerID],
-		openai.AssistantMessage(responseContent),
-	)
-	b.mutex.Unlock()
+	reply.Text = session.restore(responseContent)
+	if session.found() {
+		if reply.Metadata == nil {
+			reply.Metadata = make(map[string]string)
+		}
+		reply.Metadata[domain.PIIRedactedKey] = session.summary()
+	}
+	return reply
+}
 
-	return responseContent
+// unavailableReply answers without the language model when it can't be
+// reached. It counts as a fallback, since the customer's question went
+// unanswered.
+func (b *BotAgent) unavailableReply(message *domain.Message) botReply {
+	return botReply{Text: b.generateRuleBasedResponse(message.Content), Fallback: true}
+}
+
+// systemPrompt opens every conversation sent to the language model
+const systemPrompt = "You are a helpful customer support assistant. Be concise and professional."
+
+// recall returns the conversation so far, ending with the message. Without a
+// memory, or when it fails, the model only sees the message.
+func (b *BotAgent) recall(ctx context.Context, message *domain.Message) []domain.LLMMessage {
+	latest := []domain.LLMMessage{{Role: domain.LLMRoleUser, Content: message.Content}}
+	if b.memory == nil {
+		return latest
+	}
+
+	history, err := b.memory.Recall(ctx, message)
+	if err != nil {
+		log.Printf("Error recalling conversation for customer %s: %v", message.CustomerID, err)
+		return latest
+	}
+	return history
+}
+
+// withKnowledge returns a copy of the conversation with the knowledge entries
+// relevant to the query inserted just before the latest user turn, along with
+// the IDs of those entries. The stored history is left untouched.
+func (b *BotAgent) withKnowledge(ctx context.Context, conversation []domain.LLMMessage, query string) ([]domain.LLMMessage, []string) {
+	if b.knowledgeBase == nil || len(conversation) == 0 {
+		return conversation, nil
+	}
+
+	retrieveCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+
+	matches, err := b.knowledgeBase.Retrieve(retrieveCtx, query)
+	if err != nil {
+		log.Printf("Knowledge retrieval failed: %v", err)
+		return conversation, nil
+	}
+	if len(matches) == 0 {
+		return conversation, nil
+	}
+
+	entryIDs := make([]string, len(matches))
+	for i, match := range matches {
+		entryIDs[i] = match.Entry.ID
+	}
+
+	last := len(conversation) - 1
+	augmented := make([]domain.LLMMessage, 0, len(conversation)+1)
+	augmented = append(augmented, conversation[:last]...)
+	augmented = append(augmented, domain.LLMMessage{Role: domain.LLMRoleSystem, Content: knowledgePrompt(matches)})
+	augmented = append(augmented, conversation[last])
+
+	return augmented, entryIDs
 }
 
 // generateResponse creates a response using knowledge base first, then AI if needed
-func (b *BotAgent) generateResponse(input string) string {
-	input = strings.TrimSpace(input)
-	normalizedInput := strings.ToLower(input)
+func (b *BotAgent) generateResponse(ctx context.Context, message *domain.Message, chunks *chunkStream) botReply {
+	input := strings.TrimSpace(message.Content)
 
-	log.Printf("Bot generating response for: '%s'", input)
+	log.Printf("Bot generating response for: '%s'", b.redactor.Redact(input))
 
 	// Step 1: Try knowledge base first with timeout and error handling
-	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	kbCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
 	defer cancel()
 
-	var knowledgeResponse string
-	var knowledgeErr error
-
 	// Use a separate goroutine to query knowledge base with timeout
-	done := make(chan bool, 1)
+	found := make(chan *domain.ScoredMatch, 1)
 	go func() {
-		defer func() {
-			done <- true
-		}()
-
-		// Check if knowledge base is available
-		if b.knowledgeBase != nil {
-			knowledgeResponse = b.knowledgeBase.FindBestMatch(input)
-			if knowledgeResponse != "" && !strings.Contains(knowledgeResponse, "I'm not sure") {
-				log.Printf("Bot found knowledge base match for: '%s'", input)
-			}
-		} else {
-			knowledgeErr = errors.New("knowledge base not available")
+		if b.knowledgeBase == nil {
+			found <- nil
+			return
 		}
+		match, ok := b.knowledgeBase.FindBestMatch(input)
+		if !ok {
+			match = nil
+		}
+		found <- match
 	}()
 
 	// Wait for knowledge base query or timeout
+	var match *domain.ScoredMatch
 	select {
-	case <-done:
+	case match = <-found:
 		// Query completed
-	case <-ctx.Done():
-		log.Printf("Knowledge base query timed out for: '%s'", input)
-		knowledgeErr = ctx.Err()
+	case <-kbCtx.Done():
+		log.Printf("Knowledge base query timed out for: '%s'", b.redactor.Redact(input))
 	}
 
-	// If we got a good response from knowledge base, return it
-	if knowledgeErr == nil && knowledgeResponse != "" &&
-		!strings.Contains(knowledgeResponse, "I'm not sure") {
-		return knowledgeResponse
+	// If the knowledge base is confident enough, answer from it
+	if match != nil {
+		log.Printf("Bot found knowledge base match for: '%s'", b.redactor.Redact(input))
+		return botReply{
+			Text: match.Entry.Answer,
+			Metadata: map[string]string{
+				"kb_entries":    match.Entry.ID,
+				"kb_confidence": strconv.FormatFloat(match.Score, 'f', 2, 64),
+			},
+			source: replyFromKnowledge,
+		}
 	}
 
-	// Step 2: Fall back to rule-based for common patterns
-	if strings.Contains(normalizedInput, "hello") ||
-		strings.Contains(normalizedInput, "hi") ||
-		strings.Contains(normalizedInput, "help") {
-		return b.generateRuleBasedResponse(input)
+	// Step 2: Fall back to AI if enabled
+	if b.useAI && b.llm != nil {
+		return b.generateAIResponse(ctx, message, chunks)
 	}
 
-	// Step 3: Fall back to AI if enabled and input is complex
-	if b.useAI {
-		return b.generateAIResponse(context.Background(), &domain.Message{Content: input})
+	// Step 3: Nothing matched well enough
+	return botReply{Text: fallbackResponse, Fallback: true}
+}
+
+// chunkStream relays streamed tokens to the hub as bot_chunk frames. Once
+// closed it drops late tokens so nothing arrives after the final message.
+// Restarting it numbers the next chunk 0 again, which tells clients to drop
+// the chunks before it.
+type chunkStream struct {
+	hub        ports.MessageHub
+	messageID  string
+	customerID string
+	index      int
+	closed     bool
+	mutex      sync.Mutex
+}
+
+func newChunkStream(hub ports.MessageHub, messageID, customerID string) *chunkStream {
+	return &chunkStream{hub: hub, messageID: messageID, customerID: customerID}
+}
+
+func (s *chunkStream) send(delta string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.closed {
+		return context.Canceled
+	}
+	if s.hub != nil {
+		s.hub.SendBotChunk(&domain.MessageChunk{
+			MessageID:  s.messageID,
+			CustomerID: s.customerID,
+			Content:    delta,
+			Index:      s.index,
+		})
 	}
+	s.index++
+	return nil
+}
+
+func (s *chunkStream) restart() {
+	s.mutex.Lock()
+	s.index = 0
+	s.mutex.Unlock()
+}
 
-	// Step 4: Last resort - use basic rule-based
-	return b.generateRuleBasedResponse(input)
+func (s *chunkStream) close() {
+	s.mutex.Lock()
+	s.closed = true
+	s.mutex.Unlock()
 }
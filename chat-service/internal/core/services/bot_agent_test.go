@@ -0,0 +1,360 @@
+package services_test
+
+import (
+	"chat-service/internal/adapters/secondary/embedding"
+	"chat-service/internal/core/domain"
+	"chat-service/internal/core/ports"
+	"chat-service/internal/core/services"
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// stubKnowledgeRepo is an in-memory knowledge repository
+type stubKnowledgeRepo struct {
+	entries    []domain.KnowledgeEntry
+	embeddings map[string][]float32
+	synonyms   []domain.Synonym
+}
+
+func (r *stubKnowledgeRepo) GetAllEntries(ctx context.Context) ([]domain.KnowledgeEntry, error) {
+	return r.entries, nil
+}
+
+func (r *stubKnowledgeRepo) GetEntryByID(ctx context.Context, id string) (*domain.KnowledgeEntry, error) {
+	for i := range r.entries {
+		if r.entries[i].ID == id {
+			return &r.entries[i], nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *stubKnowledgeRepo) CreateEntry(ctx context.Context, entry *domain.KnowledgeEntry) error {
+	r.entries = append(r.entries, *entry)
+	return nil
+}
+
+func (r *stubKnowledgeRepo) UpdateEntry(ctx context.Context, entry *domain.KnowledgeEntry) error {
+	return nil
+}
+
+func (r *stubKnowledgeRepo) DeleteEntry(ctx context.Context, id string) error {
+	return nil
+}
+
+func (r *stubKnowledgeRepo) SearchEntries(ctx context.Context, query string) ([]domain.KnowledgeEntry, error) {
+	return nil, nil
+}
+
+func (r *stubKnowledgeRepo) SaveEmbedding(ctx context.Context, entryID string, embedding []float32) error {
+	if r.embeddings == nil {
+		r.embeddings = make(map[string][]float32)
+	}
+	r.embeddings[entryID] = embedding
+	return nil
+}
+
+func (r *stubKnowledgeRepo) SearchByEmbedding(ctx context.Context, embedding []float32, limit int) ([]domain.ScoredMatch, error) {
+	var matches []domain.ScoredMatch
+	for _, entry := range r.entries {
+		stored, ok := r.embeddings[entry.ID]
+		if !ok {
+			continue
+		}
+		var score float64
+		for i := range stored {
+			score += float64(stored[i]) * float64(embedding[i])
+		}
+		matches = append(matches, domain.ScoredMatch{Entry: entry, Score: score})
+	}
+	sort.Slice(matches, func(i, j int) bool { return matches[i].Score > matches[j].Score })
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches, nil
+}
+
+func (r *stubKnowledgeRepo) GetSynonyms(ctx context.Context) ([]domain.Synonym, error) {
+	return r.synonyms, nil
+}
+
+func (r *stubKnowledgeRepo) SaveSynonym(ctx context.Context, synonym *domain.Synonym) error {
+	r.synonyms = append(r.synonyms, *synonym)
+	return nil
+}
+
+func (r *stubKnowledgeRepo) DeleteSynonym(ctx context.Context, term string) error {
+	return nil
+}
+
+// streamingLLM emits its reply word by word, or chunk bytes at a time,
+// optionally blocking between deltas
+type streamingLLM struct {
+	reply    string
+	delay    time.Duration
+	chunk    int
+	received []domain.LLMMessage
+}
+
+func (p *streamingLLM) Name() string { return "stub" }
+
+func (p *streamingLLM) Complete(ctx context.Context, messages []domain.LLMMessage) (string, error) {
+	return p.reply, nil
+}
+
+func (p *streamingLLM) Stream(ctx context.Context, messages []domain.LLMMessage, onDelta func(string) error) (string, error) {
+	p.received = messages
+	deltas := strings.SplitAfter(p.reply, " ")
+	if p.chunk > 0 {
+		deltas = nil
+		for rest := p.reply; rest != ""; {
+			n := min(p.chunk, len(rest))
+			deltas = append(deltas, rest[:n])
+			rest = rest[n:]
+		}
+	}
+	for _, word := range deltas {
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-time.After(p.delay):
+		}
+		if err := onDelta(word); err != nil {
+			return "", err
+		}
+	}
+	return p.reply, nil
+}
+
+// flakyLLM streams the start of a reply and then fails with each of errs in
+// turn, before streaming the whole reply
+type flakyLLM struct {
+	streamingLLM
+	errs []error
+}
+
+func (p *flakyLLM) Stream(ctx context.Context, messages []domain.LLMMessage, onDelta func(string) error) (string, error) {
+	if len(p.errs) > 0 {
+		err := p.errs[0]
+		p.errs = p.errs[1:]
+		onDelta("Your parcel ")
+		return "", err
+	}
+	return p.streamingLLM.Stream(ctx, messages, onDelta)
+}
+
+// recordingHub captures everything the bot sends to clients
+type recordingHub struct {
+	mutex     sync.Mutex
+	chunks    []domain.MessageChunk
+	completed []domain.Message
+	system    []domain.Message
+	typing    []domain.TypingIndicator
+}
+
+func (h *recordingHub) SendBotChunk(chunk *domain.MessageChunk) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.chunks = append(h.chunks, *chunk)
+}
+
+func (h *recordingHub) SendBotComplete(message *domain.Message) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.completed = append(h.completed, *message)
+}
+
+func (h *recordingHub) SendSystemMessage(message *domain.Message) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.system = append(h.system, *message)
+}
+
+func (h *recordingHub) SendTyping(indicator *domain.TypingIndicator) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.typing = append(h.typing, *indicator)
+}
+
+var _ ports.MessageHub = (*recordingHub)(nil)
+
+func newTestBotAgent(repo *MockMessageRepo, publisher *MockMessagePublisher, provider ports.LLMProvider) (*services.BotAgent, *recordingHub) {
+	kb := services.NewKnowledgeBase(&stubKnowledgeRepo{entries: []domain.KnowledgeEntry{
+		{ID: "payment_methods", Question: "payment methods", Answer: "We accept credit cards.", Keywords: []string{"payment"}},
+	}})
+	return newTestBotAgentWithKnowledge(repo, publisher, provider, kb)
+}
+
+func newTestBotAgentWithKnowledge(repo *MockMessageRepo, publisher *MockMessagePublisher, provider ports.LLMProvider, kb *services.KnowledgeBase) (*services.BotAgent, *recordingHub) {
+	bot := services.NewBotAgent("bot-1", "Support Bot", true, repo, publisher, kb)
+	bot.SetLLMProvider(provider)
+	hub := &recordingHub{}
+	bot.SetHub(hub)
+	return bot, hub
+}
+
+func TestBotAgentStreaming(t *testing.T) {
+	t.Run("streams chunks then persists only the final message", func(t *testing.T) {
+		repo := new(MockMessageRepo)
+		publisher := new(MockMessagePublisher)
+		bot, hub := newTestBotAgent(repo, publisher, &streamingLLM{reply: "Your parcel left our warehouse"})
+
+		repo.On("SaveMessage", mock.Anything, mock.MatchedBy(func(m *domain.Message) bool {
+			return m.Type == domain.BotMessage && m.Content == "Your parcel left our warehouse"
+		})).Return(nil).Once()
+		publisher.On("PublishChatMessage", mock.Anything).Return(nil).Once()
+
+		err := bot.ProcessMessage(context.Background(), &domain.Message{
+			Content:    "where is my parcel",
+			CustomerID: "customer1",
+			Type:       domain.UserMessage,
+		})
+
+		require.NoError(t, err)
+		require.Len(t, hub.completed, 1)
+		final := hub.completed[0]
+		assert.Equal(t, "Your parcel left our warehouse", final.Content)
+
+		require.Len(t, hub.chunks, 5)
+		var assembled strings.Builder
+		for i, chunk := range hub.chunks {
+			assert.Equal(t, final.ID, chunk.MessageID)
+			assert.Equal(t, i, chunk.Index)
+			assembled.WriteString(chunk.Content)
+		}
+		assert.Equal(t, final.Content, assembled.String())
+
+		// Typing while the response was generated, stopped once it was sent
+		require.Len(t, hub.typing, 2)
+		assert.True(t, hub.typing[0].Typing)
+		assert.False(t, hub.typing[1].Typing)
+		assert.Equal(t, "Support Bot", hub.typing[0].Name)
+		assert.Equal(t, domain.ParticipantBot, hub.typing[0].Role)
+		assert.Equal(t, "customer1", hub.typing[0].CustomerID)
+
+		repo.AssertExpectations(t)
+		publisher.AssertExpectations(t)
+	})
+
+	t.Run("cancelled generation is not persisted", func(t *testing.T) {
+		repo := new(MockMessageRepo)
+		publisher := new(MockMessagePublisher)
+		bot, hub := newTestBotAgent(repo, publisher, &streamingLLM{reply: "this takes a while", delay: time.Second})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(50*time.Millisecond, cancel)
+
+		err := bot.ProcessMessage(ctx, &domain.Message{
+			Content:    "where is my parcel",
+			CustomerID: "customer1",
+			Type:       domain.UserMessage,
+		})
+
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Empty(t, hub.completed)
+		require.NotEmpty(t, hub.typing)
+		assert.False(t, hub.typing[len(hub.typing)-1].Typing)
+		repo.AssertNotCalled(t, "SaveMessage", mock.Anything, mock.Anything)
+		publisher.AssertNotCalled(t, "PublishChatMessage", mock.Anything)
+	})
+}
+
+func TestBotAgentAIFailures(t *testing.T) {
+	t.Run("a failing service falls back without telling the customer why", func(t *testing.T) {
+		repo := new(MockMessageRepo)
+		repo.On("SaveMessage", mock.Anything, mock.Anything).Return(nil)
+		publisher := new(MockMessagePublisher)
+		publisher.On("PublishChatMessage", mock.Anything).Return(nil)
+		bot, hub := newTestBotAgent(repo, publisher, &flakyLLM{errs: []error{errors.New("connection refused")}})
+
+		reply := say(t, bot, hub, "where is my parcel")
+		assert.Equal(t, "I'm not sure how to respond to that. Could you try phrasing your question differently?", reply.Content)
+	})
+
+	t.Run("a failing service counts as a fallback", func(t *testing.T) {
+		bot, hub, escalations, service, _ := newEscalationBot(t)
+		bot.SetLLMProvider(&flakyLLM{errs: []error{errors.New("connection refused")}})
+		service.SetFallbackLimit(1)
+
+		reply := say(t, bot, hub, "blorp")
+		assert.Equal(t, domain.EscalationRepeatedFallback, reply.Metadata["escalation"])
+		require.Len(t, escalations.escalations(), 1)
+	})
+
+	t.Run("a retried attempt starts the chunks over", func(t *testing.T) {
+		if testing.Short() {
+			t.Skip("waits for the rate limiter")
+		}
+		repo := new(MockMessageRepo)
+		repo.On("SaveMessage", mock.Anything, mock.Anything).Return(nil)
+		publisher := new(MockMessagePublisher)
+		publisher.On("PublishChatMessage", mock.Anything).Return(nil)
+		provider := &flakyLLM{
+			streamingLLM: streamingLLM{reply: "Your parcel left our warehouse"},
+			errs:         []error{domain.ErrLLMRateLimited},
+		}
+		bot, hub := newTestBotAgent(repo, publisher, provider)
+
+		reply := say(t, bot, hub, "where is my parcel")
+		assert.Equal(t, "Your parcel left our warehouse", reply.Content)
+
+		require.Len(t, hub.chunks, 6)
+		assert.Equal(t, "Your parcel ", hub.chunks[0].Content)
+		var assembled strings.Builder
+		for i, chunk := range hub.chunks[1:] {
+			assert.Equal(t, i, chunk.Index)
+			assembled.WriteString(chunk.Content)
+		}
+		assert.Equal(t, reply.Content, assembled.String())
+	})
+}
+
+func TestBotAgentRetrieval(t *testing.T) {
+	repo := new(MockMessageRepo)
+	publisher := new(MockMessagePublisher)
+	provider := &streamingLLM{reply: "Standard delivery takes two days."}
+
+	kb := services.NewKnowledgeBase(&stubKnowledgeRepo{entries: []domain.KnowledgeEntry{
+		{ID: "payment_methods", Question: "payment methods", Answer: "We accept credit cards.", Keywords: []string{"payment"}},
+		{ID: "delivery_times", Question: "shipping times", Answer: "Orders are delivered within two to three working days.", Keywords: []string{"shipping", "courier"}},
+	}})
+	kb.ConfigureRetrieval(embedding.NewHashingEmbedder(256), 1, 0.1)
+	// Keep direct knowledge answers out of the way so the question reaches the LLM
+	kb.SetMatchThreshold(1.1)
+	bot, hub := newTestBotAgentWithKnowledge(repo, publisher, provider, kb)
+
+	repo.On("SaveMessage", mock.Anything, mock.MatchedBy(func(m *domain.Message) bool {
+		return m.Type == domain.BotMessage && m.Metadata["kb_entries"] == "delivery_times"
+	})).Return(nil).Once()
+	publisher.On("PublishChatMessage", mock.Anything).Return(nil).Once()
+
+	err := bot.ProcessMessage(context.Background(), &domain.Message{
+		Content:    "when will my order be delivered",
+		CustomerID: "customer1",
+		Type:       domain.UserMessage,
+	})
+	require.NoError(t, err)
+
+	require.Len(t, hub.completed, 1)
+	assert.Equal(t, "delivery_times", hub.completed[0].Metadata["kb_entries"])
+
+	// The retrieved entry sits just before the user's turn
+	require.GreaterOrEqual(t, len(provider.received), 3)
+	context := provider.received[len(provider.received)-2]
+	assert.Equal(t, domain.LLMRoleSystem, context.Role)
+	assert.Contains(t, context.Content, "[delivery_times]")
+	assert.NotContains(t, context.Content, "[payment_methods]")
+	assert.Equal(t, domain.LLMRoleUser, provider.received[len(provider.received)-1].Role)
+
+	repo.AssertExpectations(t)
+	publisher.AssertExpectations(t)
+}
@@ -0,0 +1,319 @@
+package services
+
+import (
+	"chat-service/internal/core/domain"
+	"chat-service/internal/core/ports"
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// Bot worker pool defaults used when the pool is created
+const (
+	DefaultBotWorkers        = 8
+	DefaultBotQueueSize      = 256
+	DefaultBotEnqueueTimeout = 2 * time.Second
+)
+
+// BotPoolStats reports how busy the bot worker pool is
+type BotPoolStats struct {
+	Workers       int    `json:"workers"`
+	Busy          int    `json:"busy"`
+	Queued        int    `json:"queued"`
+	Capacity      int    `json:"capacity"`
+	Conversations int    `json:"conversations"`
+	Processed     uint64 `json:"processed"`
+	Failed        uint64 `json:"failed"`
+	Rejected      uint64 `json:"rejected"`
+	Cancelled     uint64 `json:"cancelled"`
+	// How long the oldest queued message has been waiting, in milliseconds
+	OldestWaitMillis int64 `json:"oldest_wait_ms"`
+}
+
+// BotWorkerPool answers messages with a fixed number of workers. Messages
+// wait in a bounded queue and each conversation's are answered one at a
+// time in the order they arrived, while different conversations are
+// answered in parallel. It is a ports.BotService whose ProcessMessage only
+// queues the message, so callers never wait on the bot itself.
+type BotWorkerPool struct {
+	bot            ports.BotService
+	workers        int
+	capacity       int
+	enqueueTimeout time.Duration
+
+	// A token per queued or running message, so producers wait for room
+	slots chan struct{}
+
+	mutex sync.Mutex
+	// Signalled when a conversation is ready or the pool stops
+	wake *sync.Cond
+	// Conversations with messages for a worker, each at most once
+	ready         []string
+	conversations map[string]*conversationJobs
+	stats         BotPoolStats
+	stopped       bool
+	done          sync.WaitGroup
+}
+
+// conversationJobs are the messages of one conversation waiting for the bot
+type conversationJobs struct {
+	queue   []*botJob
+	running *botJob
+	// Ready or being worked on
+	scheduled bool
+}
+
+// botJob is a message waiting to be answered
+type botJob struct {
+	ctx      context.Context
+	cancel   context.CancelFunc
+	message  domain.Message
+	enqueued time.Time
+}
+
+var _ ports.BotService = (*BotWorkerPool)(nil)
+var _ ports.BotQueue = (*BotWorkerPool)(nil)
+
+// NewBotWorkerPool starts workers answering messages with bot, queueing at
+// most queueSize messages
+func NewBotWorkerPool(bot ports.BotService, workers, queueSize int) *BotWorkerPool {
+	if workers < 1 {
+		workers = DefaultBotWorkers
+	}
+	if queueSize < 1 {
+		queueSize = DefaultBotQueueSize
+	}
+
+	p := &BotWorkerPool{
+		bot:            bot,
+		workers:        workers,
+		capacity:       queueSize,
+		enqueueTimeout: DefaultBotEnqueueTimeout,
+		slots:          make(chan struct{}, queueSize),
+		conversations:  make(map[string]*conversationJobs),
+	}
+	p.wake = sync.NewCond(&p.mutex)
+	p.done.Add(workers)
+	for i := 0; i < workers; i++ {
+		go p.work()
+	}
+	return p
+}
+
+// SetEnqueueTimeout sets how long ProcessMessage waits for room in a full
+// queue before giving up
+func (p *BotWorkerPool) SetEnqueueTimeout(timeout time.Duration) {
+	p.enqueueTimeout = timeout
+}
+
+// ProcessMessage queues the message behind the earlier ones of its
+// conversation. The bot answers it under ctx, which is also cancelled when
+// the conversation is. When the queue stays full for the enqueue timeout the
+// message is dropped with domain.ErrBotBusy.
+func (p *BotWorkerPool) ProcessMessage(ctx context.Context, message *domain.Message) error {
+	timer := time.NewTimer(p.enqueueTimeout)
+	defer timer.Stop()
+
+	select {
+	case p.slots <- struct{}{}:
+	case <-timer.C:
+		p.reject()
+		return domain.ErrBotBusy
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	jobCtx, cancel := context.WithCancel(ctx)
+	job := &botJob{ctx: jobCtx, cancel: cancel, message: *message, enqueued: time.Now()}
+	key := conversationKey(message)
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.stopped {
+		cancel()
+		<-p.slots
+		return domain.ErrBotBusy
+	}
+
+	jobs, ok := p.conversations[key]
+	if !ok {
+		jobs = &conversationJobs{}
+		p.conversations[key] = jobs
+	}
+	jobs.queue = append(jobs.queue, job)
+	if !jobs.scheduled {
+		jobs.scheduled = true
+		p.schedule(key)
+	}
+	return nil
+}
+
+// CancelConversation drops the messages of a conversation still waiting and
+// cancels the one being answered
+func (p *BotWorkerPool) CancelConversation(conversationID string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	jobs, ok := p.conversations[conversationID]
+	if !ok {
+		return
+	}
+	for _, job := range jobs.queue {
+		job.cancel()
+		p.stats.Cancelled++
+		<-p.slots
+	}
+	jobs.queue = nil
+	if jobs.running != nil {
+		jobs.running.cancel()
+	}
+}
+
+// Stats returns the pool's queue depth and counters
+func (p *BotWorkerPool) Stats() BotPoolStats {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	stats := p.stats
+	stats.Workers = p.workers
+	stats.Capacity = p.capacity
+	stats.Conversations = len(p.conversations)
+	var oldest time.Time
+	for _, jobs := range p.conversations {
+		stats.Queued += len(jobs.queue)
+		if jobs.running != nil {
+			stats.Busy++
+		}
+		if len(jobs.queue) > 0 && (oldest.IsZero() || jobs.queue[0].enqueued.Before(oldest)) {
+			oldest = jobs.queue[0].enqueued
+		}
+	}
+	if !oldest.IsZero() {
+		stats.OldestWaitMillis = time.Since(oldest).Milliseconds()
+	}
+	return stats
+}
+
+// Stop cancels every queued message and waits for the workers to finish the
+// ones they are answering
+func (p *BotWorkerPool) Stop() {
+	p.mutex.Lock()
+	if p.stopped {
+		p.mutex.Unlock()
+		return
+	}
+	p.stopped = true
+	for key := range p.conversations {
+		jobs := p.conversations[key]
+		for _, job := range jobs.queue {
+			job.cancel()
+			p.stats.Cancelled++
+			<-p.slots
+		}
+		jobs.queue = nil
+	}
+	p.wake.Broadcast()
+	p.mutex.Unlock()
+
+	p.done.Wait()
+}
+
+// schedule hands a conversation to the next free worker. The lock must be
+// held.
+func (p *BotWorkerPool) schedule(key string) {
+	p.ready = append(p.ready, key)
+	p.wake.Signal()
+}
+
+// work answers the next message of each ready conversation until the pool
+// stops
+func (p *BotWorkerPool) work() {
+	defer p.done.Done()
+	for {
+		p.mutex.Lock()
+		for len(p.ready) == 0 && !p.stopped {
+			p.wake.Wait()
+		}
+		if p.stopped {
+			p.mutex.Unlock()
+			return
+		}
+		key := p.ready[0]
+		p.ready = p.ready[1:]
+		p.mutex.Unlock()
+
+		p.runNext(key)
+	}
+}
+
+// runNext answers the oldest message of a conversation, then puts the
+// conversation back at the end of the line if it has more
+func (p *BotWorkerPool) runNext(key string) {
+	p.mutex.Lock()
+	jobs := p.conversations[key]
+	if jobs == nil || len(jobs.queue) == 0 {
+		// Cancelled while it waited
+		p.finish(key, jobs)
+		p.mutex.Unlock()
+		return
+	}
+	job := jobs.queue[0]
+	jobs.queue = jobs.queue[1:]
+	jobs.running = job
+	p.mutex.Unlock()
+
+	err := job.ctx.Err()
+	if err == nil {
+		err = p.bot.ProcessMessage(job.ctx, &job.message)
+	}
+	cancelled := job.ctx.Err() != nil
+	job.cancel()
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	jobs.running = nil
+	switch {
+	case cancelled && err != nil:
+		p.stats.Cancelled++
+	case err != nil:
+		p.stats.Failed++
+		log.Printf("Error processing message %s with bot: %v", job.message.ID, err)
+	default:
+		p.stats.Processed++
+	}
+	<-p.slots
+	p.finish(key, jobs)
+}
+
+// finish reschedules a conversation with messages left or forgets it. The
+// lock must be held.
+func (p *BotWorkerPool) finish(key string, jobs *conversationJobs) {
+	if jobs == nil {
+		return
+	}
+	if len(jobs.queue) > 0 && !p.stopped {
+		p.schedule(key)
+		return
+	}
+	jobs.scheduled = false
+	if len(jobs.queue) == 0 && jobs.running == nil {
+		delete(p.conversations, key)
+	}
+}
+
+// reject counts a message turned away by a full queue
+func (p *BotWorkerPool) reject() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.stats.Rejected++
+}
+
+// conversationKey is the conversation a message belongs to. Messages that
+// don't name one are ordered per customer instead.
+func conversationKey(message *domain.Message) string {
+	if id := message.Metadata["conversation_id"]; id != "" {
+		return id
+	}
+	return "customer:" + message.CustomerID
+}
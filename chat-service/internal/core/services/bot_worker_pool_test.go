@@ -0,0 +1,174 @@
+package services_test
+
+import (
+	"chat-service/internal/core/domain"
+	"chat-service/internal/core/services"
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// gatedBot answers a message only once its gate is opened, recording the
+// order messages were answered in per conversation
+type gatedBot struct {
+	mutex    sync.Mutex
+	gates    map[string]chan struct{}
+	answered map[string][]string
+	started  chan string
+}
+
+func newGatedBot() *gatedBot {
+	return &gatedBot{
+		gates:    make(map[string]chan struct{}),
+		answered: make(map[string][]string),
+		started:  make(chan string, 100),
+	}
+}
+
+func (b *gatedBot) gate(content string) chan struct{} {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	gate, ok := b.gates[content]
+	if !ok {
+		gate = make(chan struct{})
+		b.gates[content] = gate
+	}
+	return gate
+}
+
+func (b *gatedBot) ProcessMessage(ctx context.Context, message *domain.Message) error {
+	b.started <- message.Content
+	select {
+	case <-b.gate(message.Content):
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	conversationID := message.Metadata["conversation_id"]
+	b.answered[conversationID] = append(b.answered[conversationID], message.Content)
+	return nil
+}
+
+func (b *gatedBot) order(conversationID string) []string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return append([]string(nil), b.answered[conversationID]...)
+}
+
+func poolMessage(conversationID, content string) *domain.Message {
+	return &domain.Message{
+		ID:         content,
+		Content:    content,
+		CustomerID: "customer-" + conversationID,
+		Type:       domain.UserMessage,
+		Metadata:   map[string]string{"conversation_id": conversationID},
+	}
+}
+
+func expectStarted(t *testing.T, bot *gatedBot, content string) {
+	t.Helper()
+	select {
+	case started := <-bot.started:
+		require.Equal(t, content, started)
+	case <-time.After(time.Second):
+		t.Fatalf("%s was never answered", content)
+	}
+}
+
+func TestBotWorkerPool(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("answers each conversation in order and conversations in parallel", func(t *testing.T) {
+		bot := newGatedBot()
+		pool := services.NewBotWorkerPool(bot, 4, 16)
+		defer pool.Stop()
+
+		for i := 0; i < 3; i++ {
+			require.NoError(t, pool.ProcessMessage(ctx, poolMessage("a", fmt.Sprintf("a-%d", i))))
+		}
+		expectStarted(t, bot, "a-0")
+
+		// The next message of the conversation waits for the first one while
+		// another conversation goes ahead
+		require.NoError(t, pool.ProcessMessage(ctx, poolMessage("b", "b-0")))
+		expectStarted(t, bot, "b-0")
+		close(bot.gate("b-0"))
+
+		stats := pool.Stats()
+		assert.Equal(t, 2, stats.Queued)
+		assert.Equal(t, 4, stats.Workers)
+		assert.Equal(t, 16, stats.Capacity)
+
+		for i := 0; i < 3; i++ {
+			content := fmt.Sprintf("a-%d", i)
+			if i > 0 {
+				expectStarted(t, bot, content)
+			}
+			close(bot.gate(content))
+		}
+		assert.Eventually(t, func() bool {
+			return pool.Stats().Processed == 4
+		}, time.Second, 5*time.Millisecond)
+		assert.Equal(t, []string{"a-0", "a-1", "a-2"}, bot.order("a"))
+		assert.Equal(t, []string{"b-0"}, bot.order("b"))
+		assert.Zero(t, pool.Stats().Conversations)
+	})
+
+	t.Run("turns messages away once the queue stays full", func(t *testing.T) {
+		bot := newGatedBot()
+		pool := services.NewBotWorkerPool(bot, 1, 2)
+		pool.SetEnqueueTimeout(20 * time.Millisecond)
+		defer pool.Stop()
+
+		require.NoError(t, pool.ProcessMessage(ctx, poolMessage("a", "a-0")))
+		expectStarted(t, bot, "a-0")
+		require.NoError(t, pool.ProcessMessage(ctx, poolMessage("b", "b-0")))
+
+		err := pool.ProcessMessage(ctx, poolMessage("c", "c-0"))
+		assert.ErrorIs(t, err, domain.ErrBotBusy)
+
+		stats := pool.Stats()
+		assert.Equal(t, 1, stats.Busy)
+		assert.Equal(t, 1, stats.Queued)
+		assert.Equal(t, uint64(1), stats.Rejected)
+
+		// Room frees up as soon as a message is answered
+		close(bot.gate("a-0"))
+		expectStarted(t, bot, "b-0")
+		require.NoError(t, pool.ProcessMessage(ctx, poolMessage("c", "c-0")))
+		close(bot.gate("b-0"))
+		expectStarted(t, bot, "c-0")
+		close(bot.gate("c-0"))
+	})
+
+	t.Run("cancels the work of a closed conversation", func(t *testing.T) {
+		bot := newGatedBot()
+		pool := services.NewBotWorkerPool(bot, 2, 8)
+		defer pool.Stop()
+
+		require.NoError(t, pool.ProcessMessage(ctx, poolMessage("a", "a-0")))
+		expectStarted(t, bot, "a-0")
+		require.NoError(t, pool.ProcessMessage(ctx, poolMessage("a", "a-1")))
+		require.NoError(t, pool.ProcessMessage(ctx, poolMessage("a", "a-2")))
+
+		pool.CancelConversation("a")
+		assert.Eventually(t, func() bool {
+			stats := pool.Stats()
+			return stats.Cancelled == 3 && stats.Conversations == 0
+		}, time.Second, 5*time.Millisecond)
+		assert.Empty(t, bot.order("a"))
+		assert.Zero(t, pool.Stats().Processed)
+
+		// The conversation's room in the queue is given back
+		require.NoError(t, pool.ProcessMessage(ctx, poolMessage("b", "b-0")))
+		expectStarted(t, bot, "b-0")
+		close(bot.gate("b-0"))
+	})
+}
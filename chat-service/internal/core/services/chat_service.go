@@ -5,6 +5,8 @@ import (
 	"chat-service/internal/core/domain"
 	"chat-service/internal/core/ports"
 	"context"
+	"fmt"
+	"log"
 	"time"
 
 	"github.com/google/uuid"
@@ -14,21 +16,71 @@ type ChatServiceImpl struct {
 	messageRepo      ports.MessageRepository
 	conversationRepo ports.ConversationRepository
 	messagePublisher ports.MessagePublisher
+	botQueue         ports.BotQueue
+	events           ports.ConversationEventPublisher
+	attachments      ports.AttachmentLinks
+	// How long after closing a conversation is resumed instead of replaced
+	reopenWindow time.Duration
+	// How long the attachment links of messages read or sent are valid
+	attachmentLinkTTL time.Duration
+	// How long after sending a message customers may still change it
+	editWindow time.Duration
+	// Who the senders of exported transcripts are, and how long the
+	// attachment links in them are valid
+	directory         ports.ParticipantDirectory
+	botNames          map[string]string
+	transcriptLinkTTL time.Duration
 }
 
 func NewChatService(
 	messageRepo ports.MessageRepository,
 	conversationRepo ports.ConversationRepository,
 	messagePublisher ports.MessagePublisher,
-) ports.ChatService {
+) *ChatServiceImpl {
 	return &ChatServiceImpl{
 		messageRepo:      messageRepo,
 		conversationRepo: conversationRepo,
 		messagePublisher: messagePublisher,
+		editWindow:       DefaultEditWindow,
 	}
 }
 
+// SetBotQueue has closing a conversation cancel the bot work queued for it
+func (s *ChatServiceImpl) SetBotQueue(queue ports.BotQueue) {
+	s.botQueue = queue
+}
+
+// SetEventPublisher announces conversations closing and reopening
+func (s *ChatServiceImpl) SetEventPublisher(events ports.ConversationEventPublisher) {
+	s.events = events
+}
+
+// SetReopenWindow sets how long after closing a conversation a returning
+// customer resumes it; after that they start a new one
+func (s *ChatServiceImpl) SetReopenWindow(window time.Duration) {
+	s.reopenWindow = window
+}
+
+// SetAttachments lets customers attach uploads to their messages, and signs
+// the attachments' links for validFor whenever messages are sent or read
+func (s *ChatServiceImpl) SetAttachments(attachments ports.AttachmentLinks, validFor time.Duration) {
+	s.attachments = attachments
+	s.attachmentLinkTTL = validFor
+}
+
 func (s *ChatServiceImpl) SaveMessage(message *domain.Message) error {
+	// A customer writing after their conversation closed resumes it or
+	// starts a new one
+	if message.Type == domain.UserMessage {
+		conversation, err := s.CreateConversation(message.CustomerID)
+		if err != nil {
+			return err
+		}
+		if id, ok := message.Metadata["conversation_id"]; ok && id != conversation.ID {
+			message.Metadata["conversation_id"] = conversation.ID
+		}
+	}
+
 	// Generate ID if not provided
 	if message.ID == "" {
 		message.ID = uuid.New().String()
@@ -40,11 +92,21 @@ func (s *ChatServiceImpl) SaveMessage(message *domain.Message) error {
 	}
 	ctx := context.Background()
 
+	if len(message.Attachments) > 0 {
+		if s.attachments == nil {
+			return fmt.Errorf("%w: attachments are not enabled", domain.ErrInvalidAttachment)
+		}
+		if err := s.attachments.Resolve(ctx, message); err != nil {
+			return err
+		}
+	}
+
 	// Save to repository
 	err := s.messageRepo.SaveMessage(ctx, message)
 	if err != nil {
 		return err
 	}
+	s.signAttachments([]domain.Message{*message})
 
 	// Publish to message broker
 	return s.messagePublisher.PublishChatMessage(message)
@@ -52,7 +114,41 @@ func (s *ChatServiceImpl) SaveMessage(message *domain.Message) error {
 
 func (s *ChatServiceImpl) GetChatHistory(customerID string) ([]domain.Message, error) {
 	ctx := context.Background()
-	return s.messageRepo.GetMessagesByCustomer(ctx, customerID)
+	messages, err := s.messageRepo.GetMessagesByCustomer(ctx, customerID)
+	if err != nil {
+		return nil, err
+	}
+	s.signAttachments(messages)
+	return messages, nil
+}
+
+// GetMessages reads a page of a conversation's messages
+func (s *ChatServiceImpl) GetMessages(query domain.MessageQuery) (*domain.MessagePage, error) {
+	if query.Before != "" && query.After != "" {
+		return nil, fmt.Errorf("%w: only one of before and after may be set", domain.ErrInvalidCursor)
+	}
+	switch {
+	case query.Limit <= 0:
+		query.Limit = domain.DefaultMessagePageSize
+	case query.Limit > domain.MaxMessagePageSize:
+		query.Limit = domain.MaxMessagePageSize
+	}
+
+	ctx := context.Background()
+	page, err := s.messageRepo.GetMessagePage(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	s.signAttachments(page.Messages)
+	return page, nil
+}
+
+// signAttachments sets fresh download links on the messages' attachments.
+// The messages share their attachments with the slice signed.
+func (s *ChatServiceImpl) signAttachments(messages []domain.Message) {
+	if s.attachments != nil {
+		s.attachments.Sign(messages, s.attachmentLinkTTL)
+	}
 }
 
 func (s *ChatServiceImpl) GetConversation(conversationID string) (*domain.Conversation, error) {
@@ -66,6 +162,9 @@ func (s *ChatServiceImpl) CreateConversation(customerID string) (*domain.Convers
 	if err == nil && existing != nil {
 		return existing, nil
 	}
+	if conversation := s.reopenRecent(ctx, customerID); conversation != nil {
+		return conversation, nil
+	}
 
 	// Create new conversation
 	conversation := &domain.Conversation{
@@ -89,11 +188,108 @@ func (s *ChatServiceImpl) CloseConversation(conversationID string) error {
 	if err != nil {
 		return err
 	}
+	return s.closeConversation(ctx, conversation, domain.CloseReasonManual)
+}
 
+// closeConversation ends a conversation, stops the bot work queued for it
+// and announces it closed
+func (s *ChatServiceImpl) closeConversation(ctx context.Context, conversation *domain.Conversation, reason string) error {
 	conversation.Status = "closed"
 	conversation.EndedAt = time.Now()
 
-	return s.conversationRepo.UpdateConversation(ctx, conversation)
+	if err := s.conversationRepo.UpdateConversation(ctx, conversation); err != nil {
+		return err
+	}
+	s.conversationClosed(conversation, reason)
+	return nil
+}
+
+// conversationClosed stops the bot work queued for a closed conversation
+// and announces it closed
+func (s *ChatServiceImpl) conversationClosed(conversation *domain.Conversation, reason string) {
+	if s.botQueue != nil {
+		s.botQueue.CancelConversation(conversation.ID)
+	}
+	s.publishEvent(domain.ConversationClosedEvent, conversation, reason)
+}
+
+// reopenRecent resumes the customer's latest conversation if it closed
+// within the reopen window, however it was closed
+func (s *ChatServiceImpl) reopenRecent(ctx context.Context, customerID string) *domain.Conversation {
+	if s.reopenWindow <= 0 {
+		return nil
+	}
+	conversations, err := s.conversationRepo.ListConversationsByCustomer(ctx, customerID)
+	if err != nil {
+		log.Printf("Error listing conversations of customer %s: %v", customerID, err)
+		return nil
+	}
+	if len(conversations) == 0 {
+		return nil
+	}
+
+	latest := conversations[0]
+	if latest.Status != "closed" || time.Since(latest.EndedAt) > s.reopenWindow {
+		return nil
+	}
+	latest.Status = "active"
+	latest.EndedAt = time.Time{}
+	if err := s.conversationRepo.UpdateConversation(ctx, &latest); err != nil {
+		log.Printf("Error reopening conversation %s: %v", latest.ID, err)
+		return nil
+	}
+	// A reopened conversation may be handed over again
+	if _, err := s.conversationRepo.MarkEscalated(ctx, latest.ID, false); err != nil {
+		log.Printf("Error clearing escalation of conversation %s: %v", latest.ID, err)
+	}
+	s.publishEvent(domain.ConversationReopenedEvent, &latest, "")
+	return &latest
+}
+
+// publishEvent announces a lifecycle change of a conversation
+func (s *ChatServiceImpl) publishEvent(eventType string, conversation *domain.Conversation, reason string) {
+	if s.events == nil {
+		return
+	}
+	err := s.events.PublishConversationEvent(&domain.ConversationEvent{
+		Type:           eventType,
+		ConversationID: conversation.ID,
+		CustomerID:     conversation.CustomerID,
+		Reason:         reason,
+		OccurredAt:     time.Now(),
+	})
+	if err != nil {
+		log.Printf("Error publishing %s for conversation %s: %v", eventType, conversation.ID, err)
+	}
+}
+
+// ListConversations returns a customer's conversations, latest first
+func (s *ChatServiceImpl) ListConversations(customerID string) ([]domain.Conversation, error) {
+	ctx := context.Background()
+	return s.conversationRepo.ListConversationsByCustomer(ctx, customerID)
+}
+
+// UpdateDeliveryStatus records that a user received or read messages of a
+// conversation
+func (s *ChatServiceImpl) UpdateDeliveryStatus(receipt *domain.Receipt) ([]string, error) {
+	if receipt.Status != domain.DeliveryDelivered && receipt.Status != domain.DeliveryRead {
+		return nil, fmt.Errorf("%w: status must be %s or %s", domain.ErrInvalidReceipt, domain.DeliveryDelivered, domain.DeliveryRead)
+	}
+	if len(receipt.MessageIDs) == 0 {
+		return nil, fmt.Errorf("%w: no message IDs", domain.ErrInvalidReceipt)
+	}
+	if receipt.Timestamp.IsZero() {
+		receipt.Timestamp = time.Now()
+	}
+
+	ctx := context.Background()
+	return s.messageRepo.UpdateDeliveryStatus(ctx, receipt)
+}
+
+// GetUnreadCount counts the messages of a conversation the user has not read
+func (s *ChatServiceImpl) GetUnreadCount(conversationID, userID string) (int, error) {
+	ctx := context.Background()
+	return s.messageRepo.CountUnread(ctx, conversationID, userID)
 }
 
 // Add this method to your ChatService struct
@@ -33,6 +33,66 @@ func (m *MockMessageRepo) GetMessagesByConversation(ctx context.Context, convers
 	return args.Get(0).([]domain.Message), args.Error(1)
 }
 
+func (m *MockMessageRepo) GetMessagePage(ctx context.Context, query domain.MessageQuery) (*domain.MessagePage, error) {
+	args := m.Called(ctx, query)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.MessagePage), args.Error(1)
+}
+
+func (m *MockMessageRepo) UpdateDeliveryStatus(ctx context.Context, receipt *domain.Receipt) ([]string, error) {
+	args := m.Called(ctx, receipt)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *MockMessageRepo) CountUnread(ctx context.Context, conversationID, userID string) (int, error) {
+	args := m.Called(ctx, conversationID, userID)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockMessageRepo) GetMessage(ctx context.Context, id string) (*domain.Message, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.Message), args.Error(1)
+}
+
+func (m *MockMessageRepo) ReviseMessage(ctx context.Context, message *domain.Message, revision *domain.MessageRevision) error {
+	args := m.Called(ctx, message, revision)
+	return args.Error(0)
+}
+
+func (m *MockMessageRepo) SearchMessages(ctx context.Context, search domain.MessageSearch) (*domain.MessageSearchResults, error) {
+	args := m.Called(ctx, search)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.MessageSearchResults), args.Error(1)
+}
+
+func (m *MockMessageRepo) StreamMessages(ctx context.Context, conversationID string, fn func(*domain.Message) error) error {
+	args := m.Called(ctx, conversationID)
+	for _, message := range args.Get(0).([]domain.Message) {
+		if err := fn(&message); err != nil {
+			return err
+		}
+	}
+	return args.Error(1)
+}
+
+func (m *MockMessageRepo) GetMessageRevisions(ctx context.Context, messageID string) ([]domain.MessageRevision, error) {
+	args := m.Called(ctx, messageID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]domain.MessageRevision), args.Error(1)
+}
+
 type MockConversationRepo struct {
 	mock.Mock
 }
@@ -58,11 +118,45 @@ func (m *MockConversationRepo) GetActiveConversationByCustomer(ctx context.Conte
 	return args.Get(0).(*domain.Conversation), args.Error(1)
 }
 
+func (m *MockConversationRepo) ListConversationsByCustomer(ctx context.Context, customerID string) ([]domain.Conversation, error) {
+	args := m.Called(ctx, customerID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]domain.Conversation), args.Error(1)
+}
+
+func (m *MockConversationRepo) ListIdleConversations(ctx context.Context, idleSince time.Time, limit int) ([]domain.Conversation, error) {
+	args := m.Called(ctx, idleSince, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]domain.Conversation), args.Error(1)
+}
+
+func (m *MockConversationRepo) CloseIdleConversation(ctx context.Context, conversationID string, idleSince time.Time, notice *domain.Message) (bool, error) {
+	args := m.Called(ctx, conversationID, idleSince, notice)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockConversationRepo) FindConversations(ctx context.Context, filter domain.ConversationFilter) ([]domain.Conversation, error) {
+	args := m.Called(ctx, filter)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]domain.Conversation), args.Error(1)
+}
+
 func (m *MockConversationRepo) UpdateConversation(ctx context.Context, conversation *domain.Conversation) error {
 	args := m.Called(ctx, conversation)
 	return args.Error(0)
 }
 
+func (m *MockConversationRepo) MarkEscalated(ctx context.Context, conversationID string, escalated bool) (bool, error) {
+	args := m.Called(ctx, conversationID, escalated)
+	return args.Bool(0), args.Error(1)
+}
+
 type MockMessagePublisher struct {
 	mock.Mock
 }
@@ -88,6 +182,13 @@ func TestSaveMessage(t *testing.T) {
 
 	service := services.NewChatService(messageRepo, conversationRepo, publisher)
 
+	// Customer messages go to the customer's open conversation
+	conversationRepo.On("GetActiveConversationByCustomer", mock.Anything, "customer456").Return(&domain.Conversation{
+		ID:         "conv123",
+		CustomerID: "customer456",
+		Status:     "active",
+	}, nil)
+
 	t.Run("success", func(t *testing.T) {
 		message := &domain.Message{
 			Content:    "Hello",
@@ -267,6 +368,15 @@ func TestCreateConversation(t *testing.T) {
 	})
 }
 
+// recordingBotQueue records the conversations whose bot work was cancelled
+type recordingBotQueue struct {
+	cancelled []string
+}
+
+func (q *recordingBotQueue) CancelConversation(conversationID string) {
+	q.cancelled = append(q.cancelled, conversationID)
+}
+
 func TestCloseConversation(t *testing.T) {
 	messageRepo := new(MockMessageRepo)
 	conversationRepo := new(MockConversationRepo)
@@ -296,6 +406,20 @@ func TestCloseConversation(t *testing.T) {
 		conversationRepo.AssertExpectations(t)
 	})
 
+	t.Run("cancels queued bot work", func(t *testing.T) {
+		conversationID := "conv789"
+		conversation := &domain.Conversation{ID: conversationID, CustomerID: "customer456", Status: "active"}
+		conversationRepo.On("GetConversation", mock.Anything, conversationID).Return(conversation, nil).Once()
+		conversationRepo.On("UpdateConversation", mock.Anything, mock.Anything).Return(nil).Once()
+
+		queue := &recordingBotQueue{}
+		service.SetBotQueue(queue)
+		defer service.SetBotQueue(nil)
+
+		assert.NoError(t, service.CloseConversation(conversationID))
+		assert.Equal(t, []string{conversationID}, queue.cancelled)
+	})
+
 	t.Run("conversation not found", func(t *testing.T) {
 		conversationID := "conv123"
 
@@ -333,3 +457,82 @@ func TestCloseConversation(t *testing.T) {
 		conversationRepo.AssertExpectations(t)
 	})
 }
+
+func TestUpdateDeliveryStatus(t *testing.T) {
+	messageRepo := new(MockMessageRepo)
+	conversationRepo := new(MockConversationRepo)
+	publisher := new(MockMessagePublisher)
+
+	service := services.NewChatService(messageRepo, conversationRepo, publisher)
+
+	t.Run("success", func(t *testing.T) {
+		receipt := &domain.Receipt{
+			ConversationID: "conv123",
+			MessageIDs:     []string{"msg1", "msg2"},
+			Status:         domain.DeliveryRead,
+			UserID:         "user123",
+		}
+
+		messageRepo.On("UpdateDeliveryStatus", mock.Anything, receipt).Return([]string{"msg2"}, nil).Once()
+
+		updated, err := service.UpdateDeliveryStatus(receipt)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"msg2"}, updated)
+		assert.False(t, receipt.Timestamp.IsZero())
+
+		messageRepo.AssertExpectations(t)
+	})
+
+	t.Run("invalid receipts", func(t *testing.T) {
+		messageRepo := new(MockMessageRepo)
+		service := services.NewChatService(messageRepo, conversationRepo, publisher)
+
+		for _, receipt := range []*domain.Receipt{
+			{ConversationID: "conv123", MessageIDs: []string{"msg1"}, Status: domain.DeliverySent},
+			{ConversationID: "conv123", Status: domain.DeliveryRead},
+		} {
+			_, err := service.UpdateDeliveryStatus(receipt)
+			assert.ErrorIs(t, err, domain.ErrInvalidReceipt)
+		}
+
+		messageRepo.AssertNotCalled(t, "UpdateDeliveryStatus", mock.Anything, mock.Anything)
+	})
+}
+
+func TestGetMessages(t *testing.T) {
+	messageRepo := new(MockMessageRepo)
+	conversationRepo := new(MockConversationRepo)
+	publisher := new(MockMessagePublisher)
+
+	service := services.NewChatService(messageRepo, conversationRepo, publisher)
+
+	t.Run("limits are clamped", func(t *testing.T) {
+		for requested, expected := range map[int]int{
+			0:    domain.DefaultMessagePageSize,
+			-5:   domain.DefaultMessagePageSize,
+			20:   20,
+			1000: domain.MaxMessagePageSize,
+		} {
+			page := &domain.MessagePage{Messages: []domain.Message{}}
+			messageRepo.On("GetMessagePage", mock.Anything, domain.MessageQuery{
+				ConversationID: "conv123",
+				Before:         "msg9",
+				Limit:          expected,
+			}).Return(page, nil).Once()
+
+			result, err := service.GetMessages(domain.MessageQuery{ConversationID: "conv123", Before: "msg9", Limit: requested})
+
+			assert.NoError(t, err)
+			assert.Same(t, page, result)
+		}
+
+		messageRepo.AssertExpectations(t)
+	})
+
+	t.Run("one cursor at a time", func(t *testing.T) {
+		_, err := service.GetMessages(domain.MessageQuery{ConversationID: "conv123", Before: "msg9", After: "msg1"})
+
+		assert.ErrorIs(t, err, domain.ErrInvalidCursor)
+	})
+}
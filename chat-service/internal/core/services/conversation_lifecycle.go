@@ -0,0 +1,154 @@
+package services
+
+import (
+	"chat-service/internal/core/domain"
+	"chat-service/internal/core/ports"
+	"context"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Conversation lifecycle defaults used when the lifecycle is created
+const (
+	DefaultIdleTimeout       = 30 * time.Minute
+	DefaultIdleCheckInterval = time.Minute
+)
+
+// How many idle conversations are closed per check at most
+const idleBatchSize = 100
+
+// idleClosingMessage tells the customer why their conversation ended
+const idleClosingMessage = "This conversation was closed because it has been quiet for a while. Send a message anytime to pick it up again."
+
+// closingNamespace derives the IDs of closing notices
+var closingNamespace = uuid.NewSHA1(uuid.NameSpaceOID, []byte("chat-service/conversation-closed"))
+
+// ConversationLifecycle closes conversations nobody has written in for the
+// idle timeout, storing a notice telling the customer as it closes them.
+// Every replica may run it: a conversation is only closed if it is still
+// open and idle, so only one of them closes it and sends the notice.
+type ConversationLifecycle struct {
+	chat          *ChatServiceImpl
+	hub           ports.MessageHub
+	idleTimeout   time.Duration
+	checkInterval time.Duration
+}
+
+// NewConversationLifecycle closes the conversations of chat idle for
+// idleTimeout
+func NewConversationLifecycle(chat *ChatServiceImpl, idleTimeout time.Duration) *ConversationLifecycle {
+	if idleTimeout <= 0 {
+		idleTimeout = DefaultIdleTimeout
+	}
+	return &ConversationLifecycle{
+		chat:          chat,
+		idleTimeout:   idleTimeout,
+		checkInterval: DefaultIdleCheckInterval,
+	}
+}
+
+// SetHub delivers closing notices to the customer's connections
+func (l *ConversationLifecycle) SetHub(hub ports.MessageHub) {
+	l.hub = hub
+}
+
+// SetCheckInterval sets how often idle conversations are looked for
+func (l *ConversationLifecycle) SetCheckInterval(interval time.Duration) {
+	l.checkInterval = interval
+}
+
+// Run closes idle conversations every check interval until ctx is done
+func (l *ConversationLifecycle) Run(ctx context.Context) {
+	ticker := time.NewTicker(l.checkInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if _, err := l.CloseIdle(ctx, now); err != nil {
+				log.Printf("Error closing idle conversations: %v", err)
+			}
+		}
+	}
+}
+
+// CloseIdle closes the conversations idle at now and returns how many it
+// closed
+func (l *ConversationLifecycle) CloseIdle(ctx context.Context, now time.Time) (int, error) {
+	conversations, err := l.chat.conversationRepo.ListIdleConversations(ctx, now.Add(-l.idleTimeout), idleBatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	closed := 0
+	for i := range conversations {
+		ok, err := l.closeIdle(ctx, &conversations[i], now.Add(-l.idleTimeout))
+		if err != nil {
+			log.Printf("Not closing idle conversation %s: %v", conversations[i].ID, err)
+			continue
+		}
+		if ok {
+			closed++
+		}
+	}
+	return closed, nil
+}
+
+// closeIdle stores the closing notice and closes the conversation together
+// if nobody wrote in it since idleSince, then sends the notice. It reports
+// false when the conversation was written in or closed since it was listed.
+func (l *ConversationLifecycle) closeIdle(ctx context.Context, conversation *domain.Conversation, idleSince time.Time) (bool, error) {
+	notice, err := l.closingNotice(ctx, conversation)
+	if err != nil {
+		return false, err
+	}
+
+	closed, err := l.chat.conversationRepo.CloseIdleConversation(ctx, conversation.ID, idleSince, notice)
+	if err != nil || !closed {
+		return false, err
+	}
+	conversation.Status = "closed"
+	conversation.EndedAt = notice.Timestamp
+
+	if err := l.chat.messagePublisher.PublishChatMessage(notice); err != nil {
+		log.Printf("Error publishing closing notice of conversation %s: %v", conversation.ID, err)
+	}
+	if l.hub != nil {
+		l.hub.SendSystemMessage(notice)
+	}
+	l.chat.conversationClosed(conversation, domain.CloseReasonIdle)
+	return true, nil
+}
+
+// closingNotice builds the notice closing a conversation, identified by the
+// conversation and its latest message
+func (l *ConversationLifecycle) closingNotice(ctx context.Context, conversation *domain.Conversation) (*domain.Message, error) {
+	page, err := l.chat.messageRepo.GetMessagePage(ctx, domain.MessageQuery{
+		ConversationID: conversation.ID,
+		Limit:          1,
+	})
+	if err != nil {
+		return nil, err
+	}
+	latest := ""
+	if len(page.Messages) > 0 {
+		latest = page.Messages[len(page.Messages)-1].ID
+	}
+
+	return &domain.Message{
+		ID:         uuid.NewSHA1(closingNamespace, []byte(conversation.ID+"/"+latest)).String(),
+		Content:    idleClosingMessage,
+		UserID:     "system",
+		CustomerID: conversation.CustomerID,
+		Type:       domain.SystemMessage,
+		Timestamp:  time.Now(),
+		Metadata: map[string]string{
+			"conversation_id": conversation.ID,
+			"close_reason":    domain.CloseReasonIdle,
+		},
+	}, nil
+}
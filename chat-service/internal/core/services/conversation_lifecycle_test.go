@@ -0,0 +1,226 @@
+package services_test
+
+import (
+	"chat-service/internal/core/domain"
+	"chat-service/internal/core/ports"
+	"chat-service/internal/core/services"
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingEvents captures the conversation events published
+type recordingEvents struct {
+	mutex  sync.Mutex
+	events []domain.ConversationEvent
+}
+
+func (e *recordingEvents) PublishConversationEvent(event *domain.ConversationEvent) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.events = append(e.events, *event)
+	return nil
+}
+
+// racingConversations has another replica close every idle conversation as
+// soon as it is listed
+type racingConversations struct {
+	*memoryConversations
+}
+
+func (r racingConversations) ListIdleConversations(ctx context.Context, idleSince time.Time, limit int) ([]domain.Conversation, error) {
+	conversations, err := r.memoryConversations.ListIdleConversations(ctx, idleSince, limit)
+	for _, conversation := range conversations {
+		r.CloseIdleConversation(ctx, conversation.ID, idleSince, &domain.Message{Timestamp: time.Now()})
+	}
+	return conversations, err
+}
+
+// activeOnlyMessages stores messages the way the Postgres repository does,
+// in the customer's active conversation, refusing them when there is none
+type activeOnlyMessages struct {
+	*MockMessageRepo
+	conversations *memoryConversations
+	saved         []domain.Message
+}
+
+func (r *activeOnlyMessages) SaveMessage(ctx context.Context, message *domain.Message) error {
+	if _, err := r.conversations.GetActiveConversationByCustomer(ctx, message.CustomerID); err != nil {
+		return err
+	}
+	r.saved = append(r.saved, *message)
+	return nil
+}
+
+func newLifecycleChat(conversations ports.ConversationRepository, repo ports.MessageRepository) (*services.ChatServiceImpl, *recordingEvents) {
+	publisher := new(MockMessagePublisher)
+	publisher.On("PublishChatMessage", mock.Anything).Return(nil)
+
+	chat := services.NewChatService(repo, conversations, publisher)
+	events := &recordingEvents{}
+	chat.SetEventPublisher(events)
+	return chat, events
+}
+
+func TestReopenWindow(t *testing.T) {
+	closedAgo := func(ago time.Duration) *memoryConversations {
+		return newMemoryConversations(domain.Conversation{
+			ID:         "conv1",
+			CustomerID: "customer1",
+			StartedAt:  time.Now().Add(-2 * time.Hour),
+			EndedAt:    time.Now().Add(-ago),
+			Status:     "closed",
+			Mode:       domain.ConversationModeBot,
+		})
+	}
+
+	t.Run("a customer returning within the window resumes the conversation", func(t *testing.T) {
+		conversations := closedAgo(10 * time.Minute)
+		conversations.escalated["conv1"] = true
+		chat, events := newLifecycleChat(conversations, new(MockMessageRepo))
+		chat.SetReopenWindow(time.Hour)
+
+		conversation, err := chat.CreateConversation("customer1")
+		require.NoError(t, err)
+		assert.Equal(t, "conv1", conversation.ID)
+		assert.Equal(t, "active", conversation.Status)
+		assert.True(t, conversation.EndedAt.IsZero())
+
+		stored, _ := conversations.GetConversation(context.Background(), "conv1")
+		assert.Equal(t, "active", stored.Status)
+		// It can be handed over again
+		assert.False(t, conversations.escalated["conv1"])
+		require.Len(t, events.events, 1)
+		assert.Equal(t, domain.ConversationReopenedEvent, events.events[0].Type)
+	})
+
+	t.Run("a customer returning later starts a new conversation", func(t *testing.T) {
+		conversations := closedAgo(2 * time.Hour)
+		chat, events := newLifecycleChat(conversations, new(MockMessageRepo))
+		chat.SetReopenWindow(time.Hour)
+
+		conversation, err := chat.CreateConversation("customer1")
+		require.NoError(t, err)
+		assert.NotEqual(t, "conv1", conversation.ID)
+		assert.Empty(t, events.events)
+	})
+
+	t.Run("without a window closed conversations stay closed", func(t *testing.T) {
+		chat, _ := newLifecycleChat(closedAgo(time.Second), new(MockMessageRepo))
+
+		conversation, err := chat.CreateConversation("customer1")
+		require.NoError(t, err)
+		assert.NotEqual(t, "conv1", conversation.ID)
+	})
+
+	t.Run("writing after the conversation closed resumes it", func(t *testing.T) {
+		conversations := closedAgo(time.Minute)
+		repo := new(MockMessageRepo)
+		repo.On("SaveMessage", mock.Anything, mock.Anything).Return(nil)
+		chat, _ := newLifecycleChat(conversations, repo)
+		chat.SetReopenWindow(time.Hour)
+
+		require.NoError(t, chat.SaveMessage(&domain.Message{
+			Content:    "I'm back",
+			CustomerID: "customer1",
+			Type:       domain.UserMessage,
+			Metadata:   map[string]string{"conversation_id": "conv1"},
+		}))
+		stored, _ := conversations.GetConversation(context.Background(), "conv1")
+		assert.Equal(t, "active", stored.Status)
+	})
+}
+
+func TestConversationLifecycle(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+
+	newRacingLifecycle := func(race bool) (*services.ConversationLifecycle, *memoryConversations, *recordingHub, *recordingEvents) {
+		conversations := newMemoryConversations(
+			domain.Conversation{ID: "idle", CustomerID: "customer1", StartedAt: now.Add(-time.Hour), Status: "active"},
+			domain.Conversation{ID: "busy", CustomerID: "customer2", StartedAt: now, Status: "active"},
+		)
+		repo := &activeOnlyMessages{MockMessageRepo: new(MockMessageRepo), conversations: conversations}
+		repo.On("GetMessagePage", mock.Anything, mock.MatchedBy(func(query domain.MessageQuery) bool {
+			return query.ConversationID == "idle"
+		})).Return(&domain.MessagePage{Messages: []domain.Message{{ID: "msg-1"}}}, nil)
+
+		var repository ports.ConversationRepository = conversations
+		if race {
+			repository = racingConversations{conversations}
+		}
+		chat, events := newLifecycleChat(repository, repo)
+		queue := &recordingBotQueue{}
+		chat.SetBotQueue(queue)
+		lifecycle := services.NewConversationLifecycle(chat, 30*time.Minute)
+		hub := &recordingHub{}
+		lifecycle.SetHub(hub)
+		return lifecycle, conversations, hub, events
+	}
+	newLifecycle := func() (*services.ConversationLifecycle, *memoryConversations, *recordingHub, *recordingEvents) {
+		return newRacingLifecycle(false)
+	}
+
+	t.Run("closes idle conversations after telling the customer", func(t *testing.T) {
+		lifecycle, conversations, hub, events := newLifecycle()
+
+		closed, err := lifecycle.CloseIdle(ctx, now)
+		require.NoError(t, err)
+		assert.Equal(t, 1, closed)
+
+		require.Len(t, hub.system, 1)
+		notice := hub.system[0]
+		assert.Equal(t, domain.SystemMessage, notice.Type)
+		assert.Equal(t, "customer1", notice.CustomerID)
+		assert.Equal(t, "idle", notice.Metadata["conversation_id"])
+
+		// Stored in the conversation as it closed, which the repository
+		// would refuse once it is closed
+		require.Len(t, conversations.notices, 1)
+		assert.Equal(t, notice.ID, conversations.notices[0].ID)
+		idle, _ := conversations.GetConversation(ctx, "idle")
+		assert.Equal(t, "closed", idle.Status)
+		assert.Equal(t, notice.Timestamp, idle.EndedAt)
+		busy, _ := conversations.GetConversation(ctx, "busy")
+		assert.Equal(t, "active", busy.Status)
+
+		require.Len(t, events.events, 1)
+		assert.Equal(t, domain.ConversationClosedEvent, events.events[0].Type)
+		assert.Equal(t, "idle", events.events[0].ConversationID)
+		assert.Equal(t, domain.CloseReasonIdle, events.events[0].Reason)
+
+		// Nothing is left to close
+		closed, err = lifecycle.CloseIdle(ctx, now)
+		require.NoError(t, err)
+		assert.Zero(t, closed)
+	})
+
+	t.Run("leaves conversations closed or written in since they were listed", func(t *testing.T) {
+		lifecycle, conversations, hub, events := newRacingLifecycle(true)
+
+		closed, err := lifecycle.CloseIdle(ctx, now)
+		require.NoError(t, err)
+		assert.Zero(t, closed)
+		assert.Empty(t, hub.system)
+		assert.Empty(t, events.events)
+		// Only the other replica's notice was stored
+		assert.Len(t, conversations.notices, 1)
+	})
+
+	t.Run("replicas derive the same notice", func(t *testing.T) {
+		var ids []string
+		for i := 0; i < 2; i++ {
+			lifecycle, _, hub, _ := newLifecycle()
+			_, err := lifecycle.CloseIdle(ctx, now)
+			require.NoError(t, err)
+			require.Len(t, hub.system, 1)
+			ids = append(ids, hub.system[0].ID)
+		}
+		assert.Equal(t, ids[0], ids[1])
+	})
+}
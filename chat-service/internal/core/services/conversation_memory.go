@@ -0,0 +1,253 @@
+package services
+
+import (
+	"chat-service/internal/core/domain"
+	"chat-service/internal/core/ports"
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// DefaultMemoryTokenBudget is how many tokens of history are sent to the
+// language model unless configured otherwise
+const DefaultMemoryTokenBudget = 2000
+
+// messageTokenOverhead approximates the tokens a chat turn costs beyond its
+// content
+const messageTokenOverhead = 4
+
+const summaryPrompt = "Summarise the customer support conversation below in at most five sentences. " +
+	"Keep names, order numbers, dates and anything the customer asked for that is still unresolved. " +
+	"If a previous summary is given, fold it into the new one."
+
+// MemoryService rebuilds what the language model remembers of a conversation
+// from the stored messages. Turns that no longer fit the token budget are
+// condensed into a rolling summary instead of being forgotten.
+type MemoryService struct {
+	messages      ports.MessageRepository
+	conversations ports.ConversationRepository
+	summaries     ports.SummaryRepository
+	summarizer    ports.LLMProvider
+	redactor      ports.TextRedactor
+	tokenBudget   int
+}
+
+var _ ports.ConversationMemory = (*MemoryService)(nil)
+
+// NewMemoryService creates a new conversation memory
+func NewMemoryService(messages ports.MessageRepository, conversations ports.ConversationRepository, summaries ports.SummaryRepository) *MemoryService {
+	return &MemoryService{
+		messages:      messages,
+		conversations: conversations,
+		summaries:     summaries,
+		tokenBudget:   DefaultMemoryTokenBudget,
+	}
+}
+
+// SetSummarizer sets the language model that condenses older turns. Without
+// one they are dropped once they no longer fit.
+func (m *MemoryService) SetSummarizer(summarizer ports.LLMProvider) {
+	m.summarizer = summarizer
+}
+
+// SetRedactor hides personal data from the summarizer. The summaries keep
+// the markers in its place.
+func (m *MemoryService) SetRedactor(redactor ports.TextRedactor) {
+	m.redactor = redactor
+}
+
+// SetTokenBudget sets how many tokens of history are recalled
+func (m *MemoryService) SetTokenBudget(tokens int) {
+	if tokens > 0 {
+		m.tokenBudget = tokens
+	}
+}
+
+// Recall returns the summary of older turns, if any, followed by the most
+// recent turns of the message's conversation, ending with the message itself
+func (m *MemoryService) Recall(ctx context.Context, message *domain.Message) ([]domain.LLMMessage, error) {
+	conversationID, err := m.conversationID(ctx, message)
+	if err != nil {
+		return nil, err
+	}
+
+	stored, err := m.messages.GetMessagesByConversation(ctx, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	history := chatTurns(stored, message)
+
+	summary, err := m.summaries.GetSummary(ctx, conversationID)
+	if err != nil {
+		log.Printf("Error loading summary of conversation %s: %v", conversationID, err)
+		summary = nil
+	}
+	recent := unsummarised(history, summary)
+
+	budget := m.tokenBudget
+	if summary != nil {
+		budget -= estimateTokens(summary.Content) + messageTokenOverhead
+	}
+	if turnTokens(recent) > budget {
+		// Summarise down to half the budget so this doesn't happen every turn
+		keep := newestWithin(recent, m.tokenBudget/2)
+		older := recent[:len(recent)-keep]
+		summary = m.summarize(ctx, conversationID, summary, older)
+		recent = recent[len(recent)-keep:]
+	}
+
+	turns := make([]domain.LLMMessage, 0, len(recent)+1)
+	if summary != nil {
+		turns = append(turns, domain.LLMMessage{
+			Role:    domain.LLMRoleSystem,
+			Content: "Summary of the earlier conversation: " + summary.Content,
+		})
+	}
+	for _, msg := range recent {
+		turns = append(turns, llmTurn(msg))
+	}
+	return turns, nil
+}
+
+// conversationID finds the conversation a message belongs to
+func (m *MemoryService) conversationID(ctx context.Context, message *domain.Message) (string, error) {
+	if id := message.Metadata["conversation_id"]; id != "" {
+		return id, nil
+	}
+	conversation, err := m.conversations.GetActiveConversationByCustomer(ctx, message.CustomerID)
+	if err != nil {
+		return "", err
+	}
+	return conversation.ID, nil
+}
+
+// summarize folds the older turns into the previous summary and stores the
+// result. It returns the previous summary if the model can't be reached.
+func (m *MemoryService) summarize(ctx context.Context, conversationID string, previous *domain.ConversationSummary, older []domain.Message) *domain.ConversationSummary {
+	if m.summarizer == nil || len(older) == 0 {
+		return previous
+	}
+
+	var transcript strings.Builder
+	if previous != nil {
+		fmt.Fprintf(&transcript, "Previous summary: %s\n\n", previous.Content)
+	}
+	for _, msg := range older {
+		speaker := "Customer"
+		if msg.Type != domain.UserMessage {
+			speaker = "Support"
+		}
+		fmt.Fprintf(&transcript, "%s: %s\n", speaker, msg.Content)
+	}
+
+	text := transcript.String()
+	if m.redactor != nil {
+		text = m.redactor.Redact(text)
+	}
+
+	summaryCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	content, err := m.summarizer.Complete(summaryCtx, []domain.LLMMessage{
+		{Role: domain.LLMRoleSystem, Content: summaryPrompt},
+		{Role: domain.LLMRoleUser, Content: text},
+	})
+	if err != nil {
+		log.Printf("Error summarising conversation %s: %v", conversationID, err)
+		return previous
+	}
+
+	last := older[len(older)-1]
+	summary := &domain.ConversationSummary{
+		ConversationID: conversationID,
+		Content:        strings.TrimSpace(content),
+		LastMessageID:  last.ID,
+		CoveredUntil:   last.Timestamp,
+		UpdatedAt:      time.Now(),
+	}
+	if err := m.summaries.SaveSummary(ctx, summary); err != nil {
+		log.Printf("Error saving summary of conversation %s: %v", conversationID, err)
+	}
+	return summary
+}
+
+// chatTurns keeps the messages the model should see and makes sure the
+// latest one is there even if it hasn't been stored yet
+func chatTurns(stored []domain.Message, latest *domain.Message) []domain.Message {
+	turns := make([]domain.Message, 0, len(stored)+1)
+	found := false
+	for _, msg := range stored {
+		switch msg.Type {
+		case domain.UserMessage, domain.BotMessage, domain.AgentMessage:
+			// Deleted messages have nothing left to remember
+			if msg.DeletedAt == nil {
+				turns = append(turns, msg)
+			}
+		}
+		if latest.ID != "" && msg.ID == latest.ID {
+			found = true
+		}
+	}
+	if !found {
+		turns = append(turns, *latest)
+	}
+	return turns
+}
+
+// unsummarised returns the turns after the last one the summary covers
+func unsummarised(history []domain.Message, summary *domain.ConversationSummary) []domain.Message {
+	if summary == nil {
+		return history
+	}
+	for i, msg := range history {
+		if msg.ID == summary.LastMessageID {
+			return history[i+1:]
+		}
+	}
+	// The covered message is gone; fall back to its timestamp
+	for i, msg := range history {
+		if msg.Timestamp.After(summary.CoveredUntil) {
+			return history[i:]
+		}
+	}
+	return history[len(history)-1:]
+}
+
+// newestWithin counts how many of the newest turns fit the budget, never
+// fewer than one
+func newestWithin(history []domain.Message, budget int) int {
+	used := 0
+	for i := len(history) - 1; i >= 0; i-- {
+		used += estimateTokens(history[i].Content) + messageTokenOverhead
+		if used > budget {
+			return max(len(history)-1-i, 1)
+		}
+	}
+	return len(history)
+}
+
+func turnTokens(history []domain.Message) int {
+	total := 0
+	for _, msg := range history {
+		total += estimateTokens(msg.Content) + messageTokenOverhead
+	}
+	return total
+}
+
+// estimateTokens approximates the token count of English text at four
+// characters per token
+func estimateTokens(text string) int {
+	return (len(text) + 3) / 4
+}
+
+// llmTurn maps a stored message to the role the model sees. Agents answer on
+// the bot's side of the conversation.
+func llmTurn(msg domain.Message) domain.LLMMessage {
+	role := domain.LLMRoleAssistant
+	if msg.Type == domain.UserMessage {
+		role = domain.LLMRoleUser
+	}
+	return domain.LLMMessage{Role: role, Content: msg.Content}
+}
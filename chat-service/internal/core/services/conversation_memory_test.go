@@ -0,0 +1,211 @@
+package services_test
+
+import (
+	"chat-service/internal/core/domain"
+	"chat-service/internal/core/ports"
+	"chat-service/internal/core/services"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// stubSummaryRepo keeps summaries in memory
+type stubSummaryRepo struct {
+	summaries map[string]domain.ConversationSummary
+}
+
+func (r *stubSummaryRepo) GetSummary(ctx context.Context, conversationID string) (*domain.ConversationSummary, error) {
+	summary, ok := r.summaries[conversationID]
+	if !ok {
+		return nil, nil
+	}
+	return &summary, nil
+}
+
+func (r *stubSummaryRepo) SaveSummary(ctx context.Context, summary *domain.ConversationSummary) error {
+	r.summaries[summary.ConversationID] = *summary
+	return nil
+}
+
+var _ ports.SummaryRepository = (*stubSummaryRepo)(nil)
+
+// summarizingLLM returns a fixed summary and records what it was asked
+type summarizingLLM struct {
+	summary string
+	err     error
+	prompts [][]domain.LLMMessage
+}
+
+func (p *summarizingLLM) Name() string { return "summarizer" }
+
+func (p *summarizingLLM) Complete(ctx context.Context, messages []domain.LLMMessage) (string, error) {
+	p.prompts = append(p.prompts, messages)
+	return p.summary, p.err
+}
+
+func (p *summarizingLLM) Stream(ctx context.Context, messages []domain.LLMMessage, onDelta func(string) error) (string, error) {
+	return p.Complete(ctx, messages)
+}
+
+// storedConversation builds alternating customer and bot turns, each about
+// 30 tokens long
+func storedConversation(turns int) []domain.Message {
+	start := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	messages := make([]domain.Message, turns)
+	for i := range messages {
+		messageType := domain.UserMessage
+		if i%2 == 1 {
+			messageType = domain.BotMessage
+		}
+		messages[i] = domain.Message{
+			ID:         "m" + string(rune('a'+i)),
+			Content:    string(rune('a'+i)) + strings.Repeat(" word", 20),
+			CustomerID: "customer1",
+			Type:       messageType,
+			Timestamp:  start.Add(time.Duration(i) * time.Minute),
+		}
+	}
+	return messages
+}
+
+func newTestMemory(stored []domain.Message) (*services.MemoryService, *stubSummaryRepo, *MockMessageRepo) {
+	repo := new(MockMessageRepo)
+	repo.On("GetMessagesByConversation", mock.Anything, "conv-1").Return(stored, nil)
+	conversations := new(MockConversationRepo)
+	conversations.On("GetActiveConversationByCustomer", mock.Anything, "customer1").
+		Return(&domain.Conversation{ID: "conv-1", CustomerID: "customer1", Status: "active"}, nil)
+
+	summaries := &stubSummaryRepo{summaries: make(map[string]domain.ConversationSummary)}
+	return services.NewMemoryService(repo, conversations, summaries), summaries, repo
+}
+
+func TestMemoryService(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("rebuilds the conversation from stored messages", func(t *testing.T) {
+		stored := []domain.Message{
+			{ID: "m1", Content: "Where is my order?", Type: domain.UserMessage},
+			{ID: "m2", Content: "Let me check.", Type: domain.BotMessage},
+			{ID: "m3", Content: "An agent joined.", Type: domain.SystemMessage},
+			{ID: "m4", Content: "It ships tomorrow.", Type: domain.AgentMessage},
+		}
+		memory, _, repo := newTestMemory(stored)
+
+		turns, err := memory.Recall(ctx, &domain.Message{
+			ID:         "m5",
+			Content:    "Thanks!",
+			CustomerID: "customer1",
+			Type:       domain.UserMessage,
+			Metadata:   map[string]string{"conversation_id": "conv-1"},
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, []domain.LLMMessage{
+			{Role: domain.LLMRoleUser, Content: "Where is my order?"},
+			{Role: domain.LLMRoleAssistant, Content: "Let me check."},
+			{Role: domain.LLMRoleAssistant, Content: "It ships tomorrow."},
+			{Role: domain.LLMRoleUser, Content: "Thanks!"},
+		}, turns)
+		repo.AssertCalled(t, "GetMessagesByConversation", mock.Anything, "conv-1")
+	})
+
+	t.Run("does not repeat a message that is already stored", func(t *testing.T) {
+		stored := storedConversation(2)
+		memory, _, _ := newTestMemory(stored)
+
+		turns, err := memory.Recall(ctx, &stored[1])
+		require.NoError(t, err)
+		assert.Len(t, turns, 2)
+	})
+
+	t.Run("summarises turns beyond the token budget", func(t *testing.T) {
+		stored := storedConversation(10)
+		memory, summaries, _ := newTestMemory(stored)
+		summarizer := &summarizingLLM{summary: "The customer asked about a late order."}
+		memory.SetSummarizer(summarizer)
+		memory.SetTokenBudget(200)
+
+		latest := stored[9]
+		turns, err := memory.Recall(ctx, &latest)
+		require.NoError(t, err)
+
+		require.Len(t, summarizer.prompts, 1)
+		assert.Contains(t, summarizer.prompts[0][1].Content, "Customer: a word")
+
+		assert.Equal(t, domain.LLMRoleSystem, turns[0].Role)
+		assert.Contains(t, turns[0].Content, "The customer asked about a late order.")
+		assert.Equal(t, latest.Content, turns[len(turns)-1].Content)
+		assert.Less(t, len(turns)-1, 10)
+
+		summary := summaries.summaries["conv-1"]
+		assert.Equal(t, stored[9-(len(turns)-1)].ID, summary.LastMessageID)
+
+		// The next turn starts from the stored summary
+		turns, err = memory.Recall(ctx, &latest)
+		require.NoError(t, err)
+		assert.Len(t, summarizer.prompts, 1)
+		assert.Contains(t, turns[0].Content, "The customer asked about a late order.")
+	})
+
+	t.Run("drops older turns when summarising fails", func(t *testing.T) {
+		stored := storedConversation(10)
+		memory, summaries, _ := newTestMemory(stored)
+		memory.SetSummarizer(&summarizingLLM{err: errors.New("unavailable")})
+		memory.SetTokenBudget(200)
+
+		turns, err := memory.Recall(ctx, &stored[9])
+		require.NoError(t, err)
+		assert.Empty(t, summaries.summaries)
+		assert.NotEqual(t, domain.LLMRoleSystem, turns[0].Role)
+		assert.Less(t, len(turns), 10)
+	})
+
+	t.Run("hides personal data from the summarizer", func(t *testing.T) {
+		stored := storedConversation(10)
+		stored[0].Content = "Reach me at ada@example.com"
+		memory, _, _ := newTestMemory(stored)
+		summarizer := &summarizingLLM{summary: "The customer left an email address."}
+		memory.SetSummarizer(summarizer)
+		memory.SetRedactor(services.NewPIIRedactor())
+		memory.SetTokenBudget(200)
+
+		_, err := memory.Recall(ctx, &stored[9])
+		require.NoError(t, err)
+		require.Len(t, summarizer.prompts, 1)
+		assert.Contains(t, summarizer.prompts[0][1].Content, "Customer: Reach me at [EMAIL]")
+		assert.NotContains(t, summarizer.prompts[0][1].Content, "ada@example.com")
+	})
+}
+
+func TestBotAgentRecallsConversation(t *testing.T) {
+	stored := []domain.Message{
+		{ID: "m1", Content: "My order number is 1234", Type: domain.UserMessage, CustomerID: "customer1"},
+		{ID: "m2", Content: "Thanks, noted.", Type: domain.BotMessage, CustomerID: "customer1"},
+	}
+	memory, _, repo := newTestMemory(stored)
+	repo.On("SaveMessage", mock.Anything, mock.Anything).Return(nil)
+	publisher := new(MockMessagePublisher)
+	publisher.On("PublishChatMessage", mock.Anything).Return(nil)
+
+	provider := &streamingLLM{reply: "It ships tomorrow"}
+	bot, _ := newTestBotAgentWithKnowledge(repo, publisher, provider, nil)
+	bot.SetConversationMemory(memory)
+
+	require.NoError(t, bot.ProcessMessage(context.Background(), &domain.Message{
+		Content:    "when does it ship",
+		CustomerID: "customer1",
+		Type:       domain.UserMessage,
+	}))
+
+	require.Len(t, provider.received, 4)
+	assert.Equal(t, domain.LLMRoleSystem, provider.received[0].Role)
+	assert.Equal(t, "My order number is 1234", provider.received[1].Content)
+	assert.Equal(t, domain.LLMRoleAssistant, provider.received[2].Role)
+	assert.Equal(t, "when does it ship", provider.received[3].Content)
+}
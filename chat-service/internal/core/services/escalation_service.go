@@ -0,0 +1,336 @@
+package services
+
+import (
+	"chat-service/internal/cache"
+	"chat-service/internal/core/domain"
+	"chat-service/internal/core/ports"
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DefaultFallbackLimit is how many fallback answers in a row hand the
+// conversation over to a human
+const DefaultFallbackLimit = 3
+
+// Fallback streaks are remembered for this many conversations, and forgotten
+// once the conversation has been quiet this long
+const (
+	fallbackCapacity = 10000
+	fallbackTTL      = time.Hour
+)
+
+// The bot's replies when it escalates a conversation, when the conversation
+// already is, with or without the ticket number known, and when it couldn't
+// be escalated
+const (
+	handoverMessage         = "Let me connect you with a member of our support team. I'm opening a ticket for you now."
+	alreadyEscalatedMessage = "A member of our support team already has your conversation and will get back to you here."
+	alreadyTicketedMessage  = "A member of our support team already has your conversation. Your ticket number is %s."
+	handoverFailedMessage   = "I'm sorry, I couldn't reach our support team just now. Please ask again in a moment."
+)
+
+// humanRequestPhrases mark a message as asking for a person. A phrase matches
+// when its words appear together in the message, ignoring stop words. A
+// single word only matches a message that says nothing else, such as "agent
+// please", so "my travel agent" or "human resources" don't.
+var humanRequestPhrases = []string{
+	"human", "agent", "person", "representative", "operator",
+	"real person", "live person", "live agent", "human agent",
+	"talk to a human", "speak to a human", "speak with a human",
+	"talk to an agent", "speak to an agent", "speak with an agent",
+	"connect me to an agent", "connect me with an agent",
+	"talk to a person", "speak to a person", "speak with a person",
+	"talk to a representative", "speak to a representative", "speak with a representative",
+	"talk to someone", "speak to someone", "speak with someone",
+}
+
+// humanRequestTerms are the analyzed humanRequestPhrases
+var humanRequestTerms = func() [][]string {
+	terms := make([][]string, 0, len(humanRequestPhrases))
+	for _, phrase := range humanRequestPhrases {
+		if analyzed := analyze(phrase); len(analyzed) > 0 {
+			terms = append(terms, analyzed)
+		}
+	}
+	return terms
+}()
+
+// EscalationService hands conversations the bot cannot resolve over to the
+// CRM, which opens a ticket for them, and tells the customer the ticket ID
+type EscalationService struct {
+	publisher     ports.EscalationPublisher
+	notices       ports.MessagePublisher
+	messages      ports.MessageRepository
+	conversations ports.ConversationRepository
+	hub           ports.MessageHub
+	attachments   ports.AttachmentLinks
+	fallbackLimit int
+	// How long the attachment links handed to the CRM are valid
+	attachmentLinkTTL time.Duration
+
+	// Consecutive fallback answers per conversation
+	fallbacks *cache.Cache[int]
+	mutex     sync.Mutex
+}
+
+// NewEscalationService creates a new escalation service
+func NewEscalationService(publisher ports.EscalationPublisher, messages ports.MessageRepository, conversations ports.ConversationRepository) *EscalationService {
+	return &EscalationService{
+		publisher:     publisher,
+		messages:      messages,
+		conversations: conversations,
+		fallbackLimit: DefaultFallbackLimit,
+		fallbacks:     cache.New[int](fallbackCapacity, fallbackTTL),
+	}
+}
+
+// SetMessagePublisher shares ticket notices with the other replicas, since
+// only the one consuming the ticket sends its notice
+func (s *EscalationService) SetMessagePublisher(publisher ports.MessagePublisher) {
+	s.notices = publisher
+}
+
+// SetHub sets where ticket notices are delivered
+func (s *EscalationService) SetHub(hub ports.MessageHub) {
+	s.hub = hub
+}
+
+// SetAttachments signs the links to the files attached to escalated
+// conversations for validFor, long enough for the ticket to be worked on
+func (s *EscalationService) SetAttachments(attachments ports.AttachmentLinks, validFor time.Duration) {
+	s.attachments = attachments
+	s.attachmentLinkTTL = validFor
+}
+
+// SetFallbackLimit sets how many fallback answers in a row escalate the
+// conversation. Zero disables fallback escalation.
+func (s *EscalationService) SetFallbackLimit(limit int) {
+	if limit < 0 {
+		limit = 0
+	}
+	s.mutex.Lock()
+	s.fallbackLimit = limit
+	s.mutex.Unlock()
+}
+
+// IsHumanRequest reports whether the message asks to talk to a person
+func (s *EscalationService) IsHumanRequest(content string) bool {
+	terms := analyze(content)
+	for _, phrase := range humanRequestTerms {
+		if len(phrase) == 1 {
+			if len(terms) == 1 && terms[0] == phrase[0] {
+				return true
+			}
+			continue
+		}
+		if containsPhrase(terms, phrase) {
+			return true
+		}
+	}
+	return false
+}
+
+// containsPhrase reports whether the phrase's terms appear in order and next
+// to each other among the terms
+func containsPhrase(terms, phrase []string) bool {
+	for start := 0; start+len(phrase) <= len(terms); start++ {
+		matched := true
+		for i, term := range phrase {
+			if terms[start+i] != term {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+// recordFallback counts a fallback answer in the conversation, keyed by
+// conversationKey, and reports whether the limit has been reached
+func (s *EscalationService) recordFallback(conversation string) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.fallbackLimit == 0 {
+		return false
+	}
+	count, _ := s.fallbacks.Get(conversation)
+	count++
+	if count < s.fallbackLimit {
+		s.fallbacks.Set(conversation, count)
+		return false
+	}
+	s.fallbacks.Remove(conversation)
+	return true
+}
+
+// resetFallbacks clears the conversation's fallback count after a real answer
+func (s *EscalationService) resetFallbacks(conversation string) {
+	s.fallbacks.Remove(conversation)
+}
+
+// Escalate publishes the customer's active conversation and its transcript
+// so the CRM can open a ticket. A conversation is only escalated once, by
+// whichever replica marks it escalated first.
+func (s *EscalationService) Escalate(ctx context.Context, customerID, reason, flowID string) error {
+	conversation, claimed, err := s.claim(ctx, customerID)
+	if err != nil {
+		return err
+	}
+	if !claimed {
+		log.Printf("Conversation %s is already escalated", conversation.ID)
+		return nil
+	}
+	return s.publish(ctx, conversation, reason, flowID)
+}
+
+// handover decides whether a reply asking for an escalation hands the
+// conversation over, before the customer is told so. The reply is kept, and
+// the conversation to publish returned, only if this claimed the escalation.
+// A conversation already escalated gets told who has it instead.
+func (s *EscalationService) handover(ctx context.Context, customerID string, reply botReply) (botReply, *domain.Conversation) {
+	conversation, claimed, err := s.claim(ctx, customerID)
+	switch {
+	case err != nil:
+		log.Printf("Error escalating conversation: %v", err)
+		return withoutEscalation(reply, handoverFailedMessage), nil
+	case !claimed:
+		ticketID := s.ticketID(ctx, conversation.ID)
+		if ticketID == "" {
+			return withoutEscalation(reply, alreadyEscalatedMessage), nil
+		}
+		reply = withoutEscalation(reply, fmt.Sprintf(alreadyTicketedMessage, ticketID))
+		reply.Metadata["ticket_id"] = ticketID
+		return reply, nil
+	}
+	return reply, conversation
+}
+
+// claim marks the customer's active conversation escalated, reporting
+// whether this call did so
+func (s *EscalationService) claim(ctx context.Context, customerID string) (*domain.Conversation, bool, error) {
+	conversation, err := s.conversations.GetActiveConversationByCustomer(ctx, customerID)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to find conversation to escalate: %w", err)
+	}
+
+	marked, err := s.conversations.MarkEscalated(ctx, conversation.ID, true)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to mark conversation escalated: %w", err)
+	}
+	return conversation, marked, nil
+}
+
+// publish sends a claimed conversation's transcript to the CRM, releasing
+// the claim if it can't
+func (s *EscalationService) publish(ctx context.Context, conversation *domain.Conversation, reason, flowID string) error {
+	transcript, err := s.messages.GetMessagesByConversation(ctx, conversation.ID)
+	if err != nil {
+		s.forget(ctx, conversation.ID)
+		return fmt.Errorf("failed to load transcript: %w", err)
+	}
+	if s.attachments != nil {
+		s.attachments.Sign(transcript, s.attachmentLinkTTL)
+	}
+
+	escalation := &domain.Escalation{
+		ID:             uuid.New().String(),
+		ConversationID: conversation.ID,
+		CustomerID:     conversation.CustomerID,
+		Reason:         reason,
+		FlowID:         flowID,
+		Transcript:     transcript,
+		RequestedAt:    time.Now(),
+	}
+	if err := s.publisher.PublishEscalation(escalation); err != nil {
+		s.forget(ctx, conversation.ID)
+		return fmt.Errorf("failed to publish escalation: %w", err)
+	}
+
+	log.Printf("Escalated conversation %s (%s) with %d messages", conversation.ID, reason, len(transcript))
+	return nil
+}
+
+// ticketID finds the ticket opened for a conversation in its ticket notice,
+// if the CRM has answered yet
+func (s *EscalationService) ticketID(ctx context.Context, conversationID string) string {
+	messages, err := s.messages.GetMessagesByConversation(ctx, conversationID)
+	if err != nil {
+		log.Printf("Error loading messages of conversation %s: %v", conversationID, err)
+		return ""
+	}
+	for i := len(messages) - 1; i >= 0; i-- {
+		if ticketID := messages[i].Metadata["ticket_id"]; ticketID != "" {
+			return ticketID
+		}
+	}
+	return ""
+}
+
+// forget lets a conversation be escalated again after a failed attempt
+func (s *EscalationService) forget(ctx context.Context, conversationID string) {
+	if _, err := s.conversations.MarkEscalated(ctx, conversationID, false); err != nil {
+		log.Printf("Error clearing escalation of conversation %s: %v", conversationID, err)
+	}
+}
+
+// HandleTicket sends the customer a system message with the ID of the ticket
+// the CRM opened for an escalation
+func (s *EscalationService) HandleTicket(ticket *domain.EscalationTicket) {
+	message := systemMessage(ticket.CustomerID,
+		fmt.Sprintf("Your conversation has been passed to our support team. Your ticket number is %s.", ticket.TicketID),
+		map[string]string{
+			"conversation_id": ticket.ConversationID,
+			"ticket_id":       ticket.TicketID,
+		})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.messages.SaveMessage(ctx, message); err != nil {
+		log.Printf("Error saving ticket notice: %v", err)
+	}
+	if s.notices != nil {
+		if err := s.notices.PublishChatMessage(message); err != nil {
+			log.Printf("Error publishing ticket notice: %v", err)
+		}
+	}
+
+	if s.hub != nil {
+		s.hub.SendSystemMessage(message)
+	}
+	log.Printf("Conversation %s escalated to ticket %s", ticket.ConversationID, ticket.TicketID)
+}
+
+// Subscribe starts listening for tickets opened by the CRM
+func (s *EscalationService) Subscribe() error {
+	return s.publisher.SubscribeToEscalationTickets(s.HandleTicket)
+}
+
+// escalationReply is the bot's handover message
+func escalationReply(reason string) botReply {
+	return botReply{
+		Text:       handoverMessage,
+		Metadata:   map[string]string{"escalation": reason},
+		Escalation: reason,
+	}
+}
+
+// withoutEscalation replaces an escalation reply's text with one that hands
+// nothing over, keeping the rest of its metadata
+func withoutEscalation(reply botReply, text string) botReply {
+	metadata := make(map[string]string, len(reply.Metadata))
+	for key, value := range reply.Metadata {
+		if key != "escalation" {
+			metadata[key] = value
+		}
+	}
+	return botReply{Text: text, Metadata: metadata}
+}
@@ -0,0 +1,249 @@
+package services_test
+
+import (
+	"chat-service/internal/core/domain"
+	"chat-service/internal/core/ports"
+	"chat-service/internal/core/services"
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingEscalations captures published escalations
+type recordingEscalations struct {
+	mutex     sync.Mutex
+	published []domain.Escalation
+}
+
+func (p *recordingEscalations) PublishEscalation(escalation *domain.Escalation) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.published = append(p.published, *escalation)
+	return nil
+}
+
+func (p *recordingEscalations) SubscribeToEscalationTickets(handler func(*domain.EscalationTicket)) error {
+	return nil
+}
+
+func (p *recordingEscalations) escalations() []domain.Escalation {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return append([]domain.Escalation(nil), p.published...)
+}
+
+var _ ports.EscalationPublisher = (*recordingEscalations)(nil)
+
+var escalationFlow = domain.Flow{
+	ID:      "damage",
+	Name:    "Damaged item",
+	Intents: []string{"damaged item"},
+	Enabled: true,
+	Steps: []domain.FlowStep{
+		{
+			ID:         "ask_severity",
+			Slot:       "severity",
+			Prompt:     "Is the item usable or broken?",
+			Validation: domain.SlotValidation{Type: domain.SlotChoice, Choices: []string{"usable", "broken"}},
+			Branches:   map[string]string{"broken": "escalate"},
+		},
+	},
+	CompletionMessage: "Thanks, we'll send you a discount code.",
+	EscalationMessage: "A broken item needs a specialist, connecting you now.",
+}
+
+func newEscalationBot(t *testing.T) (*services.BotAgent, *recordingHub, *recordingEscalations, *services.EscalationService, *MockMessageRepo) {
+	repo := new(MockMessageRepo)
+	repo.On("SaveMessage", mock.Anything, mock.Anything).Return(nil)
+	repo.On("GetMessagesByConversation", mock.Anything, "conv-1").Return([]domain.Message{
+		{ID: "m1", Content: "Hello", Type: domain.UserMessage, CustomerID: "customer1"},
+		{ID: "m2", Content: "I'm not sure how to respond to that.", Type: domain.BotMessage, CustomerID: "customer1"},
+	}, nil)
+	publisher := new(MockMessagePublisher)
+	publisher.On("PublishChatMessage", mock.Anything).Return(nil)
+	conversations := new(MockConversationRepo)
+	conversations.On("GetActiveConversationByCustomer", mock.Anything, "customer1").
+		Return(&domain.Conversation{ID: "conv-1", CustomerID: "customer1", Status: "active"}, nil)
+	// Only the first escalation marks the conversation
+	conversations.On("MarkEscalated", mock.Anything, "conv-1", true).Return(true, nil).Once()
+	conversations.On("MarkEscalated", mock.Anything, "conv-1", true).Return(false, nil)
+
+	engine := services.NewFlowEngine(newStubFlowRepo(), conversations)
+	flow := escalationFlow
+	require.NoError(t, engine.SaveFlow(context.Background(), &flow))
+
+	escalations := &recordingEscalations{}
+	service := services.NewEscalationService(escalations, repo, conversations)
+	service.SetFallbackLimit(2)
+
+	kb := services.NewKnowledgeBase(&stubKnowledgeRepo{entries: []domain.KnowledgeEntry{
+		{ID: "payment_methods", Question: "payment methods", Answer: "We accept credit cards.", Keywords: []string{"payment"}},
+	}})
+	bot := services.NewBotAgent("bot-1", "Support Bot", false, repo, publisher, kb)
+	bot.SetFlowEngine(engine)
+	bot.SetEscalationService(service)
+	hub := &recordingHub{}
+	bot.SetHub(hub)
+	service.SetHub(hub)
+	return bot, hub, escalations, service, repo
+}
+
+func TestEscalation(t *testing.T) {
+	t.Run("customer asks for a human", func(t *testing.T) {
+		bot, hub, escalations, _, _ := newEscalationBot(t)
+
+		reply := say(t, bot, hub, "Can I talk to a real person?")
+		assert.Equal(t, domain.EscalationCustomerRequest, reply.Metadata["escalation"])
+
+		published := escalations.escalations()
+		require.Len(t, published, 1)
+		assert.Equal(t, "conv-1", published[0].ConversationID)
+		assert.Equal(t, "customer1", published[0].CustomerID)
+		assert.Equal(t, domain.EscalationCustomerRequest, published[0].Reason)
+		assert.Len(t, published[0].Transcript, 2)
+		assert.NotEmpty(t, published[0].ID)
+
+		// Asking again does not promise or open a second ticket
+		reply = say(t, bot, hub, "agent please")
+		assert.Contains(t, reply.Content, "already has your conversation")
+		assert.NotContains(t, reply.Content, "opening a ticket")
+		assert.Empty(t, reply.Metadata["escalation"])
+		assert.Len(t, escalations.escalations(), 1)
+	})
+
+	t.Run("an escalated conversation is told its ticket", func(t *testing.T) {
+		repo := new(MockMessageRepo)
+		repo.On("SaveMessage", mock.Anything, mock.Anything).Return(nil)
+		repo.On("GetMessagesByConversation", mock.Anything, "conv-1").Return([]domain.Message{
+			{ID: "m1", Content: "agent please", Type: domain.UserMessage, CustomerID: "customer1"},
+			{ID: "m2", Type: domain.SystemMessage, CustomerID: "customer1", Metadata: map[string]string{"ticket_id": "T-42"}},
+		}, nil)
+		publisher := new(MockMessagePublisher)
+		publisher.On("PublishChatMessage", mock.Anything).Return(nil)
+		conversations := newMemoryConversations(domain.Conversation{ID: "conv-1", CustomerID: "customer1", Status: "active"})
+		conversations.escalated["conv-1"] = true
+
+		escalations := &recordingEscalations{}
+		service := services.NewEscalationService(escalations, repo, conversations)
+		bot := services.NewBotAgent("bot-1", "Support Bot", false, repo, publisher, services.NewKnowledgeBase(&stubKnowledgeRepo{}))
+		bot.SetEscalationService(service)
+		hub := &recordingHub{}
+		bot.SetHub(hub)
+
+		reply := say(t, bot, hub, "Can I speak to a human?")
+		assert.Contains(t, reply.Content, "T-42")
+		assert.Equal(t, "T-42", reply.Metadata["ticket_id"])
+		assert.Empty(t, escalations.escalations())
+
+		// Clearing the escalation, as reopening does, lets it be handed over again
+		_, err := conversations.MarkEscalated(context.Background(), "conv-1", false)
+		require.NoError(t, err)
+		reply = say(t, bot, hub, "Can I speak to a human?")
+		assert.Equal(t, domain.EscalationCustomerRequest, reply.Metadata["escalation"])
+		assert.Len(t, escalations.escalations(), 1)
+	})
+
+	t.Run("repeated fallbacks", func(t *testing.T) {
+		bot, hub, escalations, _, _ := newEscalationBot(t)
+
+		say(t, bot, hub, "blorp")
+		reply := say(t, bot, hub, "what payment methods do you take")
+		assert.Equal(t, "We accept credit cards.", reply.Content)
+
+		// A real answer resets the count
+		say(t, bot, hub, "zzzt")
+		assert.Empty(t, escalations.escalations())
+
+		reply = say(t, bot, hub, "flibber")
+		assert.Equal(t, domain.EscalationRepeatedFallback, reply.Metadata["escalation"])
+		require.Len(t, escalations.escalations(), 1)
+		assert.Equal(t, domain.EscalationRepeatedFallback, escalations.escalations()[0].Reason)
+	})
+
+	t.Run("flow step", func(t *testing.T) {
+		bot, hub, escalations, _, _ := newEscalationBot(t)
+
+		say(t, bot, hub, "I received a damaged item")
+		reply := say(t, bot, hub, "it's broken")
+		assert.Equal(t, "A broken item needs a specialist, connecting you now.", reply.Content)
+		assert.Equal(t, domain.FlowEscalated, reply.Metadata["flow_status"])
+		assert.Equal(t, "broken", reply.Metadata["slot.severity"])
+
+		published := escalations.escalations()
+		require.Len(t, published, 1)
+		assert.Equal(t, domain.EscalationFlow, published[0].Reason)
+		assert.Equal(t, "damage", published[0].FlowID)
+	})
+
+	t.Run("tells the customer the ticket ID", func(t *testing.T) {
+		_, hub, _, service, repo := newEscalationBot(t)
+		notices := new(MockMessagePublisher)
+		notices.On("PublishChatMessage", mock.Anything).Return(nil)
+		service.SetMessagePublisher(notices)
+
+		service.HandleTicket(&domain.EscalationTicket{
+			ConversationID: "conv-1",
+			CustomerID:     "customer1",
+			TicketID:       "T-42",
+		})
+
+		require.Len(t, hub.system, 1)
+		notice := hub.system[0]
+		assert.Equal(t, domain.SystemMessage, notice.Type)
+		assert.Equal(t, "customer1", notice.CustomerID)
+		assert.Contains(t, notice.Content, "T-42")
+		assert.Equal(t, "T-42", notice.Metadata["ticket_id"])
+		repo.AssertCalled(t, "SaveMessage", mock.Anything, mock.MatchedBy(func(message *domain.Message) bool {
+			return message.Type == domain.SystemMessage && message.Metadata["ticket_id"] == "T-42"
+		}))
+		// Customers connected to other replicas hear about it too
+		notices.AssertCalled(t, "PublishChatMessage", mock.MatchedBy(func(message *domain.Message) bool {
+			return message.ID == notice.ID
+		}))
+	})
+}
+
+func TestEscalationAcrossReplicas(t *testing.T) {
+	repo := new(MockMessageRepo)
+	repo.On("GetMessagesByConversation", mock.Anything, "conv-1").Return([]domain.Message{}, nil)
+	conversations := newMemoryConversations(domain.Conversation{ID: "conv-1", CustomerID: "customer1", Status: "active"})
+
+	// Two replicas share the conversations
+	escalations := &recordingEscalations{}
+	first := services.NewEscalationService(escalations, repo, conversations)
+	second := services.NewEscalationService(escalations, repo, conversations)
+
+	ctx := context.Background()
+	require.NoError(t, first.Escalate(ctx, "customer1", domain.EscalationCustomerRequest, ""))
+	require.NoError(t, second.Escalate(ctx, "customer1", domain.EscalationCustomerRequest, ""))
+	assert.Len(t, escalations.escalations(), 1)
+}
+
+func TestIsHumanRequest(t *testing.T) {
+	service := services.NewEscalationService(&recordingEscalations{}, nil, nil)
+
+	for _, content := range []string{
+		"I want to speak to a human",
+		"Can I talk to someone please?",
+		"get me an agent",
+		"Agent please!",
+		"representative",
+		"Is there a real person I could talk to?",
+		"please connect me with an agent",
+	} {
+		assert.True(t, service.IsHumanRequest(content), content)
+	}
+	for _, content := range []string{
+		"what payment methods do you take",
+		"someone stole my parcel",
+		"my travel agent booked the wrong hotel",
+		"I work in human resources and need an invoice",
+		"the person at the door left my parcel outside",
+	} {
+		assert.False(t, service.IsHumanRequest(content), content)
+	}
+}
@@ -0,0 +1,567 @@
+package services
+
+import (
+	"chat-service/internal/core/domain"
+	"chat-service/internal/core/ports"
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// flowEnd can be used as a step's next target to finish the flow early
+const flowEnd = "end"
+
+// flowEscalate can be used as a step's next target to hand the conversation
+// over to a human
+const flowEscalate = "escalate"
+
+// defaultMaxAttempts is how many invalid answers a step accepts by default
+const defaultMaxAttempts = 3
+
+const (
+	defaultFlowErrorMessage   = "Sorry, I didn't catch that."
+	defaultFlowFailureMessage = "Sorry, I wasn't able to complete that with you. Let's try something else."
+	flowCancelledMessage      = "No problem, I've cancelled that. Is there anything else I can help with?"
+)
+
+// cancelPhrases stop the active flow when sent on their own
+var cancelPhrases = map[string]bool{
+	"cancel": true, "stop": true, "quit": true, "exit": true,
+	"never mind": true, "nevermind": true, "forget it": true,
+}
+
+var (
+	numberPattern   = regexp.MustCompile(`\d+`)
+	emailPattern    = regexp.MustCompile(`[^\s@]+@[^\s@]+\.[^\s@.,!?]+`)
+	templatePattern = regexp.MustCompile(`\{\{\s*(\w+)\s*\}\}`)
+)
+
+// compiledFlow is a flow prepared for matching
+type compiledFlow struct {
+	flow     domain.Flow
+	intents  [][]string
+	steps    map[string]int
+	patterns map[string]*regexp.Regexp
+}
+
+// FlowEngine runs guided multi-turn conversations described as data. Flow
+// progress is stored per conversation so it survives restarts and reconnects.
+type FlowEngine struct {
+	repository    ports.FlowRepository
+	conversations ports.ConversationRepository
+	flows         []*compiledFlow
+	loaded        bool
+	mutex         sync.RWMutex
+}
+
+// NewFlowEngine creates a new flow engine
+func NewFlowEngine(repository ports.FlowRepository, conversations ports.ConversationRepository) *FlowEngine {
+	return &FlowEngine{
+		repository:    repository,
+		conversations: conversations,
+	}
+}
+
+// GetFlows returns all flow definitions
+func (e *FlowEngine) GetFlows(ctx context.Context) ([]domain.Flow, error) {
+	return e.repository.GetFlows(ctx)
+}
+
+// GetFlow returns the flow with the given ID, or nil if there is none
+func (e *FlowEngine) GetFlow(ctx context.Context, id string) (*domain.Flow, error) {
+	flows, err := e.repository.GetFlows(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range flows {
+		if flows[i].ID == id {
+			return &flows[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// SaveFlow validates and stores a flow definition, replacing any flow with
+// the same ID
+func (e *FlowEngine) SaveFlow(ctx context.Context, flow *domain.Flow) error {
+	if _, err := compileFlow(*flow); err != nil {
+		return err
+	}
+	if err := e.repository.SaveFlow(ctx, flow); err != nil {
+		return err
+	}
+	return e.Reload(ctx)
+}
+
+// DeleteFlow removes a flow definition. Conversations in the flow leave it
+// on their next message.
+func (e *FlowEngine) DeleteFlow(ctx context.Context, id string) error {
+	if err := e.repository.DeleteFlow(ctx, id); err != nil {
+		return err
+	}
+	return e.Reload(ctx)
+}
+
+// Reload refreshes the flow definitions from the repository
+func (e *FlowEngine) Reload(ctx context.Context) error {
+	flows, err := e.repository.GetFlows(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load flows: %w", err)
+	}
+
+	var compiled []*compiledFlow
+	for _, flow := range flows {
+		c, err := compileFlow(flow)
+		if err != nil {
+			log.Printf("Skipping flow %s: %v", flow.ID, err)
+			continue
+		}
+		compiled = append(compiled, c)
+	}
+
+	e.mutex.Lock()
+	e.flows = compiled
+	e.loaded = true
+	e.mutex.Unlock()
+
+	log.Printf("Loaded %d dialog flows", len(compiled))
+	return nil
+}
+
+func (e *FlowEngine) flow(id string) *compiledFlow {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	for _, flow := range e.flows {
+		if flow.flow.ID == id {
+			return flow
+		}
+	}
+	return nil
+}
+
+// handle advances the customer's active flow, or starts one when the message
+// matches a flow intent. It reports false when no flow is involved.
+func (e *FlowEngine) handle(ctx context.Context, message *domain.Message) (botReply, bool) {
+	e.mutex.RLock()
+	loaded := e.loaded
+	e.mutex.RUnlock()
+	if !loaded {
+		if err := e.Reload(ctx); err != nil {
+			log.Printf("Flow engine unavailable: %v", err)
+			return botReply{}, false
+		}
+	}
+
+	conversation, err := e.conversations.GetActiveConversationByCustomer(ctx, message.CustomerID)
+	if err != nil {
+		log.Printf("Flow engine could not find a conversation for %s: %v", message.CustomerID, err)
+		return botReply{}, false
+	}
+
+	state, err := e.repository.GetFlowState(ctx, conversation.ID)
+	if err != nil {
+		log.Printf("Error loading flow state for conversation %s: %v", conversation.ID, err)
+		return botReply{}, false
+	}
+
+	if state != nil && state.Status == domain.FlowActive {
+		if flow := e.flow(state.FlowID); flow != nil {
+			return e.continueFlow(ctx, flow, state, message.Content), true
+		}
+		log.Printf("Conversation %s was in unknown flow %s, leaving it", conversation.ID, state.FlowID)
+		state.Status = domain.FlowCancelled
+		e.saveState(ctx, state)
+	}
+
+	flow := e.matchIntent(message.Content)
+	if flow == nil {
+		return botReply{}, false
+	}
+	return e.startFlow(ctx, flow, conversation.ID, message.Content), true
+}
+
+// matchIntent finds the enabled flow with the most specific intent contained
+// in the message
+func (e *FlowEngine) matchIntent(content string) *compiledFlow {
+	terms := make(map[string]bool)
+	for _, term := range analyze(content) {
+		terms[term] = true
+	}
+
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	var best *compiledFlow
+	bestLength := 0
+	for _, flow := range e.flows {
+		if !flow.flow.Enabled {
+			continue
+		}
+		for _, intent := range flow.intents {
+			if len(intent) <= bestLength || !containsAll(terms, intent) {
+				continue
+			}
+			best = flow
+			bestLength = len(intent)
+		}
+	}
+	return best
+}
+
+func containsAll(terms map[string]bool, required []string) bool {
+	for _, term := range required {
+		if !terms[term] {
+			return false
+		}
+	}
+	return true
+}
+
+// startFlow enters a flow, filling any slots the triggering message already
+// answers, and asks the first open question
+func (e *FlowEngine) startFlow(ctx context.Context, flow *compiledFlow, conversationID, content string) botReply {
+	now := time.Now()
+	state := &domain.FlowState{
+		ConversationID: conversationID,
+		FlowID:         flow.flow.ID,
+		Slots:          make(map[string]string),
+		Status:         domain.FlowActive,
+		StartedAt:      now,
+	}
+
+	for _, step := range flow.flow.Steps {
+		if step.Validation.Type == "" || step.Validation.Type == domain.SlotAny {
+			continue
+		}
+		if value, ok := flow.validate(step, content); ok {
+			state.Slots[step.Slot] = value
+		}
+	}
+
+	log.Printf("Conversation %s started flow %s", conversationID, flow.flow.ID)
+	return e.advance(ctx, flow, state, 0)
+}
+
+// continueFlow handles the customer's answer to the current step
+func (e *FlowEngine) continueFlow(ctx context.Context, flow *compiledFlow, state *domain.FlowState, content string) botReply {
+	if cancelPhrases[normalizeCommand(content)] {
+		state.Status = domain.FlowCancelled
+		e.saveState(ctx, state)
+		return flowReply(flow, state, flowCancelledMessage)
+	}
+
+	index, ok := flow.steps[state.StepID]
+	if !ok {
+		// The flow was edited and the step is gone; start it over
+		state.Slots = make(map[string]string)
+		return e.advance(ctx, flow, state, 0)
+	}
+	step := flow.flow.Steps[index]
+
+	value, valid := flow.validate(step, content)
+	if !valid {
+		state.Attempts++
+		maxAttempts := step.MaxAttempts
+		if maxAttempts <= 0 {
+			maxAttempts = defaultMaxAttempts
+		}
+		if state.Attempts >= maxAttempts {
+			if flow.flow.EscalateOnFailure {
+				return e.escalate(ctx, flow, state)
+			}
+			state.Status = domain.FlowFailed
+			e.saveState(ctx, state)
+
+			message := flow.flow.FailureMessage
+			if message == "" {
+				message = defaultFlowFailureMessage
+			}
+			return flowReply(flow, state, renderTemplate(message, state.Slots))
+		}
+
+		e.saveState(ctx, state)
+		errorMessage := step.ErrorMessage
+		if errorMessage == "" {
+			errorMessage = defaultFlowErrorMessage
+		}
+		return flowReply(flow, state, renderTemplate(errorMessage+" "+step.Prompt, state.Slots))
+	}
+
+	state.Slots[step.Slot] = value
+	state.Attempts = 0
+
+	target := step.Next
+	if branch, ok := step.Branches[strings.ToLower(value)]; ok {
+		target = branch
+	}
+	switch target {
+	case "":
+		return e.advance(ctx, flow, state, index+1)
+	case flowEscalate:
+		return e.escalate(ctx, flow, state)
+	default:
+		return e.advance(ctx, flow, state, flow.stepIndex(target))
+	}
+}
+
+// escalate ends the flow and asks the bot to hand the conversation over
+func (e *FlowEngine) escalate(ctx context.Context, flow *compiledFlow, state *domain.FlowState) botReply {
+	state.Status = domain.FlowEscalated
+	e.saveState(ctx, state)
+	log.Printf("Conversation %s escalated from flow %s", state.ConversationID, flow.flow.ID)
+
+	message := flow.flow.EscalationMessage
+	if message == "" {
+		message = handoverMessage
+	}
+	reply := flowReply(flow, state, renderTemplate(message, state.Slots))
+	for name, value := range state.Slots {
+		reply.Metadata["slot."+name] = value
+	}
+	reply.Metadata["escalation"] = domain.EscalationFlow
+	reply.Escalation = domain.EscalationFlow
+	return reply
+}
+
+// cancel leaves the customer's active flow, if any
+func (e *FlowEngine) cancel(ctx context.Context, customerID string) {
+	conversation, err := e.conversations.GetActiveConversationByCustomer(ctx, customerID)
+	if err != nil {
+		return
+	}
+	state, err := e.repository.GetFlowState(ctx, conversation.ID)
+	if err != nil || state == nil || state.Status != domain.FlowActive {
+		return
+	}
+	state.Status = domain.FlowCancelled
+	e.saveState(ctx, state)
+}
+
+// advance moves to the first step from index whose slot is still empty,
+// completing the flow when there is none
+func (e *FlowEngine) advance(ctx context.Context, flow *compiledFlow, state *domain.FlowState, index int) botReply {
+	for index < len(flow.flow.Steps) {
+		step := flow.flow.Steps[index]
+		if _, filled := state.Slots[step.Slot]; !filled {
+			state.StepID = step.ID
+			e.saveState(ctx, state)
+			return flowReply(flow, state, renderTemplate(step.Prompt, state.Slots))
+		}
+		index++
+	}
+
+	state.Status = domain.FlowCompleted
+	e.saveState(ctx, state)
+	log.Printf("Conversation %s completed flow %s", state.ConversationID, flow.flow.ID)
+
+	reply := flowReply(flow, state, renderTemplate(flow.flow.CompletionMessage, state.Slots))
+	for name, value := range state.Slots {
+		reply.Metadata["slot."+name] = value
+	}
+	return reply
+}
+
+func (e *FlowEngine) saveState(ctx context.Context, state *domain.FlowState) {
+	if err := e.repository.SaveFlowState(ctx, state); err != nil {
+		log.Printf("Error saving flow state for conversation %s: %v", state.ConversationID, err)
+	}
+}
+
+func flowReply(flow *compiledFlow, state *domain.FlowState, text string) botReply {
+	metadata := map[string]string{
+		"flow_id":     flow.flow.ID,
+		"flow_status": state.Status,
+	}
+	if state.Status == domain.FlowActive {
+		metadata["flow_step"] = state.StepID
+	}
+	return botReply{Text: text, Metadata: metadata}
+}
+
+// stepIndex resolves a step target; "end" and unknown targets finish the flow
+func (c *compiledFlow) stepIndex(target string) int {
+	if index, ok := c.steps[target]; ok {
+		return index
+	}
+	return len(c.flow.Steps)
+}
+
+// validate checks an answer against a step and returns the value to store
+func (c *compiledFlow) validate(step domain.FlowStep, content string) (string, bool) {
+	content = strings.TrimSpace(content)
+	if content == "" {
+		return "", false
+	}
+
+	switch step.Validation.Type {
+	case "", domain.SlotAny:
+		return content, true
+	case domain.SlotNumber:
+		value := numberPattern.FindString(content)
+		return value, value != ""
+	case domain.SlotEmail:
+		value := emailPattern.FindString(content)
+		return value, value != ""
+	case domain.SlotRegex:
+		match := c.patterns[step.ID].FindStringSubmatch(content)
+		if match == nil {
+			return "", false
+		}
+		// Use the first capture group when the pattern has one
+		if len(match) > 1 {
+			return match[1], true
+		}
+		return match[0], true
+	case domain.SlotChoice:
+		return matchChoice(step.Validation.Choices, content)
+	}
+	return "", false
+}
+
+// matchChoice accepts a choice given as the whole answer or as a word in it,
+// allowing small typos
+func matchChoice(choices []string, content string) (string, bool) {
+	answer := normalizeCommand(content)
+	candidates := append([]string{answer}, strings.FieldsFunc(answer, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})...)
+
+	// Exact matches win over corrected ones
+	for _, candidate := range candidates {
+		for _, choice := range choices {
+			if candidate == strings.ToLower(choice) {
+				return choice, true
+			}
+		}
+	}
+
+	for _, candidate := range candidates {
+		for _, choice := range choices {
+			lower := strings.ToLower(choice)
+			limit := maxEditDistance(len([]rune(lower)))
+			if limit > 0 && editDistance(candidate, lower, limit) <= limit {
+				return choice, true
+			}
+		}
+	}
+	return "", false
+}
+
+// normalizeCommand lowercases a short reply and strips surrounding punctuation
+func normalizeCommand(content string) string {
+	return strings.Trim(strings.ToLower(strings.TrimSpace(content)), ".!?,;: ")
+}
+
+// renderTemplate replaces {{slot}} placeholders with collected values
+func renderTemplate(text string, slots map[string]string) string {
+	return templatePattern.ReplaceAllStringFunc(text, func(placeholder string) string {
+		name := templatePattern.FindStringSubmatch(placeholder)[1]
+		if value, ok := slots[name]; ok {
+			return value
+		}
+		return placeholder
+	})
+}
+
+// compileFlow validates a flow definition and prepares it for matching
+func compileFlow(flow domain.Flow) (*compiledFlow, error) {
+	invalid := func(format string, args ...interface{}) error {
+		return fmt.Errorf("%w: %s", domain.ErrInvalidFlow, fmt.Sprintf(format, args...))
+	}
+
+	if strings.TrimSpace(flow.ID) == "" {
+		return nil, invalid("id is required")
+	}
+	if len(flow.Steps) == 0 {
+		return nil, invalid("flow %s has no steps", flow.ID)
+	}
+	if flow.CompletionMessage == "" {
+		return nil, invalid("flow %s has no completion message", flow.ID)
+	}
+
+	compiled := &compiledFlow{
+		flow:     flow,
+		steps:    make(map[string]int),
+		patterns: make(map[string]*regexp.Regexp),
+	}
+	// Steps are normalised below, so don't touch the caller's copy
+	compiled.flow.Steps = append([]domain.FlowStep(nil), flow.Steps...)
+
+	for _, intent := range flow.Intents {
+		if terms := analyze(intent); len(terms) > 0 {
+			compiled.intents = append(compiled.intents, terms)
+		}
+	}
+	if len(compiled.intents) == 0 {
+		return nil, invalid("flow %s needs at least one intent with meaningful words", flow.ID)
+	}
+	// Check longer intents first so they win ties in matchIntent
+	sort.SliceStable(compiled.intents, func(i, j int) bool {
+		return len(compiled.intents[i]) > len(compiled.intents[j])
+	})
+
+	slots := make(map[string]bool)
+	for i, step := range flow.Steps {
+		switch {
+		case step.ID == "":
+			return nil, invalid("step %d has no id", i+1)
+		case step.ID == flowEnd, step.ID == flowEscalate:
+			return nil, invalid("step id %q is reserved", step.ID)
+		case step.Slot == "":
+			return nil, invalid("step %s has no slot", step.ID)
+		case step.Prompt == "":
+			return nil, invalid("step %s has no prompt", step.ID)
+		}
+		if _, exists := compiled.steps[step.ID]; exists {
+			return nil, invalid("duplicate step id %s", step.ID)
+		}
+		if slots[step.Slot] {
+			return nil, invalid("duplicate slot %s", step.Slot)
+		}
+		compiled.steps[step.ID] = i
+		slots[step.Slot] = true
+
+		switch step.Validation.Type {
+		case "", domain.SlotAny, domain.SlotNumber, domain.SlotEmail:
+		case domain.SlotRegex:
+			pattern, err := regexp.Compile(step.Validation.Pattern)
+			if err != nil || step.Validation.Pattern == "" {
+				return nil, invalid("step %s has an invalid pattern: %v", step.ID, err)
+			}
+			compiled.patterns[step.ID] = pattern
+		case domain.SlotChoice:
+			if len(step.Validation.Choices) == 0 {
+				return nil, invalid("step %s has no choices", step.ID)
+			}
+		default:
+			return nil, invalid("step %s has unknown validation type %q", step.ID, step.Validation.Type)
+		}
+	}
+
+	// Branch keys are matched against lowercased values
+	for i := range compiled.flow.Steps {
+		step := &compiled.flow.Steps[i]
+		targets := []string{step.Next}
+		if len(step.Branches) > 0 {
+			branches := make(map[string]string, len(step.Branches))
+			for value, target := range step.Branches {
+				branches[strings.ToLower(value)] = target
+				targets = append(targets, target)
+			}
+			step.Branches = branches
+		}
+		for _, target := range targets {
+			if _, exists := compiled.steps[target]; target != "" && target != flowEnd && target != flowEscalate && !exists {
+				return nil, invalid("step %s points to unknown step %s", step.ID, target)
+			}
+		}
+	}
+
+	return compiled, nil
+}
@@ -0,0 +1,246 @@
+package services_test
+
+import (
+	"chat-service/internal/core/domain"
+	"chat-service/internal/core/services"
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// stubFlowRepo is an in-memory flow repository
+type stubFlowRepo struct {
+	mutex  sync.Mutex
+	flows  map[string]domain.Flow
+	states map[string]domain.FlowState
+}
+
+func newStubFlowRepo() *stubFlowRepo {
+	return &stubFlowRepo{flows: make(map[string]domain.Flow), states: make(map[string]domain.FlowState)}
+}
+
+func (r *stubFlowRepo) GetFlows(ctx context.Context) ([]domain.Flow, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	var flows []domain.Flow
+	for _, flow := range r.flows {
+		flows = append(flows, flow)
+	}
+	return flows, nil
+}
+
+func (r *stubFlowRepo) SaveFlow(ctx context.Context, flow *domain.Flow) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.flows[flow.ID] = *flow
+	return nil
+}
+
+func (r *stubFlowRepo) DeleteFlow(ctx context.Context, id string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	delete(r.flows, id)
+	return nil
+}
+
+func (r *stubFlowRepo) GetFlowState(ctx context.Context, conversationID string) (*domain.FlowState, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	state, ok := r.states[conversationID]
+	if !ok {
+		return nil, nil
+	}
+	slots := make(map[string]string)
+	for k, v := range state.Slots {
+		slots[k] = v
+	}
+	state.Slots = slots
+	return &state, nil
+}
+
+func (r *stubFlowRepo) SaveFlowState(ctx context.Context, state *domain.FlowState) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.states[state.ConversationID] = *state
+	return nil
+}
+
+func (r *stubFlowRepo) state(conversationID string) domain.FlowState {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.states[conversationID]
+}
+
+var testFlows = []domain.Flow{
+	{
+		ID:      "order_status",
+		Name:    "Order lookup",
+		Intents: []string{"order status", "where is my order", "track order"},
+		Enabled: true,
+		Steps: []domain.FlowStep{
+			{
+				ID:           "ask_number",
+				Slot:         "order_number",
+				Prompt:       "What's your order number?",
+				Validation:   domain.SlotValidation{Type: domain.SlotNumber},
+				ErrorMessage: "Order numbers only contain digits.",
+				MaxAttempts:  2,
+			},
+		},
+		CompletionMessage: "Thanks! Order {{order_number}} is on its way.",
+		FailureMessage:    "Let me get someone to help with order lookups.",
+	},
+	{
+		ID:      "refund",
+		Name:    "Refund request",
+		Intents: []string{"refund"},
+		Enabled: true,
+		Steps: []domain.FlowStep{
+			{
+				ID:         "ask_reason",
+				Slot:       "reason",
+				Prompt:     "Why would you like a refund: damaged, late or other?",
+				Validation: domain.SlotValidation{Type: domain.SlotChoice, Choices: []string{"damaged", "late", "other"}},
+				Branches:   map[string]string{"late": "end"},
+			},
+			{
+				ID:     "ask_details",
+				Slot:   "details",
+				Prompt: "Sorry to hear it was {{reason}}. Can you describe the problem?",
+			},
+		},
+		CompletionMessage: "Your refund request ({{reason}}) has been logged.",
+	},
+}
+
+func newFlowBot(t *testing.T) (*services.BotAgent, *recordingHub, *stubFlowRepo, *services.FlowEngine) {
+	repo := new(MockMessageRepo)
+	repo.On("SaveMessage", mock.Anything, mock.Anything).Return(nil)
+	publisher := new(MockMessagePublisher)
+	publisher.On("PublishChatMessage", mock.Anything).Return(nil)
+	conversations := new(MockConversationRepo)
+	conversations.On("GetActiveConversationByCustomer", mock.Anything, "customer1").
+		Return(&domain.Conversation{ID: "conv-1", CustomerID: "customer1", Status: "active"}, nil)
+
+	flowRepo := newStubFlowRepo()
+	engine := services.NewFlowEngine(flowRepo, conversations)
+	for i := range testFlows {
+		flow := testFlows[i]
+		require.NoError(t, engine.SaveFlow(context.Background(), &flow))
+	}
+
+	kb := services.NewKnowledgeBase(&stubKnowledgeRepo{entries: []domain.KnowledgeEntry{
+		{ID: "payment_methods", Question: "payment methods", Answer: "We accept credit cards.", Keywords: []string{"payment"}},
+	}})
+	bot := services.NewBotAgent("bot-1", "Support Bot", false, repo, publisher, kb)
+	bot.SetFlowEngine(engine)
+	hub := &recordingHub{}
+	bot.SetHub(hub)
+	return bot, hub, flowRepo, engine
+}
+
+// say sends a customer message and returns the bot's reply
+func say(t *testing.T, bot *services.BotAgent, hub *recordingHub, content string) domain.Message {
+	before := len(hub.completed)
+	require.NoError(t, bot.ProcessMessage(context.Background(), &domain.Message{
+		Content:    content,
+		CustomerID: "customer1",
+		Type:       domain.UserMessage,
+	}))
+	require.Len(t, hub.completed, before+1)
+	return hub.completed[before]
+}
+
+func TestFlowEngine(t *testing.T) {
+	t.Run("collects a slot across turns", func(t *testing.T) {
+		bot, hub, flowRepo, _ := newFlowBot(t)
+
+		reply := say(t, bot, hub, "Where is my order?")
+		assert.Equal(t, "What's your order number?", reply.Content)
+		assert.Equal(t, "order_status", reply.Metadata["flow_id"])
+		assert.Equal(t, "ask_number", reply.Metadata["flow_step"])
+
+		reply = say(t, bot, hub, "I don't know")
+		assert.Equal(t, "Order numbers only contain digits. What's your order number?", reply.Content)
+
+		reply = say(t, bot, hub, "It's 12345")
+		assert.Equal(t, "Thanks! Order 12345 is on its way.", reply.Content)
+		assert.Equal(t, domain.FlowCompleted, reply.Metadata["flow_status"])
+		assert.Equal(t, "12345", reply.Metadata["slot.order_number"])
+
+		state := flowRepo.state("conv-1")
+		assert.Equal(t, domain.FlowCompleted, state.Status)
+		assert.Equal(t, "12345", state.Slots["order_number"])
+
+		// Outside a flow the bot answers as usual
+		reply = say(t, bot, hub, "what payment methods do you take")
+		assert.Equal(t, "We accept credit cards.", reply.Content)
+	})
+
+	t.Run("fills slots from the triggering message", func(t *testing.T) {
+		bot, hub, _, _ := newFlowBot(t)
+
+		reply := say(t, bot, hub, "track order 98765 please")
+		assert.Equal(t, "Thanks! Order 98765 is on its way.", reply.Content)
+	})
+
+	t.Run("fails after too many invalid answers", func(t *testing.T) {
+		bot, hub, flowRepo, _ := newFlowBot(t)
+
+		say(t, bot, hub, "order status")
+		say(t, bot, hub, "no idea")
+		reply := say(t, bot, hub, "still no idea")
+		assert.Equal(t, "Let me get someone to help with order lookups.", reply.Content)
+		assert.Equal(t, domain.FlowFailed, flowRepo.state("conv-1").Status)
+	})
+
+	t.Run("can be cancelled", func(t *testing.T) {
+		bot, hub, flowRepo, _ := newFlowBot(t)
+
+		say(t, bot, hub, "I want a refund")
+		reply := say(t, bot, hub, "Cancel.")
+		assert.Contains(t, reply.Content, "cancelled")
+		assert.Equal(t, domain.FlowCancelled, flowRepo.state("conv-1").Status)
+	})
+
+	t.Run("branches on choices", func(t *testing.T) {
+		bot, hub, _, _ := newFlowBot(t)
+
+		reply := say(t, bot, hub, "I want a refund")
+		assert.Equal(t, "Why would you like a refund: damaged, late or other?", reply.Content)
+
+		reply = say(t, bot, hub, "it arrived damagd")
+		assert.Equal(t, "Sorry to hear it was damaged. Can you describe the problem?", reply.Content)
+
+		reply = say(t, bot, hub, "The box was crushed")
+		assert.Equal(t, "Your refund request (damaged) has been logged.", reply.Content)
+		assert.Equal(t, "The box was crushed", reply.Metadata["slot.details"])
+
+		say(t, bot, hub, "another refund please")
+		reply = say(t, bot, hub, "late")
+		assert.Equal(t, "Your refund request (late) has been logged.", reply.Content)
+	})
+
+	t.Run("rejects invalid definitions", func(t *testing.T) {
+		_, _, _, engine := newFlowBot(t)
+
+		invalid := []domain.Flow{
+			{ID: "", Intents: []string{"x"}},
+			{ID: "no_steps", Intents: []string{"refund"}, CompletionMessage: "done"},
+			{ID: "bad_pattern", Intents: []string{"refund"}, CompletionMessage: "done", Steps: []domain.FlowStep{
+				{ID: "s", Slot: "s", Prompt: "?", Validation: domain.SlotValidation{Type: domain.SlotRegex, Pattern: "("}},
+			}},
+			{ID: "bad_next", Intents: []string{"refund"}, CompletionMessage: "done", Steps: []domain.FlowStep{
+				{ID: "s", Slot: "s", Prompt: "?", Next: "missing"},
+			}},
+		}
+		for _, flow := range invalid {
+			err := engine.SaveFlow(context.Background(), &flow)
+			assert.ErrorIs(t, err, domain.ErrInvalidFlow, flow.ID)
+		}
+	})
+}
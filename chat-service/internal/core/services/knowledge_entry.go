@@ -10,12 +10,21 @@ import (
 	"time"
 )
 
+// DefaultMatchThreshold is the confidence a match needs to be used as an answer
+const DefaultMatchThreshold = 0.5
+
 // KnowledgeBase manages Q&A entries for the chatbot
 type KnowledgeBase struct {
 	repository    ports.KnowledgeRepository
 	cachedEntries []domain.KnowledgeEntry
 	mutex         sync.RWMutex
 	lastUpdate    time.Time
+	synonyms      []domain.Synonym
+	index         *knowledgeIndex
+	threshold     float64
+
+	// retrieval is set when semantic search has been configured
+	retrieval *retrievalConfig
 }
 
 // NewKnowledgeBase creates a new knowledge base
@@ -23,6 +32,8 @@ func NewKnowledgeBase(repository ports.KnowledgeRepository) *KnowledgeBase {
 	kb := &KnowledgeBase{
 		repository: repository,
 		lastUpdate: time.Time{}, // Zero time
+		index:      newKnowledgeIndex(nil, nil),
+		threshold:  DefaultMatchThreshold,
 	}
 
 	// Add initial default entries if repo is empty
@@ -102,11 +113,22 @@ func (kb *KnowledgeBase) RefreshCache() {
 		return
 	}
 
+	synonyms, err := kb.repository.GetSynonyms(ctx)
+	if err != nil {
+		log.Printf("ERROR: Failed to load knowledge base synonyms: %v", err)
+		// Keep matching with the synonyms we already have
+		kb.mutex.RLock()
+		synonyms = kb.synonyms
+		kb.mutex.RUnlock()
+	}
+
 	kb.mutex.Lock()
 	defer kb.mutex.Unlock()
 
+	kb.synonyms = synonyms
 	if len(entries) > 0 {
 		kb.cachedEntries = entries
+		kb.index = newKnowledgeIndex(entries, synonyms)
 		kb.lastUpdate = time.Now()
 		log.Printf("Knowledge base cache refreshed with %d entries", len(entries))
 	} else {
@@ -114,17 +136,27 @@ func (kb *KnowledgeBase) RefreshCache() {
 		// Keep previous cache if we got zero entries (might be a temporary issue)
 		// But do update the timestamp to prevent constant retries
 		if len(kb.cachedEntries) > 0 {
+			kb.index = newKnowledgeIndex(kb.cachedEntries, synonyms)
 			kb.lastUpdate = time.Now()
 		}
 	}
 }
 
-// FindBestMatch finds the best response with database error handling
-func (kb *KnowledgeBase) FindBestMatch(input string) string {
-	input = strings.ToLower(strings.TrimSpace(input))
+// SetMatchThreshold sets the confidence FindBestMatch requires, between 0 and 1
+func (kb *KnowledgeBase) SetMatchThreshold(threshold float64) {
+	kb.mutex.Lock()
+	defer kb.mutex.Unlock()
+	kb.threshold = threshold
+}
 
+// Search ranks the cached entries against the input with BM25, best first.
+// Scores are confidences between 0 and 1.
+func (kb *KnowledgeBase) Search(input string) []domain.ScoredMatch {
 	// Check if we need to refresh cache
-	if time.Since(kb.lastUpdate) > 5*time.Minute || len(kb.cachedEntries) == 0 {
+	kb.mutex.RLock()
+	stale := time.Since(kb.lastUpdate) > 5*time.Minute || len(kb.cachedEntries) == 0
+	kb.mutex.RUnlock()
+	if stale {
 		kb.RefreshCache()
 	}
 
@@ -134,41 +166,32 @@ func (kb *KnowledgeBase) FindBestMatch(input string) string {
 	// If cache is empty after refresh attempt, we might have database issues
 	if len(kb.cachedEntries) == 0 {
 		log.Printf("WARNING: Knowledge base cache is empty, possible database connectivity issue")
-		return "I'm having trouble accessing my knowledge right now. Could you try again in a moment?"
-	}
-
-	// Try exact matches first
-	for _, entry := range kb.cachedEntries {
-		if strings.Contains(input, strings.ToLower(entry.Question)) {
-			log.Printf("KB: Found exact match with entry: %s", entry.ID)
-			return entry.Answer
-		}
+		return nil
 	}
 
-	// Try keyword matches with scoring
-	bestScore := 0
-	var bestAnswer string
+	return kb.index.rank(input)
+}
 
-	for _, entry := range kb.cachedEntries {
-		for _, keyword := range entry.Keywords {
-			keyword = strings.ToLower(keyword)
-			if strings.Contains(input, keyword) {
-				// Score based on keyword length (longer keywords are more specific)
-				score := len(keyword)
-				if score > bestScore {
-					bestScore = score
-					bestAnswer = entry.Answer
-				}
-			}
-		}
+// FindBestMatch returns the highest ranked entry if its confidence reaches
+// the match threshold
+func (kb *KnowledgeBase) FindBestMatch(input string) (*domain.ScoredMatch, bool) {
+	matches := kb.Search(input)
+	if len(matches) == 0 {
+		return nil, false
 	}
 
-	if bestScore > 0 {
-		return bestAnswer
+	kb.mutex.RLock()
+	threshold := kb.threshold
+	kb.mutex.RUnlock()
+
+	best := matches[0]
+	if best.Score < threshold {
+		log.Printf("KB: Best match %s scored %.2f, below threshold %.2f", best.Entry.ID, best.Score, threshold)
+		return nil, false
 	}
 
-	// No match found
-	return "I'm not sure how to respond to that. Could you try phrasing your question differently?"
+	log.Printf("KB: Matched entry %s with confidence %.2f", best.Entry.ID, best.Score)
+	return &best, true
 }
 
 // LogEntries logs all entries in the knowledge base
@@ -184,6 +207,16 @@ func (kb *KnowledgeBase) LogEntries() {
 	}
 }
 
+// GetSynonyms returns the synonym groups used for matching
+func (kb *KnowledgeBase) GetSynonyms() []domain.Synonym {
+	kb.mutex.RLock()
+	defer kb.mutex.RUnlock()
+
+	result := make([]domain.Synonym, len(kb.synonyms))
+	copy(result, kb.synonyms)
+	return result
+}
+
 // GetAllEntries returns all entries
 func (kb *KnowledgeBase) GetAllEntries() []domain.KnowledgeEntry {
 	kb.mutex.RLock()
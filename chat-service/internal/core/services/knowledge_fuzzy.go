@@ -0,0 +1,189 @@
+package services
+
+import (
+	"chat-service/internal/core/domain"
+	"sort"
+	"strings"
+)
+
+// fuzzyWeight scales the contribution of a query term that only matched the
+// index after typo correction
+const fuzzyWeight = 0.8
+
+// queryTerm is an analyzed term and whether it came from typo correction
+type queryTerm struct {
+	text  string
+	fuzzy bool
+}
+
+// synonymPhrase is a sequence of analyzed tokens standing for a canonical term
+type synonymPhrase struct {
+	tokens    []string
+	canonical string
+}
+
+// synonymTable rewrites synonyms to the canonical term of their group so that
+// entries and queries using different words share index terms
+type synonymTable struct {
+	phrases []synonymPhrase
+	words   []string
+	starts  map[string]bool
+}
+
+// newSynonymTable analyzes every group. Each group's own term maps to itself
+// so multi-word terms become a single index term too.
+func newSynonymTable(synonyms []domain.Synonym) *synonymTable {
+	table := &synonymTable{starts: make(map[string]bool)}
+	for _, synonym := range synonyms {
+		canonicalTokens := analyze(synonym.Term)
+		if len(canonicalTokens) == 0 {
+			continue
+		}
+		canonical := strings.Join(canonicalTokens, " ")
+
+		for _, phrase := range append([]string{synonym.Term}, synonym.Synonyms...) {
+			words := analyzeWords(phrase)
+			if len(words) == 0 {
+				continue
+			}
+			tokens := make([]string, len(words))
+			for i, word := range words {
+				tokens[i] = stem(word)
+			}
+			table.phrases = append(table.phrases, synonymPhrase{tokens: tokens, canonical: canonical})
+			table.words = append(table.words, words...)
+			table.starts[tokens[0]] = true
+		}
+	}
+
+	// Prefer the longest phrase when several start at the same token
+	sort.SliceStable(table.phrases, func(i, j int) bool {
+		return len(table.phrases[i].tokens) > len(table.phrases[j].tokens)
+	})
+	return table
+}
+
+// normalize replaces every synonym phrase in terms with its canonical term.
+// A replacement is fuzzy if any of the terms it replaced was.
+func (t *synonymTable) normalize(terms []queryTerm) []queryTerm {
+	if t == nil || len(t.phrases) == 0 {
+		return terms
+	}
+
+	result := make([]queryTerm, 0, len(terms))
+	for i := 0; i < len(terms); {
+		matched := false
+		for _, phrase := range t.phrases {
+			if !hasPrefix(terms[i:], phrase.tokens) {
+				continue
+			}
+			replacement := queryTerm{text: phrase.canonical}
+			for _, term := range terms[i : i+len(phrase.tokens)] {
+				replacement.fuzzy = replacement.fuzzy || term.fuzzy
+			}
+			result = append(result, replacement)
+			i += len(phrase.tokens)
+			matched = true
+			break
+		}
+		if !matched {
+			result = append(result, terms[i])
+			i++
+		}
+	}
+	return result
+}
+
+func hasPrefix(terms []queryTerm, prefix []string) bool {
+	if len(prefix) > len(terms) {
+		return false
+	}
+	for i := range prefix {
+		if terms[i].text != prefix[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// maxEditDistance is how many typos a term of the given length may contain.
+// Short words are left alone as a single edit turns them into other words.
+func maxEditDistance(length int) int {
+	switch {
+	case length < 4:
+		return 0
+	case length < 8:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// closestTerm finds the vocabulary term nearest to term within the allowed
+// edit distance, preferring more common terms on ties
+func closestTerm(term string, vocabulary map[string]int) (string, bool) {
+	limit := maxEditDistance(len([]rune(term)))
+	if limit == 0 {
+		return "", false
+	}
+
+	best := ""
+	bestDistance := limit + 1
+	for candidate, frequency := range vocabulary {
+		distance := editDistance(term, candidate, limit)
+		if distance < bestDistance ||
+			(distance == bestDistance && best != "" &&
+				(frequency > vocabulary[best] || (frequency == vocabulary[best] && candidate < best))) {
+			best = candidate
+			bestDistance = distance
+		}
+	}
+
+	return best, best != "" && bestDistance <= limit
+}
+
+// editDistance is the Damerau-Levenshtein (optimal string alignment) distance
+// between a and b. It gives up early, returning limit+1, once the distance
+// is known to exceed limit.
+func editDistance(a, b string, limit int) int {
+	ra, rb := []rune(a), []rune(b)
+	if abs(len(ra)-len(rb)) > limit {
+		return limit + 1
+	}
+
+	prevPrev := make([]int, len(rb)+1)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		rowMin := curr[0]
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				curr[j] = min(curr[j], prevPrev[j-2]+1)
+			}
+			rowMin = min(rowMin, curr[j])
+		}
+		if rowMin > limit {
+			return limit + 1
+		}
+		prevPrev, prev, curr = prev, curr, prevPrev
+	}
+
+	return prev[len(rb)]
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,289 @@
+package services
+
+import (
+	"chat-service/internal/core/domain"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// BM25 tuning parameters, using the usual defaults
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// Field weights applied by repeating a field's tokens in the indexed document.
+// Questions and keywords describe what an entry is about better than answers.
+const (
+	questionWeight = 2
+	keywordWeight  = 2
+	answerWeight   = 1
+)
+
+// rankerStopWords carry no meaning for matching
+var rankerStopWords = map[string]bool{
+	"a": true, "about": true, "am": true, "an": true, "and": true, "are": true,
+	"as": true, "at": true, "be": true, "by": true, "can": true, "could": true,
+	"do": true, "does": true, "for": true, "from": true, "get": true, "have": true, "how": true,
+	"i": true, "if": true, "in": true, "is": true, "it": true, "me": true,
+	"my": true, "of": true, "on": true, "or": true, "our": true, "please": true,
+	"so": true, "take": true, "that": true, "the": true, "there": true, "this": true, "to": true,
+	"us": true, "was": true, "we": true, "what": true, "when": true, "where": true,
+	"want": true, "which": true, "will": true, "with": true, "would": true, "you": true, "your": true,
+}
+
+// knowledgeIndex is a BM25 index over knowledge entries
+type knowledgeIndex struct {
+	entries    []domain.KnowledgeEntry
+	termFreqs  []map[string]int
+	docLengths []int
+	docFreqs   map[string]int
+	avgDocLen  float64
+	synonyms   *synonymTable
+
+	// words counts the unstemmed words seen in entries and synonyms; typos
+	// are corrected against them
+	words map[string]int
+}
+
+// newKnowledgeIndex indexes the question, keywords and answer of each entry,
+// rewriting synonyms to the canonical term of their group
+func newKnowledgeIndex(entries []domain.KnowledgeEntry, synonyms []domain.Synonym) *knowledgeIndex {
+	index := &knowledgeIndex{
+		entries:    entries,
+		termFreqs:  make([]map[string]int, len(entries)),
+		docLengths: make([]int, len(entries)),
+		docFreqs:   make(map[string]int),
+		synonyms:   newSynonymTable(synonyms),
+		words:      make(map[string]int),
+	}
+	for _, word := range index.synonyms.words {
+		index.words[word]++
+	}
+
+	var totalLength int
+	for i, entry := range entries {
+		var tokens []string
+		for n := 0; n < questionWeight; n++ {
+			tokens = append(tokens, index.documentTerms(entry.Question)...)
+		}
+		for n := 0; n < keywordWeight; n++ {
+			tokens = append(tokens, index.documentTerms(strings.Join(entry.Keywords, " "))...)
+		}
+		for n := 0; n < answerWeight; n++ {
+			tokens = append(tokens, index.documentTerms(entry.Answer)...)
+		}
+
+		freqs := make(map[string]int)
+		for _, token := range tokens {
+			freqs[token]++
+		}
+		for term := range freqs {
+			index.docFreqs[term]++
+		}
+
+		index.termFreqs[i] = freqs
+		index.docLengths[i] = len(tokens)
+		totalLength += len(tokens)
+	}
+
+	if len(entries) > 0 {
+		index.avgDocLen = float64(totalLength) / float64(len(entries))
+	}
+	return index
+}
+
+// documentTerms analyzes entry text into index terms
+func (idx *knowledgeIndex) documentTerms(text string) []string {
+	words := analyzeWords(text)
+	terms := make([]queryTerm, len(words))
+	for i, word := range words {
+		idx.words[word]++
+		terms[i] = queryTerm{text: stem(word)}
+	}
+
+	terms = idx.synonyms.normalize(terms)
+	result := make([]string, len(terms))
+	for i, term := range terms {
+		result[i] = term.text
+	}
+	return result
+}
+
+// queryTerms analyzes a query into index terms. Words the index doesn't know
+// are corrected to the closest known word when they look like a typo.
+func (idx *knowledgeIndex) queryTerms(query string) []queryTerm {
+	words := analyzeWords(query)
+	terms := make([]queryTerm, len(words))
+	for i, word := range words {
+		terms[i] = queryTerm{text: stem(word)}
+		if idx.docFreqs[terms[i].text] > 0 || idx.synonyms.starts[terms[i].text] {
+			continue
+		}
+		if corrected, ok := closestTerm(word, idx.words); ok {
+			terms[i] = queryTerm{text: stem(corrected), fuzzy: true}
+		}
+	}
+	return idx.synonyms.normalize(terms)
+}
+
+// idf is the BM25 inverse document frequency, always positive
+func (idx *knowledgeIndex) idf(term string) float64 {
+	n := float64(len(idx.entries))
+	df := float64(idx.docFreqs[term])
+	return math.Log(1 + (n-df+0.5)/(df+0.5))
+}
+
+// rank scores every entry against the query and returns those sharing at
+// least one term with it, best first. Scores are confidences between 0 and 1:
+// each query term contributes its BM25 score, capped at what a document
+// containing it once at average length would get, as a share of the sum of
+// those caps. Query terms unknown to the index count against the confidence,
+// and typo-corrected terms count for a bit less. Raw BM25 breaks ties.
+func (idx *knowledgeIndex) rank(query string) []domain.ScoredMatch {
+	if len(idx.entries) == 0 {
+		return nil
+	}
+	terms := idx.queryTerms(query)
+	if len(terms) == 0 {
+		return nil
+	}
+
+	// Duplicated query terms don't make a match any more certain
+	seen := make(map[string]bool)
+	var unique []queryTerm
+	for _, term := range terms {
+		if !seen[term.text] {
+			seen[term.text] = true
+			unique = append(unique, term)
+		}
+	}
+
+	var ideal float64
+	for _, term := range unique {
+		ideal += idx.idf(term.text)
+	}
+
+	type ranked struct {
+		match domain.ScoredMatch
+		bm25  float64
+	}
+	var results []ranked
+	for i, freqs := range idx.termFreqs {
+		var bm25, confidence float64
+		norm := bm25K1 * (1 - bm25B + bm25B*float64(idx.docLengths[i])/idx.avgDocLen)
+		for _, term := range unique {
+			tf := float64(freqs[term.text])
+			if tf == 0 {
+				continue
+			}
+			idf := idx.idf(term.text)
+			contribution := idf * tf * (bm25K1 + 1) / (tf + norm)
+			capped := math.Min(contribution, idf)
+			if term.fuzzy {
+				contribution *= fuzzyWeight
+				capped *= fuzzyWeight
+			}
+			bm25 += contribution
+			confidence += capped
+		}
+		if bm25 == 0 {
+			continue
+		}
+
+		results = append(results, ranked{
+			match: domain.ScoredMatch{Entry: idx.entries[i], Score: confidence / ideal},
+			bm25:  bm25,
+		})
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].match.Score != results[j].match.Score {
+			return results[i].match.Score > results[j].match.Score
+		}
+		return results[i].bm25 > results[j].bm25
+	})
+
+	matches := make([]domain.ScoredMatch, len(results))
+	for i, result := range results {
+		matches[i] = result.match
+	}
+	return matches
+}
+
+// analyze splits text into lowercase word tokens, drops stop words and
+// reduces each token to a stem
+func analyze(text string) []string {
+	tokens := analyzeWords(text)
+	for i, word := range tokens {
+		tokens[i] = stem(word)
+	}
+	return tokens
+}
+
+// analyzeWords splits text into lowercase words without stop words
+func analyzeWords(text string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '\''
+	})
+
+	words := make([]string, 0, len(fields))
+	for _, field := range fields {
+		field = strings.Trim(field, "'")
+		field = strings.TrimSuffix(field, "'s")
+		if field == "" || rankerStopWords[field] {
+			continue
+		}
+		words = append(words, field)
+	}
+	return words
+}
+
+// stem strips common English inflections. It is deliberately light: both
+// queries and entries go through it, so it only needs to be consistent.
+func stem(word string) string {
+	if len(word) <= 3 {
+		return word
+	}
+
+	switch {
+	case strings.HasSuffix(word, "ies") && len(word) > 4:
+		word = strings.TrimSuffix(word, "ies") + "y"
+	case strings.HasSuffix(word, "sses"):
+		word = strings.TrimSuffix(word, "es")
+	case strings.HasSuffix(word, "ss"), strings.HasSuffix(word, "us"):
+		// Not a plural
+	case strings.HasSuffix(word, "s"):
+		word = strings.TrimSuffix(word, "s")
+	}
+
+	for _, suffix := range []string{"ing", "ed"} {
+		if strings.HasSuffix(word, suffix) && len(word)-len(suffix) >= 3 {
+			base := strings.TrimSuffix(word, suffix)
+			if !containsVowel(base) {
+				continue
+			}
+			// shipping -> shipp -> ship
+			if n := len(base); n >= 2 && base[n-1] == base[n-2] && !strings.ContainsRune("lsz", rune(base[n-1])) {
+				base = base[:n-1]
+			}
+			word = base
+			break
+		}
+	}
+
+	for _, suffix := range []string{"ment", "ly"} {
+		if strings.HasSuffix(word, suffix) && len(word)-len(suffix) >= 3 {
+			word = strings.TrimSuffix(word, suffix)
+			break
+		}
+	}
+
+	return word
+}
+
+func containsVowel(word string) bool {
+	return strings.ContainsAny(word, "aeiouy")
+}
@@ -0,0 +1,134 @@
+package services_test
+
+import (
+	"chat-service/internal/core/domain"
+	"chat-service/internal/core/services"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var rankingEntries = []domain.KnowledgeEntry{
+	{ID: "greeting", Question: "hello", Answer: "Hello! How can I assist you today?", Keywords: []string{"hi", "hello", "hey", "greetings"}},
+	{ID: "shipping", Question: "shipping costs", Answer: "Shipping is free on orders over $50.", Keywords: []string{"shipping", "delivery", "postage"}},
+	{ID: "payment_methods", Question: "payment methods", Answer: "We accept credit cards, PayPal, and bank transfers.", Keywords: []string{"payment", "pay", "credit card", "paypal"}},
+	{ID: "refunds", Question: "refund policy", Answer: "Refunds are issued within 14 days of receiving the returned item.", Keywords: []string{"refund", "return", "money back"}},
+}
+
+func newRankingKnowledgeBase(synonyms ...domain.Synonym) *services.KnowledgeBase {
+	return services.NewKnowledgeBase(&stubKnowledgeRepo{entries: rankingEntries, synonyms: synonyms})
+}
+
+func TestKnowledgeBaseSearch(t *testing.T) {
+	kb := newRankingKnowledgeBase()
+
+	t.Run("ranks the most relevant entry first", func(t *testing.T) {
+		matches := kb.Search("How much does shipping cost?")
+		require.NotEmpty(t, matches)
+		assert.Equal(t, "shipping", matches[0].Entry.ID)
+
+		for i, match := range matches {
+			assert.Greater(t, match.Score, 0.0)
+			assert.LessOrEqual(t, match.Score, 1.0)
+			if i > 0 {
+				assert.LessOrEqual(t, match.Score, matches[i-1].Score)
+			}
+		}
+	})
+
+	t.Run("does not match words inside other words", func(t *testing.T) {
+		matches := kb.Search("do you ship to Canada")
+		require.NotEmpty(t, matches)
+		assert.Equal(t, "shipping", matches[0].Entry.ID)
+		for _, match := range matches {
+			assert.NotEqual(t, "greeting", match.Entry.ID, "'hi' must not match inside 'ship'")
+		}
+	})
+
+	t.Run("stems inflected forms", func(t *testing.T) {
+		matches := kb.Search("I was refunded twice")
+		require.NotEmpty(t, matches)
+		assert.Equal(t, "refunds", matches[0].Entry.ID)
+	})
+
+	t.Run("returns nothing for unrelated or empty input", func(t *testing.T) {
+		assert.Empty(t, kb.Search("what is the weather like tomorrow"))
+		assert.Empty(t, kb.Search("the of and"))
+	})
+}
+
+func TestKnowledgeBaseFindBestMatch(t *testing.T) {
+	kb := newRankingKnowledgeBase()
+
+	match, ok := kb.FindBestMatch("hi")
+	require.True(t, ok)
+	assert.Equal(t, "greeting", match.Entry.ID)
+
+	match, ok = kb.FindBestMatch("can I pay with paypal")
+	require.True(t, ok)
+	assert.Equal(t, "payment_methods", match.Entry.ID)
+
+	// Mostly unrelated questions fall below the default threshold
+	_, ok = kb.FindBestMatch("my cat ate the charger cable, anything you recommend for pets")
+	assert.False(t, ok)
+
+	kb.SetMatchThreshold(1.01)
+	_, ok = kb.FindBestMatch("hi")
+	assert.False(t, ok, "nothing reaches a threshold above 1")
+}
+
+func TestKnowledgeBaseTypos(t *testing.T) {
+	kb := newRankingKnowledgeBase()
+
+	cases := map[string]string{
+		"refnd":                 "refunds",
+		"how do I get a refnud": "refunds",
+		"shiping costs":         "shipping",
+		"paymnet methods":       "payment_methods",
+		"do you take paypall":   "payment_methods",
+	}
+	for query, expected := range cases {
+		match, ok := kb.FindBestMatch(query)
+		if assert.True(t, ok, query) {
+			assert.Equal(t, expected, match.Entry.ID, query)
+		}
+	}
+
+	exact, ok := kb.FindBestMatch("refund")
+	require.True(t, ok)
+	typo, ok := kb.FindBestMatch("refnd")
+	require.True(t, ok)
+	assert.Less(t, typo.Score, exact.Score, "corrected terms count for less than exact ones")
+}
+
+func TestKnowledgeBaseSynonyms(t *testing.T) {
+	t.Run("unknown words match without synonyms", func(t *testing.T) {
+		kb := newRankingKnowledgeBase()
+		_, ok := kb.FindBestMatch("courier fee")
+		assert.False(t, ok)
+	})
+
+	t.Run("synonyms map onto the canonical term", func(t *testing.T) {
+		kb := newRankingKnowledgeBase(
+			domain.Synonym{Term: "shipping", Synonyms: []string{"courier", "freight"}},
+			domain.Synonym{Term: "cost", Synonyms: []string{"fee", "price"}},
+			domain.Synonym{Term: "refund", Synonyms: []string{"reimbursement", "my money"}},
+		)
+
+		match, ok := kb.FindBestMatch("courier fee")
+		require.True(t, ok)
+		assert.Equal(t, "shipping", match.Entry.ID)
+
+		match, ok = kb.FindBestMatch("I want my money")
+		require.True(t, ok)
+		assert.Equal(t, "refunds", match.Entry.ID)
+
+		// Typos in synonyms are corrected too
+		match, ok = kb.FindBestMatch("reimbursment")
+		require.True(t, ok)
+		assert.Equal(t, "refunds", match.Entry.ID)
+
+		assert.Len(t, kb.GetSynonyms(), 3)
+	})
+}
@@ -0,0 +1,167 @@
+package services
+
+import (
+	"chat-service/internal/core/domain"
+	"chat-service/internal/core/ports"
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+)
+
+// retrievalConfig controls semantic search over the knowledge base
+type retrievalConfig struct {
+	embedder ports.Embedder
+	topK     int
+	minScore float64
+
+	indexed bool
+	mutex   sync.Mutex
+}
+
+// ConfigureRetrieval enables semantic search using the given embedder. Only
+// matches scoring at least minScore are returned, at most topK of them.
+func (kb *KnowledgeBase) ConfigureRetrieval(embedder ports.Embedder, topK int, minScore float64) {
+	if embedder == nil {
+		log.Printf("Warning: No embedder configured, knowledge retrieval will be disabled")
+		return
+	}
+	if topK <= 0 {
+		topK = 3
+	}
+
+	kb.mutex.Lock()
+	kb.retrieval = &retrievalConfig{embedder: embedder, topK: topK, minScore: minScore}
+	kb.mutex.Unlock()
+
+	log.Printf("Knowledge retrieval enabled using %s embedder (top %d, min score %.2f)", embedder.Name(), topK, minScore)
+}
+
+func (r *retrievalConfig) isIndexed() bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.indexed
+}
+
+func (r *retrievalConfig) setIndexed(indexed bool) {
+	r.mutex.Lock()
+	r.indexed = indexed
+	r.mutex.Unlock()
+}
+
+func (kb *KnowledgeBase) currentRetrieval() *retrievalConfig {
+	kb.mutex.RLock()
+	defer kb.mutex.RUnlock()
+	return kb.retrieval
+}
+
+// IndexEntries embeds every knowledge entry and stores the vectors
+func (kb *KnowledgeBase) IndexEntries(ctx context.Context) (int, error) {
+	retrieval := kb.currentRetrieval()
+	if retrieval == nil {
+		return 0, nil
+	}
+
+	entries, err := kb.repository.GetAllEntries(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to load knowledge entries: %w", err)
+	}
+	if len(entries) == 0 {
+		// Entries added later are indexed as they are saved
+		retrieval.setIndexed(true)
+		return 0, nil
+	}
+
+	texts := make([]string, len(entries))
+	for i, entry := range entries {
+		texts[i] = embeddingText(entry)
+	}
+
+	vectors, err := retrieval.embedder.Embed(ctx, texts)
+	if err != nil {
+		return 0, fmt.Errorf("failed to embed knowledge entries: %w", err)
+	}
+
+	for i, entry := range entries {
+		if err := kb.repository.SaveEmbedding(ctx, entry.ID, vectors[i]); err != nil {
+			return i, fmt.Errorf("failed to save embedding for %s: %w", entry.ID, err)
+		}
+	}
+
+	retrieval.setIndexed(true)
+
+	log.Printf("Indexed %d knowledge entries", len(entries))
+	return len(entries), nil
+}
+
+// IndexEntry embeds a single entry, typically after it was created or edited.
+// If it can't, the whole index is rebuilt on the next retrieval.
+func (kb *KnowledgeBase) IndexEntry(ctx context.Context, entry *domain.KnowledgeEntry) error {
+	retrieval := kb.currentRetrieval()
+	if retrieval == nil {
+		return nil
+	}
+
+	vectors, err := retrieval.embedder.Embed(ctx, []string{embeddingText(*entry)})
+	if err != nil {
+		retrieval.setIndexed(false)
+		return fmt.Errorf("failed to embed knowledge entry: %w", err)
+	}
+
+	if err := kb.repository.SaveEmbedding(ctx, entry.ID, vectors[0]); err != nil {
+		retrieval.setIndexed(false)
+		return err
+	}
+	return nil
+}
+
+// Retrieve returns the knowledge entries most relevant to the query. The
+// index is built on first use so a fresh database still gets results, and
+// only once even if there is nothing to index yet.
+func (kb *KnowledgeBase) Retrieve(ctx context.Context, query string) ([]domain.ScoredMatch, error) {
+	retrieval := kb.currentRetrieval()
+	if retrieval == nil || strings.TrimSpace(query) == "" {
+		return nil, nil
+	}
+
+	if !retrieval.isIndexed() {
+		if _, err := kb.IndexEntries(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	vectors, err := retrieval.embedder.Embed(ctx, []string{query})
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed query: %w", err)
+	}
+
+	matches, err := kb.repository.SearchByEmbedding(ctx, vectors[0], retrieval.topK)
+	if err != nil {
+		return nil, err
+	}
+
+	var relevant []domain.ScoredMatch
+	for _, match := range matches {
+		if match.Score >= retrieval.minScore {
+			relevant = append(relevant, match)
+		}
+	}
+	return relevant, nil
+}
+
+// embeddingText is the text embedded for an entry
+func embeddingText(entry domain.KnowledgeEntry) string {
+	return entry.Question + "\n" + strings.Join(entry.Keywords, " ") + "\n" + entry.Answer
+}
+
+// knowledgePrompt formats retrieved entries as context for the language model
+func knowledgePrompt(matches []domain.ScoredMatch) string {
+	var prompt strings.Builder
+	prompt.WriteString("Use the following knowledge base entries to answer if they are relevant. ")
+	prompt.WriteString("If they don't cover the question, answer from general knowledge and don't mention them.\n")
+	for _, match := range matches {
+		fmt.Fprintf(&prompt, "\n[%s]\nQ: %s\nA: %s\n", match.Entry.ID, match.Entry.Question, match.Entry.Answer)
+	}
+	return prompt.String()
+}
@@ -0,0 +1,98 @@
+package services_test
+
+import (
+	"chat-service/internal/adapters/secondary/embedding"
+	"chat-service/internal/core/domain"
+	"chat-service/internal/core/services"
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingKnowledgeRepo counts how often every entry is loaded. Entries are
+// only added through add, so the default entries never land in it.
+type countingKnowledgeRepo struct {
+	stubKnowledgeRepo
+	mutex     sync.Mutex
+	loads     int
+	refreshed chan struct{}
+	once      sync.Once
+}
+
+func (r *countingKnowledgeRepo) GetAllEntries(ctx context.Context) ([]domain.KnowledgeEntry, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.loads++
+	return append([]domain.KnowledgeEntry(nil), r.entries...), nil
+}
+
+func (r *countingKnowledgeRepo) CreateEntry(ctx context.Context, entry *domain.KnowledgeEntry) error {
+	return nil
+}
+
+func (r *countingKnowledgeRepo) SaveEmbedding(ctx context.Context, entryID string, embedding []float32) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.stubKnowledgeRepo.SaveEmbedding(ctx, entryID, embedding)
+}
+
+func (r *countingKnowledgeRepo) SearchByEmbedding(ctx context.Context, embedding []float32, limit int) ([]domain.ScoredMatch, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.stubKnowledgeRepo.SearchByEmbedding(ctx, embedding, limit)
+}
+
+// GetSynonyms is the last call of the knowledge base's startup
+func (r *countingKnowledgeRepo) GetSynonyms(ctx context.Context) ([]domain.Synonym, error) {
+	r.once.Do(func() { close(r.refreshed) })
+	return nil, nil
+}
+
+func (r *countingKnowledgeRepo) add(entry domain.KnowledgeEntry) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.entries = append(r.entries, entry)
+}
+
+func (r *countingKnowledgeRepo) loaded() int {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.loads
+}
+
+func TestRetrieveIndexesOnce(t *testing.T) {
+	ctx := context.Background()
+	repo := &countingKnowledgeRepo{refreshed: make(chan struct{})}
+	kb := services.NewKnowledgeBase(repo)
+	kb.ConfigureRetrieval(embedding.NewHashingEmbedder(256), 1, 0.1)
+
+	select {
+	case <-repo.refreshed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("knowledge base did not start")
+	}
+	before := repo.loaded()
+
+	// An empty knowledge base is indexed on the first message only
+	for i := 0; i < 3; i++ {
+		matches, err := kb.Retrieve(ctx, "when will my parcel arrive")
+		require.NoError(t, err)
+		assert.Empty(t, matches)
+	}
+	assert.Equal(t, before+1, repo.loaded())
+
+	// Entries added later are indexed as they are saved
+	entry := domain.KnowledgeEntry{ID: "delivery_times", Question: "parcel delivery", Answer: "Parcels arrive within two days.", Keywords: []string{"parcel", "arrive"}}
+	repo.add(entry)
+	require.NoError(t, kb.IndexEntry(ctx, &entry))
+
+	matches, err := kb.Retrieve(ctx, "when will my parcel arrive")
+	require.NoError(t, err)
+	require.Len(t, matches, 1)
+	assert.Equal(t, "delivery_times", matches[0].Entry.ID)
+	assert.Equal(t, before+1, repo.loaded())
+}
@@ -0,0 +1,155 @@
+package services
+
+import (
+	"chat-service/internal/core/domain"
+	"chat-service/internal/core/ports"
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DefaultEditWindow is how long after sending a message customers may edit
+// or delete it
+const DefaultEditWindow = 15 * time.Minute
+
+var _ ports.MessageEditor = (*ChatServiceImpl)(nil)
+
+// SetEditWindow sets how long after sending a message customers may edit or
+// delete it. Admins are not limited.
+func (s *ChatServiceImpl) SetEditWindow(window time.Duration) {
+	s.editWindow = window
+}
+
+// EditMessage replaces the content of a message. A message with attachments
+// may be left without content.
+func (s *ChatServiceImpl) EditMessage(ctx context.Context, editor domain.Editor, messageID, content string) (*domain.Message, error) {
+	message, err := s.changeableMessage(ctx, editor, messageID)
+	if err != nil {
+		return nil, err
+	}
+
+	content = strings.TrimSpace(content)
+	if content == "" && len(message.Attachments) == 0 {
+		return nil, fmt.Errorf("%w: message content is required", domain.ErrInvalidEdit)
+	}
+	if content == message.Content {
+		s.signAttachments([]domain.Message{*message})
+		return message, nil
+	}
+
+	revision := newRevision(domain.RevisionEdit, message, editor)
+	message.Content = content
+	message.EditedAt = &revision.ChangedAt
+	return s.revise(ctx, message, revision, domain.MessageUpdatedEvent)
+}
+
+// DeleteMessage removes the content and attachments of a message. The
+// message keeps its place in the conversation, marked deleted.
+func (s *ChatServiceImpl) DeleteMessage(ctx context.Context, editor domain.Editor, messageID string) (*domain.Message, error) {
+	message, err := s.changeableMessage(ctx, editor, messageID)
+	if err != nil {
+		return nil, err
+	}
+
+	revision := newRevision(domain.RevisionDelete, message, editor)
+	message.Content = ""
+	message.Attachments = nil
+	message.DeletedAt = &revision.ChangedAt
+	return s.revise(ctx, message, revision, domain.MessageDeletedEvent)
+}
+
+// RedactMessage replaces spans of a message's content with
+// domain.RedactionMarker. The redacted text is not kept anywhere, including
+// the message's earlier revisions.
+func (s *ChatServiceImpl) RedactMessage(ctx context.Context, editor domain.Editor, messageID string, spans []domain.RedactionSpan) (*domain.Message, error) {
+	if !editor.Admin {
+		return nil, fmt.Errorf("%w: only admins may redact messages", domain.ErrEditNotAllowed)
+	}
+	message, err := s.changeableMessage(ctx, editor, messageID)
+	if err != nil {
+		return nil, err
+	}
+
+	redacted, ok := domain.Redact(message.Content, spans)
+	if !ok {
+		return nil, fmt.Errorf("%w: redaction spans must be non-empty and within the content", domain.ErrInvalidEdit)
+	}
+
+	revision := newRevision(domain.RevisionRedact, message, editor)
+	revision.Content = ""
+	revision.Spans = spans
+	revision.Redacted = true
+	message.Content = redacted
+	message.EditedAt = &revision.ChangedAt
+	return s.revise(ctx, message, revision, domain.MessageUpdatedEvent)
+}
+
+// GetMessageRevisions returns the audit trail of a message, oldest first
+func (s *ChatServiceImpl) GetMessageRevisions(ctx context.Context, messageID string) ([]domain.MessageRevision, error) {
+	if _, err := s.messageRepo.GetMessage(ctx, messageID); err != nil {
+		return nil, err
+	}
+	return s.messageRepo.GetMessageRevisions(ctx, messageID)
+}
+
+// changeableMessage loads a message the editor may change
+func (s *ChatServiceImpl) changeableMessage(ctx context.Context, editor domain.Editor, messageID string) (*domain.Message, error) {
+	message, err := s.messageRepo.GetMessage(ctx, messageID)
+	if err != nil {
+		return nil, err
+	}
+	if message.DeletedAt != nil {
+		return nil, fmt.Errorf("%w: message was deleted", domain.ErrInvalidEdit)
+	}
+	if editor.Admin {
+		return message, nil
+	}
+
+	if message.Type != domain.UserMessage || message.UserID != editor.UserID {
+		return nil, domain.ErrEditNotAllowed
+	}
+	if time.Since(message.Timestamp) > s.editWindow {
+		return nil, domain.ErrEditWindowClosed
+	}
+	return message, nil
+}
+
+// newRevision keeps the version of a message about to be changed
+func newRevision(action string, message *domain.Message, editor domain.Editor) *domain.MessageRevision {
+	return &domain.MessageRevision{
+		ID:          uuid.New().String(),
+		MessageID:   message.ID,
+		Action:      action,
+		Content:     message.Content,
+		Attachments: message.Attachments,
+		ChangedBy:   editor.UserID,
+		ChangedAt:   time.Now(),
+	}
+}
+
+// revise stores a changed message with the revision it replaces and
+// announces the change to the conversation
+func (s *ChatServiceImpl) revise(ctx context.Context, message *domain.Message, revision *domain.MessageRevision, eventType string) (*domain.Message, error) {
+	if err := s.messageRepo.ReviseMessage(ctx, message, revision); err != nil {
+		return nil, err
+	}
+	s.signAttachments([]domain.Message{*message})
+
+	if s.events != nil {
+		err := s.events.PublishConversationEvent(&domain.ConversationEvent{
+			Type:           eventType,
+			ConversationID: message.Metadata["conversation_id"],
+			CustomerID:     message.CustomerID,
+			Message:        message,
+			OccurredAt:     revision.ChangedAt,
+		})
+		if err != nil {
+			log.Printf("Error publishing %s for message %s: %v", eventType, message.ID, err)
+		}
+	}
+	return message, nil
+}
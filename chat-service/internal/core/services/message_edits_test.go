@@ -0,0 +1,191 @@
+package services_test
+
+import (
+	"chat-service/internal/core/domain"
+	"chat-service/internal/core/services"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// editableRepo serves a copy of one message on every read and records the
+// revisions stored
+type editableRepo struct {
+	*MockMessageRepo
+	message   domain.Message
+	revisions []domain.MessageRevision
+}
+
+func (r *editableRepo) GetMessage(ctx context.Context, id string) (*domain.Message, error) {
+	if id != r.message.ID {
+		return nil, domain.ErrMessageNotFound
+	}
+	stored := r.message
+	stored.Metadata = map[string]string{"conversation_id": "conv1"}
+	return &stored, nil
+}
+
+func (r *editableRepo) ReviseMessage(ctx context.Context, message *domain.Message, revision *domain.MessageRevision) error {
+	r.revisions = append(r.revisions, *revision)
+	return nil
+}
+
+func newEditableChat(message domain.Message) (*services.ChatServiceImpl, *recordingEvents, *[]domain.MessageRevision) {
+	repo := &editableRepo{MockMessageRepo: new(MockMessageRepo), message: message}
+	publisher := new(MockMessagePublisher)
+	chat := services.NewChatService(repo, newMemoryConversations(), publisher)
+	events := &recordingEvents{}
+	chat.SetEventPublisher(events)
+	return chat, events, &repo.revisions
+}
+
+// The customer who sent the message and an admin
+var (
+	sender = domain.Editor{UserID: "user1"}
+	admin  = domain.Editor{UserID: "admin1", Admin: true}
+)
+
+func sentMessage(ago time.Duration) domain.Message {
+	return domain.Message{
+		ID:         "msg1",
+		Content:    "I ordered the wrong szie",
+		UserID:     "user1",
+		CustomerID: "customer1",
+		Type:       domain.UserMessage,
+		Timestamp:  time.Now().Add(-ago),
+	}
+}
+
+func TestEditMessage(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("customers edit their messages and the old version is kept", func(t *testing.T) {
+		chat, events, revisions := newEditableChat(sentMessage(time.Minute))
+
+		edited, err := chat.EditMessage(ctx, sender, "msg1", " I ordered the wrong size ")
+		require.NoError(t, err)
+		assert.Equal(t, "I ordered the wrong size", edited.Content)
+		require.NotNil(t, edited.EditedAt)
+
+		require.Len(t, *revisions, 1)
+		assert.Equal(t, domain.RevisionEdit, (*revisions)[0].Action)
+		assert.Equal(t, "I ordered the wrong szie", (*revisions)[0].Content)
+		assert.Equal(t, "user1", (*revisions)[0].ChangedBy)
+
+		require.Len(t, events.events, 1)
+		assert.Equal(t, domain.MessageUpdatedEvent, events.events[0].Type)
+		assert.Equal(t, "conv1", events.events[0].ConversationID)
+		assert.Equal(t, "customer1", events.events[0].CustomerID)
+		assert.Equal(t, edited.Content, events.events[0].Message.Content)
+	})
+
+	t.Run("customers only edit their own recent messages", func(t *testing.T) {
+		chat, _, revisions := newEditableChat(sentMessage(time.Hour))
+
+		_, err := chat.EditMessage(ctx, domain.Editor{UserID: "someone"}, "msg1", "Hijacked")
+		assert.ErrorIs(t, err, domain.ErrEditNotAllowed)
+		_, err = chat.EditMessage(ctx, sender, "msg1", "Too late")
+		assert.ErrorIs(t, err, domain.ErrEditWindowClosed)
+		assert.Empty(t, *revisions)
+
+		chat.SetEditWindow(2 * time.Hour)
+		_, err = chat.EditMessage(ctx, sender, "msg1", "Just in time")
+		assert.NoError(t, err)
+	})
+
+	t.Run("admins edit any message", func(t *testing.T) {
+		message := sentMessage(24 * time.Hour)
+		message.Type = domain.BotMessage
+		message.UserID = "bot"
+		chat, _, revisions := newEditableChat(message)
+
+		_, err := chat.EditMessage(ctx, admin, "msg1", "Corrected answer")
+		require.NoError(t, err)
+		assert.Equal(t, "admin1", (*revisions)[0].ChangedBy)
+	})
+
+	t.Run("rejects empty content and unknown messages", func(t *testing.T) {
+		chat, _, _ := newEditableChat(sentMessage(time.Minute))
+
+		_, err := chat.EditMessage(ctx, sender, "msg1", "  ")
+		assert.ErrorIs(t, err, domain.ErrInvalidEdit)
+		_, err = chat.EditMessage(ctx, sender, "missing", "Hello")
+		assert.ErrorIs(t, err, domain.ErrMessageNotFound)
+	})
+}
+
+func TestDeleteMessage(t *testing.T) {
+	ctx := context.Background()
+	message := sentMessage(time.Minute)
+	message.Attachments = []domain.Attachment{{ID: "upload1", FileName: "card.png"}}
+	chat, events, revisions := newEditableChat(message)
+
+	deleted, err := chat.DeleteMessage(ctx, sender, "msg1")
+	require.NoError(t, err)
+	assert.Empty(t, deleted.Content)
+	assert.Empty(t, deleted.Attachments)
+	require.NotNil(t, deleted.DeletedAt)
+
+	require.Len(t, *revisions, 1)
+	assert.Equal(t, domain.RevisionDelete, (*revisions)[0].Action)
+	assert.Equal(t, message.Content, (*revisions)[0].Content)
+	assert.Equal(t, message.Attachments, (*revisions)[0].Attachments)
+	require.Len(t, events.events, 1)
+	assert.Equal(t, domain.MessageDeletedEvent, events.events[0].Type)
+
+	t.Run("deleted messages stay deleted", func(t *testing.T) {
+		message.DeletedAt = deleted.DeletedAt
+		chat, _, _ := newEditableChat(message)
+
+		_, err := chat.EditMessage(ctx, admin, "msg1", "Back again")
+		assert.ErrorIs(t, err, domain.ErrInvalidEdit)
+		_, err = chat.DeleteMessage(ctx, admin, "msg1")
+		assert.ErrorIs(t, err, domain.ErrInvalidEdit)
+	})
+}
+
+func TestRedactMessage(t *testing.T) {
+	ctx := context.Background()
+	message := sentMessage(48 * time.Hour)
+	message.Content = "Card 4111 1111 1111 1111, CVC 123, café"
+
+	t.Run("only admins redact", func(t *testing.T) {
+		chat, _, revisions := newEditableChat(message)
+		_, err := chat.RedactMessage(ctx, sender, "msg1", []domain.RedactionSpan{{Start: 5, End: 24}})
+		assert.ErrorIs(t, err, domain.ErrEditNotAllowed)
+		assert.Empty(t, *revisions)
+	})
+
+	t.Run("replaces the spans and keeps none of their text", func(t *testing.T) {
+		chat, events, revisions := newEditableChat(message)
+		spans := []domain.RedactionSpan{{Start: 30, End: 33}, {Start: 5, End: 15}, {Start: 10, End: 24}}
+
+		redacted, err := chat.RedactMessage(ctx, admin, "msg1", spans)
+		require.NoError(t, err)
+		assert.Equal(t, "Card [redacted], CVC [redacted], café", redacted.Content)
+
+		require.Len(t, *revisions, 1)
+		assert.Equal(t, domain.RevisionRedact, (*revisions)[0].Action)
+		assert.Empty(t, (*revisions)[0].Content)
+		assert.True(t, (*revisions)[0].Redacted)
+		assert.Equal(t, spans, (*revisions)[0].Spans)
+		require.Len(t, events.events, 1)
+		assert.Equal(t, domain.MessageUpdatedEvent, events.events[0].Type)
+	})
+
+	t.Run("spans are counted in characters and must fit the content", func(t *testing.T) {
+		chat, _, _ := newEditableChat(message)
+
+		redacted, err := chat.RedactMessage(ctx, admin, "msg1", []domain.RedactionSpan{{Start: 35, End: 39}})
+		require.NoError(t, err)
+		assert.Equal(t, "Card 4111 1111 1111 1111, CVC 123, [redacted]", redacted.Content)
+
+		for _, spans := range [][]domain.RedactionSpan{nil, {{Start: 35, End: 40}}, {{Start: 3, End: 3}}, {{Start: -1, End: 2}}} {
+			_, err := chat.RedactMessage(ctx, admin, "msg1", spans)
+			assert.ErrorIs(t, err, domain.ErrInvalidEdit)
+		}
+	})
+}
@@ -0,0 +1,53 @@
+package services
+
+import (
+	"chat-service/internal/core/domain"
+	"chat-service/internal/core/ports"
+	"context"
+	"fmt"
+	"strings"
+)
+
+var _ ports.MessageSearch = (*ChatServiceImpl)(nil)
+
+// SearchMessages runs a full-text search over the messages of every
+// conversation
+func (s *ChatServiceImpl) SearchMessages(ctx context.Context, search domain.MessageSearch) (*domain.MessageSearchResults, error) {
+	search.Query = strings.TrimSpace(search.Query)
+	if search.Query == "" {
+		return nil, fmt.Errorf("%w: a query is required", domain.ErrInvalidSearch)
+	}
+
+	switch search.Type {
+	case "", domain.UserMessage, domain.BotMessage, domain.AgentMessage, domain.SystemMessage:
+	default:
+		return nil, fmt.Errorf("%w: unknown message type %q", domain.ErrInvalidSearch, search.Type)
+	}
+	switch search.ConversationStatus {
+	case "", "active", "closed":
+	default:
+		return nil, fmt.Errorf("%w: conversation status must be active or closed", domain.ErrInvalidSearch)
+	}
+	if !search.From.IsZero() && !search.To.IsZero() && !search.From.Before(search.To) {
+		return nil, fmt.Errorf("%w: from must be before to", domain.ErrInvalidSearch)
+	}
+	if search.Offset < 0 {
+		return nil, fmt.Errorf("%w: offset must not be negative", domain.ErrInvalidSearch)
+	}
+
+	switch {
+	case search.Limit <= 0:
+		search.Limit = domain.DefaultSearchLimit
+	case search.Limit > domain.MaxSearchLimit:
+		search.Limit = domain.MaxSearchLimit
+	}
+
+	results, err := s.messageRepo.SearchMessages(ctx, search)
+	if err != nil {
+		return nil, err
+	}
+	for i := range results.Hits {
+		s.signAttachments([]domain.Message{results.Hits[i].Message})
+	}
+	return results, nil
+}
@@ -0,0 +1,46 @@
+package services_test
+
+import (
+	"chat-service/internal/core/domain"
+	"chat-service/internal/core/services"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSearchMessages(t *testing.T) {
+	ctx := context.Background()
+	repo := new(MockMessageRepo)
+	chat := services.NewChatService(repo, newMemoryConversations(), new(MockMessagePublisher))
+
+	t.Run("clamps the page size", func(t *testing.T) {
+		for limit, want := range map[int]int{0: domain.DefaultSearchLimit, 500: domain.MaxSearchLimit, 7: 7} {
+			repo.On("SearchMessages", mock.Anything, mock.MatchedBy(func(search domain.MessageSearch) bool {
+				return search.Limit == want && search.Query == "double charge"
+			})).Return(&domain.MessageSearchResults{Hits: []domain.MessageSearchHit{}}, nil).Once()
+
+			_, err := chat.SearchMessages(ctx, domain.MessageSearch{Query: " double charge ", Limit: limit})
+			require.NoError(t, err, limit)
+		}
+		repo.AssertExpectations(t)
+	})
+
+	t.Run("rejects bad filters", func(t *testing.T) {
+		now := time.Now()
+		searches := map[string]domain.MessageSearch{
+			"no query":       {Query: "  "},
+			"unknown type":   {Query: "refund", Type: "robot"},
+			"unknown status": {Query: "refund", ConversationStatus: "archived"},
+			"empty range":    {Query: "refund", From: now, To: now},
+			"negative page":  {Query: "refund", Offset: -1},
+		}
+		for name, search := range searches {
+			_, err := chat.SearchMessages(ctx, search)
+			assert.ErrorIs(t, err, domain.ErrInvalidSearch, name)
+		}
+	})
+}
@@ -0,0 +1,255 @@
+package services
+
+import (
+	"chat-service/internal/core/domain"
+	"chat-service/internal/core/ports"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// PIIRedactor finds personal data in what customers write: email addresses,
+// phone numbers, card numbers that pass the Luhn check, IBANs with a valid
+// checksum and any custom patterns. It hides the data from the logs and,
+// through sessions, from the language model.
+type PIIRedactor struct {
+	detectors []piiDetector
+	// Kinds put back in replies as written; the rest are masked
+	restored map[string]bool
+}
+
+var _ ports.TextRedactor = (*PIIRedactor)(nil)
+
+// piiDetector finds one kind of personal data. Candidates found by the
+// pattern are passed to accept, which returns the length of the data at the
+// start of the candidate, or false if it holds none.
+type piiDetector struct {
+	kind    string
+	pattern *regexp.Regexp
+	accept  func(candidate string) (int, bool)
+}
+
+// piiMatch is personal data found in a text, at [start, end)
+type piiMatch struct {
+	kind       string
+	start, end int
+}
+
+var (
+	piiEmailPattern = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9-]+(?:\.[A-Za-z0-9-]+)*\.[A-Za-z]{2,}`)
+	piiIBANPattern  = regexp.MustCompile(`\b[A-Za-z]{2}\d{2}(?: ?[A-Za-z0-9]){10,32}\b`)
+	piiCardPattern  = regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`)
+	piiPhonePattern = regexp.MustCompile(`(?:\+\d|\(\d|\b\d)[\d ().-]{7,}\d\b`)
+	// Dates look like phone numbers to piiPhonePattern
+	isoDatePattern = regexp.MustCompile(`\d{4}-\d{2}-\d{2}`)
+)
+
+// NewPIIRedactor creates a redactor for the built-in kinds of personal data,
+// putting emails and phone numbers back in replies
+func NewPIIRedactor() *PIIRedactor {
+	return &PIIRedactor{
+		detectors: []piiDetector{
+			{kind: domain.PIIEmail, pattern: piiEmailPattern, accept: acceptAll},
+			{kind: domain.PIIIBAN, pattern: piiIBANPattern, accept: acceptIBAN},
+			{kind: domain.PIICard, pattern: piiCardPattern, accept: acceptCard},
+			{kind: domain.PIIPhone, pattern: piiPhonePattern, accept: acceptPhone},
+		},
+		restored: map[string]bool{domain.PIIEmail: true, domain.PIIPhone: true},
+	}
+}
+
+// AddPattern finds a custom kind of personal data. Custom patterns are
+// matched before the built-in kinds.
+func (r *PIIRedactor) AddPattern(pattern domain.PIIPattern) error {
+	kind := strings.ToLower(strings.TrimSpace(pattern.Kind))
+	if kind == "" {
+		return fmt.Errorf("pattern %q has no kind", pattern.Pattern)
+	}
+	compiled, err := regexp.Compile(pattern.Pattern)
+	if err != nil {
+		return fmt.Errorf("invalid pattern for %s: %w", kind, err)
+	}
+
+	custom := 0
+	for custom < len(r.detectors) && !isBuiltInPII(r.detectors[custom].kind) {
+		custom++
+	}
+	detector := piiDetector{kind: kind, pattern: compiled, accept: acceptAll}
+	r.detectors = append(r.detectors[:custom], append([]piiDetector{detector}, r.detectors[custom:]...)...)
+	return nil
+}
+
+// SetRestoredKinds sets the kinds of personal data put back in replies as
+// the customer wrote them. Card numbers and IBANs left out are shown with
+// their last four characters, other kinds as domain.RedactionMarker.
+func (r *PIIRedactor) SetRestoredKinds(kinds []string) {
+	r.restored = make(map[string]bool, len(kinds))
+	for _, kind := range kinds {
+		r.restored[strings.ToLower(kind)] = true
+	}
+}
+
+// Redact replaces the personal data in the text with markers naming its
+// kind, such as [EMAIL], for text that is logged or kept
+func (r *PIIRedactor) Redact(text string) string {
+	matches := r.find(text)
+	if len(matches) == 0 {
+		return text
+	}
+
+	var redacted strings.Builder
+	next := 0
+	for _, match := range matches {
+		redacted.WriteString(text[next:match.start])
+		redacted.WriteString("[" + piiLabel(match.kind) + "]")
+		next = match.end
+	}
+	redacted.WriteString(text[next:])
+	return redacted.String()
+}
+
+// find returns the personal data in the text in order. Where matches
+// overlap, the detector that comes first wins.
+func (r *PIIRedactor) find(text string) []piiMatch {
+	var matches []piiMatch
+	for _, detector := range r.detectors {
+		for _, loc := range detector.pattern.FindAllStringIndex(text, -1) {
+			length, ok := detector.accept(text[loc[0]:loc[1]])
+			if !ok || length == 0 {
+				continue
+			}
+			match := piiMatch{kind: detector.kind, start: loc[0], end: loc[0] + length}
+			overlaps := false
+			for _, found := range matches {
+				if match.start < found.end && found.start < match.end {
+					overlaps = true
+					break
+				}
+			}
+			if !overlaps {
+				matches = append(matches, match)
+			}
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool { return matches[i].start < matches[j].start })
+	return matches
+}
+
+func isBuiltInPII(kind string) bool {
+	switch kind {
+	case domain.PIIEmail, domain.PIIIBAN, domain.PIICard, domain.PIIPhone:
+		return true
+	}
+	return false
+}
+
+// piiLabel is the upper case name of a kind used in placeholders
+func piiLabel(kind string) string {
+	return strings.Map(func(r rune) rune {
+		if r > unicode.MaxASCII || !(unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			return '_'
+		}
+		return unicode.ToUpper(r)
+	}, kind)
+}
+
+func acceptAll(candidate string) (int, bool) {
+	return len(candidate), true
+}
+
+// acceptCard takes 13 to 19 digits that pass the Luhn check
+func acceptCard(candidate string) (int, bool) {
+	digits := keepDigits(candidate)
+	if len(digits) < 13 || len(digits) > 19 {
+		return 0, false
+	}
+
+	sum := 0
+	for i := range digits {
+		digit := int(digits[len(digits)-1-i] - '0')
+		if i%2 == 1 {
+			digit *= 2
+			if digit > 9 {
+				digit -= 9
+			}
+		}
+		sum += digit
+	}
+	return len(candidate), sum%10 == 0
+}
+
+// acceptPhone takes 9 to 15 digits that are not part of a date
+func acceptPhone(candidate string) (int, bool) {
+	digits := keepDigits(candidate)
+	if len(digits) < 9 || len(digits) > 15 || isoDatePattern.MatchString(candidate) {
+		return 0, false
+	}
+	return len(candidate), true
+}
+
+// ibanLengths is the length of the IBANs of each country that has them
+var ibanLengths = map[string]int{
+	"AD": 24, "AE": 23, "AL": 28, "AT": 20, "AZ": 28, "BA": 20, "BE": 16, "BG": 22,
+	"BH": 22, "BR": 29, "BY": 28, "CH": 21, "CR": 22, "CY": 28, "CZ": 24, "DE": 22,
+	"DK": 18, "DO": 28, "EE": 20, "EG": 29, "ES": 24, "FI": 18, "FO": 18, "FR": 27,
+	"GB": 22, "GE": 22, "GI": 23, "GL": 18, "GR": 27, "GT": 28, "HR": 21, "HU": 28,
+	"IE": 22, "IL": 23, "IQ": 23, "IS": 26, "IT": 27, "JO": 30, "KW": 30, "KZ": 20,
+	"LB": 28, "LC": 32, "LI": 21, "LT": 20, "LU": 20, "LV": 21, "MC": 27, "MD": 24,
+	"ME": 22, "MK": 19, "MR": 27, "MT": 31, "MU": 30, "NL": 18, "NO": 15, "PK": 24,
+	"PL": 28, "PS": 29, "PT": 25, "QA": 29, "RO": 24, "RS": 22, "SA": 24, "SC": 31,
+	"SE": 24, "SI": 19, "SK": 24, "SM": 27, "ST": 25, "SV": 28, "TL": 23, "TN": 24,
+	"TR": 26, "UA": 29, "VA": 22, "VG": 24, "XK": 20,
+}
+
+// acceptIBAN takes as many characters as an IBAN of the candidate's country
+// has, if they pass the mod 97 check. The pattern may run into the words
+// that follow, which are left out.
+func acceptIBAN(candidate string) (int, bool) {
+	length, ok := ibanLengths[strings.ToUpper(candidate[:2])]
+	if !ok {
+		return 0, false
+	}
+
+	var iban strings.Builder
+	end := 0
+	for i, r := range candidate {
+		if r == ' ' {
+			continue
+		}
+		iban.WriteRune(unicode.ToUpper(r))
+		if iban.Len() == length {
+			end = i + 1
+			break
+		}
+	}
+	if end == 0 {
+		return 0, false
+	}
+
+	// Move the country and check digits to the end, read letters as 10 to
+	// 35 and check the number leaves 1 divided by 97
+	compact := iban.String()
+	remainder := 0
+	for _, r := range compact[4:] + compact[:4] {
+		value := int(r - '0')
+		if r >= 'A' && r <= 'Z' {
+			value = int(r-'A') + 10
+			remainder = remainder * 100 % 97
+		} else {
+			remainder = remainder * 10 % 97
+		}
+		remainder = (remainder + value) % 97
+	}
+	return end, remainder == 1
+}
+
+func keepDigits(text string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, text)
+}
@@ -0,0 +1,84 @@
+package services_test
+
+import (
+	"chat-service/internal/core/domain"
+	"chat-service/internal/core/services"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPIIRedactor(t *testing.T) {
+	t.Run("hides the built-in kinds", func(t *testing.T) {
+		redactor := services.NewPIIRedactor()
+		cases := map[string]string{
+			"Mail me at Ada.Lovelace+orders@example.co.uk today": "Mail me at [EMAIL] today",
+			"Card 4111 1111 1111 1111 was charged":               "Card [CARD] was charged",
+			"Card 4111-1111-1111-1112 fails the Luhn check":      "Card 4111-1111-1111-1112 fails the Luhn check",
+			"Pay GB82 WEST 1234 5698 7654 32 please":             "Pay [IBAN] please",
+			"Pay DE89370400440532013000":                         "Pay [IBAN]",
+			"Call +44 20 7946 0958 or (555) 123-4567":            "Call [PHONE] or [PHONE]",
+			"Ordered 2024-03-01 10:30, order 12345":              "Ordered 2024-03-01 10:30, order 12345",
+			"Nothing personal here":                              "Nothing personal here",
+		}
+		for text, want := range cases {
+			assert.Equal(t, want, redactor.Redact(text), text)
+		}
+	})
+
+	t.Run("custom patterns come before the built-in kinds", func(t *testing.T) {
+		redactor := services.NewPIIRedactor()
+		require.NoError(t, redactor.AddPattern(domain.PIIPattern{Kind: "Customer number", Pattern: `CUST-\d{9}`}))
+		// The digits alone would pass for a phone number
+		assert.Equal(t, "I am [CUSTOMER_NUMBER], mail [EMAIL]", redactor.Redact("I am CUST-123456789, mail ada@example.com"))
+
+		assert.Error(t, redactor.AddPattern(domain.PIIPattern{Kind: "broken", Pattern: `(`}))
+		assert.Error(t, redactor.AddPattern(domain.PIIPattern{Pattern: `\d+`}))
+	})
+}
+
+func TestBotAgentRedaction(t *testing.T) {
+	const reply = "I have refunded card [CARD_1] and sent a receipt to [EMAIL_1]. Reference [EMAIL_9]."
+
+	repo := new(MockMessageRepo)
+	publisher := new(MockMessagePublisher)
+	// Small chunks split the placeholders across deltas
+	provider := &streamingLLM{reply: reply, chunk: 4}
+	bot, hub := newTestBotAgent(repo, publisher, provider)
+
+	var saved *domain.Message
+	repo.On("SaveMessage", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		saved = args.Get(1).(*domain.Message)
+	}).Return(nil).Once()
+	publisher.On("PublishChatMessage", mock.Anything).Return(nil).Once()
+
+	err := bot.ProcessMessage(context.Background(), &domain.Message{
+		Content:    "Refund 4111 1111 1111 1111 and mail ada@example.com, yes ADA@example.com",
+		CustomerID: "customer1",
+		Type:       domain.UserMessage,
+	})
+	require.NoError(t, err)
+
+	// The model only sees placeholders, numbered by value
+	require.NotEmpty(t, provider.received)
+	assert.Contains(t, provider.received[0].Content, "placeholders")
+	latest := provider.received[len(provider.received)-1]
+	assert.Equal(t, "Refund [CARD_1] and mail [EMAIL_1], yes [EMAIL_1]", latest.Content)
+
+	// Emails are put back as written, cards masked, unknown placeholders left
+	want := "I have refunded card ****1111 and sent a receipt to ada@example.com. Reference [EMAIL_9]."
+	require.NotNil(t, saved)
+	assert.Equal(t, want, saved.Content)
+	assert.Equal(t, "card:1,email:1", saved.Metadata[domain.PIIRedactedKey])
+
+	var streamed strings.Builder
+	for _, chunk := range hub.chunks {
+		assert.NotContains(t, chunk.Content, "[CARD_1")
+		streamed.WriteString(chunk.Content)
+	}
+	assert.Equal(t, want, streamed.String())
+}
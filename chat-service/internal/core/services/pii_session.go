@@ -0,0 +1,191 @@
+package services
+
+import (
+	"chat-service/internal/core/domain"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// piiSession hides personal data from one request to the language model.
+// Each value gets a numbered placeholder such as [EMAIL_1], the same one
+// wherever it appears, so the model can refer to it and the reply can have
+// it put back.
+type piiSession struct {
+	redactor     *PIIRedactor
+	placeholders map[string]string
+	values       map[string]piiValue
+	counts       map[string]int
+}
+
+// piiValue is personal data as the customer wrote it
+type piiValue struct {
+	kind  string
+	value string
+}
+
+var (
+	placeholderPattern = regexp.MustCompile(`\[[A-Z0-9_]+_\d+\]`)
+	// What a placeholder split across streamed deltas starts with
+	partialPlaceholderPattern = regexp.MustCompile(`\[[A-Z0-9_]*$`)
+)
+
+// maxPlaceholderLength bounds the text held back while streaming in case it
+// is the start of a placeholder
+const maxPlaceholderLength = 48
+
+// piiPrompt tells the model what the placeholders are
+const piiPrompt = " Personal details the customer gave are replaced with placeholders such as [EMAIL_1]. " +
+	"Use a placeholder exactly as written when you need to refer to that detail, and never ask for it to be repeated."
+
+func (r *PIIRedactor) newSession() *piiSession {
+	return &piiSession{
+		redactor:     r,
+		placeholders: make(map[string]string),
+		values:       make(map[string]piiValue),
+		counts:       make(map[string]int),
+	}
+}
+
+// redact replaces the personal data in the text with placeholders
+func (s *piiSession) redact(text string) string {
+	matches := s.redactor.find(text)
+	if len(matches) == 0 {
+		return text
+	}
+
+	var redacted strings.Builder
+	next := 0
+	for _, match := range matches {
+		redacted.WriteString(text[next:match.start])
+		redacted.WriteString(s.placeholder(match.kind, text[match.start:match.end]))
+		next = match.end
+	}
+	redacted.WriteString(text[next:])
+	return redacted.String()
+}
+
+// redactConversation returns a copy of the conversation with every turn
+// redacted
+func (s *piiSession) redactConversation(conversation []domain.LLMMessage) []domain.LLMMessage {
+	redacted := make([]domain.LLMMessage, len(conversation))
+	for i, turn := range conversation {
+		turn.Content = s.redact(turn.Content)
+		redacted[i] = turn
+	}
+	return redacted
+}
+
+// placeholder returns the placeholder of a value, numbering new values by
+// kind. Values written differently, such as a card number with and without
+// spaces, share a placeholder.
+func (s *piiSession) placeholder(kind, value string) string {
+	key := kind + ":" + normalizePII(kind, value)
+	if placeholder, ok := s.placeholders[key]; ok {
+		return placeholder
+	}
+
+	s.counts[kind]++
+	placeholder := fmt.Sprintf("[%s_%d]", piiLabel(kind), s.counts[kind])
+	s.placeholders[key] = placeholder
+	s.values[placeholder] = piiValue{kind: kind, value: value}
+	return placeholder
+}
+
+// restore puts the session's values back in place of their placeholders:
+// as written for the kinds the redactor restores, masked for the rest.
+// Placeholders the session did not give out are left alone.
+func (s *piiSession) restore(text string) string {
+	if len(s.values) == 0 {
+		return text
+	}
+	return placeholderPattern.ReplaceAllStringFunc(text, func(placeholder string) string {
+		found, ok := s.values[placeholder]
+		if !ok {
+			return placeholder
+		}
+		if s.redactor.restored[found.kind] {
+			return found.value
+		}
+		return maskPII(found)
+	})
+}
+
+// found reports whether any personal data was hidden
+func (s *piiSession) found() bool {
+	return len(s.values) > 0
+}
+
+// summary lists the kinds of personal data hidden with how many values of
+// each, for domain.PIIRedactedKey
+func (s *piiSession) summary() string {
+	kinds := make([]string, 0, len(s.counts))
+	for kind, count := range s.counts {
+		kinds = append(kinds, fmt.Sprintf("%s:%d", kind, count))
+	}
+	sort.Strings(kinds)
+	return strings.Join(kinds, ",")
+}
+
+// restoreStream relays streamed text with the session's placeholders
+// restored, holding back what may be the start of a placeholder until the
+// next delta shows whether it is one. Call flush once the stream ends.
+func (s *piiSession) restoreStream(onDelta func(string) error) *restoringStream {
+	return &restoringStream{session: s, onDelta: onDelta}
+}
+
+type restoringStream struct {
+	session *piiSession
+	onDelta func(string) error
+	pending string
+}
+
+func (r *restoringStream) send(delta string) error {
+	r.pending += delta
+	ready := r.pending
+	if loc := partialPlaceholderPattern.FindStringIndex(r.pending); loc != nil && loc[1]-loc[0] < maxPlaceholderLength {
+		ready = r.pending[:loc[0]]
+	}
+	r.pending = r.pending[len(ready):]
+	if ready == "" {
+		return nil
+	}
+	return r.onDelta(r.session.restore(ready))
+}
+
+func (r *restoringStream) flush() error {
+	if r.pending == "" {
+		return nil
+	}
+	rest := r.pending
+	r.pending = ""
+	return r.onDelta(r.session.restore(rest))
+}
+
+// normalizePII is the form of a value that tells whether two values are the
+// same
+func normalizePII(kind, value string) string {
+	switch kind {
+	case domain.PIICard, domain.PIIPhone:
+		return keepDigits(value)
+	case domain.PIIIBAN:
+		return strings.ToUpper(strings.ReplaceAll(value, " ", ""))
+	case domain.PIIEmail:
+		return strings.ToLower(value)
+	default:
+		return value
+	}
+}
+
+// maskPII shows only the last four characters of card numbers and IBANs,
+// and nothing of other kinds
+func maskPII(found piiValue) string {
+	switch found.kind {
+	case domain.PIICard, domain.PIIIBAN:
+		compact := normalizePII(found.kind, found.value)
+		return "****" + compact[len(compact)-4:]
+	default:
+		return domain.RedactionMarker
+	}
+}
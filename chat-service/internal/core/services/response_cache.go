@@ -0,0 +1,109 @@
+package services
+
+import (
+	"chat-service/internal/cache"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Response cache defaults used when the bot is created
+const (
+	DefaultResponseCacheSize = 1000
+	DefaultResponseCacheTTL  = 10 * time.Minute
+)
+
+// Cache tags for invalidation
+const (
+	knowledgeTag = "knowledge"
+	entryTag     = "entry:"
+)
+
+// replySource is where a bot reply came from, deciding how it may be cached
+type replySource int
+
+const (
+	replyUncached replySource = iota
+	replyFromKnowledge
+)
+
+// ResponseCache remembers bot replies. Knowledge base answers only depend on
+// the question, so everyone asking it shares them until the knowledge base
+// changes. AI answers depend on the conversation's history and summary, so
+// they are never cached; a follow-up like "yes" means something else every
+// time.
+type ResponseCache struct {
+	replies *cache.Cache[botReply]
+}
+
+// NewResponseCache creates a response cache holding at most size replies
+// for ttl each
+func NewResponseCache(size int, ttl time.Duration) *ResponseCache {
+	return &ResponseCache{replies: cache.New[botReply](size, ttl)}
+}
+
+// InvalidateEntry forgets the answers taken from a knowledge entry
+func (c *ResponseCache) InvalidateEntry(entryID string) int {
+	return c.replies.Invalidate(entryTag + entryID)
+}
+
+// InvalidateKnowledge forgets every knowledge base answer. Adding or changing
+// an entry can change which entry best matches any question.
+func (c *ResponseCache) InvalidateKnowledge() int {
+	return c.replies.Invalidate(knowledgeTag)
+}
+
+// Purge forgets every reply
+func (c *ResponseCache) Purge() {
+	c.replies.Purge()
+}
+
+// Stats returns the cache's size and hit counters
+func (c *ResponseCache) Stats() cache.Stats {
+	return c.replies.Stats()
+}
+
+// lookup finds a shared answer to the question
+func (c *ResponseCache) lookup(question string) (botReply, bool) {
+	normalized := normalizeQuestion(question)
+	if normalized == "" {
+		return botReply{}, false
+	}
+	return c.replies.Get(knowledgeKey(normalized))
+}
+
+// store caches the reply if it came from the knowledge base
+func (c *ResponseCache) store(question string, reply botReply) {
+	normalized := normalizeQuestion(question)
+	if normalized == "" || reply.source != replyFromKnowledge {
+		return
+	}
+	c.replies.Set(knowledgeKey(normalized), reply, knowledgeTags(reply)...)
+}
+
+// knowledgeTags tags a reply with the knowledge entries it was taken from
+func knowledgeTags(reply botReply) []string {
+	var tags []string
+	for _, id := range strings.Split(reply.Metadata["kb_entries"], ",") {
+		if id != "" {
+			tags = append(tags, entryTag+id)
+		}
+	}
+	if len(tags) == 0 {
+		return nil
+	}
+	return append([]string{knowledgeTag}, tags...)
+}
+
+func knowledgeKey(question string) string {
+	return "kb:" + question
+}
+
+// normalizeQuestion lowercases the question and drops punctuation and extra
+// whitespace so trivially different phrasings share a cache entry
+func normalizeQuestion(question string) string {
+	words := strings.FieldsFunc(strings.ToLower(question), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '\''
+	})
+	return strings.Join(words, " ")
+}
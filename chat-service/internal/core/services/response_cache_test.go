@@ -0,0 +1,96 @@
+package services_test
+
+import (
+	"chat-service/internal/adapters/secondary/embedding"
+	"chat-service/internal/core/domain"
+	"chat-service/internal/core/services"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func newCachingBot(provider *streamingLLM) (*services.BotAgent, *recordingHub, *services.ResponseCache) {
+	repo := new(MockMessageRepo)
+	repo.On("SaveMessage", mock.Anything, mock.Anything).Return(nil)
+	publisher := new(MockMessagePublisher)
+	publisher.On("PublishChatMessage", mock.Anything).Return(nil)
+
+	bot, hub := newTestBotAgent(repo, publisher, provider)
+	cache := services.NewResponseCache(10, time.Minute)
+	bot.SetResponseCache(cache)
+	return bot, hub, cache
+}
+
+func ask(t *testing.T, ctx context.Context, bot *services.BotAgent, customerID, content string) {
+	require.NoError(t, bot.ProcessMessage(ctx, &domain.Message{
+		Content:    content,
+		CustomerID: customerID,
+		Type:       domain.UserMessage,
+	}))
+}
+
+func TestResponseCache(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("knowledge answers are shared until the entry changes", func(t *testing.T) {
+		bot, hub, cache := newCachingBot(&streamingLLM{reply: "unused"})
+
+		ask(t, ctx, bot, "customer1", "What payment methods do you accept?")
+		ask(t, ctx, bot, "customer2", "what payment methods do you accept")
+		require.Len(t, hub.completed, 2)
+		assert.Equal(t, "We accept credit cards.", hub.completed[1].Content)
+		assert.Equal(t, uint64(1), cache.Stats().Hits)
+
+		assert.Equal(t, 1, cache.InvalidateEntry("payment_methods"))
+		ask(t, ctx, bot, "customer2", "what payment methods do you accept")
+		assert.Equal(t, uint64(1), cache.Stats().Hits)
+	})
+
+	t.Run("AI answers are not cached", func(t *testing.T) {
+		repo := new(MockMessageRepo)
+		repo.On("SaveMessage", mock.Anything, mock.Anything).Return(nil)
+		publisher := new(MockMessagePublisher)
+		publisher.On("PublishChatMessage", mock.Anything).Return(nil)
+		kb := services.NewKnowledgeBase(&stubKnowledgeRepo{entries: []domain.KnowledgeEntry{
+			{ID: "delivery_times", Question: "shipping times", Answer: "Orders are delivered within two to three working days.", Keywords: []string{"shipping", "courier"}},
+		}})
+		kb.ConfigureRetrieval(embedding.NewHashingEmbedder(256), 1, 0.1)
+		kb.SetMatchThreshold(1.1)
+		bot, hub := newTestBotAgentWithKnowledge(repo, publisher, &streamingLLM{reply: "Delivery takes two days."}, kb)
+		cache := services.NewResponseCache(10, time.Minute)
+		bot.SetResponseCache(cache)
+
+		// They depend on the conversation so far, even when grounded in an entry
+		ask(t, ctx, bot, "customer1", "when will my order be delivered")
+		require.Len(t, hub.completed, 1)
+		assert.Equal(t, "Delivery takes two days.", hub.completed[0].Content)
+		assert.Equal(t, 0, cache.Stats().Size)
+
+		// Asking again goes back to the provider, which the rate limiter holds up
+		shortCtx, cancel := context.WithTimeout(ctx, 200*time.Millisecond)
+		defer cancel()
+		ask(t, shortCtx, bot, "customer1", "When will my order be delivered?")
+		require.Len(t, hub.completed, 2)
+		assert.NotEqual(t, "Delivery takes two days.", hub.completed[1].Content)
+		assert.Equal(t, uint64(0), cache.Stats().Hits)
+	})
+
+	t.Run("fallbacks are not cached", func(t *testing.T) {
+		repo := new(MockMessageRepo)
+		repo.On("SaveMessage", mock.Anything, mock.Anything).Return(nil)
+		publisher := new(MockMessagePublisher)
+		publisher.On("PublishChatMessage", mock.Anything).Return(nil)
+		kb := services.NewKnowledgeBase(&stubKnowledgeRepo{})
+		bot := services.NewBotAgent("bot-1", "Support Bot", false, repo, publisher, kb)
+		bot.SetHub(&recordingHub{})
+		cache := services.NewResponseCache(10, time.Minute)
+		bot.SetResponseCache(cache)
+
+		ask(t, ctx, bot, "customer1", "blorp")
+		assert.Equal(t, 0, cache.Stats().Size)
+	})
+}
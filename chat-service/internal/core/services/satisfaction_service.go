@@ -0,0 +1,73 @@
+package services
+
+import (
+	"chat-service/internal/core/domain"
+	"chat-service/internal/core/ports"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// surveyQuestion is what customers are asked when their conversation closes
+const surveyQuestion = "How satisfied are you with the help you got?"
+
+// SatisfactionService collects customers' ratings of their conversations
+// and reports how satisfied they are
+type SatisfactionService struct {
+	responses     ports.SatisfactionRepository
+	conversations ports.ConversationRepository
+}
+
+var _ ports.SatisfactionSurvey = (*SatisfactionService)(nil)
+
+// NewSatisfactionService creates a new satisfaction service
+func NewSatisfactionService(responses ports.SatisfactionRepository, conversations ports.ConversationRepository) *SatisfactionService {
+	return &SatisfactionService{
+		responses:     responses,
+		conversations: conversations,
+	}
+}
+
+// Survey builds the survey sent for a conversation
+func (s *SatisfactionService) Survey(conversationID string) domain.Survey {
+	return domain.Survey{
+		ConversationID: conversationID,
+		Question:       surveyQuestion,
+		MinRating:      domain.MinSatisfactionRating,
+		MaxRating:      domain.MaxSatisfactionRating,
+	}
+}
+
+// SubmitSurvey stores a customer's rating of one of their own conversations
+// once it has closed
+func (s *SatisfactionService) SubmitSurvey(ctx context.Context, response *domain.SatisfactionResponse) error {
+	if response.Rating < domain.MinSatisfactionRating || response.Rating > domain.MaxSatisfactionRating {
+		return fmt.Errorf("%w: rating must be between %d and %d",
+			domain.ErrInvalidSurvey, domain.MinSatisfactionRating, domain.MaxSatisfactionRating)
+	}
+	response.Comment = strings.TrimSpace(response.Comment)
+	if len(response.Comment) > domain.MaxSatisfactionCommentLength {
+		return fmt.Errorf("%w: comment is too long", domain.ErrInvalidSurvey)
+	}
+
+	conversation, err := s.conversations.GetConversation(ctx, response.ConversationID)
+	if err != nil || conversation.CustomerID != response.CustomerID {
+		return fmt.Errorf("%w: no such conversation", domain.ErrInvalidSurvey)
+	}
+	// An early rating would take the place of the one asked for on closing
+	if conversation.Status != "closed" {
+		return fmt.Errorf("%w: conversation is still open", domain.ErrInvalidSurvey)
+	}
+
+	response.ID = uuid.New().String()
+	response.CreatedAt = time.Now()
+	return s.responses.SaveResponse(ctx, response)
+}
+
+// Report aggregates the ratings given since the time
+func (s *SatisfactionService) Report(ctx context.Context, since time.Time) (*domain.SatisfactionReport, error) {
+	return s.responses.Report(ctx, since)
+}
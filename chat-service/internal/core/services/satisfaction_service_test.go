@@ -0,0 +1,91 @@
+package services_test
+
+import (
+	"chat-service/internal/core/domain"
+	"chat-service/internal/core/services"
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryResponses keeps survey responses in memory, one per conversation
+type memoryResponses struct {
+	mutex     sync.Mutex
+	responses map[string]domain.SatisfactionResponse
+}
+
+func (r *memoryResponses) SaveResponse(ctx context.Context, response *domain.SatisfactionResponse) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if _, ok := r.responses[response.ConversationID]; ok {
+		return domain.ErrSurveyAnswered
+	}
+	r.responses[response.ConversationID] = *response
+	return nil
+}
+
+func (r *memoryResponses) Report(ctx context.Context, since time.Time) (*domain.SatisfactionReport, error) {
+	return &domain.SatisfactionReport{Since: since}, nil
+}
+
+func TestSatisfactionService(t *testing.T) {
+	ctx := context.Background()
+	responses := &memoryResponses{responses: make(map[string]domain.SatisfactionResponse)}
+	conversations := newMemoryConversations(domain.Conversation{
+		ID:         "conv1",
+		CustomerID: "customer1",
+		Status:     "closed",
+	}, domain.Conversation{
+		ID:         "conv2",
+		CustomerID: "customer1",
+		Status:     "active",
+	})
+	service := services.NewSatisfactionService(responses, conversations)
+
+	survey := service.Survey("conv1")
+	assert.Equal(t, "conv1", survey.ConversationID)
+	assert.Equal(t, domain.MinSatisfactionRating, survey.MinRating)
+	assert.Equal(t, domain.MaxSatisfactionRating, survey.MaxRating)
+	assert.NotEmpty(t, survey.Question)
+
+	t.Run("rejects invalid responses", func(t *testing.T) {
+		invalid := []domain.SatisfactionResponse{
+			{ConversationID: "conv1", CustomerID: "customer1", Rating: 0},
+			{ConversationID: "conv1", CustomerID: "customer1", Rating: 6},
+			{ConversationID: "conv1", CustomerID: "customer1", Rating: 3, Comment: strings.Repeat("a", domain.MaxSatisfactionCommentLength+1)},
+			{ConversationID: "conv1", CustomerID: "customer2", Rating: 3},
+			{ConversationID: "missing", CustomerID: "customer1", Rating: 3},
+			{ConversationID: "conv2", CustomerID: "customer1", Rating: 5},
+		}
+		for _, response := range invalid {
+			err := service.SubmitSurvey(ctx, &response)
+			assert.ErrorIs(t, err, domain.ErrInvalidSurvey, "%+v", response)
+		}
+		assert.Empty(t, responses.responses)
+	})
+
+	t.Run("stores one response per conversation", func(t *testing.T) {
+		response := &domain.SatisfactionResponse{
+			ConversationID: "conv1",
+			CustomerID:     "customer1",
+			Rating:         4,
+			Comment:        "  Quick and helpful  ",
+		}
+		require.NoError(t, service.SubmitSurvey(ctx, response))
+		assert.NotEmpty(t, response.ID)
+		assert.False(t, response.CreatedAt.IsZero())
+		assert.Equal(t, "Quick and helpful", responses.responses["conv1"].Comment)
+
+		err := service.SubmitSurvey(ctx, &domain.SatisfactionResponse{
+			ConversationID: "conv1",
+			CustomerID:     "customer1",
+			Rating:         1,
+		})
+		assert.ErrorIs(t, err, domain.ErrSurveyAnswered)
+	})
+}
@@ -0,0 +1,196 @@
+package services
+
+import (
+	"chat-service/internal/core/domain"
+	"chat-service/internal/core/ports"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	agentJoinedMessage = "A member of our support team has joined the conversation."
+	agentLeftMessage   = "Our support agent has left the conversation. The assistant will take it from here."
+)
+
+// TakeoverService lets human agents take conversations over from the bot.
+// The conversation mode decides whether the bot still answers.
+type TakeoverService struct {
+	conversations ports.ConversationRepository
+	messages      ports.MessageRepository
+	publisher     ports.MessagePublisher
+	hub           ports.MessageHub
+}
+
+var _ ports.AgentTakeover = (*TakeoverService)(nil)
+
+// NewTakeoverService creates a new takeover service
+func NewTakeoverService(conversations ports.ConversationRepository, messages ports.MessageRepository, publisher ports.MessagePublisher) *TakeoverService {
+	return &TakeoverService{
+		conversations: conversations,
+		messages:      messages,
+		publisher:     publisher,
+	}
+}
+
+// SetHub sets where notices for the customer are delivered
+func (s *TakeoverService) SetHub(hub ports.MessageHub) {
+	s.hub = hub
+}
+
+// JoinConversation puts the agent in control of the conversation in the
+// given mode, "agent" unless hybrid is asked for. An agent joining replaces
+// the one in control.
+func (s *TakeoverService) JoinConversation(ctx context.Context, agentID, conversationID, mode string) (*domain.Conversation, error) {
+	if mode == "" {
+		mode = domain.ConversationModeAgent
+	}
+	if mode != domain.ConversationModeAgent && mode != domain.ConversationModeHybrid {
+		return nil, fmt.Errorf("%w: agents join in %q or %q mode", domain.ErrInvalidMode,
+			domain.ConversationModeAgent, domain.ConversationModeHybrid)
+	}
+
+	conversation, err := s.activeConversation(ctx, conversationID)
+	if err != nil {
+		return nil, err
+	}
+
+	joined := conversation.AgentID != agentID
+	conversation.Mode = mode
+	conversation.AgentID = agentID
+	if err := s.conversations.UpdateConversation(ctx, conversation); err != nil {
+		return nil, err
+	}
+
+	log.Printf("Agent %s joined conversation %s in %s mode", agentID, conversationID, mode)
+	if joined {
+		s.notify(ctx, conversation, agentJoinedMessage)
+	}
+	return conversation, nil
+}
+
+// SetMode switches the conversation between agent and hybrid mode, or hands
+// it back to the bot. Only the agent in control may change it.
+func (s *TakeoverService) SetMode(ctx context.Context, agentID, conversationID, mode string) (*domain.Conversation, error) {
+	switch mode {
+	case domain.ConversationModeBot, domain.ConversationModeAgent, domain.ConversationModeHybrid:
+	default:
+		return nil, fmt.Errorf("%w: %q", domain.ErrInvalidMode, mode)
+	}
+
+	conversation, err := s.activeConversation(ctx, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	if conversation.AgentID != agentID {
+		return nil, domain.ErrNotInControl
+	}
+
+	conversation.Mode = mode
+	if mode == domain.ConversationModeBot {
+		conversation.AgentID = ""
+	}
+	if err := s.conversations.UpdateConversation(ctx, conversation); err != nil {
+		return nil, err
+	}
+
+	log.Printf("Agent %s set conversation %s to %s mode", agentID, conversationID, mode)
+	if mode == domain.ConversationModeBot {
+		s.notify(ctx, conversation, agentLeftMessage)
+	}
+	return conversation, nil
+}
+
+// HandBack returns the conversation to the bot
+func (s *TakeoverService) HandBack(ctx context.Context, agentID, conversationID string) (*domain.Conversation, error) {
+	return s.SetMode(ctx, agentID, conversationID, domain.ConversationModeBot)
+}
+
+// SendAgentMessage stores and publishes a message from the agent in control
+func (s *TakeoverService) SendAgentMessage(ctx context.Context, agentID, conversationID, content string) (*domain.Message, error) {
+	content = strings.TrimSpace(content)
+	if content == "" {
+		return nil, errors.New("message content is required")
+	}
+
+	conversation, err := s.activeConversation(ctx, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	if conversation.AgentID != agentID || conversation.Mode == domain.ConversationModeBot {
+		return nil, domain.ErrNotInControl
+	}
+
+	message := &domain.Message{
+		ID:         uuid.New().String(),
+		Content:    content,
+		UserID:     agentID,
+		CustomerID: conversation.CustomerID,
+		Type:       domain.AgentMessage,
+		Timestamp:  time.Now(),
+		Metadata:   map[string]string{"conversation_id": conversation.ID},
+	}
+	if err := s.messages.SaveMessage(ctx, message); err != nil {
+		return nil, err
+	}
+	if err := s.publisher.PublishChatMessage(message); err != nil {
+		log.Printf("Error publishing agent message: %v", err)
+	}
+	return message, nil
+}
+
+// botMuted reports whether an agent has taken the customer's conversation
+// over from the bot
+func (s *TakeoverService) botMuted(ctx context.Context, customerID string) bool {
+	conversation, err := s.conversations.GetActiveConversationByCustomer(ctx, customerID)
+	if err != nil {
+		return false
+	}
+	return conversation.Mode == domain.ConversationModeAgent
+}
+
+func (s *TakeoverService) activeConversation(ctx context.Context, conversationID string) (*domain.Conversation, error) {
+	conversation, err := s.conversations.GetConversation(ctx, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	if conversation.Status != "active" {
+		return nil, domain.ErrConversationClosed
+	}
+	return conversation, nil
+}
+
+// notify tells the customer about a change of who they are talking to
+func (s *TakeoverService) notify(ctx context.Context, conversation *domain.Conversation, content string) {
+	message := systemMessage(conversation.CustomerID, content, map[string]string{
+		"conversation_id": conversation.ID,
+		"mode":            conversation.Mode,
+	})
+	if err := s.messages.SaveMessage(ctx, message); err != nil {
+		log.Printf("Error saving takeover notice: %v", err)
+	}
+	if err := s.publisher.PublishChatMessage(message); err != nil {
+		log.Printf("Error publishing takeover notice: %v", err)
+	}
+	if s.hub != nil {
+		s.hub.SendSystemMessage(message)
+	}
+}
+
+// systemMessage builds a notice from the service itself
+func systemMessage(customerID, content string, metadata map[string]string) *domain.Message {
+	return &domain.Message{
+		ID:         uuid.New().String(),
+		Content:    content,
+		UserID:     "system",
+		CustomerID: customerID,
+		Type:       domain.SystemMessage,
+		Timestamp:  time.Now(),
+		Metadata:   metadata,
+	}
+}
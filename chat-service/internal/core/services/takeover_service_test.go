@@ -0,0 +1,254 @@
+package services_test
+
+import (
+	"chat-service/internal/core/domain"
+	"chat-service/internal/core/ports"
+	"chat-service/internal/core/services"
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryConversations keeps conversations in memory so mode changes stick
+type memoryConversations struct {
+	mutex         sync.Mutex
+	conversations map[string]domain.Conversation
+	escalated     map[string]bool
+	// Closing notices stored as idle conversations closed
+	notices []domain.Message
+}
+
+func newMemoryConversations(conversations ...domain.Conversation) *memoryConversations {
+	repo := &memoryConversations{
+		conversations: make(map[string]domain.Conversation),
+		escalated:     make(map[string]bool),
+	}
+	for _, conversation := range conversations {
+		repo.conversations[conversation.ID] = conversation
+	}
+	return repo
+}
+
+func (r *memoryConversations) CreateConversation(ctx context.Context, conversation *domain.Conversation) error {
+	return r.UpdateConversation(ctx, conversation)
+}
+
+func (r *memoryConversations) MarkEscalated(ctx context.Context, conversationID string, escalated bool) (bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	changed := r.escalated[conversationID] != escalated
+	r.escalated[conversationID] = escalated
+	return changed, nil
+}
+
+func (r *memoryConversations) GetConversation(ctx context.Context, id string) (*domain.Conversation, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	conversation, ok := r.conversations[id]
+	if !ok {
+		return nil, domain.ErrNoConversation
+	}
+	return &conversation, nil
+}
+
+func (r *memoryConversations) GetActiveConversationByCustomer(ctx context.Context, customerID string) (*domain.Conversation, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for _, conversation := range r.conversations {
+		if conversation.CustomerID == customerID && conversation.Status == "active" {
+			return &conversation, nil
+		}
+	}
+	return nil, errors.New("no active conversation")
+}
+
+func (r *memoryConversations) ListConversationsByCustomer(ctx context.Context, customerID string) ([]domain.Conversation, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	var conversations []domain.Conversation
+	for _, conversation := range r.conversations {
+		if conversation.CustomerID == customerID {
+			conversations = append(conversations, conversation)
+		}
+	}
+	return conversations, nil
+}
+
+// ListIdleConversations knows no messages, so conversations are idle since
+// they started
+func (r *memoryConversations) ListIdleConversations(ctx context.Context, idleSince time.Time, limit int) ([]domain.Conversation, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	var conversations []domain.Conversation
+	for _, conversation := range r.conversations {
+		if conversation.Status == "active" && conversation.StartedAt.Before(idleSince) && len(conversations) < limit {
+			conversations = append(conversations, conversation)
+		}
+	}
+	return conversations, nil
+}
+
+func (r *memoryConversations) CloseIdleConversation(ctx context.Context, conversationID string, idleSince time.Time, notice *domain.Message) (bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	conversation, ok := r.conversations[conversationID]
+	if !ok || conversation.Status == "closed" || !conversation.StartedAt.Before(idleSince) {
+		return false, nil
+	}
+	conversation.Status = "closed"
+	conversation.EndedAt = notice.Timestamp
+	r.conversations[conversationID] = conversation
+	r.notices = append(r.notices, *notice)
+	return true, nil
+}
+
+func (r *memoryConversations) FindConversations(ctx context.Context, filter domain.ConversationFilter) ([]domain.Conversation, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	var conversations []domain.Conversation
+	for _, conversation := range r.conversations {
+		if (filter.CustomerID == "" || conversation.CustomerID == filter.CustomerID) &&
+			(filter.From.IsZero() || !conversation.StartedAt.Before(filter.From)) &&
+			(filter.To.IsZero() || conversation.StartedAt.Before(filter.To)) {
+			conversations = append(conversations, conversation)
+		}
+	}
+	sort.Slice(conversations, func(i, j int) bool {
+		if conversations[i].StartedAt.Equal(conversations[j].StartedAt) {
+			return conversations[i].ID < conversations[j].ID
+		}
+		return conversations[i].StartedAt.Before(conversations[j].StartedAt)
+	})
+	if filter.After != "" {
+		for i, conversation := range conversations {
+			if conversation.ID == filter.After {
+				conversations = conversations[i+1:]
+				break
+			}
+		}
+	}
+	if len(conversations) > filter.Limit {
+		conversations = conversations[:filter.Limit]
+	}
+	return conversations, nil
+}
+
+func (r *memoryConversations) UpdateConversation(ctx context.Context, conversation *domain.Conversation) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.conversations[conversation.ID] = *conversation
+	return nil
+}
+
+var _ ports.ConversationRepository = (*memoryConversations)(nil)
+
+func newTakeoverBot(t *testing.T) (*services.BotAgent, *recordingHub, *services.TakeoverService, *MockMessageRepo) {
+	repo := new(MockMessageRepo)
+	repo.On("SaveMessage", mock.Anything, mock.Anything).Return(nil)
+	publisher := new(MockMessagePublisher)
+	publisher.On("PublishChatMessage", mock.Anything).Return(nil)
+	conversations := newMemoryConversations(
+		domain.Conversation{ID: "conv-1", CustomerID: "customer1", Status: "active", Mode: domain.ConversationModeBot},
+		domain.Conversation{ID: "conv-closed", CustomerID: "customer2", Status: "closed", Mode: domain.ConversationModeBot},
+	)
+
+	takeover := services.NewTakeoverService(conversations, repo, publisher)
+	bot, hub := newTestBotAgent(repo, publisher, &streamingLLM{reply: "The bot answered"})
+	bot.SetTakeoverService(takeover)
+	takeover.SetHub(hub)
+	return bot, hub, takeover, repo
+}
+
+func TestTakeover(t *testing.T) {
+	ctx := context.Background()
+	customerSays := func(t *testing.T, bot *services.BotAgent) {
+		require.NoError(t, bot.ProcessMessage(ctx, &domain.Message{
+			Content:    "where is my parcel",
+			CustomerID: "customer1",
+			Type:       domain.UserMessage,
+		}))
+	}
+
+	t.Run("agent mode mutes the bot until hand back", func(t *testing.T) {
+		bot, hub, takeover, _ := newTakeoverBot(t)
+
+		conversation, err := takeover.JoinConversation(ctx, "agent-1", "conv-1", "")
+		require.NoError(t, err)
+		assert.Equal(t, domain.ConversationModeAgent, conversation.Mode)
+		assert.Equal(t, "agent-1", conversation.AgentID)
+		require.Len(t, hub.system, 1)
+		assert.Equal(t, domain.ConversationModeAgent, hub.system[0].Metadata["mode"])
+
+		customerSays(t, bot)
+		assert.Empty(t, hub.completed)
+
+		conversation, err = takeover.HandBack(ctx, "agent-1", "conv-1")
+		require.NoError(t, err)
+		assert.Equal(t, domain.ConversationModeBot, conversation.Mode)
+		assert.Empty(t, conversation.AgentID)
+		assert.Len(t, hub.system, 2)
+
+		customerSays(t, bot)
+		require.Len(t, hub.completed, 1)
+		assert.Equal(t, "The bot answered", hub.completed[0].Content)
+	})
+
+	t.Run("hybrid mode keeps the bot answering", func(t *testing.T) {
+		bot, hub, takeover, _ := newTakeoverBot(t)
+
+		_, err := takeover.JoinConversation(ctx, "agent-1", "conv-1", domain.ConversationModeHybrid)
+		require.NoError(t, err)
+
+		customerSays(t, bot)
+		assert.Len(t, hub.completed, 1)
+	})
+
+	t.Run("agent messages are stored as agent messages", func(t *testing.T) {
+		_, _, takeover, repo := newTakeoverBot(t)
+
+		_, err := takeover.SendAgentMessage(ctx, "agent-1", "conv-1", "Hello")
+		assert.ErrorIs(t, err, domain.ErrNotInControl)
+
+		_, err = takeover.JoinConversation(ctx, "agent-1", "conv-1", "")
+		require.NoError(t, err)
+
+		message, err := takeover.SendAgentMessage(ctx, "agent-1", "conv-1", "  Hello, I'm Sam from support  ")
+		require.NoError(t, err)
+		assert.Equal(t, domain.AgentMessage, message.Type)
+		assert.Equal(t, "agent-1", message.UserID)
+		assert.Equal(t, "customer1", message.CustomerID)
+		assert.Equal(t, "Hello, I'm Sam from support", message.Content)
+		repo.AssertCalled(t, "SaveMessage", mock.Anything, message)
+	})
+
+	t.Run("only the agent in control changes the mode", func(t *testing.T) {
+		_, _, takeover, _ := newTakeoverBot(t)
+
+		_, err := takeover.JoinConversation(ctx, "agent-1", "conv-1", "")
+		require.NoError(t, err)
+
+		_, err = takeover.HandBack(ctx, "agent-2", "conv-1")
+		assert.ErrorIs(t, err, domain.ErrNotInControl)
+		_, err = takeover.SendAgentMessage(ctx, "agent-2", "conv-1", "Hi")
+		assert.ErrorIs(t, err, domain.ErrNotInControl)
+
+		_, err = takeover.SetMode(ctx, "agent-1", "conv-1", "autopilot")
+		assert.ErrorIs(t, err, domain.ErrInvalidMode)
+	})
+
+	t.Run("rejects bad joins", func(t *testing.T) {
+		_, _, takeover, _ := newTakeoverBot(t)
+
+		_, err := takeover.JoinConversation(ctx, "agent-1", "conv-1", domain.ConversationModeBot)
+		assert.ErrorIs(t, err, domain.ErrInvalidMode)
+		_, err = takeover.JoinConversation(ctx, "agent-1", "conv-closed", "")
+		assert.ErrorIs(t, err, domain.ErrConversationClosed)
+	})
+}
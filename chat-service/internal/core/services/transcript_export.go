@@ -0,0 +1,202 @@
+package services
+
+import (
+	"bufio"
+	"chat-service/internal/core/domain"
+	"chat-service/internal/core/ports"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"time"
+)
+
+// transcriptPageSize is how many conversations an export reads at a time
+const transcriptPageSize = 100
+
+var _ ports.TranscriptExport = (*ChatServiceImpl)(nil)
+
+// SetParticipantDirectory names the customers and agents of exported
+// transcripts. Without one they are named by their IDs.
+func (s *ChatServiceImpl) SetParticipantDirectory(directory ports.ParticipantDirectory) {
+	s.directory = directory
+}
+
+// SetBotName names the bot with the ID in exported transcripts
+func (s *ChatServiceImpl) SetBotName(botID, name string) {
+	if s.botNames == nil {
+		s.botNames = make(map[string]string)
+	}
+	s.botNames[botID] = name
+}
+
+// SetTranscriptLinkTTL sets how long the attachment links of exported
+// transcripts are valid. By default they last as long as the links of
+// messages read.
+func (s *ChatServiceImpl) SetTranscriptLinkTTL(validFor time.Duration) {
+	s.transcriptLinkTTL = validFor
+}
+
+// ExportTranscripts writes the conversations the query selects, reading a
+// page of conversations at a time and streaming the messages of each
+func (s *ChatServiceImpl) ExportTranscripts(ctx context.Context, query domain.TranscriptQuery, format string, w io.Writer) error {
+	if _, ok := domain.TranscriptFormats[format]; !ok {
+		return fmt.Errorf("%w: unknown format %q", domain.ErrInvalidExport, format)
+	}
+	hasFilter := query.CustomerID != "" || !query.From.IsZero() || !query.To.IsZero()
+	if query.ConversationID != "" && hasFilter {
+		return fmt.Errorf("%w: a conversation cannot be combined with a customer or dates", domain.ErrInvalidExport)
+	}
+	if query.ConversationID == "" && !hasFilter {
+		return fmt.Errorf("%w: a conversation, customer or date range is required", domain.ErrInvalidExport)
+	}
+	if !query.From.IsZero() && !query.To.IsZero() && !query.From.Before(query.To) {
+		return fmt.Errorf("%w: from must be before to", domain.ErrInvalidExport)
+	}
+
+	// Read the first conversations before writing, so that failing to
+	// find them is reported instead of an empty transcript
+	var conversations []domain.Conversation
+	filter := domain.ConversationFilter{
+		CustomerID: query.CustomerID,
+		From:       query.From,
+		To:         query.To,
+		Limit:      transcriptPageSize,
+	}
+	if query.ConversationID != "" {
+		conversation, err := s.conversationRepo.GetConversation(ctx, query.ConversationID)
+		if err != nil {
+			return err
+		}
+		conversations = []domain.Conversation{*conversation}
+	} else {
+		page, err := s.conversationRepo.FindConversations(ctx, filter)
+		if err != nil {
+			return err
+		}
+		conversations = page
+	}
+
+	out := &transcriptOutput{w: bufio.NewWriter(w)}
+	transcript := newTranscriptFormat(format, out)
+	senders := s.newTranscriptSenders(ctx)
+
+	transcript.begin()
+	for len(conversations) > 0 {
+		for i := range conversations {
+			if err := s.exportConversation(ctx, transcript, out, senders, &conversations[i]); err != nil {
+				return err
+			}
+		}
+		if query.ConversationID != "" || len(conversations) < transcriptPageSize {
+			break
+		}
+
+		filter.After = conversations[len(conversations)-1].ID
+		page, err := s.conversationRepo.FindConversations(ctx, filter)
+		if err != nil {
+			return err
+		}
+		conversations = page
+	}
+	transcript.end()
+	return out.flush()
+}
+
+// exportConversation writes one conversation with its messages
+func (s *ChatServiceImpl) exportConversation(ctx context.Context, transcript transcriptFormat, out *transcriptOutput, senders *transcriptSenders, conversation *domain.Conversation) error {
+	customer := senders.customer(conversation.CustomerID)
+	transcript.conversation(conversation, customer)
+
+	err := s.messageRepo.StreamMessages(ctx, conversation.ID, func(message *domain.Message) error {
+		if s.attachments != nil && len(message.Attachments) > 0 {
+			linkTTL := s.transcriptLinkTTL
+			if linkTTL == 0 {
+				linkTTL = s.attachmentLinkTTL
+			}
+			s.attachments.Sign([]domain.Message{*message}, linkTTL)
+		}
+		transcript.message(message, senders.sender(message))
+		return out.err
+	})
+	if err != nil {
+		return err
+	}
+
+	transcript.endConversation()
+	return out.err
+}
+
+// transcriptSenders names the senders of an export's messages, looking each
+// customer and agent up once
+type transcriptSenders struct {
+	ctx       context.Context
+	directory ports.ParticipantDirectory
+	botNames  map[string]string
+	names     map[string]string
+}
+
+func (s *ChatServiceImpl) newTranscriptSenders(ctx context.Context) *transcriptSenders {
+	return &transcriptSenders{
+		ctx:       ctx,
+		directory: s.directory,
+		botNames:  s.botNames,
+		names:     make(map[string]string),
+	}
+}
+
+func (t *transcriptSenders) customer(customerID string) domain.TranscriptSender {
+	return domain.TranscriptSender{
+		ID:   customerID,
+		Role: domain.ParticipantCustomer,
+		Name: t.lookUp(domain.ParticipantCustomer, customerID),
+	}
+}
+
+func (t *transcriptSenders) sender(message *domain.Message) domain.TranscriptSender {
+	switch message.Type {
+	case domain.UserMessage:
+		return t.customer(message.CustomerID)
+	case domain.BotMessage:
+		name := t.botNames[message.UserID]
+		if name == "" {
+			name = "Bot"
+		}
+		return domain.TranscriptSender{ID: message.UserID, Role: domain.ParticipantBot, Name: name}
+	case domain.AgentMessage:
+		return domain.TranscriptSender{
+			ID:   message.UserID,
+			Role: domain.ParticipantAgent,
+			Name: t.lookUp(domain.ParticipantAgent, message.UserID),
+		}
+	default:
+		return domain.TranscriptSender{ID: message.UserID, Role: domain.ParticipantSystem, Name: "System"}
+	}
+}
+
+// lookUp finds the name of a customer or agent in the directory, falling
+// back to their ID. Failed lookups are not retried within the export.
+func (t *transcriptSenders) lookUp(role, id string) string {
+	key := role + ":" + id
+	if name, ok := t.names[key]; ok {
+		return name
+	}
+
+	name := id
+	if t.directory != nil && id != "" {
+		var found string
+		var err error
+		if role == domain.ParticipantAgent {
+			found, err = t.directory.AgentName(t.ctx, id)
+		} else {
+			found, err = t.directory.CustomerName(t.ctx, id)
+		}
+		if err != nil {
+			log.Printf("Error looking up the name of %s %s: %v", role, id, err)
+		} else if found != "" {
+			name = found
+		}
+	}
+	t.names[key] = name
+	return name
+}
@@ -0,0 +1,236 @@
+package services_test
+
+import (
+	"bytes"
+	"chat-service/internal/core/domain"
+	"chat-service/internal/core/services"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeDirectory names the people it knows and counts the lookups
+type fakeDirectory struct {
+	names   map[string]string
+	lookups int
+}
+
+func (d *fakeDirectory) CustomerName(ctx context.Context, customerID string) (string, error) {
+	return d.lookUp(customerID)
+}
+
+func (d *fakeDirectory) AgentName(ctx context.Context, agentID string) (string, error) {
+	return d.lookUp(agentID)
+}
+
+func (d *fakeDirectory) lookUp(id string) (string, error) {
+	d.lookups++
+	name, ok := d.names[id]
+	if !ok {
+		return "", errors.New("not found")
+	}
+	return name, nil
+}
+
+// failingWriter refuses every write
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func newTranscriptChat(repo *MockMessageRepo, conversations ...domain.Conversation) (*services.ChatServiceImpl, *fakeDirectory) {
+	chat := services.NewChatService(repo, newMemoryConversations(conversations...), new(MockMessagePublisher))
+	directory := &fakeDirectory{names: map[string]string{"customer1": "Ada Lovelace", "agent1": "Grace Hopper"}}
+	chat.SetParticipantDirectory(directory)
+	chat.SetBotName("bot-1", "Support Bot")
+	return chat, directory
+}
+
+var transcriptStart = time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+
+func transcriptMessages() []domain.Message {
+	deletedAt := transcriptStart.Add(3 * time.Minute)
+	return []domain.Message{
+		{ID: "m1", Content: "My card <b>was</b> charged *twice*", UserID: "user1", CustomerID: "customer1",
+			Type: domain.UserMessage, Timestamp: transcriptStart,
+			Attachments: []domain.Attachment{{ID: "a1", FileName: "receipt.pdf", ContentType: "application/pdf", Size: 2048, URL: "/attachments/a1?sig=x"}}},
+		{ID: "m2", Content: "Let me check that for you", UserID: "bot-1", CustomerID: "customer1",
+			Type: domain.BotMessage, Timestamp: transcriptStart.Add(time.Minute)},
+		{ID: "m3", Content: "Refunded, sorry about that", UserID: "agent1", CustomerID: "customer1",
+			Type: domain.AgentMessage, Timestamp: transcriptStart.Add(2 * time.Minute)},
+		{ID: "m4", UserID: "user1", CustomerID: "customer1",
+			Type: domain.UserMessage, Timestamp: transcriptStart.Add(3 * time.Minute), DeletedAt: &deletedAt},
+	}
+}
+
+func TestExportTranscripts(t *testing.T) {
+	ctx := context.Background()
+	conversation := domain.Conversation{ID: "conv1", CustomerID: "customer1", StartedAt: transcriptStart, Status: "closed", Mode: "bot"}
+
+	t.Run("json names every sender and lists attachments", func(t *testing.T) {
+		repo := new(MockMessageRepo)
+		repo.On("StreamMessages", mock.Anything, "conv1").Return(transcriptMessages(), nil)
+		chat, directory := newTranscriptChat(repo, conversation)
+
+		var out bytes.Buffer
+		require.NoError(t, chat.ExportTranscripts(ctx, domain.TranscriptQuery{ConversationID: "conv1"}, domain.TranscriptJSON, &out))
+
+		var transcript struct {
+			Conversations []struct {
+				ID       string                  `json:"id"`
+				Customer domain.TranscriptSender `json:"customer"`
+				Messages []struct {
+					domain.Message
+					Sender domain.TranscriptSender `json:"sender"`
+				} `json:"messages"`
+			} `json:"conversations"`
+		}
+		require.NoError(t, json.Unmarshal(out.Bytes(), &transcript), out.String())
+		require.Len(t, transcript.Conversations, 1)
+		exported := transcript.Conversations[0]
+		assert.Equal(t, "conv1", exported.ID)
+		assert.Equal(t, "Ada Lovelace", exported.Customer.Name)
+
+		require.Len(t, exported.Messages, 4)
+		names := make([]string, len(exported.Messages))
+		for i, message := range exported.Messages {
+			names[i] = message.Sender.Name
+		}
+		assert.Equal(t, []string{"Ada Lovelace", "Support Bot", "Grace Hopper", "Ada Lovelace"}, names)
+		assert.Equal(t, "receipt.pdf", exported.Messages[0].Attachments[0].FileName)
+		assert.NotNil(t, exported.Messages[3].DeletedAt)
+		assert.Equal(t, 2, directory.lookups, "each person is looked up once")
+	})
+
+	t.Run("csv writes a row per message", func(t *testing.T) {
+		repo := new(MockMessageRepo)
+		repo.On("StreamMessages", mock.Anything, "conv1").Return(transcriptMessages(), nil)
+		chat, _ := newTranscriptChat(repo, conversation)
+
+		var out bytes.Buffer
+		require.NoError(t, chat.ExportTranscripts(ctx, domain.TranscriptQuery{CustomerID: "customer1"}, domain.TranscriptCSV, &out))
+
+		rows, err := csv.NewReader(&out).ReadAll()
+		require.NoError(t, err)
+		require.Len(t, rows, 5)
+		assert.Equal(t, "conversation_id", rows[0][0])
+		assert.Equal(t, []string{"conv1", "customer1", "Ada Lovelace"}, rows[1][:3])
+		assert.Equal(t, "receipt.pdf </attachments/a1?sig=x>", rows[1][10])
+		assert.Equal(t, "Grace Hopper", rows[3][8])
+		assert.NotEmpty(t, rows[4][12])
+	})
+
+	t.Run("csv keeps what people wrote from being a formula", func(t *testing.T) {
+		messages := []domain.Message{
+			{ID: "m1", Content: `=HYPERLINK("http://evil.example","refund")`, UserID: "user1", CustomerID: "customer1",
+				Type: domain.UserMessage, Timestamp: transcriptStart,
+				Attachments: []domain.Attachment{{ID: "a1", FileName: "@SUM(A1).png"}}},
+			{ID: "m2", Content: "-1 is not a valid quantity", UserID: "user1", CustomerID: "customer1",
+				Type: domain.UserMessage, Timestamp: transcriptStart.Add(time.Minute)},
+			{ID: "m3", Content: "Thanks, +1", UserID: "user1", CustomerID: "customer1",
+				Type: domain.UserMessage, Timestamp: transcriptStart.Add(2 * time.Minute)},
+		}
+		repo := new(MockMessageRepo)
+		repo.On("StreamMessages", mock.Anything, "conv1").Return(messages, nil)
+		chat, directory := newTranscriptChat(repo, conversation)
+		directory.names["customer1"] = "+Ada"
+
+		var out bytes.Buffer
+		require.NoError(t, chat.ExportTranscripts(ctx, domain.TranscriptQuery{ConversationID: "conv1"}, domain.TranscriptCSV, &out))
+
+		rows, err := csv.NewReader(&out).ReadAll()
+		require.NoError(t, err)
+		require.Len(t, rows, 4)
+		assert.Equal(t, `'=HYPERLINK("http://evil.example","refund")`, rows[1][9])
+		assert.Equal(t, "'@SUM(A1).png", rows[1][10])
+		assert.Equal(t, "'+Ada", rows[1][2])
+		assert.Equal(t, "'+Ada", rows[1][8])
+		assert.Equal(t, "'-1 is not a valid quantity", rows[2][9])
+		assert.Equal(t, "Thanks, +1", rows[3][9])
+	})
+
+	t.Run("csv reports write errors", func(t *testing.T) {
+		repo := new(MockMessageRepo)
+		repo.On("StreamMessages", mock.Anything, "conv1").Return(transcriptMessages(), nil)
+		chat, _ := newTranscriptChat(repo, conversation)
+
+		err := chat.ExportTranscripts(ctx, domain.TranscriptQuery{ConversationID: "conv1"}, domain.TranscriptCSV, failingWriter{})
+		assert.Error(t, err)
+	})
+
+	t.Run("html and markdown keep what people wrote from being markup", func(t *testing.T) {
+		repo := new(MockMessageRepo)
+		repo.On("StreamMessages", mock.Anything, "conv1").Return(transcriptMessages(), nil)
+		chat, _ := newTranscriptChat(repo, conversation)
+
+		var page bytes.Buffer
+		require.NoError(t, chat.ExportTranscripts(ctx, domain.TranscriptQuery{ConversationID: "conv1"}, domain.TranscriptHTML, &page))
+		assert.Contains(t, page.String(), "My card &lt;b&gt;was&lt;/b&gt; charged *twice*")
+		assert.Contains(t, page.String(), `<a href="/attachments/a1?sig=x">receipt.pdf</a>`)
+		assert.Contains(t, page.String(), "Message deleted")
+		assert.True(t, strings.HasSuffix(page.String(), "</html>\n"))
+
+		var document bytes.Buffer
+		require.NoError(t, chat.ExportTranscripts(ctx, domain.TranscriptQuery{ConversationID: "conv1"}, domain.TranscriptMarkdown, &document))
+		assert.Contains(t, document.String(), "## Conversation conv1")
+		assert.Contains(t, document.String(), `> My card \<b\>was\</b\> charged \*twice\*`)
+		assert.Contains(t, document.String(), "**Grace Hopper** (agent), 2024-03-01 09:02:00 UTC")
+		assert.Contains(t, document.String(), "- Attachment: [receipt.pdf](</attachments/a1?sig=x>) (application/pdf, 2048 bytes)")
+	})
+
+	t.Run("pages through the conversations of a date range", func(t *testing.T) {
+		var conversations []domain.Conversation
+		for i := 0; i < 150; i++ {
+			conversations = append(conversations, domain.Conversation{
+				ID:         fmt.Sprintf("conv%03d", i),
+				CustomerID: fmt.Sprintf("customer%d", i),
+				StartedAt:  transcriptStart.Add(time.Duration(i) * time.Minute),
+				Status:     "closed",
+			})
+		}
+		repo := new(MockMessageRepo)
+		repo.On("StreamMessages", mock.Anything, mock.Anything).Return([]domain.Message{}, nil)
+		chat, _ := newTranscriptChat(repo, conversations...)
+
+		var out bytes.Buffer
+		query := domain.TranscriptQuery{From: transcriptStart.Add(10 * time.Minute), To: transcriptStart.Add(140 * time.Minute)}
+		require.NoError(t, chat.ExportTranscripts(ctx, query, domain.TranscriptCSV, &out))
+
+		repo.AssertNumberOfCalls(t, "StreamMessages", 130)
+		repo.AssertCalled(t, "StreamMessages", mock.Anything, "conv010")
+		repo.AssertCalled(t, "StreamMessages", mock.Anything, "conv139")
+		repo.AssertNotCalled(t, "StreamMessages", mock.Anything, "conv140")
+	})
+
+	t.Run("reports bad queries before writing", func(t *testing.T) {
+		chat, _ := newTranscriptChat(new(MockMessageRepo), conversation)
+		queries := map[string]domain.TranscriptQuery{
+			"nothing selected":         {},
+			"conversation and filters": {ConversationID: "conv1", CustomerID: "customer1"},
+			"empty range":              {From: transcriptStart, To: transcriptStart},
+		}
+		for name, query := range queries {
+			var out bytes.Buffer
+			err := chat.ExportTranscripts(ctx, query, domain.TranscriptJSON, &out)
+			assert.ErrorIs(t, err, domain.ErrInvalidExport, name)
+			assert.Empty(t, out.String(), name)
+		}
+
+		var out bytes.Buffer
+		err := chat.ExportTranscripts(ctx, domain.TranscriptQuery{ConversationID: "conv1"}, "pdf", &out)
+		assert.ErrorIs(t, err, domain.ErrInvalidExport)
+		err = chat.ExportTranscripts(ctx, domain.TranscriptQuery{ConversationID: "missing"}, domain.TranscriptJSON, &out)
+		assert.ErrorIs(t, err, domain.ErrNoConversation)
+		assert.Empty(t, out.String())
+	})
+}
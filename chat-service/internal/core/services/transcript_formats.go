@@ -0,0 +1,318 @@
+package services
+
+import (
+	"bufio"
+	"chat-service/internal/core/domain"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"html"
+	"strings"
+	"time"
+)
+
+// transcriptOutput buffers what an export writes and keeps the first write
+// error, after which nothing more is written
+type transcriptOutput struct {
+	w   *bufio.Writer
+	err error
+}
+
+func (o *transcriptOutput) Write(p []byte) (int, error) {
+	if o.err != nil {
+		return 0, o.err
+	}
+	n, err := o.w.Write(p)
+	o.err = err
+	return n, err
+}
+
+func (o *transcriptOutput) printf(format string, args ...interface{}) {
+	fmt.Fprintf(o, format, args...)
+}
+
+func (o *transcriptOutput) flush() error {
+	if o.err == nil {
+		o.err = o.w.Flush()
+	}
+	return o.err
+}
+
+// transcriptFormat renders conversations as they are read: begin, then each
+// conversation followed by its messages and endConversation, then end
+type transcriptFormat interface {
+	begin()
+	conversation(conversation *domain.Conversation, customer domain.TranscriptSender)
+	message(message *domain.Message, sender domain.TranscriptSender)
+	endConversation()
+	end()
+}
+
+func newTranscriptFormat(format string, out *transcriptOutput) transcriptFormat {
+	switch format {
+	case domain.TranscriptCSV:
+		return &csvTranscript{out: out, csv: csv.NewWriter(out)}
+	case domain.TranscriptHTML:
+		return &htmlTranscript{out: out}
+	case domain.TranscriptMarkdown:
+		return &markdownTranscript{out: out}
+	default:
+		return &jsonTranscript{out: out}
+	}
+}
+
+// jsonTranscript writes {"conversations": [...]}, each conversation with its
+// customer and a "messages" array of messages with their sender
+type jsonTranscript struct {
+	out           *transcriptOutput
+	conversations int
+	messages      int
+}
+
+type jsonTranscriptConversation struct {
+	*domain.Conversation
+	Customer domain.TranscriptSender `json:"customer"`
+}
+
+type jsonTranscriptMessage struct {
+	*domain.Message
+	Sender domain.TranscriptSender `json:"sender"`
+}
+
+func (t *jsonTranscript) begin() {
+	t.out.printf(`{"conversations":[`)
+}
+
+func (t *jsonTranscript) conversation(conversation *domain.Conversation, customer domain.TranscriptSender) {
+	encoded, err := json.Marshal(jsonTranscriptConversation{Conversation: conversation, Customer: customer})
+	if err != nil {
+		t.out.err = err
+		return
+	}
+	if t.conversations > 0 {
+		t.out.printf(",")
+	}
+	t.conversations++
+	t.messages = 0
+	// Leave the object open for its messages
+	t.out.printf("\n%s,\"messages\":[", encoded[:len(encoded)-1])
+}
+
+func (t *jsonTranscript) message(message *domain.Message, sender domain.TranscriptSender) {
+	encoded, err := json.Marshal(jsonTranscriptMessage{Message: message, Sender: sender})
+	if err != nil {
+		t.out.err = err
+		return
+	}
+	if t.messages > 0 {
+		t.out.printf(",")
+	}
+	t.messages++
+	t.out.printf("\n%s", encoded)
+}
+
+func (t *jsonTranscript) endConversation() {
+	t.out.printf("]}")
+}
+
+func (t *jsonTranscript) end() {
+	t.out.printf("\n]}\n")
+}
+
+// csvTranscript writes a row per message, repeating its conversation's
+// details on each
+type csvTranscript struct {
+	out      *transcriptOutput
+	csv      *csv.Writer
+	current  *domain.Conversation
+	customer domain.TranscriptSender
+}
+
+func (t *csvTranscript) begin() {
+	t.csv.Write([]string{
+		"conversation_id", "customer_id", "customer_name", "conversation_status", "message_id",
+		"timestamp", "sender_role", "sender_id", "sender_name", "content", "attachments",
+		"edited_at", "deleted_at",
+	})
+}
+
+func (t *csvTranscript) conversation(conversation *domain.Conversation, customer domain.TranscriptSender) {
+	t.current = conversation
+	t.customer = customer
+}
+
+func (t *csvTranscript) message(message *domain.Message, sender domain.TranscriptSender) {
+	attachments := make([]string, len(message.Attachments))
+	for i, attachment := range message.Attachments {
+		attachments[i] = attachment.FileName
+		if attachment.URL != "" {
+			attachments[i] += " <" + attachment.URL + ">"
+		}
+	}
+	row := []string{
+		t.current.ID, t.customer.ID, t.customer.Name, t.current.Status, message.ID,
+		message.Timestamp.UTC().Format(time.RFC3339), sender.Role, sender.ID, sender.Name,
+		message.Content, strings.Join(attachments, "; "),
+		formatOptionalTime(message.EditedAt), formatOptionalTime(message.DeletedAt),
+	}
+	for i, cell := range row {
+		row[i] = csvCell(cell)
+	}
+	t.csv.Write(row)
+}
+
+func (t *csvTranscript) endConversation() {}
+
+func (t *csvTranscript) end() {
+	t.csv.Flush()
+	if err := t.csv.Error(); err != nil && t.out.err == nil {
+		t.out.err = err
+	}
+}
+
+// csvCell keeps spreadsheets from running what people wrote as a formula by
+// quoting cells that would start one
+func csvCell(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+func formatOptionalTime(value *time.Time) string {
+	if value == nil {
+		return ""
+	}
+	return value.UTC().Format(time.RFC3339)
+}
+
+// transcriptTime is how the HTML and Markdown transcripts show times
+func transcriptTime(value time.Time) string {
+	return value.UTC().Format("2006-01-02 15:04:05 UTC")
+}
+
+// htmlTranscript writes a standalone page with a section per conversation
+type htmlTranscript struct {
+	out *transcriptOutput
+}
+
+func (t *htmlTranscript) begin() {
+	t.out.printf(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Conversation transcripts</title>
+<style>
+body { font-family: sans-serif; max-width: 50em; margin: 2em auto; }
+ol { list-style: none; padding: 0; }
+li.message { margin: 1em 0; }
+.meta { color: #666; font-size: 0.9em; }
+.content { white-space: pre-wrap; margin: 0.25em 0; }
+.deleted { font-style: italic; color: #999; }
+</style>
+</head>
+<body>
+`)
+}
+
+func (t *htmlTranscript) conversation(conversation *domain.Conversation, customer domain.TranscriptSender) {
+	t.out.printf("<section>\n<h2>Conversation %s</h2>\n<p class=\"meta\">Customer: %s (%s) &middot; Started %s",
+		html.EscapeString(conversation.ID), html.EscapeString(customer.Name), html.EscapeString(customer.ID),
+		transcriptTime(conversation.StartedAt))
+	if !conversation.EndedAt.IsZero() {
+		t.out.printf(" &middot; Ended %s", transcriptTime(conversation.EndedAt))
+	}
+	t.out.printf(" &middot; %s</p>\n<ol>\n", html.EscapeString(conversation.Status))
+}
+
+func (t *htmlTranscript) message(message *domain.Message, sender domain.TranscriptSender) {
+	t.out.printf("<li class=\"message %s\">\n<p class=\"meta\"><strong>%s</strong> <time datetime=\"%s\">%s</time>",
+		html.EscapeString(sender.Role), html.EscapeString(sender.Name),
+		message.Timestamp.UTC().Format(time.RFC3339), transcriptTime(message.Timestamp))
+	if message.EditedAt != nil && message.DeletedAt == nil {
+		t.out.printf(" (edited)")
+	}
+	t.out.printf("</p>\n")
+
+	if message.DeletedAt != nil {
+		t.out.printf("<p class=\"deleted\">Message deleted</p>\n</li>\n")
+		return
+	}
+	if message.Content != "" {
+		t.out.printf("<p class=\"content\">%s</p>\n", html.EscapeString(message.Content))
+	}
+	if len(message.Attachments) > 0 {
+		t.out.printf("<ul class=\"attachments\">\n")
+		for _, attachment := range message.Attachments {
+			name := html.EscapeString(attachment.FileName)
+			if attachment.URL != "" {
+				name = fmt.Sprintf("<a href=\"%s\">%s</a>", html.EscapeString(attachment.URL), name)
+			}
+			t.out.printf("<li>%s (%s, %d bytes)</li>\n", name, html.EscapeString(attachment.ContentType), attachment.Size)
+		}
+		t.out.printf("</ul>\n")
+	}
+	t.out.printf("</li>\n")
+}
+
+func (t *htmlTranscript) endConversation() {
+	t.out.printf("</ol>\n</section>\n")
+}
+
+func (t *htmlTranscript) end() {
+	t.out.printf("</body>\n</html>\n")
+}
+
+// markdownTranscript writes a heading per conversation and quotes each
+// message under its sender
+type markdownTranscript struct {
+	out *transcriptOutput
+}
+
+// markdownEscaper keeps text people wrote from being read as formatting
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "`", "\\`", `*`, `\*`, `_`, `\_`, `[`, `\[`, `]`, `\]`, `<`, `\<`, `>`, `\>`, `#`, `\#`,
+)
+
+func (t *markdownTranscript) begin() {
+	t.out.printf("# Conversation transcripts\n")
+}
+
+func (t *markdownTranscript) conversation(conversation *domain.Conversation, customer domain.TranscriptSender) {
+	t.out.printf("\n## Conversation %s\n\n- Customer: %s (%s)\n- Started: %s\n",
+		markdownEscaper.Replace(conversation.ID), markdownEscaper.Replace(customer.Name),
+		markdownEscaper.Replace(customer.ID), transcriptTime(conversation.StartedAt))
+	if !conversation.EndedAt.IsZero() {
+		t.out.printf("- Ended: %s\n", transcriptTime(conversation.EndedAt))
+	}
+	t.out.printf("- Status: %s\n", markdownEscaper.Replace(conversation.Status))
+}
+
+func (t *markdownTranscript) message(message *domain.Message, sender domain.TranscriptSender) {
+	t.out.printf("\n**%s** (%s), %s", markdownEscaper.Replace(sender.Name), sender.Role, transcriptTime(message.Timestamp))
+	if message.EditedAt != nil && message.DeletedAt == nil {
+		t.out.printf(", edited")
+	}
+	t.out.printf("\n\n")
+
+	if message.DeletedAt != nil {
+		t.out.printf("> _Message deleted_\n")
+		return
+	}
+	if message.Content != "" {
+		for _, line := range strings.Split(message.Content, "\n") {
+			t.out.printf("> %s\n", markdownEscaper.Replace(line))
+		}
+	}
+	for _, attachment := range message.Attachments {
+		name := markdownEscaper.Replace(attachment.FileName)
+		if attachment.URL != "" {
+			name = fmt.Sprintf("[%s](<%s>)", name, attachment.URL)
+		}
+		t.out.printf("- Attachment: %s (%s, %d bytes)\n", name, attachment.ContentType, attachment.Size)
+	}
+}
+
+func (t *markdownTranscript) endConversation() {}
+
+func (t *markdownTranscript) end() {}
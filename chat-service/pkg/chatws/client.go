@@ -0,0 +1,226 @@
+package chatws
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+// Client is a connection to the chat socket. Reads must come from one
+// goroutine at a time; writes are safe from several.
+type Client struct {
+	conn       *websocket.Conn
+	writeMutex sync.Mutex
+
+	// Frames read while waiting for another one, returned by Next first
+	pending []*Envelope
+}
+
+// Dial connects to the chat socket at url, e.g.
+// ws://host/ws?user_id=u&customer_id=c
+func Dial(ctx context.Context, url string, header http.Header) (*Client, error) {
+	conn, resp, err := websocket.DefaultDialer.DialContext(ctx, url, header)
+	if err != nil {
+		if resp != nil {
+			return nil, fmt.Errorf("dial %s: %w (status %d)", url, err, resp.StatusCode)
+		}
+		return nil, fmt.Errorf("dial %s: %w", url, err)
+	}
+	return &Client{conn: conn}, nil
+}
+
+// Close closes the connection
+func (c *Client) Close() error {
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+	c.conn.WriteControl(websocket.CloseMessage,
+		websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+	return c.conn.Close()
+}
+
+// SendFrame writes one frame
+func (c *Client) SendFrame(frameType, id string, payload interface{}) error {
+	data, err := Encode(frameType, id, payload)
+	if err != nil {
+		return err
+	}
+
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+	return c.conn.WriteMessage(websocket.TextMessage, data)
+}
+
+// Send writes a chat message and returns the ID its ack will carry
+func (c *Client) Send(content string) (string, error) {
+	id := uuid.New().String()
+	return id, c.SendFrame(TypeMessage, id, SendMessage{Content: content})
+}
+
+// SendWithAttachments writes a message carrying files uploaded to the
+// conversation beforehand and returns the ID of the frame
+func (c *Client) SendWithAttachments(content string, attachmentIDs []string) (string, error) {
+	id := uuid.New().String()
+	return id, c.SendFrame(TypeMessage, id, SendMessage{Content: content, Attachments: attachmentIDs})
+}
+
+// SendCommand writes an agent command and returns the ID of the frame
+func (c *Client) SendCommand(command, mode string) (string, error) {
+	id := uuid.New().String()
+	return id, c.SendFrame(TypeCommand, id, Command{Command: command, Mode: mode})
+}
+
+// AnswerSurvey rates a closed conversation and returns the ID of the frame
+func (c *Client) AnswerSurvey(conversationID string, rating int, comment string) (string, error) {
+	id := uuid.New().String()
+	return id, c.SendFrame(TypeSurveyResponse, id, SurveyResponse{
+		ConversationID: conversationID,
+		Rating:         rating,
+		Comment:        comment,
+	})
+}
+
+// Typing tells the conversation the user started or stopped typing
+func (c *Client) Typing(typing bool) error {
+	if typing {
+		return c.SendFrame(TypeTypingStart, "", nil)
+	}
+	return c.SendFrame(TypeTypingStop, "", nil)
+}
+
+// RequestHistory asks for a page of messages and waits for it
+func (c *Client) RequestHistory(ctx context.Context, request HistoryRequest) (*History, error) {
+	id := uuid.New().String()
+	if err := c.SendFrame(TypeHistoryRequest, id, request); err != nil {
+		return nil, err
+	}
+
+	envelope, err := c.await(ctx, func(envelope *Envelope) bool {
+		return envelope.ID == id && (envelope.Type == TypeHistory || envelope.Type == TypeError)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if envelope.Type == TypeError {
+		return nil, rejection(envelope)
+	}
+
+	var history History
+	if err := envelope.Decode(&history); err != nil {
+		return nil, err
+	}
+	return &history, nil
+}
+
+// SendReceipt reports messages as delivered or read and returns the ID its
+// ack will carry
+func (c *Client) SendReceipt(status string, messageIDs ...string) (string, error) {
+	id := uuid.New().String()
+	return id, c.SendFrame(TypeReceipt, id, Receipt{MessageIDs: messageIDs, Status: status})
+}
+
+// Next returns the next frame, waiting until the context is done. A read
+// that times out leaves the connection unusable.
+func (c *Client) Next(ctx context.Context) (*Envelope, error) {
+	if len(c.pending) > 0 {
+		envelope := c.pending[0]
+		c.pending = c.pending[1:]
+		return envelope, nil
+	}
+	return c.read(ctx)
+}
+
+// Expect returns the first frame of the given type. Frames of other types
+// are kept for Next.
+func (c *Client) Expect(ctx context.Context, frameType string) (*Envelope, error) {
+	return c.await(ctx, func(envelope *Envelope) bool {
+		return envelope.Type == frameType
+	})
+}
+
+// AwaitAck waits for the ack or error answering the frame with the given ID.
+// A rejection is returned as an *Error. Other frames are kept for Next.
+func (c *Client) AwaitAck(ctx context.Context, id string) (*Ack, error) {
+	envelope, err := c.await(ctx, func(envelope *Envelope) bool {
+		return envelope.ID == id && (envelope.Type == TypeAck || envelope.Type == TypeError)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if envelope.Type == TypeError {
+		return nil, rejection(envelope)
+	}
+
+	var ack Ack
+	if err := envelope.Decode(&ack); err != nil {
+		return nil, err
+	}
+	return &ack, nil
+}
+
+// await returns the first frame that matches, keeping the others in order
+func (c *Client) await(ctx context.Context, match func(*Envelope) bool) (*Envelope, error) {
+	for i, envelope := range c.pending {
+		if match(envelope) {
+			c.pending = append(c.pending[:i], c.pending[i+1:]...)
+			return envelope, nil
+		}
+	}
+
+	for {
+		envelope, err := c.read(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if match(envelope) {
+			return envelope, nil
+		}
+		c.pending = append(c.pending, envelope)
+	}
+}
+
+// rejection reads the error frame answering a client frame
+func rejection(envelope *Envelope) error {
+	var rejected Error
+	if err := envelope.Decode(&rejected); err != nil {
+		return err
+	}
+	return &rejected
+}
+
+// read reads a frame from the connection
+func (c *Client) read(ctx context.Context) (*Envelope, error) {
+	if deadline, ok := ctx.Deadline(); ok {
+		c.conn.SetReadDeadline(deadline)
+	} else {
+		c.conn.SetReadDeadline(time.Time{})
+	}
+
+	_, data, err := c.conn.ReadMessage()
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, err
+	}
+
+	var envelope Envelope
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return nil, fmt.Errorf("invalid frame: %w", err)
+	}
+	return &envelope, nil
+}
+
+// IsRejection reports whether err is an error frame from the server with the
+// given code
+func IsRejection(err error, code string) bool {
+	var rejection *Error
+	return errors.As(err, &rejection) && rejection.Code == code
+}
@@ -0,0 +1,285 @@
+// Package chatws defines the chat WebSocket protocol and a client that
+// speaks it. Every frame in either direction is an Envelope; its Type says
+// what the payload holds.
+package chatws
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Version is the protocol version spoken by this package. Frames without a
+// version are taken to be the current one.
+const Version = 1
+
+// Frame types
+const (
+	// Chat messages, sent by clients and delivered by the server
+	TypeMessage = "message"
+	// A page of messages, sent after connecting with the latest ones and in
+	// answer to a history_request
+	TypeHistory = "history"
+	// A client asks for a page of older or newer messages
+	TypeHistoryRequest = "history_request"
+	// A client message was stored
+	TypeAck = "ack"
+	// A client frame was rejected
+	TypeError = "error"
+	// Someone in the conversation started typing. Clients repeat it while
+	// they type; the server stops it after TypingTTL without one.
+	TypeTypingStart = "typing_start"
+	// Someone in the conversation stopped typing
+	TypeTypingStop = "typing_stop"
+	// Who is connected to the conversation, sent on connect and whenever
+	// someone joins or leaves
+	TypePresence = "presence"
+	// A notice from the service itself
+	TypeSystem = "system"
+	// Part of a bot response that is still being generated
+	TypeBotChunk = "bot_chunk"
+	// The finished bot response
+	TypeBotComplete = "bot_complete"
+	// An agent took the conversation over or handed it back
+	TypeModeChanged = "mode_changed"
+	// A command from an agent
+	TypeCommand = "command"
+	// Messages were delivered to or read by someone. Clients send it for
+	// messages they received; the server relays it to the conversation.
+	TypeReceipt = "receipt"
+	// The server asks the customer to rate a conversation that closed
+	TypeSurvey = "survey"
+	// The customer's rating of a closed conversation
+	TypeSurveyResponse = "survey_response"
+	// A message was edited or redacted; the payload is the message as it
+	// is now
+	TypeMessageUpdated = "message_updated"
+	// A message was deleted
+	TypeMessageDeleted = "message_deleted"
+)
+
+// Error codes
+const (
+	ErrorInvalidFrame       = "invalid_frame"
+	ErrorUnsupportedVersion = "unsupported_version"
+	ErrorUnknownType        = "unknown_type"
+	ErrorInvalidMessage     = "invalid_message"
+	ErrorRejected           = "rejected"
+	ErrorInternal           = "internal"
+)
+
+// MaxContentLength is the longest message content accepted, in bytes
+const MaxContentLength = 4000
+
+// MaxIDLength is the longest client-supplied frame ID accepted
+const MaxIDLength = 64
+
+// MaxReceiptMessages is the most messages one receipt may cover
+const MaxReceiptMessages = 100
+
+// Envelope wraps every frame. ID is chosen by the client for frames it sends
+// and echoed in the ack or error answering them; server frames use it for
+// the ID of the message they carry.
+type Envelope struct {
+	Version int             `json:"v"`
+	Type    string          `json:"type"`
+	ID      string          `json:"id,omitempty"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// NewEnvelope builds a frame with the payload encoded as JSON
+func NewEnvelope(frameType, id string, payload interface{}) (*Envelope, error) {
+	envelope := &Envelope{Version: Version, Type: frameType, ID: id}
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode %s payload: %w", frameType, err)
+		}
+		envelope.Payload = data
+	}
+	return envelope, nil
+}
+
+// Encode builds a frame and marshals it in one go
+func Encode(frameType, id string, payload interface{}) ([]byte, error) {
+	envelope, err := NewEnvelope(frameType, id, payload)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(envelope)
+}
+
+// Decode unmarshals the payload into v
+func (e *Envelope) Decode(v interface{}) error {
+	if len(e.Payload) == 0 {
+		return fmt.Errorf("%s frame has no payload", e.Type)
+	}
+	return json.Unmarshal(e.Payload, v)
+}
+
+// Message is a chat message as it travels over the socket
+type Message struct {
+	ID         string            `json:"id"`
+	Content    string            `json:"content"`
+	UserID     string            `json:"user_id"`
+	CustomerID string            `json:"customer_id"`
+	Type       string            `json:"type"`
+	Timestamp  time.Time         `json:"timestamp"`
+	Status     string            `json:"status,omitempty"`
+	Metadata   map[string]string `json:"metadata,omitempty"`
+	// Attachments carry a signed URL to download each file until it expires
+	Attachments []Attachment `json:"attachments,omitempty"`
+	// When the message was last edited or redacted, and when it was deleted
+	EditedAt  *time.Time `json:"edited_at,omitempty"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+// Attachment is a file attached to a message
+type Attachment struct {
+	ID          string `json:"id"`
+	FileName    string `json:"file_name"`
+	ContentType string `json:"content_type"`
+	Size        int64  `json:"size"`
+	URL         string `json:"url,omitempty"`
+}
+
+// MessageDeleted says a message was deleted. The envelope ID is the
+// message's ID.
+type MessageDeleted struct {
+	ConversationID string    `json:"conversation_id"`
+	DeletedAt      time.Time `json:"deleted_at"`
+}
+
+// History is a page of messages, oldest first. HasMore says whether there
+// are more beyond it in the direction requested; for the page sent on
+// connect, whether there are older ones.
+type History struct {
+	Messages []Message `json:"messages"`
+	HasMore  bool      `json:"has_more"`
+}
+
+// HistoryRequest asks for the messages before or after a message ID. With
+// neither the latest messages are sent. A zero limit uses the server default.
+type HistoryRequest struct {
+	Before string `json:"before,omitempty"`
+	After  string `json:"after,omitempty"`
+	Limit  int    `json:"limit,omitempty"`
+}
+
+// SendMessage is the payload of a message frame sent by a client.
+// Attachments are the IDs of files uploaded to the conversation over HTTP;
+// a message with attachments may have no content.
+type SendMessage struct {
+	Content     string   `json:"content"`
+	Attachments []string `json:"attachments,omitempty"`
+}
+
+// Ack answers a client frame once it has been stored
+type Ack struct {
+	MessageID string    `json:"message_id"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// Error answers a client frame that was rejected
+type Error struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return e.Code + ": " + e.Message
+}
+
+// Delivery statuses of a message, each implying the ones before it
+const (
+	StatusSent      = "sent"
+	StatusDelivered = "delivered"
+	StatusRead      = "read"
+)
+
+// Receipt reports messages reaching a delivery status. Clients send the
+// message IDs and status; the server adds who sent the receipt and when.
+type Receipt struct {
+	MessageIDs []string  `json:"message_ids"`
+	Status     string    `json:"status"`
+	UserID     string    `json:"user_id,omitempty"`
+	Timestamp  time.Time `json:"timestamp"`
+}
+
+// Survey asks the customer to rate a closed conversation from MinRating to
+// MaxRating
+type Survey struct {
+	ConversationID string `json:"conversation_id"`
+	Question       string `json:"question"`
+	MinRating      int    `json:"min_rating"`
+	MaxRating      int    `json:"max_rating"`
+}
+
+// SurveyResponse answers a survey. The conversation defaults to the one the
+// client is connected to. It is acknowledged with the ID of the stored
+// response.
+type SurveyResponse struct {
+	ConversationID string `json:"conversation_id,omitempty"`
+	Rating         int    `json:"rating"`
+	Comment        string `json:"comment,omitempty"`
+}
+
+// BotChunk is a streamed piece of a bot response. The envelope ID is the ID
+// the finished message will have. A chunk with index 0 after others starts
+// the response over, as when a failed attempt is retried, so the chunks
+// before it are dropped.
+type BotChunk struct {
+	Index   int    `json:"index"`
+	Content string `json:"content"`
+}
+
+// ModeChanged says who is answering the customer
+type ModeChanged struct {
+	ConversationID string `json:"conversation_id"`
+	Mode           string `json:"mode"`
+	AgentID        string `json:"agent_id,omitempty"`
+}
+
+// Typing is the payload of typing_start and typing_stop frames sent by the
+// server. Clients send these frames without a payload.
+type Typing struct {
+	ConversationID string `json:"conversation_id"`
+	UserID         string `json:"user_id"`
+	Role           string `json:"role"`
+	Name           string `json:"name,omitempty"`
+}
+
+// Presence events
+const (
+	PresenceJoin  = "join"
+	PresenceLeave = "leave"
+)
+
+// Participant is someone connected to a conversation
+type Participant struct {
+	UserID string    `json:"user_id"`
+	Role   string    `json:"role"`
+	Since  time.Time `json:"since"`
+}
+
+// Presence lists who is connected to a conversation. Event and Participant
+// say what changed; the snapshot sent on connect has neither.
+type Presence struct {
+	ConversationID string        `json:"conversation_id"`
+	Event          string        `json:"event,omitempty"`
+	Participant    *Participant  `json:"participant,omitempty"`
+	Online         []Participant `json:"online"`
+}
+
+// Agent commands
+const (
+	CommandJoin     = "join"
+	CommandMode     = "mode"
+	CommandHandBack = "handback"
+)
+
+// Command is sent by agents to take a conversation over or hand it back
+type Command struct {
+	Command string `json:"command"`
+	Mode    string `json:"mode,omitempty"`
+}
@@ -4,17 +4,17 @@ package tests
 import (
 	webSock "chat-service/internal/adapters/primary/websocket"
 	"chat-service/internal/core/domain"
+	"chat-service/pkg/chatws"
 	"context"
-	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
 	"time"
 
-	"github.com/gorilla/websocket"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 type MockBotService struct {
@@ -45,6 +45,14 @@ func (m *MockChatService) GetChatHistory(customerID string) ([]domain.Message, e
 	return args.Get(0).([]domain.Message), args.Error(1)
 }
 
+func (m *MockChatService) GetMessages(query domain.MessageQuery) (*domain.MessagePage, error) {
+	args := m.Called(query)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.MessagePage), args.Error(1)
+}
+
 func (m *MockChatService) GetConversation(conversationID string) (*domain.Conversation, error) {
 	args := m.Called(conversationID)
 	if args.Get(0) == nil {
@@ -66,6 +74,27 @@ func (m *MockChatService) CloseConversation(conversationID string) error {
 	return args.Error(0)
 }
 
+func (m *MockChatService) ListConversations(customerID string) ([]domain.Conversation, error) {
+	args := m.Called(customerID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]domain.Conversation), args.Error(1)
+}
+
+func (m *MockChatService) UpdateDeliveryStatus(receipt *domain.Receipt) ([]string, error) {
+	args := m.Called(receipt)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *MockChatService) GetUnreadCount(conversationID, userID string) (int, error) {
+	args := m.Called(conversationID, userID)
+	return args.Int(0), args.Error(1)
+}
+
 // SubscribeToMessages mocks the subscription to message events
 func (m *MockChatService) SubscribeToMessages(handler func(*domain.Message)) error {
 	args := m.Called(handler)
@@ -99,12 +128,15 @@ func TestWebSocketEndToEnd(t *testing.T) {
 
 	// Set up mock expectations
 	mockService.On("CreateConversation", "customer123").Return(conversation, nil)
-	mockService.On("GetChatHistory", "customer123").Return(history, nil)
-	mockService.On("SaveMessage", mock.AnythingOfType("*domain.Message")).Return(nil)
+	mockService.On("GetMessages", domain.MessageQuery{ConversationID: "test-conv", Limit: domain.DefaultMessagePageSize}).
+		Return(&domain.MessagePage{Messages: history}, nil)
+	mockService.On("SaveMessage", mock.AnythingOfType("*domain.Message")).Run(func(args mock.Arguments) {
+		args.Get(0).(*domain.Message).ID = "saved-msg"
+	}).Return(nil)
 
 	// Add this expectation for the bot service
 	mockBotService.On("ProcessMessage", mock.Anything, mock.MatchedBy(func(msg *domain.Message) bool {
-		return msg.Content == "Hello from test" &&
+		return strings.HasPrefix(msg.Content, "Hello") &&
 			msg.UserID == "user123" &&
 			msg.CustomerID == "customer123"
 	})).Return(nil)
@@ -129,70 +161,56 @@ func TestWebSocketEndToEnd(t *testing.T) {
 	}))
 	defer server.Close()
 
-	// Convert to WebSocket URL
-	wsURL := strings.Replace(server.URL, "http://", "ws://", 1) + "/ws?user_id=user123&customer_id=customer123"
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
 
 	// Connect to WebSocket
-	c, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	wsURL := strings.Replace(server.URL, "http://", "ws://", 1) + "/ws?user_id=user123&customer_id=customer123"
+	client, err := chatws.Dial(ctx, wsURL, nil)
 	if err != nil {
 		t.Fatalf("Error connecting to WebSocket: %v", err)
 	}
-	defer c.Close()
-
-	// Create channels for communication
-	receivedMsg := make(chan []byte, 10)
-	errorChan := make(chan error, 1)
-
-	// Goroutine to read messages
-	go func() {
-		for {
-			_, message, err := c.ReadMessage()
-			if err != nil {
-				errorChan <- err
-				return
-			}
-			receivedMsg <- message
-		}
-	}()
-
-	// First message should be the history
-	select {
-	case msg := <-receivedMsg:
-		var messages []domain.Message
-		err := json.Unmarshal(msg, &messages)
-		assert.NoError(t, err)
-		assert.Len(t, messages, 1)
-		assert.Equal(t, "Previous message", messages[0].Content)
-	case err := <-errorChan:
-		t.Fatalf("Error reading message: %v", err)
-	case <-time.After(1 * time.Second):
-		t.Fatal("Timeout waiting for history message")
-	}
-
-	// Send a new message
-	newMessage := map[string]interface{}{
-		"content": "Hello from test",
-		"type":    string(domain.UserMessage),
-	}
-	messageBytes, _ := json.Marshal(newMessage)
-	err = c.WriteMessage(websocket.TextMessage, messageBytes)
-	assert.NoError(t, err)
-
-	// We should receive the same message back (broadcast)
-	select {
-	case msg := <-receivedMsg:
-		var receivedMessage map[string]interface{}
-		err := json.Unmarshal(msg, &receivedMessage)
-		assert.NoError(t, err)
-		assert.Equal(t, "Hello from test", receivedMessage["content"])
-		assert.Equal(t, "user123", receivedMessage["user_id"])
-		assert.Equal(t, "customer123", receivedMessage["customer_id"])
-	case err := <-errorChan:
-		t.Fatalf("Error reading message: %v", err)
-	case <-time.After(1 * time.Second):
-		t.Fatal("Timeout waiting for echo message")
-	}
+	defer client.Close()
+
+	// First frame should be the history
+	frame, err := client.Next(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, chatws.TypeHistory, frame.Type)
+	var page chatws.History
+	require.NoError(t, frame.Decode(&page))
+	assert.Len(t, page.Messages, 1)
+	assert.Equal(t, "Previous message", page.Messages[0].Content)
+
+	// Send a new message; it is acknowledged once stored
+	id, err := client.Send("Hello from test")
+	require.NoError(t, err)
+	ack, err := client.AwaitAck(ctx, id)
+	require.NoError(t, err)
+	assert.NotEmpty(t, ack.MessageID)
+
+	// A second connection of the same customer sees the message
+	other, err := chatws.Dial(ctx, wsURL, nil)
+	require.NoError(t, err)
+	defer other.Close()
+	_, err = other.Expect(ctx, chatws.TypeHistory)
+	require.NoError(t, err)
+
+	id, err = client.Send("Hello again")
+	require.NoError(t, err)
+	_, err = client.AwaitAck(ctx, id)
+	require.NoError(t, err)
+
+	frame, err = other.Expect(ctx, chatws.TypeMessage)
+	require.NoError(t, err)
+	var received domain.Message
+	require.NoError(t, frame.Decode(&received))
+	assert.Equal(t, "Hello again", received.Content)
+	assert.Equal(t, "user123", received.UserID)
+	assert.Equal(t, "customer123", received.CustomerID)
 
 	// Verify our mock expectations
+	assert.Eventually(t, func() bool {
+		return len(mockBotService.Calls) == 2
+	}, time.Second, 10*time.Millisecond)
 	mockService.AssertExpectations(t)
 }
@@ -6,6 +6,7 @@ import (
 	"crm-service/internal/adapters/secondary/messaging"
 	"crm-service/internal/adapters/secondary/repository"
 	"crm-service/internal/config"
+	"crm-service/internal/core/domain"
 	"crm-service/internal/core/ports"
 	"crm-service/internal/core/services"
 	"fmt"
@@ -57,6 +58,25 @@ func main() {
 	customerService := services.NewCustomerService(customerRepo)
 	ticketService := services.NewTicketService(ticketRepo, customerRepo, agentRepo, messagePublisher)
 	agentService := services.NewAgentService(agentRepo, ticketRepo)
+	escalationService := services.NewEscalationService(ticketService, messagePublisher)
+
+	// Open tickets for conversations escalated by chat-service
+	if rabbitMQClient != nil {
+		err := rabbitMQClient.SubscribeToEscalations(func(escalation *domain.Escalation) error {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			ticket, err := escalationService.HandleEscalation(ctx, escalation)
+			if err != nil {
+				return err
+			}
+			log.Printf("Opened ticket %s for escalated conversation %s", ticket.ID, escalation.ConversationID)
+			return nil
+		})
+		if err != nil {
+			log.Printf("Warning: Failed to subscribe to chat escalations: %v", err)
+		}
+	}
 
 	// Create HTTP handlers
 	handlers := httphandlers.NewHandlers(customerService, ticketService, agentService)
@@ -37,4 +37,10 @@ func (p *NoOpMessagePublisher) PublishCustomerEvent(customer *domain.Customer, e
 func (p *NoOpMessagePublisher) PublishAgentEvent(agent *domain.Agent, eventType string) error {
     log.Printf("[NOOP] Would publish agent event: %s for agent %s", eventType, agent.ID)
     return nil
+}
+
+// PublishTicketEscalated logs but doesn't publish events
+func (p *NoOpMessagePublisher) PublishTicketEscalated(ticket *domain.Ticket, escalation *domain.Escalation) error {
+    log.Printf("[NOOP] Would publish escalation of conversation %s to ticket %s", escalation.ConversationID, ticket.ID)
+    return nil
 }
\ No newline at end of file
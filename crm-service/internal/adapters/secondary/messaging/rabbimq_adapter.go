@@ -197,3 +197,51 @@ func (a *RabbitMQAdapter) PublishAgentEvent(agent *domain.Agent, eventType strin
 	log.Printf("Published agent event: %s for agent %s", eventType, agent.ID)
 	return nil
 }
+
+// PublishTicketEscalated publishes the ticket opened for an escalated chat
+// conversation so chat-service can tell the customer about it
+func (a *RabbitMQAdapter) PublishTicketEscalated(ticket *domain.Ticket, escalation *domain.Escalation) error {
+	if ticket == nil || escalation == nil {
+		return fmt.Errorf("ticket and escalation cannot be nil")
+	}
+
+	// Create escalation data
+	escalationData, err := json.Marshal(struct {
+		EscalationID   string `json:"escalation_id"`
+		ConversationID string `json:"conversation_id"`
+		CustomerID     string `json:"customer_id"`
+		TicketID       string `json:"ticket_id"`
+	}{
+		EscalationID:   escalation.ID,
+		ConversationID: escalation.ConversationID,
+		CustomerID:     escalation.CustomerID,
+		TicketID:       ticket.ID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal escalation: %w", err)
+	}
+
+	// Create event message
+	event := EventMessage{
+		EventType:    "escalated",
+		ResourceID:   ticket.ID,
+		ResourceType: "ticket",
+		Timestamp:    time.Now(),
+		Data:         escalationData,
+	}
+
+	// Serialize event
+	eventJSON, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	// Publish to RabbitMQ
+	err = a.client.PublishMessage("tickets.escalated", eventJSON)
+	if err != nil {
+		return fmt.Errorf("failed to publish ticket escalation: %w", err)
+	}
+
+	log.Printf("Published escalation of conversation %s to ticket %s", escalation.ConversationID, ticket.ID)
+	return nil
+}
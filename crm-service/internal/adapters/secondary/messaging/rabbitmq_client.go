@@ -1,6 +1,8 @@
 package messaging
 
 import (
+	"crm-service/internal/core/domain"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
@@ -14,6 +16,9 @@ type RabbitMQClient struct {
 	conn         *amqp.Connection
 	channel      *amqp.Channel
 	exchangeName string
+
+	// Consumers to restart after a reconnection
+	subscriptions []func() error
 }
 
 // NewRabbitMQClient creates a new RabbitMQ client with connection retry
@@ -108,6 +113,13 @@ func (c *RabbitMQClient) monitorConnection(amqpURL string) {
 					connErrChan = make(chan *amqp.Error)
 					c.conn.NotifyClose(connErrChan)
 
+					// Restart consumers on the new channel
+					for _, subscribe := range c.subscriptions {
+						if err := subscribe(); err != nil {
+							log.Printf("Failed to restart RabbitMQ consumer: %v", err)
+						}
+					}
+
 					log.Println("Successfully reconnected to RabbitMQ")
 					break
 				}
@@ -149,6 +161,198 @@ func (c *RabbitMQClient) PublishMessage(routingKey string, message []byte) error
 	)
 }
 
+// Escalations the handler fails on wait in the retry queue and come back to
+// the escalation queue; after maxEscalationAttempts they are parked in the
+// dead letter queue for someone to look at
+const (
+	escalationQueue       = "crm_service_escalations"
+	escalationRetryQueue  = "crm_service_escalations_retry"
+	escalationDeadQueue   = "crm_service_escalations_dead"
+	escalationRetryDelay  = 30 * time.Second
+	maxEscalationAttempts = 10
+	attemptsHeader        = "x-attempts"
+)
+
+// SubscribeToEscalations consumes the conversations chat-service escalates.
+// chat-service never sends an escalation twice, so failures are retried
+// later; only escalations that can't be decoded or are invalid are dropped.
+func (c *RabbitMQClient) SubscribeToEscalations(handler func(*domain.Escalation) error) error {
+	subscribe := func() error {
+		return c.consumeEscalations(handler)
+	}
+	if err := subscribe(); err != nil {
+		return err
+	}
+	c.subscriptions = append(c.subscriptions, subscribe)
+	return nil
+}
+
+func (c *RabbitMQClient) consumeEscalations(handler func(*domain.Escalation) error) error {
+	channel := c.channel
+	if channel == nil {
+		return errors.New("channel not initialized")
+	}
+
+	// Escalations are published on chat-service's exchange
+	err := channel.ExchangeDeclare(
+		"chat_events", // name
+		"topic",       // type
+		true,          // durable
+		false,         // auto-deleted
+		false,         // internal
+		false,         // no-wait
+		nil,           // arguments
+	)
+	if err != nil {
+		return fmt.Errorf("failed to declare chat exchange: %w", err)
+	}
+
+	queue, err := channel.QueueDeclare(
+		escalationQueue, // name
+		true,            // durable
+		false,           // delete when unused
+		false,           // exclusive
+		false,           // no-wait
+		nil,             // arguments
+	)
+	if err != nil {
+		return fmt.Errorf("failed to declare escalation queue: %w", err)
+	}
+
+	// Messages expire from the retry queue back into the escalation queue
+	_, err = channel.QueueDeclare(
+		escalationRetryQueue, // name
+		true,                 // durable
+		false,                // delete when unused
+		false,                // exclusive
+		false,                // no-wait
+		amqp.Table{ // arguments
+			"x-message-ttl":             escalationRetryDelay.Milliseconds(),
+			"x-dead-letter-exchange":    "",
+			"x-dead-letter-routing-key": escalationQueue,
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to declare escalation retry queue: %w", err)
+	}
+
+	_, err = channel.QueueDeclare(
+		escalationDeadQueue, // name
+		true,                // durable
+		false,               // delete when unused
+		false,               // exclusive
+		false,               // no-wait
+		nil,                 // arguments
+	)
+	if err != nil {
+		return fmt.Errorf("failed to declare escalation dead letter queue: %w", err)
+	}
+
+	err = channel.QueueBind(
+		queue.Name,         // queue name
+		"chat.escalations", // routing key
+		"chat_events",      // exchange
+		false,              // no-wait
+		nil,                // arguments
+	)
+	if err != nil {
+		return fmt.Errorf("failed to bind escalation queue: %w", err)
+	}
+
+	deliveries, err := channel.Consume(
+		queue.Name, // queue
+		"",         // consumer
+		false,      // auto-ack
+		false,      // exclusive
+		false,      // no-local
+		false,      // no-wait
+		nil,        // args
+	)
+	if err != nil {
+		return fmt.Errorf("failed to consume escalations: %w", err)
+	}
+
+	go func() {
+		for d := range deliveries {
+			handleEscalation(channel, d, handler)
+		}
+	}()
+
+	return nil
+}
+
+// queuePublisher is the part of a channel failed escalations are moved with
+type queuePublisher interface {
+	Publish(exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error
+}
+
+// handleEscalation hands a delivered escalation to the handler. Escalations
+// that can't be decoded or are invalid are dropped; other failures are
+// retried later.
+func handleEscalation(channel queuePublisher, d amqp.Delivery, handler func(*domain.Escalation) error) {
+	var escalation domain.Escalation
+	if err := json.Unmarshal(d.Body, &escalation); err != nil {
+		log.Printf("Error unmarshaling escalation: %v", err)
+		d.Nack(false, false)
+		return
+	}
+
+	if err := handler(&escalation); err != nil {
+		log.Printf("Error handling escalation of conversation %s: %v", escalation.ConversationID, err)
+		if errors.Is(err, domain.ErrInvalidInput) {
+			d.Nack(false, false)
+			return
+		}
+		retryEscalation(channel, d)
+		return
+	}
+	d.Ack(false)
+}
+
+// retryEscalation moves a failed escalation to the retry queue, or to the
+// dead letter queue once it has been tried too often. If neither takes it,
+// it goes back to the escalation queue.
+func retryEscalation(channel queuePublisher, d amqp.Delivery) {
+	attempts := deliveryAttempts(d) + 1
+	target := escalationRetryQueue
+	if attempts >= maxEscalationAttempts {
+		log.Printf("Giving up on escalation after %d attempts, moving it to %s", attempts, escalationDeadQueue)
+		target = escalationDeadQueue
+	}
+
+	err := channel.Publish(
+		"",     // exchange
+		target, // routing key
+		false,  // mandatory
+		false,  // immediate
+		amqp.Publishing{
+			DeliveryMode: amqp.Persistent,
+			Timestamp:    time.Now(),
+			ContentType:  d.ContentType,
+			Headers:      amqp.Table{attemptsHeader: int32(attempts)},
+			Body:         d.Body,
+		},
+	)
+	if err != nil {
+		log.Printf("Error moving escalation to %s: %v", target, err)
+		d.Nack(false, true)
+		return
+	}
+	d.Ack(false)
+}
+
+// deliveryAttempts is how often the delivery has been handled before
+func deliveryAttempts(d amqp.Delivery) int {
+	switch attempts := d.Headers[attemptsHeader].(type) {
+	case int32:
+		return int(attempts)
+	case int64:
+		return int(attempts)
+	default:
+		return 0
+	}
+}
+
 // Close closes the connection
 func (c *RabbitMQClient) Close() error {
 	if c.channel != nil {
@@ -0,0 +1,108 @@
+package messaging
+
+import (
+	"crm-service/internal/core/domain"
+	"errors"
+	"fmt"
+	"testing"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// recordingAcknowledger records how a delivery was settled
+type recordingAcknowledger struct {
+	acked   bool
+	nacked  bool
+	requeue bool
+}
+
+func (a *recordingAcknowledger) Ack(tag uint64, multiple bool) error {
+	a.acked = true
+	return nil
+}
+
+func (a *recordingAcknowledger) Nack(tag uint64, multiple, requeue bool) error {
+	a.nacked = true
+	a.requeue = requeue
+	return nil
+}
+
+func (a *recordingAcknowledger) Reject(tag uint64, requeue bool) error {
+	return a.Nack(tag, false, requeue)
+}
+
+// recordingQueues records the messages moved to other queues
+type recordingQueues struct {
+	published map[string][]amqp.Publishing
+	err       error
+}
+
+func (q *recordingQueues) Publish(exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error {
+	if q.err != nil {
+		return q.err
+	}
+	q.published[key] = append(q.published[key], msg)
+	return nil
+}
+
+func TestHandleEscalation(t *testing.T) {
+	body := []byte(`{"id":"escalation-1","conversation_id":"conversation-1","customer_id":"customer-1"}`)
+	deliver := func(body []byte, attempts int32, handlerErr, publishErr error) (*recordingAcknowledger, *recordingQueues) {
+		acknowledger := &recordingAcknowledger{}
+		queues := &recordingQueues{published: make(map[string][]amqp.Publishing), err: publishErr}
+		delivery := amqp.Delivery{Acknowledger: acknowledger, Body: body}
+		if attempts > 0 {
+			delivery.Headers = amqp.Table{attemptsHeader: attempts}
+		}
+		handleEscalation(queues, delivery, func(escalation *domain.Escalation) error {
+			return handlerErr
+		})
+		return acknowledger, queues
+	}
+
+	t.Run("handled escalations are acknowledged", func(t *testing.T) {
+		acknowledger, queues := deliver(body, 0, nil, nil)
+		if !acknowledger.acked || len(queues.published) != 0 {
+			t.Errorf("acked = %v, moved to %v", acknowledger.acked, queues.published)
+		}
+	})
+
+	t.Run("invalid escalations are dropped", func(t *testing.T) {
+		undecodable, _ := deliver([]byte("{"), 0, nil, nil)
+		invalid, _ := deliver(body, 0, fmt.Errorf("missing customer: %w", domain.ErrInvalidInput), nil)
+		for _, acknowledger := range []*recordingAcknowledger{undecodable, invalid} {
+			if !acknowledger.nacked || acknowledger.requeue {
+				t.Errorf("nacked = %v, requeue = %v, want dropped", acknowledger.nacked, acknowledger.requeue)
+			}
+		}
+	})
+
+	t.Run("failures are retried later", func(t *testing.T) {
+		acknowledger, queues := deliver(body, 0, errors.New("connection refused"), nil)
+		retried := queues.published[escalationRetryQueue]
+		if len(retried) != 1 || !acknowledger.acked {
+			t.Fatalf("moved to %v, acked = %v, want the retry queue", queues.published, acknowledger.acked)
+		}
+		if attempts := retried[0].Headers[attemptsHeader]; attempts != int32(1) {
+			t.Errorf("attempts = %v, want 1", attempts)
+		}
+		if string(retried[0].Body) != string(body) {
+			t.Errorf("retried body = %s, want %s", retried[0].Body, body)
+		}
+	})
+
+	t.Run("escalations failing too often are given up on", func(t *testing.T) {
+		_, queues := deliver(body, maxEscalationAttempts-1, errors.New("connection refused"), nil)
+		if len(queues.published[escalationDeadQueue]) != 1 {
+			t.Errorf("moved to %v, want the dead letter queue", queues.published)
+		}
+	})
+
+	t.Run("escalations that can't be moved go back to the queue", func(t *testing.T) {
+		acknowledger, _ := deliver(body, 0, errors.New("connection refused"), errors.New("channel closed"))
+		if acknowledger.acked || !acknowledger.nacked || !acknowledger.requeue {
+			t.Errorf("acked = %v, nacked = %v, requeue = %v, want requeued",
+				acknowledger.acked, acknowledger.nacked, acknowledger.requeue)
+		}
+	})
+}
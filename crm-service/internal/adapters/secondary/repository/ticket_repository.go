@@ -151,8 +151,7 @@ func (r *TicketRepository) GetTicketsByAgent(ctx context.Context, agentID string
 	return tickets, nil
 }
 
-func (r *TicketRepository) CreateTicket(ctx context.Context, ticket *domain.Ticket) error {
-	query := `
+const insertTicketQuery = `
         INSERT INTO tickets (
             id, customer_id, agent_id, subject, description, 
             status, priority, created_at, updated_at, closed_at, tags
@@ -161,9 +160,15 @@ func (r *TicketRepository) CreateTicket(ctx context.Context, ticket *domain.Tick
         )
     `
 
+const insertTicketEventQuery = `
+        INSERT INTO ticket_events (id, ticket_id, user_id, event_type, content, timestamp)
+        VALUES ($1, $2, $3, $4, $5, $6)
+    `
+
+func (r *TicketRepository) CreateTicket(ctx context.Context, ticket *domain.Ticket) error {
 	_, err := r.db.ExecContext(
 		ctx,
-		query,
+		insertTicketQuery,
 		ticket.ID, ticket.CustomerID, ticket.AgentID, ticket.Subject, ticket.Description,
 		ticket.Status, ticket.Priority, ticket.CreatedAt, ticket.UpdatedAt, ticket.ClosedAt,
 		pq.Array(ticket.Tags),
@@ -172,6 +177,40 @@ func (r *TicketRepository) CreateTicket(ctx context.Context, ticket *domain.Tick
 	return err
 }
 
+// CreateTicketWithEvents inserts a ticket and its first events in one
+// transaction, so a ticket never exists without them
+func (r *TicketRepository) CreateTicketWithEvents(ctx context.Context, ticket *domain.Ticket, events []domain.TicketEvent) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(
+		ctx,
+		insertTicketQuery,
+		ticket.ID, ticket.CustomerID, ticket.AgentID, ticket.Subject, ticket.Description,
+		ticket.Status, ticket.Priority, ticket.CreatedAt, ticket.UpdatedAt, ticket.ClosedAt,
+		pq.Array(ticket.Tags),
+	)
+	if err != nil {
+		return err
+	}
+
+	for _, event := range events {
+		_, err := tx.ExecContext(
+			ctx,
+			insertTicketEventQuery,
+			event.ID, event.TicketID, event.UserID, event.EventType, event.Content, event.Timestamp,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
 func (r *TicketRepository) UpdateTicket(ctx context.Context, ticket *domain.Ticket) error {
 	query := `
         UPDATE tickets 
@@ -230,14 +269,9 @@ func (r *TicketRepository) DeleteTicket(ctx context.Context, id string) error {
 }
 
 func (r *TicketRepository) AddTicketEvent(ctx context.Context, event *domain.TicketEvent) error {
-	query := `
-        INSERT INTO ticket_events (id, ticket_id, user_id, event_type, content, timestamp)
-        VALUES ($1, $2, $3, $4, $5, $6)
-    `
-
 	_, err := r.db.ExecContext(
 		ctx,
-		query,
+		insertTicketEventQuery,
 		event.ID, event.TicketID, event.UserID, event.EventType, event.Content, event.Timestamp,
 	)
 
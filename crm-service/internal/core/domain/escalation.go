@@ -0,0 +1,35 @@
+package domain
+
+import "time"
+
+// Escalation is a chat conversation the bot handed over to a human. It is
+// published by chat-service and turned into a ticket.
+type Escalation struct {
+	ID             string              `json:"id"`
+	ConversationID string              `json:"conversation_id"`
+	CustomerID     string              `json:"customer_id"`
+	Reason         string              `json:"reason"` // customer_request, repeated_fallback, flow
+	FlowID         string              `json:"flow_id,omitempty"`
+	Transcript     []TranscriptMessage `json:"transcript"`
+	RequestedAt    time.Time           `json:"requested_at"`
+}
+
+// TranscriptMessage is a single chat message in an escalation transcript
+type TranscriptMessage struct {
+	ID          string                 `json:"id"`
+	Content     string                 `json:"content"`
+	UserID      string                 `json:"user_id"`
+	Type        string                 `json:"type"` // user, bot, agent, system
+	Timestamp   time.Time              `json:"timestamp"`
+	Attachments []TranscriptAttachment `json:"attachments,omitempty"`
+}
+
+// TranscriptAttachment is a file the customer sent with a message. The URL
+// is a signed link to chat-service that expires.
+type TranscriptAttachment struct {
+	ID          string `json:"id"`
+	FileName    string `json:"file_name"`
+	ContentType string `json:"content_type"`
+	Size        int64  `json:"size"`
+	URL         string `json:"url"`
+}
@@ -23,6 +23,8 @@ type TicketRepository interface {
 	GetTicketsByCustomer(ctx context.Context, customerID string) ([]domain.Ticket, error)
 	GetTicketsByAgent(ctx context.Context, agentID string) ([]domain.Ticket, error)
 	CreateTicket(ctx context.Context, ticket *domain.Ticket) error
+	// CreateTicketWithEvents creates a ticket and its first events atomically
+	CreateTicketWithEvents(ctx context.Context, ticket *domain.Ticket, events []domain.TicketEvent) error
 	UpdateTicket(ctx context.Context, ticket *domain.Ticket) error
 	DeleteTicket(ctx context.Context, id string) error
 	AddTicketEvent(ctx context.Context, event *domain.TicketEvent) error
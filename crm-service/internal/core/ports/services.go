@@ -22,6 +22,9 @@ type TicketService interface {
 	GetTickets(ctx context.Context, limit, offset int) ([]domain.Ticket, error)
 	GetTicketByID(ctx context.Context, id string) (*domain.Ticket, error)
 	CreateTicket(ctx context.Context, ticket *domain.Ticket) error
+	// CreateTicketWithEvents creates a ticket whose history starts with the
+	// events, all or nothing
+	CreateTicketWithEvents(ctx context.Context, ticket *domain.Ticket, events ...domain.TicketEvent) error
 	UpdateTicket(ctx context.Context, ticket *domain.Ticket) error
 	AssignTicketToAgent(ctx context.Context, ticketID, agentID string) error
 	AddTicketComment(ctx context.Context, ticketID, userID, content string) error
@@ -41,6 +44,12 @@ type AgentService interface {
 	FindBestAgentForTicket(ctx context.Context, ticket *domain.Ticket) (*domain.Agent, error)
 }
 
+// EscalationService defines business operations for chat escalations
+type EscalationService interface {
+	// HandleEscalation opens a ticket for an escalated chat conversation
+	HandleEscalation(ctx context.Context, escalation *domain.Escalation) (*domain.Ticket, error)
+}
+
 // MessagePublisher defines operations for publishing events to a message broker
 type MessagePublisher interface {
 	// PublishTicketEvent publishes an event when a ticket is created, updated, or its status changes
@@ -54,4 +63,7 @@ type MessagePublisher interface {
 
 	// PublishAgentEvent publishes an event when an agent is created, updated or status changes
 	PublishAgentEvent(agent *domain.Agent, eventType string) error
+
+	// PublishTicketEscalated tells chat-service which ticket was opened for an escalation
+	PublishTicketEscalated(ticket *domain.Ticket, escalation *domain.Escalation) error
 }
\ No newline at end of file
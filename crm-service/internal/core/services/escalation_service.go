@@ -0,0 +1,180 @@
+package services
+
+import (
+	"context"
+	"crm-service/internal/core/domain"
+	"crm-service/internal/core/ports"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// escalationNamespace derives ticket IDs from escalation IDs so a redelivered
+// escalation finds the ticket it already created
+var escalationNamespace = uuid.MustParse("5b0d7a0e-3c1f-4d39-9a8e-6f1c2b7d4e21")
+
+// escalationSubjects describe each escalation reason in the ticket subject
+var escalationSubjects = map[string]string{
+	"customer_request":  "Customer asked for a human",
+	"repeated_fallback": "Bot could not answer the customer",
+	"flow":              "Handed over from a guided conversation",
+}
+
+// EscalationServiceImpl implements the EscalationService interface
+type EscalationServiceImpl struct {
+	ticketService ports.TicketService
+	publisher     ports.MessagePublisher
+}
+
+// NewEscalationService creates a new escalation service
+func NewEscalationService(
+	ticketService ports.TicketService,
+	publisher ports.MessagePublisher,
+) ports.EscalationService {
+	return &EscalationServiceImpl{
+		ticketService: ticketService,
+		publisher:     publisher,
+	}
+}
+
+// HandleEscalation creates a ticket for the conversation with the transcript
+// as its first event, then reports the ticket back to chat-service. The
+// ticket and its events are created together, so a retried escalation either
+// finds the complete ticket or creates it.
+func (s *EscalationServiceImpl) HandleEscalation(ctx context.Context, escalation *domain.Escalation) (*domain.Ticket, error) {
+	if escalation.ID == "" || escalation.ConversationID == "" || escalation.CustomerID == "" {
+		return nil, domain.ErrInvalidInput
+	}
+
+	ticketID := uuid.NewSHA1(escalationNamespace, []byte(escalation.ID)).String()
+
+	// The escalation may be redelivered; don't open a second ticket
+	ticket, err := s.ticketService.GetTicketByID(ctx, ticketID)
+	if err != nil {
+		return nil, err
+	}
+
+	if ticket == nil {
+		ticket = &domain.Ticket{
+			ID:          ticketID,
+			CustomerID:  escalation.CustomerID,
+			Subject:     escalationSubject(escalation.Reason),
+			Description: escalationDescription(escalation),
+			Tags:        []string{"chat", "escalation", escalation.Reason},
+		}
+
+		// The transcript predates the ticket, so it sorts before the created
+		// event, which is stamped once the ticket is created
+		transcript := domain.TicketEvent{
+			ID:        uuid.New().String(),
+			TicketID:  ticket.ID,
+			UserID:    "chat-service",
+			EventType: "transcript",
+			Content:   formatTranscript(escalation.Transcript),
+			Timestamp: transcriptTime(escalation, time.Now()),
+		}
+		events := []domain.TicketEvent{transcript}
+
+		// Each file the customer sent gets its own event with its link
+		for _, attachment := range transcriptAttachments(escalation.Transcript) {
+			events = append(events, domain.TicketEvent{
+				ID:        uuid.New().String(),
+				TicketID:  ticket.ID,
+				UserID:    "chat-service",
+				EventType: "attachment",
+				Content: fmt.Sprintf("%s (%s, %d bytes): %s",
+					attachment.FileName, attachment.ContentType, attachment.Size, attachment.URL),
+				Timestamp: transcript.Timestamp,
+			})
+		}
+
+		if err := s.ticketService.CreateTicketWithEvents(ctx, ticket, events...); err != nil {
+			return nil, err
+		}
+	}
+
+	// Publish event for the escalation
+	if s.publisher != nil {
+		s.publisher.PublishTicketEscalated(ticket, escalation)
+	}
+
+	return ticket, nil
+}
+
+// escalationSubject returns the ticket subject for an escalation reason
+func escalationSubject(reason string) string {
+	if subject, ok := escalationSubjects[reason]; ok {
+		return "Chat escalation: " + subject
+	}
+	return "Chat escalation"
+}
+
+// escalationDescription summarises the conversation for the ticket
+func escalationDescription(escalation *domain.Escalation) string {
+	description := fmt.Sprintf("Chat conversation %s was escalated (%s).", escalation.ConversationID, escalation.Reason)
+	if escalation.FlowID != "" {
+		description += fmt.Sprintf(" Flow: %s.", escalation.FlowID)
+	}
+
+	for i := len(escalation.Transcript) - 1; i >= 0; i-- {
+		if escalation.Transcript[i].Type == "user" {
+			description += fmt.Sprintf(" Last customer message: %q", escalation.Transcript[i].Content)
+			break
+		}
+	}
+	return description
+}
+
+// formatTranscript renders the chat transcript one message per line
+func formatTranscript(transcript []domain.TranscriptMessage) string {
+	if len(transcript) == 0 {
+		return "No messages"
+	}
+
+	var builder strings.Builder
+	for _, message := range transcript {
+		speaker := "Customer"
+		switch message.Type {
+		case "bot":
+			speaker = "Bot"
+		case "agent":
+			speaker = "Agent"
+		case "system":
+			speaker = "System"
+		}
+		content := message.Content
+		for _, attachment := range message.Attachments {
+			content = strings.TrimSpace(content + " [attachment: " + attachment.FileName + "]")
+		}
+		fmt.Fprintf(&builder, "[%s] %s: %s\n",
+			message.Timestamp.UTC().Format("2006-01-02 15:04:05"), speaker, content)
+	}
+	return strings.TrimSuffix(builder.String(), "\n")
+}
+
+// transcriptAttachments lists the files sent in the conversation in order
+func transcriptAttachments(transcript []domain.TranscriptMessage) []domain.TranscriptAttachment {
+	var attachments []domain.TranscriptAttachment
+	for _, message := range transcript {
+		attachments = append(attachments, message.Attachments...)
+	}
+	return attachments
+}
+
+// transcriptTime is when the conversation started, kept before the ticket's
+// creation even when the two services' clocks disagree
+func transcriptTime(escalation *domain.Escalation, createdAt time.Time) time.Time {
+	start := escalation.RequestedAt
+	for _, message := range escalation.Transcript {
+		if !message.Timestamp.IsZero() && (start.IsZero() || message.Timestamp.Before(start)) {
+			start = message.Timestamp
+		}
+	}
+
+	if start.IsZero() || !start.Before(createdAt) {
+		start = createdAt.Add(-time.Millisecond)
+	}
+	return start
+}
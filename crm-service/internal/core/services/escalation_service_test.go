@@ -0,0 +1,227 @@
+package services
+
+import (
+	"context"
+	"crm-service/internal/core/domain"
+	"crm-service/internal/core/ports"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// memoryTickets keeps tickets and their events in memory. Methods the tests
+// don't need are left to the embedded nil interface.
+type memoryTickets struct {
+	ports.TicketRepository
+	tickets map[string]domain.Ticket
+	events  map[string][]domain.TicketEvent
+	// Returned by every call when set
+	err error
+}
+
+func newMemoryTickets() *memoryTickets {
+	return &memoryTickets{
+		tickets: make(map[string]domain.Ticket),
+		events:  make(map[string][]domain.TicketEvent),
+	}
+}
+
+func (r *memoryTickets) GetTicketByID(ctx context.Context, id string) (*domain.Ticket, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	ticket, ok := r.tickets[id]
+	if !ok {
+		return nil, nil
+	}
+	return &ticket, nil
+}
+
+func (r *memoryTickets) CreateTicketWithEvents(ctx context.Context, ticket *domain.Ticket, events []domain.TicketEvent) error {
+	if r.err != nil {
+		return r.err
+	}
+	r.tickets[ticket.ID] = *ticket
+	r.events[ticket.ID] = append([]domain.TicketEvent(nil), events...)
+	return nil
+}
+
+// memoryCustomers looks customers up in memory
+type memoryCustomers struct {
+	ports.CustomerRepository
+	customers map[string]domain.Customer
+}
+
+func (r *memoryCustomers) GetCustomerByID(ctx context.Context, id string) (*domain.Customer, error) {
+	customer, ok := r.customers[id]
+	if !ok {
+		return nil, nil
+	}
+	return &customer, nil
+}
+
+// recordingPublisher records the escalations reported back to chat-service
+type recordingPublisher struct {
+	ports.MessagePublisher
+	escalated []string
+}
+
+func (p *recordingPublisher) PublishTicketEvent(ticket *domain.Ticket, eventType string) error {
+	return nil
+}
+
+func (p *recordingPublisher) PublishTicketEscalated(ticket *domain.Ticket, escalation *domain.Escalation) error {
+	p.escalated = append(p.escalated, ticket.ID)
+	return nil
+}
+
+func newEscalationService() (ports.EscalationService, *memoryTickets, *recordingPublisher) {
+	tickets := newMemoryTickets()
+	customers := &memoryCustomers{customers: map[string]domain.Customer{"customer-1": {ID: "customer-1"}}}
+	publisher := &recordingPublisher{}
+	ticketService := NewTicketService(tickets, customers, nil, publisher)
+	return NewEscalationService(ticketService, publisher), tickets, publisher
+}
+
+func TestHandleEscalation(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	escalation := func(customerID string) *domain.Escalation {
+		return &domain.Escalation{
+			ID:             "escalation-1",
+			ConversationID: "conversation-1",
+			CustomerID:     customerID,
+			Reason:         "customer_request",
+			RequestedAt:    start.Add(time.Minute),
+			Transcript: []domain.TranscriptMessage{
+				{Type: "user", Content: "Where is my refund?", Timestamp: start},
+				{Type: "user", Timestamp: start.Add(30 * time.Second),
+					Attachments: []domain.TranscriptAttachment{{FileName: "receipt.pdf", ContentType: "application/pdf", Size: 42, URL: "https://files/receipt.pdf"}}},
+			},
+		}
+	}
+
+	t.Run("opens a ticket with the transcript", func(t *testing.T) {
+		service, tickets, publisher := newEscalationService()
+
+		ticket, err := service.HandleEscalation(ctx, escalation("customer-1"))
+		if err != nil {
+			t.Fatalf("HandleEscalation() error = %v", err)
+		}
+		if want := uuid.NewSHA1(escalationNamespace, []byte("escalation-1")).String(); ticket.ID != want {
+			t.Errorf("ticket ID = %s, want %s derived from the escalation", ticket.ID, want)
+		}
+		if ticket.Subject != "Chat escalation: Customer asked for a human" {
+			t.Errorf("ticket subject = %q", ticket.Subject)
+		}
+
+		// The created event, the transcript and one event per attachment,
+		// stored in one go
+		events := tickets.events[ticket.ID]
+		if len(events) != 3 {
+			t.Fatalf("got %d events, want 3", len(events))
+		}
+		for i, eventType := range []string{"created", "transcript", "attachment"} {
+			if events[i].EventType != eventType {
+				t.Errorf("event %d is %q, want %q", i, events[i].EventType, eventType)
+			}
+		}
+		if !events[1].Timestamp.Equal(start) {
+			t.Errorf("transcript stamped %v, want the conversation's start %v", events[1].Timestamp, start)
+		}
+		if want := "receipt.pdf (application/pdf, 42 bytes): https://files/receipt.pdf"; events[2].Content != want {
+			t.Errorf("attachment event = %q, want %q", events[2].Content, want)
+		}
+		if len(publisher.escalated) != 1 || publisher.escalated[0] != ticket.ID {
+			t.Errorf("reported %v to chat-service, want [%s]", publisher.escalated, ticket.ID)
+		}
+	})
+
+	t.Run("a redelivered escalation finds its ticket", func(t *testing.T) {
+		service, tickets, publisher := newEscalationService()
+
+		first, err := service.HandleEscalation(ctx, escalation("customer-1"))
+		if err != nil {
+			t.Fatalf("HandleEscalation() error = %v", err)
+		}
+		second, err := service.HandleEscalation(ctx, escalation("customer-1"))
+		if err != nil {
+			t.Fatalf("HandleEscalation() redelivered error = %v", err)
+		}
+		if second.ID != first.ID || len(tickets.tickets) != 1 {
+			t.Errorf("redelivery opened ticket %s besides %s (%d tickets)", second.ID, first.ID, len(tickets.tickets))
+		}
+		// chat-service hears about the ticket again in case the first
+		// report was lost
+		if len(publisher.escalated) != 2 {
+			t.Errorf("reported %d times, want 2", len(publisher.escalated))
+		}
+	})
+
+	t.Run("unknown customers get no ticket", func(t *testing.T) {
+		service, tickets, publisher := newEscalationService()
+
+		_, err := service.HandleEscalation(ctx, escalation("customer-2"))
+		if err == nil {
+			t.Fatal("HandleEscalation() for an unknown customer succeeded")
+		}
+		// The customer may not have been synced yet, so it is retried
+		if errors.Is(err, domain.ErrInvalidInput) {
+			t.Errorf("unknown customer error %v would drop the escalation", err)
+		}
+		if len(tickets.tickets) != 0 || len(publisher.escalated) != 0 {
+			t.Errorf("got %d tickets and %d reports, want none", len(tickets.tickets), len(publisher.escalated))
+		}
+	})
+
+	t.Run("invalid escalations are not retried", func(t *testing.T) {
+		service, _, _ := newEscalationService()
+
+		for _, invalid := range []*domain.Escalation{
+			{ConversationID: "conversation-1", CustomerID: "customer-1"},
+			{ID: "escalation-1", CustomerID: "customer-1"},
+			{ID: "escalation-1", ConversationID: "conversation-1"},
+		} {
+			if _, err := service.HandleEscalation(ctx, invalid); !errors.Is(err, domain.ErrInvalidInput) {
+				t.Errorf("HandleEscalation(%+v) error = %v, want %v", invalid, err, domain.ErrInvalidInput)
+			}
+		}
+	})
+
+	t.Run("storage failures are retried", func(t *testing.T) {
+		service, tickets, _ := newEscalationService()
+		tickets.err = errors.New("connection refused")
+
+		_, err := service.HandleEscalation(ctx, escalation("customer-1"))
+		if err == nil || errors.Is(err, domain.ErrInvalidInput) {
+			t.Errorf("HandleEscalation() error = %v, want a retryable error", err)
+		}
+	})
+}
+
+func TestFormatTranscript(t *testing.T) {
+	start := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	transcript := []domain.TranscriptMessage{
+		{Type: "user", Content: "My card was charged twice", Timestamp: start},
+		{Type: "bot", Content: "Let me check that for you", Timestamp: start.Add(time.Minute)},
+		{Type: "system", Content: "An agent joined the conversation", Timestamp: start.Add(2 * time.Minute)},
+		{Type: "agent", Content: "Refunded, sorry about that", Timestamp: start.Add(3 * time.Minute)},
+		{Type: "user", Timestamp: start.Add(4 * time.Minute),
+			Attachments: []domain.TranscriptAttachment{{FileName: "receipt.pdf"}}},
+	}
+
+	want := "[2024-03-01 09:00:00] Customer: My card was charged twice\n" +
+		"[2024-03-01 09:01:00] Bot: Let me check that for you\n" +
+		"[2024-03-01 09:02:00] System: An agent joined the conversation\n" +
+		"[2024-03-01 09:03:00] Agent: Refunded, sorry about that\n" +
+		"[2024-03-01 09:04:00] Customer: [attachment: receipt.pdf]"
+	if got := formatTranscript(transcript); got != want {
+		t.Errorf("formatTranscript() =\n%s\nwant\n%s", got, want)
+	}
+
+	if got := formatTranscript(nil); got != "No messages" {
+		t.Errorf("formatTranscript(nil) = %q, want %q", got, "No messages")
+	}
+}
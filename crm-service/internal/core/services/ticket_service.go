@@ -45,6 +45,12 @@ func (s *TicketServiceImpl) GetTicketByID(ctx context.Context, id string) (*doma
 
 // CreateTicket creates a new ticket
 func (s *TicketServiceImpl) CreateTicket(ctx context.Context, ticket *domain.Ticket) error {
+	return s.CreateTicketWithEvents(ctx, ticket)
+}
+
+// CreateTicketWithEvents creates a new ticket together with the events that
+// follow its created event, in one transaction
+func (s *TicketServiceImpl) CreateTicketWithEvents(ctx context.Context, ticket *domain.Ticket, events ...domain.TicketEvent) error {
 	// Verify the customer exists
 	customer, err := s.customerRepo.GetCustomerByID(ctx, ticket.CustomerID)
 	if err != nil {
@@ -72,13 +78,8 @@ func (s *TicketServiceImpl) CreateTicket(ctx context.Context, ticket *domain.Tic
 	ticket.CreatedAt = now
 	ticket.UpdatedAt = now
 
-	// Create the ticket
-	if err := s.ticketRepo.CreateTicket(ctx, ticket); err != nil {
-		return err
-	}
-
-	// Create a ticket created event
-	event := &domain.TicketEvent{
+	// Create the ticket with its created event
+	created := domain.TicketEvent{
 		ID:        uuid.New().String(),
 		TicketID:  ticket.ID,
 		UserID:    "system", // Replace with actual user if available
@@ -86,7 +87,7 @@ func (s *TicketServiceImpl) CreateTicket(ctx context.Context, ticket *domain.Tic
 		Content:   "Ticket created",
 		Timestamp: now,
 	}
-	if err := s.ticketRepo.AddTicketEvent(ctx, event); err != nil {
+	if err := s.ticketRepo.CreateTicketWithEvents(ctx, ticket, append([]domain.TicketEvent{created}, events...)); err != nil {
 		return err
 	}
 
@@ -103,6 +103,40 @@ export default function ChatPage() {
         return
       }
 
+      // Every frame is an envelope: { v, type, id, payload }
+      switch (parsedData?.type) {
+        case 'history':
+          // A page of the latest messages, oldest first
+          parsedData = parsedData.payload?.messages ?? []
+          break
+        case 'message':
+        case 'system':
+        case 'bot_complete':
+          parsedData = parsedData.payload
+          break
+        case 'ack': {
+          // Swap the optimistic ID for the one the server stored
+          const tempId = `temp-${parsedData.id}`
+          const storedId = parsedData.payload?.message_id
+          setMessages((prev) =>
+            prev.map((msg) =>
+              msg.id === tempId ? { ...msg, id: storedId || msg.id } : msg
+            )
+          )
+          return
+        }
+        case 'error': {
+          const tempId = `temp-${parsedData.id}`
+          setMessages((prev) => prev.filter((msg) => msg.id !== tempId))
+          setAppError(parsedData.payload?.message || 'Message was rejected.')
+          return
+        }
+        default:
+          // Streaming chunks, typing, presence and mode changes are not shown
+          return
+      }
+      if (!parsedData) return
+
       if (Array.isArray(parsedData)) {
         console.log(
           'WebSocket: Detected history array from backend.',
@@ -291,11 +325,17 @@ export default function ChatPage() {
       conversation_id: conversationId,
     }
 
-    sendWsMessage(messageData) // Use sendMessage from hook
+    const frameId = `user-${Date.now()}`
+    sendWsMessage({
+      v: 1,
+      type: 'message',
+      id: frameId,
+      payload: { content: messageData.content },
+    }) // Use sendMessage from hook
 
     const optimisticMessage: Message = {
       ...messageData,
-      id: `temp-user-${Date.now()}`,
+      id: `temp-${frameId}`,
       status: 'complete',
     }
     setMessages((prev) => [...prev, optimisticMessage])